@@ -13,6 +13,8 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipni/storetheindex/announce/message"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const DefaultAnnouncePath = "/ingest/announce"
@@ -153,6 +155,7 @@ func (s *Sender) sendAnnounce(ctx context.Context, announceURL string, buf *byte
 	} else {
 		req.Header.Set("Content-Type", "application/octet-stream")
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 
 // config contains all options for configuring Subscriber.
 type config struct {
+	allowAddr AllowAddrFunc
 	allowPeer AllowPeerFunc
 	filterIPs bool
 	resend    bool
@@ -37,6 +38,18 @@ func WithAllowPeer(allowPeer AllowPeerFunc) Option {
 	}
 }
 
+// WithAllowAddr sets the function that determines whether to allow or reject
+// the addresses supplied in announce messages. Addresses that are rejected are
+// removed from the announce message, and a message is ignored if all of its
+// addresses are rejected. A message without addresses is ignored if all of the
+// peer's known addresses are rejected.
+func WithAllowAddr(allowAddr AllowAddrFunc) Option {
+	return func(c *config) error {
+		c.allowAddr = allowAddr
+		return nil
+	}
+}
+
 // WithFilterIPs sets whether or not IP filtering is enabled. When enabled it
 // removes any private, loopback, or unspecified IP multiaddrs from addresses
 // supplied in announce messages.
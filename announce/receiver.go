@@ -16,11 +16,17 @@ import (
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var log = logging.Logger("announce")
 
+var tracer = otel.Tracer("github.com/ipni/storetheindex/announce")
+
 const announceCacheSize = 64
 
 // AllowPeerFunc is the signature of a function given to Subscriber that
@@ -29,6 +35,11 @@ const announceCacheSize = 64
 // from that peer are allowed rejected, respectively.
 type AllowPeerFunc func(peer.ID) bool
 
+// AllowAddrFunc is the signature of a function given to Subscriber that
+// determines whether to allow or reject a multiaddr supplied by an announce
+// message.
+type AllowAddrFunc func(multiaddr.Multiaddr) bool
+
 var (
 	// ErrClosed is returned from Next and Direct when the Received is closed.
 	ErrClosed = errors.New("closed")
@@ -39,6 +50,9 @@ var (
 	// errAlreadySeenCid is the error returned when an announce message is for a
 	// CID has already been announced by a previous announce message.
 	errAlreadySeenCid = errors.New("announcement for already seen CID")
+	// errAddrNotAllowed is the error returned when none of the addresses in an
+	// announce message are allowed.
+	errAddrNotAllowed = errors.New("announce addresses not allowed")
 )
 
 // Receiver receives announce messages via gossip pubsub and HTTP. Receiver
@@ -47,9 +61,13 @@ var (
 // is called.
 type Receiver struct {
 	allowPeer AllowPeerFunc
+	allowAddr AllowAddrFunc
 	filterIPs bool
 	resend    bool
 	hostID    peer.ID
+	// peerstore has the known addresses of peers that send announces
+	// without addresses.
+	peerstore peerstore.Peerstore
 
 	announceCache *stringLRU
 	// announceMutex protects announceCache, and allowPeer, topicSub
@@ -80,6 +98,9 @@ type Announce struct {
 	PeerID peer.ID
 	// Addrs is the network location(s) hosting the announced advertisement.
 	Addrs []multiaddr.Multiaddr
+	// SpanContext identifies the span in which the announce was received, so
+	// that handling of the announce can be traced back to it.
+	SpanContext trace.SpanContext
 }
 
 // NewReceiver creates a new Receiver that subscribes to the named pubsub topic
@@ -123,6 +144,7 @@ func NewReceiver(p2pHost host.Host, topicName string, options ...Option) (*Recei
 
 	r := &Receiver{
 		allowPeer: opts.allowPeer,
+		allowAddr: opts.allowAddr,
 		filterIPs: opts.filterIPs,
 		resend:    opts.resend,
 
@@ -140,6 +162,7 @@ func NewReceiver(p2pHost host.Host, topicName string, options ...Option) (*Recei
 
 	if p2pHost != nil {
 		r.hostID = p2pHost.ID()
+		r.peerstore = p2pHost.Peerstore()
 		watchCtx, cancelWatch := context.WithCancel(context.Background())
 		r.cancelWatch = cancelWatch
 		r.watchDone = make(chan struct{})
@@ -320,13 +343,49 @@ func (r *Receiver) Direct(ctx context.Context, nextCid cid.Cid, peerID peer.ID,
 	return r.handleAnnounce(ctx, amsg, true)
 }
 
+// addrsAllowed removes the announce addresses that are not allowed, and
+// returns false if none are allowed. An announce without addresses is only
+// allowed if the peer has no known addresses, or has a known address that is
+// allowed, since those are the addresses used to sync with the peer.
+func (r *Receiver) addrsAllowed(amsg *Announce) bool {
+	if len(amsg.Addrs) != 0 {
+		amsg.Addrs = multiaddr.FilterAddrs(amsg.Addrs, r.allowAddr)
+		return len(amsg.Addrs) != 0
+	}
+	if r.peerstore == nil {
+		return true
+	}
+	knownAddrs := r.peerstore.Addrs(amsg.PeerID)
+	if len(knownAddrs) == 0 {
+		return true
+	}
+	return len(multiaddr.FilterAddrs(knownAddrs, r.allowAddr)) != 0
+}
+
 func (r *Receiver) handleAnnounce(ctx context.Context, amsg Announce, direct bool) error {
+	ctx, span := tracer.Start(ctx, "Receiver.handleAnnounce", trace.WithAttributes(
+		attribute.String("cid", amsg.Cid.String()),
+		attribute.String("peer", amsg.PeerID.String()),
+		attribute.Bool("direct", direct)))
+	defer span.End()
+	amsg.SpanContext = span.SpanContext()
+
+	// Check addresses before checking the announce cache, so that an announce
+	// with addresses that are not allowed does not prevent a later announce,
+	// of the same CID, with allowed addresses.
+	if r.allowAddr != nil && !r.addrsAllowed(&amsg) {
+		log.Infow("Ignored announcement", "reason", errAddrNotAllowed, "peer", amsg.PeerID)
+		span.SetAttributes(attribute.String("ignored", errAddrNotAllowed.Error()))
+		return nil
+	}
+
 	err := r.announceCheck(amsg)
 	if err != nil {
 		if err == ErrClosed {
 			return err
 		}
 		log.Infow("Ignored announcement", "reason", err, "peer", amsg.PeerID)
+		span.SetAttributes(attribute.String("ignored", err.Error()))
 		return nil
 	}
 
@@ -140,3 +140,56 @@ func TestReceiverCidCache(t *testing.T) {
 
 	require.NoError(t, rcvr.Close())
 }
+
+func TestReceiverAllowAddr(t *testing.T) {
+	srcHost, _ := libp2p.New()
+	allowAddr := func(maddr multiaddr.Multiaddr) bool {
+		_, err := maddr.ValueForProtocol(multiaddr.P_DNS4)
+		return err == nil
+	}
+	rcvr, err := announce.NewReceiver(srcHost, testTopic, announce.WithAllowAddr(allowAddr))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Announce with no allowed addresses is ignored.
+	err = rcvr.Direct(context.Background(), testCid, testPeerID, testAddrs)
+	require.NoError(t, err)
+	_, err = rcvr.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Announce for the same CID is accepted with only the allowed address.
+	dnsAddr, err := multiaddr.NewMultiaddr("/dns4/example.com/tcp/9999")
+	require.NoError(t, err)
+	err = rcvr.Direct(context.Background(), testCid, testPeerID, append(testAddrs, dnsAddr))
+	require.NoError(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	amsg, err := rcvr.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []multiaddr.Multiaddr{dnsAddr}, amsg.Addrs)
+
+	// Announce without addresses is ignored when none of the peer's known
+	// addresses are allowed.
+	srcHost.Peerstore().AddAddrs(testPeerID, testAddrs, time.Hour)
+	err = rcvr.Direct(context.Background(), testCid2, testPeerID, nil)
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = rcvr.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Announce without addresses is accepted when a known address is allowed.
+	srcHost.Peerstore().AddAddr(testPeerID, dnsAddr, time.Hour)
+	err = rcvr.Direct(context.Background(), testCid2, testPeerID, nil)
+	require.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	amsg, err = rcvr.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, testCid2, amsg.Cid)
+
+	require.NoError(t, rcvr.Close())
+}
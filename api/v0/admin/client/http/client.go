@@ -25,6 +25,10 @@ const (
 	ingestResource = "/ingest"
 )
 
+// ErrCannotResume is returned when the indexer cannot deliver changes starting
+// from the requested sequence number.
+var ErrCannotResume = errors.New("cannot resume changes")
+
 // Client is an http client for the indexer finder API,
 type Client struct {
 	c       *http.Client
@@ -63,6 +67,52 @@ func (c *Client) Freeze(ctx context.Context) error {
 	return nil
 }
 
+// Drain stops the indexer from taking new ingest work, and lets ingestion in
+// progress finish. The finder continues to serve requests.
+func (c *Client) Drain(ctx context.Context) error {
+	return c.putRequest(ctx, "/drain")
+}
+
+// Undrain resumes taking new ingest work after Drain.
+func (c *Client) Undrain(ctx context.Context) error {
+	return c.putRequest(ctx, "/undrain")
+}
+
+// Snapshot starts a snapshot of the indexer's state, and returns the status of
+// the snapshot that was started. The snapshot is taken in the background; use
+// SnapshotStatus to see when it finishes.
+func (c *Client) Snapshot(ctx context.Context) (*model.SnapshotStatus, error) {
+	return c.snapshotRequest(ctx, http.MethodPut, "/snapshot", http.StatusAccepted)
+}
+
+// SnapshotStatus returns the status of the latest snapshot.
+func (c *Client) SnapshotStatus(ctx context.Context) (*model.SnapshotStatus, error) {
+	return c.snapshotRequest(ctx, http.MethodGet, "/snapshot/status", http.StatusOK)
+}
+
+func (c *Client) snapshotRequest(ctx context.Context, method, resource string, wantCode int) (*model.SnapshotStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+resource, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantCode {
+		return nil, httpclient.ReadErrorFrom(resp.StatusCode, resp.Body)
+	}
+
+	var status model.SnapshotStatus
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
 // ImportFromManifest processes entries from manifest and imports them into the
 // indexer.
 func (c *Client) ImportFromManifest(ctx context.Context, fileName string, provID peer.ID, contextID, metadata []byte) error {
@@ -142,6 +192,13 @@ func (c *Client) Sync(ctx context.Context, peerID peer.ID, peerAddr multiaddr.Mu
 	return c.ingestRequest(ctx, peerID, "sync", http.MethodPost, data, q...)
 }
 
+// Recount starts recomputing the index counts for a provider from the
+// provider's advertisement chain. The recount happens in the background on the
+// indexer.
+func (c *Client) Recount(ctx context.Context, providerID peer.ID) error {
+	return c.ingestRequest(ctx, providerID, "recount", http.MethodPost, nil)
+}
+
 // ImportProviders
 func (c *Client) ImportProviders(ctx context.Context, fromURL *url.URL) error {
 	if fromURL == nil || fromURL.String() == "" {
@@ -321,6 +378,18 @@ func (c *Client) Block(ctx context.Context, peerID peer.ID) error {
 	return c.ingestRequest(ctx, peerID, "block", http.MethodPut, nil)
 }
 
+// Pause pauses ingestion from the publisher. Announces from the publisher are
+// recorded, but not synced, until ingestion is resumed.
+func (c *Client) Pause(ctx context.Context, peerID peer.ID) error {
+	return c.ingestRequest(ctx, peerID, "pause", http.MethodPut, nil)
+}
+
+// Resume resumes ingestion from a paused publisher, syncing from the latest
+// advertisement announced while paused.
+func (c *Client) Resume(ctx context.Context, peerID peer.ID) error {
+	return c.ingestRequest(ctx, peerID, "resume", http.MethodPut, nil)
+}
+
 func (c *Client) ListLogSubSystems(ctx context.Context) ([]string, error) {
 	u := c.baseURL + "/config/log/subsystems"
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
@@ -404,6 +473,160 @@ func (c *Client) Status(ctx context.Context) (*model.Status, error) {
 	return &status, nil
 }
 
+// IngestEvents calls onEvent each time the indexer processes, skips, or fails
+// to process an advertisement. If publisher is not empty, then only events for
+// advertisements from that publisher are delivered. IngestEvents returns when
+// the context is canceled, onEvent returns an error, or the connection to the
+// indexer fails.
+//
+// The indexer ends each event stream before its write timeout, after which a
+// new stream is requested. Events that happen between streams are not
+// delivered. The http client timeout must be greater than the indexer's write
+// timeout.
+func (c *Client) IngestEvents(ctx context.Context, publisher peer.ID, onEvent func(model.IngestEvent) error) error {
+	u := c.baseURL + path.Join(ingestResource, "events")
+	if publisher != "" {
+		q := url.Values{}
+		q.Set("publisher", publisher.String())
+		u += "?" + q.Encode()
+	}
+	for {
+		err := c.readIngestEvents(ctx, u, onEvent)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+func (c *Client) readIngestEvents(ctx context.Context, u string, onEvent func(model.IngestEvent) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpclient.ReadErrorFrom(resp.StatusCode, resp.Body)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event model.IngestEvent
+		if err = dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err = onEvent(event); err != nil {
+			return err
+		}
+	}
+}
+
+// RegistryChanges calls onChange for each change made to the indexer's
+// provider registry, starting with the changes after the since sequence
+// number. If since is zero, then only new changes are delivered.
+// RegistryChanges returns when the context is canceled, onChange returns an
+// error, or the connection to the indexer fails. The caller can continue
+// receiving changes by calling RegistryChanges again with the Seq of the
+// last change received.
+//
+// ErrCannotResume is returned if the indexer no longer has all changes after
+// the since sequence number. The caller must then get the full list of
+// providers and call RegistryChanges with since set to zero.
+//
+// The indexer ends each change stream before its write timeout, after which a
+// new stream is requested. The http client timeout must be greater than the
+// indexer's write timeout.
+func (c *Client) RegistryChanges(ctx context.Context, since uint64, onChange func(model.ProviderChange) error) error {
+	for {
+		var err error
+		since, err = c.readRegistryChanges(ctx, since, onChange)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+func (c *Client) readRegistryChanges(ctx context.Context, since uint64, onChange func(model.ProviderChange) error) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/registry/events", nil)
+	if err != nil {
+		return 0, err
+	}
+	if since != 0 {
+		q := url.Values{}
+		q.Set("since", strconv.FormatUint(since, 10))
+		req.URL.RawQuery = q.Encode()
+	}
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = httpclient.ReadErrorFrom(resp.StatusCode, resp.Body)
+		if resp.StatusCode == http.StatusGone {
+			return 0, fmt.Errorf("%w: %s", ErrCannotResume, err)
+		}
+		return 0, err
+	}
+
+	if seqStr := resp.Header.Get(model.ChangeSeqHeader); seqStr != "" {
+		since, err = strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad change sequence header: %w", err)
+		}
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var change model.ProviderChange
+		if err = dec.Decode(&change); err != nil {
+			if errors.Is(err, io.EOF) {
+				return since, nil
+			}
+			return 0, err
+		}
+		if err = onChange(change); err != nil {
+			return 0, err
+		}
+		since = change.Seq
+	}
+}
+
+func (c *Client) putRequest(ctx context.Context, resource string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+resource, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return httpclient.ReadErrorFrom(resp.StatusCode, resp.Body)
+	}
+
+	return nil
+}
+
 func (c *Client) ingestRequest(ctx context.Context, peerID peer.ID, action, method string, data []byte, queryPairs ...string) error {
 	u := c.baseURL + path.Join(ingestResource, action, peerID.String())
 	var body io.Reader
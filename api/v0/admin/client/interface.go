@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ipni/storetheindex/api/v0/admin/model"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Admin is the interface implemented by all admin client protocols.
+type Admin interface {
+	// Status gets the indexer's status.
+	Status(context.Context) (*model.Status, error)
+	// Freeze puts the indexer into frozen mode.
+	Freeze(context.Context) error
+	// ReloadConfig reloads the reloadable parts of the indexer's config file.
+	ReloadConfig(context.Context) error
+	// ImportProviders imports provider information from another indexer.
+	ImportProviders(context.Context, *url.URL) error
+
+	// Sync syncs advertisements from a publisher.
+	Sync(ctx context.Context, peerID peer.ID, peerAddr multiaddr.Multiaddr, depth int64, resync bool) error
+	// Recount starts recomputing the index counts for a provider.
+	Recount(context.Context, peer.ID) error
+	// Allow allows a peer to publish advertisements and provide content.
+	Allow(context.Context, peer.ID) error
+	// Block blocks a peer from publishing advertisements and providing
+	// content.
+	Block(context.Context, peer.ID) error
+	// Pause pauses ingestion from a publisher.
+	Pause(context.Context, peer.ID) error
+	// Resume resumes ingestion from a paused publisher.
+	Resume(context.Context, peer.ID) error
+
+	// Assign assigns a publisher to the indexer.
+	Assign(context.Context, peer.ID) error
+	// Handoff assigns a publisher to the indexer, continuing from where a
+	// frozen indexer left off.
+	Handoff(ctx context.Context, publisherID, frozenID peer.ID, frozenURL string) error
+	// Unassign unassigns a publisher from the indexer.
+	Unassign(context.Context, peer.ID) error
+	// ListAssignedPeers gets the publishers assigned to the indexer.
+	ListAssignedPeers(context.Context) (map[peer.ID]peer.ID, error)
+	// ListPreferredPeers gets the unassigned publishers the indexer has
+	// previously retrieved advertisements from.
+	ListPreferredPeers(context.Context) ([]peer.ID, error)
+}
@@ -0,0 +1,270 @@
+package adminp2pclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	v0 "github.com/ipni/storetheindex/api/v0"
+	"github.com/ipni/storetheindex/api/v0/admin/client"
+	"github.com/ipni/storetheindex/api/v0/admin/model"
+	pb "github.com/ipni/storetheindex/api/v0/admin/pb"
+	"github.com/ipni/storetheindex/api/v0/libp2pclient"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Client is an admin client that uses the libp2p admin protocol. The indexer
+// only handles requests from peers that are configured as authorized admin
+// peers, so the client's libp2p host must have an authorized identity.
+type Client struct {
+	p2pc *libp2pclient.Client
+}
+
+var _ client.Admin = (*Client)(nil)
+
+// New creates a new admin client. If p2pHost is nil, then a host with a new
+// random identity is created, which is useful only if that identity is then
+// authorized by the indexer.
+func New(p2pHost host.Host, peerID peer.ID, options ...libp2pclient.Option) (*Client, error) {
+	p2pc, err := libp2pclient.New(p2pHost, peerID, v0.AdminProtocolID, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		p2pc: p2pc,
+	}, nil
+}
+
+// Connect connects the client to the host at the location specified by
+// hostname.  The value of hostname is a host or host:port, where the host is a
+// hostname or IP address.
+func (c *Client) Connect(ctx context.Context, hostname string) error {
+	return c.p2pc.Connect(ctx, hostname)
+}
+
+func (c *Client) ConnectAddrs(ctx context.Context, maddrs ...multiaddr.Multiaddr) error {
+	return c.p2pc.ConnectAddrs(ctx, maddrs...)
+}
+
+// Close closes the client's stream to the indexer, and closes the libp2p host
+// if the client created it.
+func (c *Client) Close() error {
+	return c.p2pc.Close()
+}
+
+func (c *Client) Status(ctx context.Context) (*model.Status, error) {
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_STATUS,
+	}
+	data, err := c.sendRecv(ctx, req, pb.AdminMessage_STATUS_RESPONSE)
+	if err != nil {
+		return nil, err
+	}
+
+	var status model.Status
+	err = json.Unmarshal(data, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Freeze puts the indexer into frozen mode.
+func (c *Client) Freeze(ctx context.Context) error {
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_FREEZE,
+	}
+	_, err := c.sendRecv(ctx, req, pb.AdminMessage_FREEZE_RESPONSE)
+	return err
+}
+
+// ReloadConfig reloads reloadable parts of the configuration file.
+func (c *Client) ReloadConfig(ctx context.Context) error {
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_RELOAD_CONFIG,
+	}
+	_, err := c.sendRecv(ctx, req, pb.AdminMessage_RELOAD_CONFIG_RESPONSE)
+	return err
+}
+
+// ImportProviders imports provider information from another indexer.
+func (c *Client) ImportProviders(ctx context.Context, fromURL *url.URL) error {
+	if fromURL == nil || fromURL.String() == "" {
+		return errors.New("missing indexer url")
+	}
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_IMPORT_PROVIDERS,
+		Data: []byte(fromURL.String()),
+	}
+	_, err := c.sendRecv(ctx, req, pb.AdminMessage_IMPORT_PROVIDERS_RESPONSE)
+	return err
+}
+
+// Sync with a data peer up to the latest ID. The sync happens in the
+// background on the indexer.
+func (c *Client) Sync(ctx context.Context, peerID peer.ID, peerAddr multiaddr.Multiaddr, depth int64, resync bool) error {
+	syncReq := model.SyncRequest{
+		PeerID: peerID,
+		Depth:  depth,
+		Resync: resync,
+	}
+	if peerAddr != nil {
+		syncReq.Addr = peerAddr.String()
+	}
+	data, err := json.Marshal(&syncReq)
+	if err != nil {
+		return err
+	}
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_SYNC,
+		Data: data,
+	}
+	_, err = c.sendRecv(ctx, req, pb.AdminMessage_SYNC_RESPONSE)
+	return err
+}
+
+// Recount starts recomputing the index counts for a provider from the
+// provider's advertisement chain. The recount happens in the background on the
+// indexer.
+func (c *Client) Recount(ctx context.Context, providerID peer.ID) error {
+	return c.peerRequest(ctx, providerID, pb.AdminMessage_RECOUNT, pb.AdminMessage_RECOUNT_RESPONSE)
+}
+
+// Allow configures the indexer to allow the peer to publish messages and
+// provide content.
+func (c *Client) Allow(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_ALLOW, pb.AdminMessage_ALLOW_RESPONSE)
+}
+
+// Block configures indexer to block the peer from publishing messages and
+// providing content.
+func (c *Client) Block(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_BLOCK, pb.AdminMessage_BLOCK_RESPONSE)
+}
+
+// Pause pauses ingestion from the publisher. Announces from the publisher are
+// recorded, but not synced, until ingestion is resumed.
+func (c *Client) Pause(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_PAUSE, pb.AdminMessage_PAUSE_RESPONSE)
+}
+
+// Resume resumes ingestion from a paused publisher, syncing from the latest
+// advertisement announced while paused.
+func (c *Client) Resume(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_RESUME, pb.AdminMessage_RESUME_RESPONSE)
+}
+
+// Assign assigns a publish to an indexer, when the indexer is configured to
+// work with an assigner service.
+func (c *Client) Assign(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_ASSIGN, pb.AdminMessage_ASSIGN_RESPONSE)
+}
+
+func (c *Client) Handoff(ctx context.Context, publisherID, frozenID peer.ID, frozenURL string) error {
+	handoff := model.HandoffRequest{
+		Publisher: publisherID,
+		Handoff: model.Handoff{
+			FrozenID:  frozenID,
+			FrozenURL: frozenURL,
+		},
+	}
+	data, err := json.Marshal(&handoff)
+	if err != nil {
+		return err
+	}
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_HANDOFF,
+		Data: data,
+	}
+	_, err = c.sendRecv(ctx, req, pb.AdminMessage_HANDOFF_RESPONSE)
+	return err
+}
+
+// Unassign unassigns a publish from an indexer, when the indexer is configured
+// to work with an assigner service.
+func (c *Client) Unassign(ctx context.Context, peerID peer.ID) error {
+	return c.peerRequest(ctx, peerID, pb.AdminMessage_UNASSIGN, pb.AdminMessage_UNASSIGN_RESPONSE)
+}
+
+// ListAssignedPeers gets a list of explicitly allowed peers, if indexer is
+// configured to work with an assigner service.
+func (c *Client) ListAssignedPeers(ctx context.Context) (map[peer.ID]peer.ID, error) {
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_LIST_ASSIGNED,
+	}
+	data, err := c.sendRecv(ctx, req, pb.AdminMessage_LIST_ASSIGNED_RESPONSE)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var assigned []model.Assigned
+	err = json.Unmarshal(data, &assigned)
+	if err != nil {
+		return nil, err
+	}
+
+	assignedMap := make(map[peer.ID]peer.ID, len(assigned))
+	for i := range assigned {
+		assignedMap[assigned[i].Publisher] = assigned[i].Continued
+	}
+	return assignedMap, nil
+}
+
+// ListPreferredPeers gets a list of unassigned peers that the indexer has
+// previously retrieved advertisements from.
+func (c *Client) ListPreferredPeers(ctx context.Context) ([]peer.ID, error) {
+	req := &pb.AdminMessage{
+		Type: pb.AdminMessage_LIST_PREFERRED,
+	}
+	data, err := c.sendRecv(ctx, req, pb.AdminMessage_LIST_PREFERRED_RESPONSE)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var peers []peer.ID
+	err = json.Unmarshal(data, &peers)
+	if err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+func (c *Client) peerRequest(ctx context.Context, peerID peer.ID, reqType, expectRspType pb.AdminMessage_MessageType) error {
+	data, err := json.Marshal(peerID)
+	if err != nil {
+		return err
+	}
+	req := &pb.AdminMessage{
+		Type: reqType,
+		Data: data,
+	}
+	_, err = c.sendRecv(ctx, req, expectRspType)
+	return err
+}
+
+func (c *Client) sendRecv(ctx context.Context, req *pb.AdminMessage, expectRspType pb.AdminMessage_MessageType) ([]byte, error) {
+	resp := new(pb.AdminMessage)
+	err := c.p2pc.SendRequest(ctx, req, func(data []byte) error {
+		return resp.Unmarshal(data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to indexer: %s", err)
+	}
+	if resp.GetType() != expectRspType {
+		if resp.GetType() == pb.AdminMessage_ERROR_RESPONSE {
+			return nil, v0.DecodeError(resp.GetData())
+		}
+		return nil, fmt.Errorf("response type is not %s", expectRspType.String())
+	}
+	return resp.GetData(), nil
+}
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"time"
+
+	"github.com/ipfs/go-cid"
+	findermodel "github.com/ipni/storetheindex/api/v0/finder/model"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -14,8 +18,75 @@ type Handoff struct {
 	FrozenURL string
 }
 
+// HandoffRequest is the request data for a handoff over the libp2p admin
+// protocol.
+type HandoffRequest struct {
+	Publisher peer.ID
+	Handoff
+}
+
+// SyncRequest is the request data for a sync over the libp2p admin protocol.
+type SyncRequest struct {
+	PeerID peer.ID
+	// Addr is the multiaddr to sync with, instead of the peer's known address.
+	Addr   string `json:",omitempty"`
+	Depth  int64  `json:",omitempty"`
+	Resync bool   `json:",omitempty"`
+}
+
 type Status struct {
 	Frozen bool
 	ID     peer.ID
 	Usage  float64
+	// Draining is true if the indexer has stopped taking new ingest work.
+	Draining bool `json:",omitempty"`
+	// Drained is true if the indexer is draining and has finished all
+	// ingest work in progress.
+	Drained bool `json:",omitempty"`
+	// ReadOnly is true if the indexer is a read-only finder replica.
+	ReadOnly bool `json:",omitempty"`
+}
+
+// SnapshotStatus is the status of the latest snapshot of the indexer's state.
+type SnapshotStatus struct {
+	// Name is the name of the snapshot in the snapshot file store.
+	Name    string
+	Started time.Time
+	// Finished is the time the snapshot finished, or is zero if the snapshot
+	// is still in progress.
+	Finished time.Time `json:",omitempty"`
+	// Error is the reason the snapshot failed.
+	Error string `json:",omitempty"`
+}
+
+// ChangeSeqHeader is the response header that contains the sequence number
+// after which a registry change stream starts.
+const ChangeSeqHeader = "X-Change-Seq"
+
+// ProviderChange is a change made to a provider in the indexer's registry.
+type ProviderChange struct {
+	// Seq is the sequence number of the change, used to resume receiving
+	// changes after the last one seen.
+	Seq uint64
+	// Kind is one of "registered", "updated", "frozen", or "removed".
+	Kind       string
+	ProviderID peer.ID
+	// Provider is the provider information after the change. It is not
+	// present when the provider was removed.
+	Provider *findermodel.ProviderInfo `json:",omitempty"`
+	Time     time.Time
+}
+
+// IngestEvent is the result of processing an advertisement.
+type IngestEvent struct {
+	Publisher peer.ID
+	Provider  peer.ID `json:",omitempty"`
+	AdCid     cid.Cid
+	// Status is one of "processed", "skipped", or "failed".
+	Status         string
+	MultihashCount int
+	Elapsed        time.Duration
+	// Error is the reason a skipped or failed advertisement was not ingested.
+	Error string `json:",omitempty"`
+	Time  time.Time
 }
@@ -0,0 +1,484 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: admin.proto
+
+package reqresp_pb
+
+import (
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+type AdminMessage_MessageType int32
+
+const (
+	AdminMessage_ERROR_RESPONSE            AdminMessage_MessageType = 0
+	AdminMessage_STATUS                    AdminMessage_MessageType = 1
+	AdminMessage_STATUS_RESPONSE           AdminMessage_MessageType = 2
+	AdminMessage_SYNC                      AdminMessage_MessageType = 3
+	AdminMessage_SYNC_RESPONSE             AdminMessage_MessageType = 4
+	AdminMessage_ALLOW                     AdminMessage_MessageType = 5
+	AdminMessage_ALLOW_RESPONSE            AdminMessage_MessageType = 6
+	AdminMessage_BLOCK                     AdminMessage_MessageType = 7
+	AdminMessage_BLOCK_RESPONSE            AdminMessage_MessageType = 8
+	AdminMessage_FREEZE                    AdminMessage_MessageType = 9
+	AdminMessage_FREEZE_RESPONSE           AdminMessage_MessageType = 10
+	AdminMessage_ASSIGN                    AdminMessage_MessageType = 11
+	AdminMessage_ASSIGN_RESPONSE           AdminMessage_MessageType = 12
+	AdminMessage_HANDOFF                   AdminMessage_MessageType = 13
+	AdminMessage_HANDOFF_RESPONSE          AdminMessage_MessageType = 14
+	AdminMessage_UNASSIGN                  AdminMessage_MessageType = 15
+	AdminMessage_UNASSIGN_RESPONSE         AdminMessage_MessageType = 16
+	AdminMessage_LIST_ASSIGNED             AdminMessage_MessageType = 17
+	AdminMessage_LIST_ASSIGNED_RESPONSE    AdminMessage_MessageType = 18
+	AdminMessage_LIST_PREFERRED            AdminMessage_MessageType = 19
+	AdminMessage_LIST_PREFERRED_RESPONSE   AdminMessage_MessageType = 20
+	AdminMessage_RELOAD_CONFIG             AdminMessage_MessageType = 21
+	AdminMessage_RELOAD_CONFIG_RESPONSE    AdminMessage_MessageType = 22
+	AdminMessage_IMPORT_PROVIDERS          AdminMessage_MessageType = 23
+	AdminMessage_IMPORT_PROVIDERS_RESPONSE AdminMessage_MessageType = 24
+	AdminMessage_RECOUNT                   AdminMessage_MessageType = 25
+	AdminMessage_RECOUNT_RESPONSE          AdminMessage_MessageType = 26
+	AdminMessage_PAUSE                     AdminMessage_MessageType = 27
+	AdminMessage_PAUSE_RESPONSE            AdminMessage_MessageType = 28
+	AdminMessage_RESUME                    AdminMessage_MessageType = 29
+	AdminMessage_RESUME_RESPONSE           AdminMessage_MessageType = 30
+)
+
+var AdminMessage_MessageType_name = map[int32]string{
+	0:  "ERROR_RESPONSE",
+	1:  "STATUS",
+	2:  "STATUS_RESPONSE",
+	3:  "SYNC",
+	4:  "SYNC_RESPONSE",
+	5:  "ALLOW",
+	6:  "ALLOW_RESPONSE",
+	7:  "BLOCK",
+	8:  "BLOCK_RESPONSE",
+	9:  "FREEZE",
+	10: "FREEZE_RESPONSE",
+	11: "ASSIGN",
+	12: "ASSIGN_RESPONSE",
+	13: "HANDOFF",
+	14: "HANDOFF_RESPONSE",
+	15: "UNASSIGN",
+	16: "UNASSIGN_RESPONSE",
+	17: "LIST_ASSIGNED",
+	18: "LIST_ASSIGNED_RESPONSE",
+	19: "LIST_PREFERRED",
+	20: "LIST_PREFERRED_RESPONSE",
+	21: "RELOAD_CONFIG",
+	22: "RELOAD_CONFIG_RESPONSE",
+	23: "IMPORT_PROVIDERS",
+	24: "IMPORT_PROVIDERS_RESPONSE",
+	25: "RECOUNT",
+	26: "RECOUNT_RESPONSE",
+	27: "PAUSE",
+	28: "PAUSE_RESPONSE",
+	29: "RESUME",
+	30: "RESUME_RESPONSE",
+}
+
+var AdminMessage_MessageType_value = map[string]int32{
+	"ERROR_RESPONSE":            0,
+	"STATUS":                    1,
+	"STATUS_RESPONSE":           2,
+	"SYNC":                      3,
+	"SYNC_RESPONSE":             4,
+	"ALLOW":                     5,
+	"ALLOW_RESPONSE":            6,
+	"BLOCK":                     7,
+	"BLOCK_RESPONSE":            8,
+	"FREEZE":                    9,
+	"FREEZE_RESPONSE":           10,
+	"ASSIGN":                    11,
+	"ASSIGN_RESPONSE":           12,
+	"HANDOFF":                   13,
+	"HANDOFF_RESPONSE":          14,
+	"UNASSIGN":                  15,
+	"UNASSIGN_RESPONSE":         16,
+	"LIST_ASSIGNED":             17,
+	"LIST_ASSIGNED_RESPONSE":    18,
+	"LIST_PREFERRED":            19,
+	"LIST_PREFERRED_RESPONSE":   20,
+	"RELOAD_CONFIG":             21,
+	"RELOAD_CONFIG_RESPONSE":    22,
+	"IMPORT_PROVIDERS":          23,
+	"IMPORT_PROVIDERS_RESPONSE": 24,
+	"RECOUNT":                   25,
+	"RECOUNT_RESPONSE":          26,
+	"PAUSE":                     27,
+	"PAUSE_RESPONSE":            28,
+	"RESUME":                    29,
+	"RESUME_RESPONSE":           30,
+}
+
+func (x AdminMessage_MessageType) String() string {
+	return proto.EnumName(AdminMessage_MessageType_name, int32(x))
+}
+
+func (AdminMessage_MessageType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_73a7fc70dcc2027c, []int{0, 0}
+}
+
+type AdminMessage struct {
+	// defines what type of message it is.
+	Type AdminMessage_MessageType `protobuf:"varint,1,opt,name=type,proto3,enum=reqresp.pb.AdminMessage_MessageType" json:"type,omitempty"`
+	// Value for the message
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *AdminMessage) Reset()         { *m = AdminMessage{} }
+func (m *AdminMessage) String() string { return proto.CompactTextString(m) }
+func (*AdminMessage) ProtoMessage()    {}
+func (*AdminMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_73a7fc70dcc2027c, []int{0}
+}
+func (m *AdminMessage) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *AdminMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_AdminMessage.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *AdminMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AdminMessage.Merge(m, src)
+}
+func (m *AdminMessage) XXX_Size() int {
+	return m.Size()
+}
+func (m *AdminMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_AdminMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AdminMessage proto.InternalMessageInfo
+
+func (m *AdminMessage) GetType() AdminMessage_MessageType {
+	if m != nil {
+		return m.Type
+	}
+	return AdminMessage_ERROR_RESPONSE
+}
+
+func (m *AdminMessage) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("reqresp.pb.AdminMessage_MessageType", AdminMessage_MessageType_name, AdminMessage_MessageType_value)
+	proto.RegisterType((*AdminMessage)(nil), "reqresp.pb.AdminMessage")
+}
+
+func init() { proto.RegisterFile("admin.proto", fileDescriptor_73a7fc70dcc2027c) }
+
+var fileDescriptor_73a7fc70dcc2027c = []byte{
+	// 438 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x92, 0xcf, 0x6e, 0xd3, 0x40,
+	0x10, 0xc6, 0xe3, 0xe2, 0xa4, 0xe9, 0x24, 0x4d, 0x27, 0xd3, 0x7f, 0x69, 0x4b, 0xad, 0xaa, 0xe2,
+	0xd0, 0x93, 0x0f, 0x70, 0xe1, 0xea, 0xc6, 0xeb, 0x62, 0xe1, 0x78, 0xa3, 0x5d, 0x1b, 0x04, 0x97,
+	0x28, 0x55, 0x2d, 0xc4, 0x01, 0x6a, 0x92, 0x5c, 0xfa, 0x16, 0xbc, 0x08, 0xef, 0xc1, 0x09, 0xf5,
+	0xc8, 0x11, 0x25, 0x2f, 0x82, 0xc6, 0x0e, 0x9a, 0x86, 0x53, 0xbe, 0x99, 0xef, 0x37, 0xdf, 0x6a,
+	0x26, 0x86, 0xce, 0xf4, 0xee, 0xcb, 0xe7, 0xaf, 0x7e, 0x39, 0xbb, 0x5f, 0xdc, 0x13, 0xcc, 0x8a,
+	0x6f, 0xb3, 0x62, 0x5e, 0xfa, 0xe5, 0xed, 0xe5, 0x8f, 0x26, 0x74, 0x03, 0xf6, 0x46, 0xc5, 0x7c,
+	0x3e, 0xfd, 0x54, 0xd0, 0x6b, 0x70, 0x17, 0x0f, 0x65, 0x31, 0x70, 0x2e, 0x9c, 0xab, 0xde, 0xcb,
+	0x17, 0xbe, 0xb0, 0xfe, 0x53, 0xce, 0x5f, 0xff, 0x66, 0x0f, 0x65, 0x61, 0xaa, 0x09, 0x22, 0x70,
+	0xef, 0xa6, 0x8b, 0xe9, 0x60, 0xeb, 0xc2, 0xb9, 0xea, 0x9a, 0x4a, 0x5f, 0xfe, 0x72, 0xa1, 0xf3,
+	0x84, 0x24, 0x82, 0x9e, 0x32, 0x46, 0x9b, 0x89, 0x51, 0x76, 0xac, 0x53, 0xab, 0xb0, 0x41, 0x00,
+	0x2d, 0x9b, 0x05, 0x59, 0x6e, 0xd1, 0xa1, 0x7d, 0xd8, 0xab, 0xb5, 0x00, 0x5b, 0xd4, 0x06, 0xd7,
+	0x7e, 0x48, 0x87, 0xf8, 0x8c, 0xfa, 0xb0, 0xcb, 0x4a, 0x4c, 0x97, 0x76, 0xa0, 0x19, 0x24, 0x89,
+	0x7e, 0x8f, 0x4d, 0x0e, 0xaf, 0xa4, 0xd8, 0x2d, 0xb6, 0xaf, 0x13, 0x3d, 0x7c, 0x8b, 0xdb, 0x6c,
+	0x57, 0x52, 0xec, 0x36, 0xbf, 0x1d, 0x19, 0xa5, 0x3e, 0x2a, 0xdc, 0xe1, 0xb7, 0x6b, 0x2d, 0x00,
+	0x30, 0x10, 0x58, 0x1b, 0xdf, 0xa4, 0xd8, 0x61, 0xa0, 0xd6, 0x02, 0x74, 0xa9, 0x03, 0xdb, 0x6f,
+	0x82, 0x34, 0xd4, 0x51, 0x84, 0xbb, 0x74, 0x00, 0xb8, 0x2e, 0x04, 0xe9, 0x51, 0x17, 0xda, 0x79,
+	0xba, 0x4e, 0xd9, 0xa3, 0x43, 0xe8, 0xff, 0xab, 0x04, 0x42, 0x5e, 0x2d, 0x89, 0x6d, 0x36, 0xa9,
+	0x1d, 0x15, 0x62, 0x9f, 0x4e, 0xe1, 0x68, 0xa3, 0x25, 0x38, 0xf1, 0x32, 0x95, 0x37, 0x36, 0x2a,
+	0x52, 0xc6, 0xa8, 0x10, 0xf7, 0xe9, 0x0c, 0x8e, 0x37, 0x7b, 0x32, 0x70, 0xc0, 0xf9, 0x46, 0x25,
+	0x3a, 0x08, 0x27, 0x43, 0x9d, 0x46, 0xf1, 0x0d, 0x1e, 0x72, 0xfe, 0x46, 0x4b, 0xf0, 0x23, 0xde,
+	0x24, 0x1e, 0x8d, 0xb5, 0xe1, 0x34, 0xfd, 0x2e, 0x0e, 0x95, 0xb1, 0x78, 0x4c, 0xe7, 0x70, 0xf2,
+	0x7f, 0x57, 0x86, 0x06, 0x7c, 0x0b, 0xa3, 0x86, 0x3a, 0x4f, 0x33, 0x3c, 0xe1, 0x84, 0x75, 0x21,
+	0xc8, 0x29, 0xff, 0x1f, 0xe3, 0x20, 0xb7, 0x0a, 0xcf, 0x78, 0x85, 0x4a, 0x8a, 0xfd, 0x9c, 0xcf,
+	0x6d, 0x94, 0xcd, 0x47, 0x0a, 0xcf, 0xf9, 0xdc, 0xb5, 0x16, 0xc0, 0xbb, 0x1e, 0xfc, 0x5c, 0x7a,
+	0xce, 0xe3, 0xd2, 0x73, 0xfe, 0x2c, 0x3d, 0xe7, 0xfb, 0xca, 0x6b, 0x3c, 0xae, 0xbc, 0xc6, 0xef,
+	0x95, 0xd7, 0xb8, 0x6d, 0x55, 0x1f, 0xf7, 0xab, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x7f, 0x31,
+	0xd3, 0x8e, 0xeb, 0x02, 0x00, 0x00,
+}
+
+func (m *AdminMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *AdminMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *AdminMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Data) > 0 {
+		i -= len(m.Data)
+		copy(dAtA[i:], m.Data)
+		i = encodeVarintAdmin(dAtA, i, uint64(len(m.Data)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Type != 0 {
+		i = encodeVarintAdmin(dAtA, i, uint64(m.Type))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintAdmin(dAtA []byte, offset int, v uint64) int {
+	offset -= sovAdmin(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *AdminMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Type != 0 {
+		n += 1 + sovAdmin(uint64(m.Type))
+	}
+	l = len(m.Data)
+	if l > 0 {
+		n += 1 + l + sovAdmin(uint64(l))
+	}
+	return n
+}
+
+func sovAdmin(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozAdmin(x uint64) (n int) {
+	return sovAdmin(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *AdminMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowAdmin
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AdminMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AdminMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAdmin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= AdminMessage_MessageType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAdmin
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthAdmin
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthAdmin
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipAdmin(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthAdmin
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipAdmin(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowAdmin
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowAdmin
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowAdmin
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthAdmin
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupAdmin
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthAdmin
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthAdmin        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowAdmin          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupAdmin = fmt.Errorf("proto: unexpected end of group")
+)
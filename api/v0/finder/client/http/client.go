@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +19,12 @@ const (
 	finderPath    = "/multihash"
 	providersPath = "/providers"
 	statsPath     = "/stats"
+
+	mediaTypeNDJson  = "application/x-ndjson"
+	nextCursorHeader = "X-Next-Cursor"
+	// providersPageSize is the number of providers requested per page when
+	// a query does not specify a limit.
+	providersPageSize = 100
 )
 
 // Client is an http client for the indexer finder API
@@ -103,8 +110,123 @@ func (c *Client) ListProviders(ctx context.Context) ([]*model.ProviderInfo, erro
 	return providers, nil
 }
 
+// ListProvidersPage gets one page of the providers selected by the query. If
+// the query has no limit, then a page of providersPageSize is requested.
+func (c *Client) ListProvidersPage(ctx context.Context, query model.ProviderQuery) (*model.ProvidersPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if query.Limit == 0 {
+		query.Limit = providersPageSize
+	}
+	u := c.providersURL + "?" + query.Values().Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpclient.ReadError(resp.StatusCode, body)
+	}
+
+	var page model.ProvidersPage
+	if err = json.Unmarshal(body, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// EachProvider calls onProvider for each of the providers selected by the
+// query, starting after query.Cursor, getting providers from the server one
+// page at a time. The query limit is the page size. EachProvider returns when
+// there are no more providers, or when onProvider returns an error.
+func (c *Client) EachProvider(ctx context.Context, query model.ProviderQuery, onProvider func(*model.ProviderInfo) error) error {
+	for {
+		page, err := c.ListProvidersPage(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, prov := range page.Providers {
+			if err = onProvider(prov); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// StreamProviders calls onProvider for each of the providers selected by the
+// query, as they are read from a single NDJSON response. If the query is
+// paged, then the cursor for the next page is returned.
+func (c *Client) StreamProviders(ctx context.Context, query model.ProviderQuery, onProvider func(*model.ProviderInfo) error) (string, error) {
+	if err := query.Validate(); err != nil {
+		return "", err
+	}
+	u := c.providersURL
+	if v := query.Values(); len(v) != 0 {
+		u += "?" + v.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Accept", mediaTypeNDJson)
+
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", httpclient.ReadErrorFrom(resp.StatusCode, resp.Body)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var prov model.ProviderInfo
+		if err = dec.Decode(&prov); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resp.Header.Get(nextCursorHeader), nil
+			}
+			return "", err
+		}
+		if err = onProvider(&prov); err != nil {
+			return "", err
+		}
+	}
+}
+
 func (c *Client) GetProvider(ctx context.Context, providerID peer.ID) (*model.ProviderInfo, error) {
+	return c.getProvider(ctx, providerID, false)
+}
+
+// GetProviderWithContexts gets information about a provider, including the
+// index count for each of the provider's context IDs.
+func (c *Client) GetProviderWithContexts(ctx context.Context, providerID peer.ID) (*model.ProviderInfo, error) {
+	return c.getProvider(ctx, providerID, true)
+}
+
+func (c *Client) getProvider(ctx context.Context, providerID peer.ID, withContexts bool) (*model.ProviderInfo, error) {
 	u := fmt.Sprint(c.providersURL, "/", providerID.String())
+	if withContexts {
+		u += "?contexts=true"
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, err
@@ -135,7 +257,21 @@ func (c *Client) GetProvider(ctx context.Context, providerID peer.ID) (*model.Pr
 }
 
 func (c *Client) GetStats(ctx context.Context) (*model.Stats, error) {
+	return c.getStats(ctx, false)
+}
+
+// GetStatsWithDetails gets statistics for the indexer, including provider
+// counts by state, ingest pipeline stats, and the providers with the most
+// indexes.
+func (c *Client) GetStatsWithDetails(ctx context.Context) (*model.Stats, error) {
+	return c.getStats(ctx, true)
+}
+
+func (c *Client) getStats(ctx context.Context, withDetails bool) (*model.Stats, error) {
 	u := fmt.Sprint(c.statsURL)
+	if withDetails {
+		u += "?details=true"
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, err
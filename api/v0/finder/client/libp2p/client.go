@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	v0 "github.com/ipni/storetheindex/api/v0"
 	"github.com/ipni/storetheindex/api/v0/finder/model"
@@ -15,12 +16,16 @@ import (
 	"github.com/multiformats/go-multihash"
 )
 
+// providersPageSize is the number of providers requested per page when a
+// query does not specify a limit.
+const providersPageSize = 100
+
 type Client struct {
 	p2pc *libp2pclient.Client
 }
 
-func New(p2pHost host.Host, peerID peer.ID) (*Client, error) {
-	client, err := libp2pclient.New(p2pHost, peerID, v0.FinderProtocolID)
+func New(p2pHost host.Host, peerID peer.ID, options ...libp2pclient.Option) (*Client, error) {
+	client, err := libp2pclient.New(p2pHost, peerID, v0.FinderProtocolID, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +45,12 @@ func (c *Client) ConnectAddrs(ctx context.Context, maddrs ...multiaddr.Multiaddr
 	return c.p2pc.ConnectAddrs(ctx, maddrs...)
 }
 
+// Close closes the client's stream to the indexer, and closes the libp2p host
+// if the client created it.
+func (c *Client) Close() error {
+	return c.p2pc.Close()
+}
+
 func (c *Client) Find(ctx context.Context, m multihash.Multihash) (*model.FindResponse, error) {
 	return c.FindBatch(ctx, []multihash.Multihash{m})
 }
@@ -66,6 +77,79 @@ func (c *Client) FindBatch(ctx context.Context, mhs []multihash.Multihash) (*mod
 	return model.UnmarshalFindResponse(data)
 }
 
+// FindStream finds the provider results for multiple multihashes, and calls
+// onResult as the result for each multihash is received. Multihashes that have
+// no results are not passed to onResult. If onResult returns an error, then the
+// request is canceled and that error is returned.
+//
+// If ctx has a deadline, then the indexer stops handling the request when the
+// deadline is reached.
+func (c *Client) FindStream(ctx context.Context, mhs []multihash.Multihash, onResult func(model.MultihashResult) error) error {
+	if len(mhs) == 0 {
+		return nil
+	}
+
+	data, err := model.MarshalFindRequest(&model.FindRequest{Multihashes: mhs})
+	if err != nil {
+		return err
+	}
+	req := &pb.FinderMessage{
+		Type: pb.FinderMessage_FIND_STREAM,
+		Data: data,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline).Milliseconds()
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+		req.TimeoutMsec = uint64(timeout)
+	}
+	cancelMsg := &pb.FinderMessage{
+		Type: pb.FinderMessage_CANCEL,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var resultErr, rspErr error
+	err = c.p2pc.SendStreamRequest(ctx, req, cancelMsg, func(data []byte) (bool, error) {
+		var resp pb.FinderMessage
+		if err := resp.Unmarshal(data); err != nil {
+			return false, err
+		}
+		switch resp.GetType() {
+		case pb.FinderMessage_FIND_STREAM_RESPONSE:
+			// Discard the remaining results after canceling the request.
+			if ctx.Err() != nil {
+				return false, nil
+			}
+			var result model.MultihashResult
+			if err := json.Unmarshal(resp.GetData(), &result); err != nil {
+				return false, err
+			}
+			if err := onResult(result); err != nil {
+				resultErr = err
+				cancel()
+			}
+			return false, nil
+		case pb.FinderMessage_FIND_STREAM_END:
+			return true, nil
+		case pb.FinderMessage_ERROR_RESPONSE:
+			rspErr = v0.DecodeError(resp.GetData())
+			return true, nil
+		default:
+			return false, fmt.Errorf("response type is not %s", pb.FinderMessage_FIND_STREAM_RESPONSE.String())
+		}
+	})
+	if resultErr != nil {
+		return resultErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send request to indexer: %w", err)
+	}
+	return rspErr
+}
+
 func (c *Client) GetProvider(ctx context.Context, providerID peer.ID) (*model.ProviderInfo, error) {
 	data, err := json.Marshal(providerID)
 	if err != nil {
@@ -109,6 +193,58 @@ func (c *Client) ListProviders(ctx context.Context) ([]*model.ProviderInfo, erro
 	return providers, nil
 }
 
+// ListProvidersPage gets one page of the providers selected by the query. If
+// the query has no limit, then a page of providersPageSize is requested.
+func (c *Client) ListProvidersPage(ctx context.Context, query model.ProviderQuery) (*model.ProvidersPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if query.Limit == 0 {
+		query.Limit = providersPageSize
+	}
+	data, err := json.Marshal(&query)
+	if err != nil {
+		return nil, err
+	}
+	req := &pb.FinderMessage{
+		Type: pb.FinderMessage_LIST_PROVIDERS,
+		Data: data,
+	}
+
+	data, err = c.sendRecv(ctx, req, pb.FinderMessage_LIST_PROVIDERS_RESPONSE)
+	if err != nil {
+		return nil, err
+	}
+
+	var page model.ProvidersPage
+	if err = json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// EachProvider calls onProvider for each of the providers selected by the
+// query, starting after query.Cursor, getting providers from the server one
+// page at a time. The query limit is the page size. EachProvider returns when
+// there are no more providers, or when onProvider returns an error.
+func (c *Client) EachProvider(ctx context.Context, query model.ProviderQuery, onProvider func(*model.ProviderInfo) error) error {
+	for {
+		page, err := c.ListProvidersPage(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, prov := range page.Providers {
+			if err = onProvider(prov); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
 func (c *Client) GetStats(ctx context.Context) (*model.Stats, error) {
 	req := &pb.FinderMessage{
 		Type: pb.FinderMessage_GET_STATS,
@@ -10,6 +10,12 @@ import (
 	"github.com/multiformats/go-multihash"
 )
 
+// FederatedHeader is set in find requests that an indexer sends to its
+// federated indexers. A request with this header is answered only from the
+// receiving indexer's own value store, so that find requests do not loop
+// between indexers that federate with each other.
+const FederatedHeader = "X-Federated"
+
 // FindRequest is the client request send by end user clients
 type FindRequest struct {
 	Multihashes []multihash.Multihash
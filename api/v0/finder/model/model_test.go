@@ -3,12 +3,15 @@ package model
 import (
 	"bytes"
 	"math/rand"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/ipni/storetheindex/test/util"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
 )
 
 var rng = rand.New(rand.NewSource(1413))
@@ -115,3 +118,38 @@ func equalMultihashes(m1, m2 []multihash.Multihash) bool {
 	}
 	return true
 }
+
+func TestProviderQueryValues(t *testing.T) {
+	p, _ := peer.Decode("12D3KooWKRyzVWW6ChFjQjK4miCty85Niy48tpPV95XdKu1BcvMA")
+	yes, no := true, false
+	query := ProviderQuery{
+		Cursor:                 p.String(),
+		Limit:                  10,
+		Publisher:              p,
+		LastAdvertisementSince: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		LastAdvertisementUntil: time.Date(2023, 2, 3, 4, 5, 6, 0, time.UTC),
+		ExtendedProviders:      &yes,
+		Inactive:               &no,
+		Frozen:                 &yes,
+		Address:                "127.0.0.1",
+		Protocol:               "quic",
+	}
+	parsed, err := ParseProviderQuery(query.Values())
+	require.NoError(t, err)
+	require.Equal(t, query, parsed)
+	require.True(t, parsed.Paged())
+
+	parsed, err = ParseProviderQuery(ProviderQuery{}.Values())
+	require.NoError(t, err)
+	require.Equal(t, ProviderQuery{}, parsed)
+	require.False(t, parsed.Paged())
+
+	_, err = ParseProviderQuery(url.Values{"limit": []string{"-1"}})
+	require.Error(t, err)
+	_, err = ParseProviderQuery(url.Values{"cursor": []string{"bad"}})
+	require.Error(t, err)
+	_, err = ParseProviderQuery(url.Values{"protocol": []string{"nosuchproto"}})
+	require.Error(t, err)
+	_, err = ParseProviderQuery(url.Values{"frozen": []string{"maybe"}})
+	require.Error(t, err)
+}
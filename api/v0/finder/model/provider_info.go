@@ -20,6 +20,19 @@ type ProviderInfo struct {
 	// Inactive means that no update has been received for the configured
 	// Discovery.PollInterval, and the publisher is not responding to polls.
 	Inactive bool `json:",omitempty"`
+	// Paused means that ingestion from the provider's publisher is paused by
+	// the indexer administrator. The provider's existing index is still served.
+	Paused bool `json:",omitempty"`
+	// ContextCounts is the index count for each of the provider's context IDs.
+	// This is only present when requested.
+	ContextCounts []ContextCount `json:",omitempty"`
+}
+
+// ContextCount is the number of multihashes indexed for a provider's context
+// ID.
+type ContextCount struct {
+	ContextID []byte
+	Count     uint64
 }
 
 type ExtendedProviders struct {
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// ProviderQuery selects which providers are listed, and which page of the
+// selected providers is returned. Providers are ordered by provider ID. The
+// zero value selects all providers.
+type ProviderQuery struct {
+	// Cursor is the provider ID after which to start listing providers. This
+	// is the NextCursor from the previous page.
+	Cursor string `json:",omitempty"`
+	// Limit is the maximum number of providers to return. A value of zero
+	// means no limit, unless Cursor is set in which case the server default
+	// is used.
+	Limit int `json:",omitempty"`
+
+	// Publisher selects providers whose advertisements are published by this
+	// peer.
+	Publisher peer.ID `json:",omitempty"`
+	// LastAdvertisementSince selects providers whose latest advertisement was
+	// received at or after this time.
+	LastAdvertisementSince time.Time `json:",omitempty"`
+	// LastAdvertisementUntil selects providers whose latest advertisement was
+	// received before this time.
+	LastAdvertisementUntil time.Time `json:",omitempty"`
+	// ExtendedProviders, if set, selects providers that do or do not have
+	// extended providers.
+	ExtendedProviders *bool `json:",omitempty"`
+	// Inactive, if set, selects providers that are or are not inactive.
+	Inactive *bool `json:",omitempty"`
+	// Frozen, if set, selects providers that do or do not have a frozen-at
+	// advertisement.
+	Frozen *bool `json:",omitempty"`
+	// Address selects providers with an address that contains this string.
+	Address string `json:",omitempty"`
+	// Protocol selects providers with an address that uses this multiaddr
+	// protocol, such as "quic" or "http".
+	Protocol string `json:",omitempty"`
+}
+
+// ProvidersPage is one page of providers selected by a ProviderQuery.
+type ProvidersPage struct {
+	Providers []*ProviderInfo
+	// NextCursor is the cursor to get the next page. It is empty if there are
+	// no more providers.
+	NextCursor string `json:",omitempty"`
+}
+
+// Paged returns true if the query requests a page of providers instead of all
+// selected providers.
+func (q ProviderQuery) Paged() bool {
+	return q.Limit != 0 || q.Cursor != ""
+}
+
+// Values encodes the query as URL query parameters.
+func (q ProviderQuery) Values() url.Values {
+	v := url.Values{}
+	if q.Cursor != "" {
+		v.Set("cursor", q.Cursor)
+	}
+	if q.Limit != 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Publisher != "" {
+		v.Set("publisher", q.Publisher.String())
+	}
+	if !q.LastAdvertisementSince.IsZero() {
+		v.Set("since", q.LastAdvertisementSince.Format(time.RFC3339))
+	}
+	if !q.LastAdvertisementUntil.IsZero() {
+		v.Set("until", q.LastAdvertisementUntil.Format(time.RFC3339))
+	}
+	if q.ExtendedProviders != nil {
+		v.Set("extended", strconv.FormatBool(*q.ExtendedProviders))
+	}
+	if q.Inactive != nil {
+		v.Set("inactive", strconv.FormatBool(*q.Inactive))
+	}
+	if q.Frozen != nil {
+		v.Set("frozen", strconv.FormatBool(*q.Frozen))
+	}
+	if q.Address != "" {
+		v.Set("addr", q.Address)
+	}
+	if q.Protocol != "" {
+		v.Set("protocol", q.Protocol)
+	}
+	return v
+}
+
+// ParseProviderQuery decodes a ProviderQuery from URL query parameters, and
+// validates it. The parameters are: cursor, limit, publisher, since and until
+// (RFC 3339 times), extended, inactive, frozen (booleans), addr, and protocol.
+func ParseProviderQuery(v url.Values) (ProviderQuery, error) {
+	var q ProviderQuery
+	var err error
+
+	q.Cursor = v.Get("cursor")
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return ProviderQuery{}, errors.New("invalid limit value")
+		}
+	}
+	if s := v.Get("publisher"); s != "" {
+		if q.Publisher, err = peer.Decode(s); err != nil {
+			return ProviderQuery{}, fmt.Errorf("invalid publisher value: %w", err)
+		}
+	}
+	if s := v.Get("since"); s != "" {
+		if q.LastAdvertisementSince, err = time.Parse(time.RFC3339, s); err != nil {
+			return ProviderQuery{}, errors.New("invalid since value")
+		}
+	}
+	if s := v.Get("until"); s != "" {
+		if q.LastAdvertisementUntil, err = time.Parse(time.RFC3339, s); err != nil {
+			return ProviderQuery{}, errors.New("invalid until value")
+		}
+	}
+	if q.ExtendedProviders, err = parseOptBool(v, "extended"); err != nil {
+		return ProviderQuery{}, err
+	}
+	if q.Inactive, err = parseOptBool(v, "inactive"); err != nil {
+		return ProviderQuery{}, err
+	}
+	if q.Frozen, err = parseOptBool(v, "frozen"); err != nil {
+		return ProviderQuery{}, err
+	}
+	q.Address = v.Get("addr")
+	q.Protocol = v.Get("protocol")
+
+	if err = q.Validate(); err != nil {
+		return ProviderQuery{}, err
+	}
+	return q, nil
+}
+
+// Validate checks that the query values are valid.
+func (q ProviderQuery) Validate() error {
+	if q.Limit < 0 {
+		return errors.New("limit cannot be negative")
+	}
+	if q.Cursor != "" {
+		if _, err := peer.Decode(q.Cursor); err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	if q.Protocol != "" && multiaddr.ProtocolWithName(q.Protocol).Code == 0 {
+		return fmt.Errorf("unknown protocol %q", q.Protocol)
+	}
+	return nil
+}
+
+func parseOptBool(v url.Values, name string) (*bool, error) {
+	s := v.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value", name)
+	}
+	return &b, nil
+}
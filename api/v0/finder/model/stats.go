@@ -2,6 +2,9 @@ package model
 
 import (
 	"encoding/json"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // Stats is the client response to a stats request.
@@ -17,6 +20,61 @@ type Stats struct {
 	// entries in the value store. Its value is not an exact count. It is considered to be far more
 	// accurate than estimates based on size of valuestore, e.g. EntriesEstimate.
 	EntriesCount int64
+	// Details contains additional statistics about providers and ingestion.
+	// It is only present when requested.
+	Details *StatsDetails `json:",omitempty"`
+}
+
+// StatsDetails contains detailed indexer statistics.
+type StatsDetails struct {
+	// Providers is the number of providers in each state.
+	Providers ProviderStateCounts
+	// Ingest describes the advertisement ingestion pipeline. It is not present
+	// if the indexer is not ingesting advertisements.
+	Ingest *IngestStats `json:",omitempty"`
+	// TopProviders are the providers with the most indexes, in descending
+	// order of index count. It is not present if index counts are not kept.
+	TopProviders []ProviderIndexCount `json:",omitempty"`
+	// ValueStoreUsage is the percentage of value store disk space used, or -1
+	// if not available.
+	ValueStoreUsage float64
+	// UpdatedAt is when these statistics were collected.
+	UpdatedAt time.Time
+}
+
+// ProviderStateCounts is the number of providers in each state. A frozen
+// provider is only counted as frozen.
+type ProviderStateCounts struct {
+	Active   int
+	Inactive int
+	Frozen   int
+}
+
+// IngestStats describes the advertisement ingestion pipeline.
+type IngestStats struct {
+	// QueueDepth is the number of providers waiting for a worker to process
+	// their advertisements.
+	QueueDepth int
+	// Workers is the number of ingest workers.
+	Workers int
+	// ActiveWorkers is the number of workers currently processing
+	// advertisements.
+	ActiveWorkers int
+	// WorkerUtilization is the fraction of workers that are active, from 0 to
+	// 1.
+	WorkerUtilization float64
+	// AdsProcessedLastHour is the number of advertisements processed in the
+	// last hour.
+	AdsProcessedLastHour uint64
+	// AdsProcessedLastDay is the number of advertisements processed in the
+	// last 24 hours.
+	AdsProcessedLastDay uint64
+}
+
+// ProviderIndexCount is the number of indexes for a provider.
+type ProviderIndexCount struct {
+	ProviderID peer.ID
+	IndexCount uint64
 }
 
 // MarshalStats serializes the stats response. Currently uses JSON, but could
@@ -34,18 +34,26 @@ const (
 	FinderMessage_GET_PROVIDER_RESPONSE   FinderMessage_MessageType = 6
 	FinderMessage_GET_STATS               FinderMessage_MessageType = 7
 	FinderMessage_GET_STATS_RESPONSE      FinderMessage_MessageType = 8
+	FinderMessage_FIND_STREAM             FinderMessage_MessageType = 9
+	FinderMessage_FIND_STREAM_RESPONSE    FinderMessage_MessageType = 10
+	FinderMessage_FIND_STREAM_END         FinderMessage_MessageType = 11
+	FinderMessage_CANCEL                  FinderMessage_MessageType = 12
 )
 
 var FinderMessage_MessageType_name = map[int32]string{
-	0: "ERROR_RESPONSE",
-	1: "FIND",
-	2: "FIND_RESPONSE",
-	3: "LIST_PROVIDERS",
-	4: "LIST_PROVIDERS_RESPONSE",
-	5: "GET_PROVIDER",
-	6: "GET_PROVIDER_RESPONSE",
-	7: "GET_STATS",
-	8: "GET_STATS_RESPONSE",
+	0:  "ERROR_RESPONSE",
+	1:  "FIND",
+	2:  "FIND_RESPONSE",
+	3:  "LIST_PROVIDERS",
+	4:  "LIST_PROVIDERS_RESPONSE",
+	5:  "GET_PROVIDER",
+	6:  "GET_PROVIDER_RESPONSE",
+	7:  "GET_STATS",
+	8:  "GET_STATS_RESPONSE",
+	9:  "FIND_STREAM",
+	10: "FIND_STREAM_RESPONSE",
+	11: "FIND_STREAM_END",
+	12: "CANCEL",
 }
 
 var FinderMessage_MessageType_value = map[string]int32{
@@ -58,6 +66,10 @@ var FinderMessage_MessageType_value = map[string]int32{
 	"GET_PROVIDER_RESPONSE":   6,
 	"GET_STATS":               7,
 	"GET_STATS_RESPONSE":      8,
+	"FIND_STREAM":             9,
+	"FIND_STREAM_RESPONSE":    10,
+	"FIND_STREAM_END":         11,
+	"CANCEL":                  12,
 }
 
 func (x FinderMessage_MessageType) String() string {
@@ -73,6 +85,9 @@ type FinderMessage struct {
 	Type FinderMessage_MessageType `protobuf:"varint,1,opt,name=type,proto3,enum=reqresp.pb.FinderMessage_MessageType" json:"type,omitempty"`
 	// Value for the message
 	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Maximum time in milliseconds to spend handling a streamed request. Zero
+	// means no limit.
+	TimeoutMsec uint64 `protobuf:"varint,3,opt,name=timeout_msec,json=timeoutMsec,proto3" json:"timeout_msec,omitempty"`
 }
 
 func (m *FinderMessage) Reset()         { *m = FinderMessage{} }
@@ -122,6 +137,13 @@ func (m *FinderMessage) GetData() []byte {
 	return nil
 }
 
+func (m *FinderMessage) GetTimeoutMsec() uint64 {
+	if m != nil {
+		return m.TimeoutMsec
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("reqresp.pb.FinderMessage_MessageType", FinderMessage_MessageType_name, FinderMessage_MessageType_value)
 	proto.RegisterType((*FinderMessage)(nil), "reqresp.pb.FinderMessage")
@@ -130,24 +152,28 @@ func init() {
 func init() { proto.RegisterFile("finder.proto", fileDescriptor_02dfec63316bfb34) }
 
 var fileDescriptor_02dfec63316bfb34 = []byte{
-	// 260 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x49, 0xcb, 0xcc, 0x4b,
-	0x49, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x2a, 0x4a, 0x2d, 0x2c, 0x4a, 0x2d,
-	0x2e, 0xd0, 0x2b, 0x48, 0x52, 0x5a, 0xc2, 0xc4, 0xc5, 0xeb, 0x06, 0x96, 0xf4, 0x4d, 0x2d, 0x2e,
-	0x4e, 0x4c, 0x4f, 0x15, 0xb2, 0xe4, 0x62, 0x29, 0xa9, 0x2c, 0x48, 0x95, 0x60, 0x54, 0x60, 0xd4,
-	0xe0, 0x33, 0x52, 0xd5, 0x43, 0x28, 0xd6, 0x43, 0x51, 0xa8, 0x07, 0xa5, 0x43, 0x2a, 0x0b, 0x52,
-	0x83, 0xc0, 0x5a, 0x84, 0x84, 0xb8, 0x58, 0x52, 0x12, 0x4b, 0x12, 0x25, 0x98, 0x14, 0x18, 0x35,
-	0x78, 0x82, 0xc0, 0x6c, 0xa5, 0xc3, 0x8c, 0x5c, 0xdc, 0x48, 0x2a, 0x85, 0x84, 0xb8, 0xf8, 0x5c,
-	0x83, 0x82, 0xfc, 0x83, 0xe2, 0x83, 0x5c, 0x83, 0x03, 0xfc, 0xfd, 0x82, 0x5d, 0x05, 0x18, 0x84,
-	0x38, 0xb8, 0x58, 0xdc, 0x3c, 0xfd, 0x5c, 0x04, 0x18, 0x85, 0x04, 0xb9, 0x78, 0x41, 0x2c, 0x84,
-	0x24, 0x13, 0x48, 0x83, 0x8f, 0x67, 0x70, 0x48, 0x7c, 0x40, 0x90, 0x7f, 0x98, 0xa7, 0x8b, 0x6b,
-	0x50, 0xb0, 0x00, 0xb3, 0x90, 0x34, 0x97, 0x38, 0xaa, 0x18, 0x42, 0x03, 0x8b, 0x90, 0x00, 0x17,
-	0x8f, 0xbb, 0x2b, 0x42, 0x4e, 0x80, 0x55, 0x48, 0x92, 0x4b, 0x14, 0x59, 0x04, 0xa1, 0x98, 0x4d,
-	0x88, 0x97, 0x8b, 0x13, 0x24, 0x15, 0x1c, 0xe2, 0x18, 0x12, 0x2c, 0xc0, 0x2e, 0x24, 0xc6, 0x25,
-	0x04, 0xe7, 0x22, 0x94, 0x71, 0x38, 0x49, 0x9c, 0x78, 0x24, 0xc7, 0x78, 0xe1, 0x91, 0x1c, 0xe3,
-	0x83, 0x47, 0x72, 0x8c, 0x13, 0x1e, 0xcb, 0x31, 0x5c, 0x78, 0x2c, 0xc7, 0x70, 0xe3, 0xb1, 0x1c,
-	0x43, 0x12, 0x1b, 0x38, 0x4c, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xdd, 0x03, 0x37, 0x1d,
-	0x63, 0x01, 0x00, 0x00,
+	// 325 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x91, 0xcd, 0x4e, 0xf2, 0x40,
+	0x14, 0x86, 0x3b, 0xd0, 0x8f, 0x0f, 0x4e, 0x0b, 0x8c, 0xc7, 0xbf, 0x1a, 0x93, 0x09, 0x92, 0x98,
+	0xb0, 0xea, 0x42, 0x57, 0x2e, 0x11, 0x06, 0x43, 0x02, 0x85, 0xcc, 0x34, 0x6e, 0x09, 0x3f, 0xa3,
+	0x61, 0x81, 0xd4, 0xb6, 0x2e, 0xb8, 0x0a, 0x4d, 0xbc, 0x29, 0x97, 0x2c, 0x5d, 0x1a, 0x7a, 0x23,
+	0xa6, 0x0d, 0x71, 0xca, 0x6a, 0xce, 0xbc, 0xcf, 0xf3, 0x4e, 0x26, 0x39, 0x60, 0x3f, 0x2d, 0x5f,
+	0x16, 0x2a, 0x74, 0x83, 0x70, 0x1d, 0xaf, 0x11, 0x42, 0xf5, 0x1a, 0xaa, 0x28, 0x70, 0x83, 0x59,
+	0xf3, 0xb3, 0x08, 0xd5, 0x5e, 0x06, 0x87, 0x2a, 0x8a, 0xa6, 0xcf, 0x0a, 0xef, 0xc0, 0x8c, 0x37,
+	0x81, 0x72, 0x48, 0x83, 0xb4, 0x6a, 0x37, 0xd7, 0xae, 0x96, 0xdd, 0x03, 0xd1, 0xdd, 0x9f, 0xfe,
+	0x26, 0x50, 0x22, 0xab, 0x20, 0x82, 0xb9, 0x98, 0xc6, 0x53, 0xa7, 0xd0, 0x20, 0x2d, 0x5b, 0x64,
+	0x33, 0x5e, 0x81, 0x1d, 0x2f, 0x57, 0x6a, 0xfd, 0x16, 0x4f, 0x56, 0x91, 0x9a, 0x3b, 0xc5, 0x06,
+	0x69, 0x99, 0xc2, 0xda, 0x67, 0xc3, 0x48, 0xcd, 0x9b, 0xef, 0x05, 0xb0, 0x72, 0x8f, 0x21, 0x42,
+	0x8d, 0x0b, 0x31, 0x12, 0x13, 0xc1, 0xe5, 0x78, 0xe4, 0x49, 0x4e, 0x0d, 0x2c, 0x83, 0xd9, 0xeb,
+	0x7b, 0x5d, 0x4a, 0xf0, 0x08, 0xaa, 0xe9, 0xa4, 0x61, 0x21, 0x2d, 0x0c, 0xfa, 0xd2, 0x9f, 0x8c,
+	0xc5, 0xe8, 0xb1, 0xdf, 0xe5, 0x42, 0xd2, 0x22, 0x5e, 0xc2, 0xf9, 0x61, 0xa6, 0x0b, 0x26, 0x52,
+	0xb0, 0x1f, 0xb8, 0x66, 0xf4, 0x1f, 0x5e, 0xc0, 0x69, 0x3e, 0xd1, 0x72, 0x09, 0xab, 0x50, 0x49,
+	0x91, 0xf4, 0xdb, 0xbe, 0xa4, 0xff, 0xf1, 0x0c, 0xf0, 0xef, 0xaa, 0xb5, 0x32, 0xd6, 0xc1, 0xca,
+	0xfe, 0x25, 0x7d, 0xc1, 0xdb, 0x43, 0x5a, 0x41, 0x07, 0x4e, 0x72, 0x81, 0x56, 0x01, 0x8f, 0xa1,
+	0x9e, 0x27, 0xdc, 0xeb, 0x52, 0x0b, 0x01, 0x4a, 0x9d, 0xb6, 0xd7, 0xe1, 0x03, 0x6a, 0xdf, 0x3b,
+	0x5f, 0x3b, 0x46, 0xb6, 0x3b, 0x46, 0x7e, 0x76, 0x8c, 0x7c, 0x24, 0xcc, 0xd8, 0x26, 0xcc, 0xf8,
+	0x4e, 0x98, 0x31, 0x2b, 0x65, 0x2b, 0xbc, 0xfd, 0x0d, 0x00, 0x00, 0xff, 0xff, 0x0c, 0xd0, 0x90,
+	0x81, 0xd2, 0x01, 0x00, 0x00,
 }
 
 func (m *FinderMessage) Marshal() (dAtA []byte, err error) {
@@ -170,6 +196,11 @@ func (m *FinderMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.TimeoutMsec != 0 {
+		i = encodeVarintFinder(dAtA, i, uint64(m.TimeoutMsec))
+		i--
+		dAtA[i] = 0x18
+	}
 	if len(m.Data) > 0 {
 		i -= len(m.Data)
 		copy(dAtA[i:], m.Data)
@@ -209,6 +240,9 @@ func (m *FinderMessage) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFinder(uint64(l))
 	}
+	if m.TimeoutMsec != 0 {
+		n += 1 + sovFinder(uint64(m.TimeoutMsec))
+	}
 	return n
 }
 
@@ -300,6 +334,25 @@ func (m *FinderMessage) Unmarshal(dAtA []byte) error {
 				m.Data = []byte{}
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TimeoutMsec", wireType)
+			}
+			m.TimeoutMsec = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFinder
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TimeoutMsec |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFinder(dAtA[iNdEx:])
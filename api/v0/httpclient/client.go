@@ -31,15 +31,70 @@ func New(baseURL, resource string, options ...Option) (*url.URL, *http.Client, e
 	}
 	u.Path = resource
 
-	if opts.client != nil {
-		return u, opts.client, nil
+	cl := opts.client
+	if cl == nil {
+		cl = &http.Client{
+			Timeout: opts.timeout,
+		}
 	}
-	cl := &http.Client{
-		Timeout: opts.timeout,
+	if opts.bearerToken != "" {
+		// Copy the client so that the token is not added to a client that is
+		// shared with other users.
+		tokenClient := *cl
+		tokenClient.Transport = &bearerTransport{
+			token: opts.bearerToken,
+			base:  cl.Transport,
+		}
+		cl = &tokenClient
+	}
+	if len(opts.headers) != 0 {
+		headerClient := *cl
+		headerClient.Transport = &headerTransport{
+			headers: opts.headers,
+			base:    cl.Transport,
+		}
+		cl = &headerClient
 	}
 	return u, cl, nil
 }
 
+// bearerTransport is an http.RoundTripper that adds a bearer token to each
+// request.
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// RoundTrip must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return base.RoundTrip(req)
+}
+
+// headerTransport is an http.RoundTripper that adds headers to each request.
+type headerTransport struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// RoundTrip must not modify the request.
+	req = req.Clone(req.Context())
+	for key, values := range t.headers {
+		req.Header[key] = values
+	}
+	return base.RoundTrip(req)
+}
+
 func ReadErrorFrom(status int, r io.Reader) error {
 	body, err := io.ReadAll(r)
 	if err != nil {
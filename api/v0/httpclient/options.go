@@ -9,8 +9,10 @@ import (
 const defaultTimeout = time.Minute
 
 type config struct {
-	timeout time.Duration
-	client  *http.Client
+	timeout     time.Duration
+	client      *http.Client
+	bearerToken string
+	headers     http.Header
 }
 
 // Option is a function that sets a value in a config.
@@ -45,3 +47,23 @@ func WithClient(c *http.Client) Option {
 		return nil
 	}
 }
+
+// WithBearerToken configures a token that is sent in the Authorization header
+// of every request.
+func WithBearerToken(token string) Option {
+	return func(cfg *config) error {
+		cfg.bearerToken = token
+		return nil
+	}
+}
+
+// WithHeader configures a header that is sent with every request.
+func WithHeader(key, value string) Option {
+	return func(cfg *config) error {
+		if cfg.headers == nil {
+			cfg.headers = make(http.Header)
+		}
+		cfg.headers.Set(key, value)
+		return nil
+	}
+}
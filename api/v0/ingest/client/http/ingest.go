@@ -13,6 +13,8 @@ import (
 	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
@@ -97,6 +99,7 @@ func (c *Client) Announce(ctx context.Context, provider *peer.AddrInfo, root cid
 		return err
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	resp, err := c.c.Do(req)
 	if err != nil {
@@ -22,13 +22,14 @@ import (
 // from libp2p peers.  Each instance of Client communicates with a single peer
 // using a single protocolID.
 type Client struct {
-	ctxLock ctxMutex
-	host    host.Host
-	ownHost bool
-	peerID  peer.ID
-	protoID protocol.ID
-	r       msgio.ReadCloser
-	stream  network.Stream
+	ctxLock     ctxMutex
+	host        host.Host
+	ownHost     bool
+	peerID      peer.ID
+	protoID     protocol.ID
+	r           msgio.ReadCloser
+	stream      network.Stream
+	readTimeout time.Duration
 }
 
 // DecodeResponseFunc is a function that is passed into this generic libp2p
@@ -37,12 +38,13 @@ type Client struct {
 // only know to a specific libp2p client using this generic client.
 type DecodeResponseFunc func([]byte) error
 
-const (
-	// default port for libp2p client to connect to
-	defaultLibp2pPort = 3003
-	// Timeout to wait for a response after a request is sent
-	readMessageTimeout = 10 * time.Second
-)
+// DecodeStreamFunc is a function that is passed into this generic libp2p
+// Client to decode each message of a streamed response. It returns true if the
+// message ends the stream.
+type DecodeStreamFunc func([]byte) (bool, error)
+
+// default port for libp2p client to connect to
+const defaultLibp2pPort = 3003
 
 // ErrReadTimeout is an error that occurs when no message is read within the
 // timeout period
@@ -50,11 +52,15 @@ var ErrReadTimeout = fmt.Errorf("timed out reading response")
 
 // New creates a new libp2pclient Client that communicates with a specific peer identified by
 // protocolID.  If host is nil, then one is created.
-func New(p2pHost host.Host, peerID peer.ID, protoID protocol.ID) (*Client, error) {
+func New(p2pHost host.Host, peerID peer.ID, protoID protocol.ID, options ...Option) (*Client, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+
 	// If no host was given, create one.
 	var ownHost bool
 	if p2pHost == nil {
-		var err error
 		p2pHost, err = libp2p.New()
 		if err != nil {
 			return nil, err
@@ -64,11 +70,12 @@ func New(p2pHost host.Host, peerID peer.ID, protoID protocol.ID) (*Client, error
 
 	// Start a client
 	return &Client{
-		ctxLock: newCtxMutex(),
-		host:    p2pHost,
-		ownHost: ownHost,
-		peerID:  peerID,
-		protoID: protoID,
+		ctxLock:     newCtxMutex(),
+		host:        p2pHost,
+		ownHost:     ownHost,
+		peerID:      peerID,
+		protoID:     protoID,
+		readTimeout: opts.readTimeout,
 	}, nil
 }
 
@@ -169,6 +176,166 @@ func (c *Client) SendRequest(ctx context.Context, msg proto.Message, decodeRsp D
 	return nil
 }
 
+// SendStreamRequest sends out a request that has a streamed response.
+// decodeRsp is called for each message received, until it returns true to
+// indicate the end of the stream.
+//
+// If ctx is canceled before the end of the stream, then cancelMsg is sent to
+// ask the peer to end the stream, and the remaining messages are read, and
+// still passed to decodeRsp, until the end of the stream. This leaves the
+// stream usable for other requests. If cancelMsg is nil, or the peer does not
+// end the stream, then the stream is reset.
+func (c *Client) SendStreamRequest(ctx context.Context, msg, cancelMsg proto.Message, decodeRsp DecodeStreamFunc) error {
+	err := c.ctxLock.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.ctxLock.Unlock()
+
+	err = c.sendMessage(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("cannot sent request: %w", err)
+	}
+
+	timer := newReadTimer(c.readTimeout)
+	defer timer.stop()
+
+	var pending <-chan readResult
+	for {
+		if pending == nil {
+			pending = c.readMsgAsync()
+		}
+		var data []byte
+		select {
+		case res := <-pending:
+			pending = nil
+			if res.err != nil {
+				c.closeStream()
+				return fmt.Errorf("cannot read response: %w", res.err)
+			}
+			data = res.data
+		case <-ctx.Done():
+			c.cancelStream(cancelMsg, pending, decodeRsp, timer)
+			return ctx.Err()
+		case <-timer.c:
+			c.closeStream()
+			return fmt.Errorf("cannot read response: %w", ErrReadTimeout)
+		}
+		timer.reset()
+
+		done, err := decodeRsp(data)
+		if err != nil {
+			c.closeStream()
+			return fmt.Errorf("cannot decode response: %w", err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// cancelStream asks the peer to end a streamed response, and discards the
+// remaining messages. The stream is reset if the peer does not end the stream.
+func (c *Client) cancelStream(cancelMsg proto.Message, pending <-chan readResult, decodeRsp DecodeStreamFunc, timer *readTimer) {
+	if cancelMsg == nil {
+		c.closeStream()
+		return
+	}
+	if err := writeMsg(c.stream, cancelMsg); err != nil {
+		c.closeStream()
+		return
+	}
+	timer.reset()
+	for {
+		if pending == nil {
+			pending = c.readMsgAsync()
+		}
+		select {
+		case res := <-pending:
+			pending = nil
+			if res.err != nil {
+				c.closeStream()
+				return
+			}
+			done, err := decodeRsp(res.data)
+			if err != nil {
+				c.closeStream()
+				return
+			}
+			if done {
+				return
+			}
+		case <-timer.c:
+			c.closeStream()
+			return
+		}
+		timer.reset()
+	}
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// readMsgAsync reads the next message from the stream in a separate goroutine,
+// and returns a channel that receives the result.
+func (c *Client) readMsgAsync() <-chan readResult {
+	resChan := make(chan readResult, 1)
+	go func(r msgio.ReadCloser) {
+		data, err := r.ReadMsg()
+		if err != nil {
+			r.ReleaseMsg(data)
+			resChan <- readResult{err: err}
+			return
+		}
+		msg := make([]byte, len(data))
+		copy(msg, data)
+		r.ReleaseMsg(data)
+		resChan <- readResult{data: msg}
+	}(c.r)
+	return resChan
+}
+
+// readTimer signals c when no message is read within the read timeout. If
+// there is no read timeout, then c is nil.
+type readTimer struct {
+	c       <-chan time.Time
+	t       *time.Timer
+	timeout time.Duration
+}
+
+func newReadTimer(timeout time.Duration) *readTimer {
+	if timeout == 0 {
+		return &readTimer{}
+	}
+	t := time.NewTimer(timeout)
+	return &readTimer{
+		c:       t.C,
+		t:       t,
+		timeout: timeout,
+	}
+}
+
+func (rt *readTimer) reset() {
+	if rt.t == nil {
+		return
+	}
+	if !rt.t.Stop() {
+		select {
+		case <-rt.t.C:
+		default:
+		}
+	}
+	rt.t.Reset(rt.timeout)
+}
+
+func (rt *readTimer) stop() {
+	if rt.t != nil {
+		rt.t.Stop()
+	}
+}
+
 // SendMessage sends out a message
 func (c *Client) SendMessage(ctx context.Context, msg proto.Message) error {
 	err := c.ctxLock.Lock(ctx)
@@ -228,14 +395,18 @@ func (c *Client) ctxReadMsg(ctx context.Context, decodeRsp DecodeResponseFunc) e
 		err = decodeRsp(data)
 	}(c.r)
 
-	t := time.NewTimer(readMessageTimeout)
-	defer t.Stop()
+	var timeout <-chan time.Time
+	if c.readTimeout != 0 {
+		t := time.NewTimer(c.readTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
 
 	select {
 	case <-done:
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-t.C:
+	case <-timeout:
 		return ErrReadTimeout
 	}
 
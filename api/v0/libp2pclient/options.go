@@ -0,0 +1,43 @@
+package libp2pclient
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultReadTimeout is how long to wait for a response after a request is
+// sent, and between messages of a streamed response.
+const defaultReadTimeout = 10 * time.Second
+
+type config struct {
+	readTimeout time.Duration
+}
+
+// Option is a function that sets a value in a config.
+type Option func(*config) error
+
+// getOpts creates a config and applies Options to it.
+func getOpts(opts []Option) (config, error) {
+	cfg := config{
+		readTimeout: defaultReadTimeout,
+	}
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return config{}, fmt.Errorf("option %d failed: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithReadTimeout configures how long to wait for a response message after
+// sending a request, and how long to wait for each message of a streamed
+// response. A value of zero means wait until the request context is done.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(cfg *config) error {
+		if timeout < 0 {
+			return fmt.Errorf("read timeout cannot be negative")
+		}
+		cfg.readTimeout = timeout
+		return nil
+	}
+}
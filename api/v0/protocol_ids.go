@@ -3,6 +3,8 @@ package v0
 import "github.com/libp2p/go-libp2p/core/protocol"
 
 const (
+	// AdminProtocolID is the libp2p protocol that admin API uses
+	AdminProtocolID protocol.ID = "/indexer/admin/0.0.1"
 	// FinderProtocolID is the libp2p protocol that finder API uses
 	FinderProtocolID protocol.ID = "/indexer/finder/0.0.1"
 	// IngestProtocolID is the libp2p protocol that ingest API uses
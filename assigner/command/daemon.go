@@ -1,6 +1,7 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"github.com/ipni/storetheindex/assigner/core"
 	server "github.com/ipni/storetheindex/assigner/server"
 	sticfg "github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/tracing"
 	"github.com/ipni/storetheindex/mautil"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -69,6 +71,16 @@ func daemonAction(cctx *cli.Context) error {
 		return err
 	}
 
+	stopTracing, err := tracing.Start(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stopTracing(context.Background()); err != nil {
+			log.Errorw("Error stopping tracing", "err", err)
+		}
+	}()
+
 	if cfg.Version != config.Version {
 		log.Warnf("Configuration file out-of-date. Upgrade by running: ./%s init --upgrade", progName)
 	}
@@ -34,6 +34,10 @@ type Assignment struct {
 type Indexer struct {
 	// AdminURL is the base URL for the indexer's admin interface.
 	AdminURL string
+	// AdminToken is the bearer token sent to the indexer's admin interface,
+	// when the indexer requires one. The token's role must be "superuser" to
+	// allow handoff of publishers from frozen indexers.
+	AdminToken string
 	// FindURL is the base URL for the indexer's find interface.
 	FindURL string
 	// IngestURL is the base URL for the indexer's ingest interface.
@@ -19,6 +19,7 @@ type Config struct {
 	Daemon     Daemon           // daemon settings.
 	Logging    Logging          // logging configuration.,
 	Peering    sticfg.Peering   // peering service configuration.
+	Tracing    sticfg.Tracing   // tracing configuration.
 }
 
 const (
@@ -98,6 +99,7 @@ func Load(filePath string) (*Config, error) {
 		Daemon:     NewDaemon(),
 		Logging:    NewLogging(),
 		Peering:    sticfg.NewPeering(),
+		Tracing:    NewTracing(),
 	}
 
 	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
@@ -23,6 +23,7 @@ func InitWithIdentity(identity sticfg.Identity) (*Config, error) {
 		Daemon:     NewDaemon(),
 		Identity:   identity,
 		Logging:    NewLogging(),
+		Tracing:    NewTracing(),
 	}
 
 	return conf, nil
@@ -0,0 +1,11 @@
+package config
+
+import sticfg "github.com/ipni/storetheindex/config"
+
+// NewTracing returns the indexer Tracing configuration with the service name
+// set for the assigner.
+func NewTracing() sticfg.Tracing {
+	tracing := sticfg.NewTracing()
+	tracing.ServiceName = "assigner"
+	return tracing
+}
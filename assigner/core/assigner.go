@@ -22,10 +22,16 @@ import (
 	"github.com/ipni/storetheindex/peerutil"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var log = logging.Logger("assigner/core")
 
+var tracer = otel.Tracer("github.com/ipni/storetheindex/assigner/core")
+
 const pollFrozenTimeout = 2 * time.Minute
 
 // Assigner is responsible for assigning publishers to indexers.
@@ -103,9 +109,10 @@ func (asmt *assignment) hasIndexer(x int) bool {
 
 // indexerInfo describes an indexer in the indexer pool.
 type indexerInfo struct {
-	adminURL  string
-	findURL   string
-	ingestURL string
+	adminURL   string
+	adminToken string
+	findURL    string
+	ingestURL  string
 
 	assigned    int32
 	frozen      bool
@@ -358,8 +365,7 @@ func (a *Assigner) initAssignments(ctx context.Context) int {
 }
 
 func (a *Assigner) handoffPublisher(ctx context.Context, publisher peer.ID, fromIndexer, toIndexer int) error {
-	toURL := a.indexerPool[toIndexer].adminURL
-	cl, err := adminclient.New(toURL, httpclient.WithClient(a.httpClient))
+	cl, err := a.adminClient(toIndexer)
 	if err != nil {
 		return err
 	}
@@ -369,6 +375,17 @@ func (a *Assigner) handoffPublisher(ctx context.Context, publisher peer.ID, from
 	return cl.Handoff(ctx, publisher, fromID, fromURL)
 }
 
+// adminClient returns a client for the admin interface of an indexer in the
+// pool.
+func (a *Assigner) adminClient(indexerNum int) (*adminclient.Client, error) {
+	indexer := a.indexerPool[indexerNum]
+	opts := []httpclient.Option{httpclient.WithClient(a.httpClient)}
+	if indexer.adminToken != "" {
+		opts = append(opts, httpclient.WithBearerToken(indexer.adminToken))
+	}
+	return adminclient.New(indexer.adminURL, opts...)
+}
+
 // indexersFromConfig reads the indexer pool config to create the indexer pool.
 func indexersFromConfig(cfgIndexerPool []config.Indexer) ([]indexerInfo, map[peer.ID][]int, error) {
 	seen := make(map[string]struct{}, len(cfgIndexerPool))
@@ -383,6 +400,7 @@ func indexersFromConfig(cfgIndexerPool []config.Indexer) ([]indexerInfo, map[pee
 		if err != nil {
 			return nil, nil, err
 		}
+		iInfo.adminToken = cfgIndexerPool[i].AdminToken
 		iInfo.findURL, err = configURL(cfgIndexerPool[i].FindURL, "find", i, seen)
 		if err != nil {
 			return nil, nil, err
@@ -661,6 +679,12 @@ func (a *Assigner) checkAssignment(pubID peer.ID) (*assignment, int) {
 func (a *Assigner) makeAssignments(ctx context.Context, amsg announce.Announce, asmt *assignment, need int) {
 	log := log.With("publisher", amsg.PeerID)
 
+	ctx, span := tracer.Start(trace.ContextWithSpanContext(ctx, amsg.SpanContext), "Assigner.makeAssignments",
+		trace.WithAttributes(
+			attribute.String("publisher", amsg.PeerID.String()),
+			attribute.Int("need", need)))
+	defer span.End()
+
 	var candidates []int
 	var required int
 
@@ -766,8 +790,7 @@ func (a *Assigner) pollFrozen(ctx context.Context) {
 }
 
 func (a *Assigner) checkFrozen(ctx context.Context, indexerNum int) (bool, error) {
-	adminURL := a.indexerPool[indexerNum].adminURL
-	cl, err := adminclient.New(adminURL, httpclient.WithClient(a.httpClient))
+	cl, err := a.adminClient(indexerNum)
 	if err != nil {
 		return false, fmt.Errorf("cannot create admin client: %w", err)
 	}
@@ -922,10 +945,20 @@ func (a *Assigner) orderCandidates(indexers []int, preferred []int) {
 	sort.Sort(&iSlice)
 }
 
-func (a *Assigner) assignIndexer(ctx context.Context, indexerNum int, amsg announce.Announce) error {
+func (a *Assigner) assignIndexer(ctx context.Context, indexerNum int, amsg announce.Announce) (err error) {
 	indexer := a.indexerPool[indexerNum]
 
-	cl, err := adminclient.New(indexer.adminURL, httpclient.WithClient(a.httpClient))
+	ctx, span := tracer.Start(ctx, "Assigner.assignIndexer", trace.WithAttributes(
+		attribute.Int("indexer", indexerNum),
+		attribute.String("adminURL", indexer.adminURL)))
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	cl, err := a.adminClient(indexerNum)
 	if err != nil {
 		return err
 	}
@@ -951,14 +984,13 @@ func (a *Assigner) assignIndexer(ctx context.Context, indexerNum int, amsg annou
 	}
 	if err = icl.Announce(ctx, &pubInfo, amsg.Cid); err != nil {
 		log.Errorw("Error sending announce message", "err", err)
+		span.RecordError(err)
 	}
 	return nil
 }
 
 func (a *Assigner) getAssignments(ctx context.Context, indexerNum int) (peer.ID, bool, map[peer.ID]peer.ID, []peer.ID, error) {
-	adminURL := a.indexerPool[indexerNum].adminURL
-
-	cl, err := adminclient.New(adminURL, httpclient.WithClient(a.httpClient))
+	cl, err := a.adminClient(indexerNum)
 	if err != nil {
 		return peer.ID(""), false, nil, nil, fmt.Errorf("cannot create admin client: %w", err)
 	}
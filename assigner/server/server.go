@@ -10,6 +10,8 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipni/storetheindex/announce/message"
 	"github.com/ipni/storetheindex/assigner/core"
+	"github.com/ipni/storetheindex/internal/httpserver"
+	"github.com/ipni/storetheindex/internal/tracing"
 	"github.com/ipni/storetheindex/version"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -41,7 +43,7 @@ func New(listen string, assigner *core.Assigner, options ...Option) (*Server, er
 
 	mux := http.NewServeMux()
 	server := &http.Server{
-		Handler:      mux,
+		Handler:      httpserver.TraceContext(mux),
 		WriteTimeout: opts.writeTimeout,
 		ReadTimeout:  opts.readTimeout,
 	}
@@ -115,8 +117,9 @@ func (s *Server) announce(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use background context because this will be an async process. We don't
-	// want to attach the context to the request context that started this.
-	err = s.assigner.Announce(context.Background(), an.Cid, addrInfo)
+	// want to attach the context to the request context that started this,
+	// but do keep the request's trace.
+	err = s.assigner.Announce(tracing.Detach(r.Context()), an.Cid, addrInfo)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -29,7 +29,8 @@ func TestRead(t *testing.T) {
 
 	fileStore, err := filestore.New(cfg)
 	require.NoError(t, err)
-	carw := carstore.NewWriter(dstore, fileStore)
+	carw, err := carstore.NewWriter(dstore, fileStore)
+	require.NoError(t, err)
 
 	adLink, ad, _, _, _ := storeRandomIndexAndAd(t, entBlockCount, metadata, nil, dstore)
 	adCid := adLink.(cidlink.Link).Cid
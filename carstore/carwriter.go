@@ -48,15 +48,21 @@ func (e WriteError) Unwrap() error {
 type CarWriter struct {
 	dstore    datastore.Datastore
 	fileStore filestore.Interface
+	keepData  bool
 }
 
 // NewWriter create a new CarWriter that reads advertisement data from the
 // given datastore and writes car files to the specified directory.
-func NewWriter(dstore datastore.Datastore, fileStore filestore.Interface) *CarWriter {
+func NewWriter(dstore datastore.Datastore, fileStore filestore.Interface, options ...Option) (*CarWriter, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
 	return &CarWriter{
 		dstore:    dstore,
 		fileStore: fileStore,
-	}
+		keepData:  opts.keepData,
+	}, nil
 }
 
 // Write reads the advertisement, specified by CID, from the datastore and
@@ -276,6 +282,9 @@ func (cw *CarWriter) WriteHead(ctx context.Context, adCid cid.Cid, publisher pee
 }
 
 func (cw *CarWriter) deleteCids(delCids []cid.Cid) {
+	if cw.keepData {
+		return
+	}
 	for i := len(delCids) - 1; i >= 0; i-- {
 		err := cw.dstore.Delete(context.Background(), datastore.NewKey(delCids[i].String()))
 		if err != nil {
@@ -49,7 +49,8 @@ func TestWrite(t *testing.T) {
 
 	fileStore, err := filestore.New(cfg)
 	require.NoError(t, err)
-	carw := carstore.NewWriter(dstore, fileStore)
+	carw, err := carstore.NewWriter(dstore, fileStore)
+	require.NoError(t, err)
 
 	adLink, ad, _, _, _ := storeRandomIndexAndAd(t, entBlockCount, metadata, nil, dstore)
 	adCid := adLink.(cidlink.Link).Cid
@@ -138,6 +139,40 @@ func TestWrite(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestWriteKeepData(t *testing.T) {
+	const entBlockCount = 3
+
+	dstore := datastore.NewMapDatastore()
+	metadata := []byte("car-test-metadata")
+
+	fileStore, err := filestore.New(config.FileStore{
+		Type: "local",
+		Local: config.LocalFileStore{
+			BasePath: t.TempDir(),
+		},
+	})
+	require.NoError(t, err)
+	carw, err := carstore.NewWriter(dstore, fileStore, carstore.WithKeepData(true))
+	require.NoError(t, err)
+
+	adLink, ad, _, _, _ := storeRandomIndexAndAd(t, entBlockCount, metadata, nil, dstore)
+	adCid := adLink.(cidlink.Link).Cid
+	entriesCid := ad.Entries.(cidlink.Link).Cid
+
+	ctx := context.Background()
+	carInfo, err := carw.Write(ctx, adCid, false)
+	require.NoError(t, err)
+	require.NotNil(t, carInfo)
+
+	// Check that ad and entries block are still in datastore.
+	ok, err := dstore.Has(ctx, datastore.NewKey(adCid.String()))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = dstore.Has(ctx, datastore.NewKey(entriesCid.String()))
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
 func TestWriteToExistingAdCar(t *testing.T) {
 	const entBlockCount = 1
 
@@ -171,7 +206,8 @@ func TestWriteToExistingAdCar(t *testing.T) {
 	_, err = fileStore.Put(ctx, fileName, nil)
 	require.NoError(t, err)
 
-	carw := carstore.NewWriter(dstore, fileStore)
+	carw, err := carstore.NewWriter(dstore, fileStore)
+	require.NoError(t, err)
 
 	carInfo, err := carw.Write(ctx, adCid, false)
 	require.NoError(t, err)
@@ -206,7 +242,8 @@ func TestWriteChain(t *testing.T) {
 
 	fileStore, err := filestore.New(cfg)
 	require.NoError(t, err)
-	carw := carstore.NewWriter(dstore, fileStore)
+	carw, err := carstore.NewWriter(dstore, fileStore)
+	require.NoError(t, err)
 
 	adLink1, _, _, _, _ := storeRandomIndexAndAd(t, entBlockCount, metadata, nil, dstore)
 	adLink2, _, _, _, _ := storeRandomIndexAndAd(t, entBlockCount, metadata, adLink1, dstore)
@@ -258,7 +295,8 @@ func TestWriteExistingAdsInStore(t *testing.T) {
 	fileStore, err := filestore.New(cfg)
 	require.NoError(t, err)
 
-	carw := carstore.NewWriter(dstore, fileStore)
+	carw, err := carstore.NewWriter(dstore, fileStore)
+	require.NoError(t, err)
 
 	countChan := carw.WriteExisting(ctx)
 	n := <-countChan
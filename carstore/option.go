@@ -0,0 +1,35 @@
+package carstore
+
+import (
+	"fmt"
+)
+
+// config contains all options for the CarWriter.
+type config struct {
+	keepData bool
+}
+
+// Option is a function that sets a value in a config.
+type Option func(*config) error
+
+// getOpts creates a config and applies Options to it.
+func getOpts(opts []Option) (config, error) {
+	var cfg config
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return config{}, fmt.Errorf("option %d error: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithKeepData configures the CarWriter to leave advertisement and entries
+// data in the datastore after writing it to a CAR file. This is needed when
+// the data in the datastore is also served to others, such as when the
+// indexer publishes ingested advertisements for replication.
+func WithKeepData(keep bool) Option {
+	return func(c *config) error {
+		c.keepData = keep
+		return nil
+	}
+}
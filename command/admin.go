@@ -1,10 +1,20 @@
 package command
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"time"
 
+	"github.com/ipni/storetheindex/api/v0/admin/client"
 	httpclient "github.com/ipni/storetheindex/api/v0/admin/client/http"
+	p2pclient "github.com/ipni/storetheindex/api/v0/admin/client/libp2p"
+	"github.com/ipni/storetheindex/api/v0/admin/model"
+	apihttp "github.com/ipni/storetheindex/api/v0/httpclient"
+	"github.com/ipni/storetheindex/config"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
@@ -16,17 +26,42 @@ var AdminCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		allowCmd,
 		blockCmd,
+		drainCmd,
 		freezeIndexerCmd,
 		importProvidersCmd,
 		listAssignedCmd,
 		listPreferredCmd,
+		pauseCmd,
+		recountCmd,
 		reloadCmd,
+		resumeCmd,
+		snapshotCmd,
 		statusCmd,
 		syncCmd,
 		unassignCmd,
+		undrainCmd,
 	},
 }
 
+var adminProtocolFlag = &cli.StringFlag{
+	Name:  "protocol",
+	Usage: "Protocol to use for admin requests (http, libp2p)",
+	Value: "http",
+}
+
+var adminIndexerIDFlag = &cli.StringFlag{
+	Name:    "indexerid",
+	Usage:   "Indexer peer ID to use when protocol=libp2p. Defaults to the peer ID in the local config",
+	Aliases: []string{"iid"},
+	EnvVars: []string{"INDEXER_ID"},
+}
+
+var adminKeyFileFlag = &cli.StringFlag{
+	Name:    "keyfile",
+	Usage:   "File containing the private key of an authorized admin peer, required when protocol=libp2p",
+	EnvVars: []string{"INDEXER_ADMIN_KEY_PATH"},
+}
+
 var syncCmd = &cli.Command{
 	Name:   "sync",
 	Usage:  "Sync indexer with provider",
@@ -36,6 +71,10 @@ var syncCmd = &cli.Command{
 
 var adminSyncFlags = []cli.Flag{
 	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
 	&cli.StringFlag{
 		Name:     "pubid",
 		Usage:    "Publisher peer ID",
@@ -79,6 +118,10 @@ var adminPolicyFlags = []cli.Flag{
 		Required: true,
 	},
 	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
 }
 
 var freezeIndexerCmd = &cli.Command{
@@ -86,11 +129,53 @@ var freezeIndexerCmd = &cli.Command{
 	Usage: "Put indexer into frozen mode",
 	Flags: []cli.Flag{
 		indexerHostFlag,
+		adminTokenFlag,
+		adminProtocolFlag,
+		adminIndexerIDFlag,
+		adminKeyFileFlag,
 	},
 
 	Action: freezeAction,
 }
 
+var drainCmd = &cli.Command{
+	Name:  "drain",
+	Usage: "Stop indexer from taking new ingest work, for planned maintenance",
+	Flags: []cli.Flag{
+		indexerHostFlag,
+		adminTokenFlag,
+	},
+	Action: drainAction,
+}
+
+var undrainCmd = &cli.Command{
+	Name:  "undrain",
+	Usage: "Resume taking new ingest work after drain",
+	Flags: []cli.Flag{
+		indexerHostFlag,
+		adminTokenFlag,
+	},
+	Action: undrainAction,
+}
+
+var snapshotCmd = &cli.Command{
+	Name:  "snapshot",
+	Usage: "Write a snapshot of the indexer's state to the configured snapshot destination",
+	Flags: []cli.Flag{
+		indexerHostFlag,
+		adminTokenFlag,
+		&cli.BoolFlag{
+			Name:  "status",
+			Usage: "Show the status of the latest snapshot instead of starting a snapshot",
+		},
+		&cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for the snapshot to finish",
+		},
+	},
+	Action: snapshotAction,
+}
+
 var importProvidersCmd = &cli.Command{
 	Name:   "import-providers",
 	Usage:  "Import provider information from another indexer",
@@ -106,6 +191,10 @@ var importProvidersFlags = []cli.Flag{
 		Required: true,
 	},
 	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
 }
 
 var listAssignedCmd = &cli.Command{
@@ -113,6 +202,10 @@ var listAssignedCmd = &cli.Command{
 	Usage: "List assigned peers when configured to work with assigner service",
 	Flags: []cli.Flag{
 		indexerHostFlag,
+		adminTokenFlag,
+		adminProtocolFlag,
+		adminIndexerIDFlag,
+		adminKeyFileFlag,
 	},
 	Action: listAssignedAction,
 }
@@ -122,10 +215,71 @@ var listPreferredCmd = &cli.Command{
 	Usage: "List unassigned peers that indexer has retrieved content from",
 	Flags: []cli.Flag{
 		indexerHostFlag,
+		adminTokenFlag,
+		adminProtocolFlag,
+		adminIndexerIDFlag,
+		adminKeyFileFlag,
 	},
 	Action: listPreferredAction,
 }
 
+var pauseCmd = &cli.Command{
+	Name:  "pause",
+	Usage: "Pause ingestion from a publisher",
+	Description: "Stops syncing advertisements from the publisher. Announces from the" +
+		" publisher are recorded, and the publisher's existing index continues to be" +
+		" served. The publisher stays paused if the indexer restarts.",
+	Flags:  adminPublisherFlags,
+	Action: pauseAction,
+}
+
+var resumeCmd = &cli.Command{
+	Name:  "resume",
+	Usage: "Resume ingestion from a paused publisher",
+	Description: "Resumes syncing advertisements from the publisher, starting with" +
+		" the latest advertisement announced while the publisher was paused.",
+	Flags:  adminPublisherFlags,
+	Action: resumeAction,
+}
+
+var adminPublisherFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "publisher",
+		Usage:    "Peer ID of publisher to pause or resume",
+		Aliases:  []string{"p"},
+		Required: true,
+	},
+	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
+}
+
+var recountCmd = &cli.Command{
+	Name:  "recount",
+	Usage: "Recompute the index counts for a provider",
+	Description: "Recomputes a provider's index counts by syncing the provider's" +
+		" advertisement chain and counting the multihashes in each advertisement's" +
+		" entries. The recount happens in the background on the indexer.",
+	Flags:  recountFlags,
+	Action: recountAction,
+}
+
+var recountFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "provider",
+		Usage:    "Provider ID to recount indexes for",
+		Aliases:  []string{"p"},
+		Required: true,
+	},
+	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
+}
+
 var reloadCmd = &cli.Command{
 	Name:  "reload-config",
 	Usage: "Reload various settings from the configuration file",
@@ -140,6 +294,10 @@ var reloadCmd = &cli.Command{
 		" Peering",
 	Flags: []cli.Flag{
 		indexerHostFlag,
+		adminTokenFlag,
+		adminProtocolFlag,
+		adminIndexerIDFlag,
+		adminKeyFileFlag,
 	},
 	Action: reloadConfigAction,
 }
@@ -149,6 +307,10 @@ var statusCmd = &cli.Command{
 	Usage: "Show indexer status",
 	Flags: []cli.Flag{
 		indexerHostFlag,
+		adminTokenFlag,
+		adminProtocolFlag,
+		adminIndexerIDFlag,
+		adminKeyFileFlag,
 	},
 	Action: statusAction,
 }
@@ -168,13 +330,18 @@ var unassignFlags = []cli.Flag{
 		Required: true,
 	},
 	indexerHostFlag,
+	adminTokenFlag,
+	adminProtocolFlag,
+	adminIndexerIDFlag,
+	adminKeyFileFlag,
 }
 
 func syncAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	peerID, err := peer.Decode(cctx.String("pubid"))
 	if err != nil {
 		return err
@@ -196,10 +363,11 @@ func syncAction(cctx *cli.Context) error {
 }
 
 func allowAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	peerID, err := peer.Decode(cctx.String("peer"))
 	if err != nil {
 		return err
@@ -213,10 +381,11 @@ func allowAction(cctx *cli.Context) error {
 }
 
 func listAssignedAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	assigned, err := cl.ListAssignedPeers(cctx.Context)
 	if err != nil {
 		return err
@@ -232,10 +401,11 @@ func listAssignedAction(cctx *cli.Context) error {
 }
 
 func listPreferredAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	assigned, err := cl.ListPreferredPeers(cctx.Context)
 	if err != nil {
 		return err
@@ -247,10 +417,11 @@ func listPreferredAction(cctx *cli.Context) error {
 }
 
 func blockAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	peerID, err := peer.Decode(cctx.String("peer"))
 	if err != nil {
 		return err
@@ -264,10 +435,11 @@ func blockAction(cctx *cli.Context) error {
 }
 
 func freezeAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	if err = cl.Freeze(cctx.Context); err != nil {
 		return err
 	}
@@ -275,16 +447,84 @@ func freezeAction(cctx *cli.Context) error {
 	return nil
 }
 
+func drainAction(cctx *cli.Context) error {
+	cl, err := adminHTTPClient(cctx)
+	if err != nil {
+		return err
+	}
+	if err = cl.Drain(cctx.Context); err != nil {
+		return err
+	}
+	fmt.Println("Indexer draining. Run status to see when ingestion in progress has finished.")
+	return nil
+}
+
+func undrainAction(cctx *cli.Context) error {
+	cl, err := adminHTTPClient(cctx)
+	if err != nil {
+		return err
+	}
+	if err = cl.Undrain(cctx.Context); err != nil {
+		return err
+	}
+	fmt.Println("Indexer resumed ingest")
+	return nil
+}
+
+func snapshotAction(cctx *cli.Context) error {
+	cl, err := adminHTTPClient(cctx)
+	if err != nil {
+		return err
+	}
+
+	var st *model.SnapshotStatus
+	if cctx.Bool("status") {
+		st, err = cl.SnapshotStatus(cctx.Context)
+	} else {
+		st, err = cl.Snapshot(cctx.Context)
+		if err == nil {
+			fmt.Println("Started snapshot", st.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if cctx.Bool("wait") {
+		for st.Finished.IsZero() {
+			select {
+			case <-cctx.Context.Done():
+				return cctx.Context.Err()
+			case <-time.After(5 * time.Second):
+			}
+			if st, err = cl.SnapshotStatus(cctx.Context); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case st.Finished.IsZero():
+		fmt.Println("Snapshot", st.Name, "in progress, started", st.Started.Format(time.RFC3339))
+	case st.Error != "":
+		return fmt.Errorf("snapshot %s failed: %s", st.Name, st.Error)
+	default:
+		fmt.Println("Snapshot", st.Name, "finished in", st.Finished.Sub(st.Started).Round(time.Second))
+	}
+	return nil
+}
+
 func importProvidersAction(cctx *cli.Context) error {
 	fromURL := &url.URL{
 		Scheme: "http",
 		Host:   cctx.String("from"),
 		Path:   "/providers",
 	}
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	err = cl.ImportProviders(cctx.Context, fromURL)
 	if err != nil {
 		return err
@@ -293,11 +533,66 @@ func importProvidersAction(cctx *cli.Context) error {
 	return nil
 }
 
+func pauseAction(cctx *cli.Context) error {
+	cl, closeCl, err := adminClient(cctx)
+	if err != nil {
+		return err
+	}
+	defer closeCl()
+	publisherID, err := peer.Decode(cctx.String("publisher"))
+	if err != nil {
+		return err
+	}
+	err = cl.Pause(cctx.Context, publisherID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Paused ingestion from publisher", publisherID)
+	return nil
+}
+
+func resumeAction(cctx *cli.Context) error {
+	cl, closeCl, err := adminClient(cctx)
+	if err != nil {
+		return err
+	}
+	defer closeCl()
+	publisherID, err := peer.Decode(cctx.String("publisher"))
+	if err != nil {
+		return err
+	}
+	err = cl.Resume(cctx.Context, publisherID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Resumed ingestion from publisher", publisherID)
+	return nil
+}
+
+func recountAction(cctx *cli.Context) error {
+	cl, closeCl, err := adminClient(cctx)
+	if err != nil {
+		return err
+	}
+	defer closeCl()
+	providerID, err := peer.Decode(cctx.String("provider"))
+	if err != nil {
+		return err
+	}
+	err = cl.Recount(cctx.Context, providerID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Recount request accepted for provider", providerID)
+	return nil
+}
+
 func reloadConfigAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	err = cl.ReloadConfig(cctx.Context)
 	if err != nil {
 		return err
@@ -307,16 +602,28 @@ func reloadConfigAction(cctx *cli.Context) error {
 }
 
 func statusAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	st, err := cl.Status(cctx.Context)
 	if err != nil {
 		return err
 	}
 	fmt.Println("ID:", st.ID)
 	fmt.Println("Frozen:", st.Frozen)
+	switch {
+	case st.Drained:
+		fmt.Println("Draining: drained")
+	case st.Draining:
+		fmt.Println("Draining: in progress")
+	default:
+		fmt.Println("Draining: false")
+	}
+	if st.ReadOnly {
+		fmt.Println("Read-only: true")
+	}
 	var percent string
 	if st.Usage < 0 {
 		percent = "not available"
@@ -328,10 +635,11 @@ func statusAction(cctx *cli.Context) error {
 }
 
 func unassignAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, closeCl, err := adminClient(cctx)
 	if err != nil {
 		return err
 	}
+	defer closeCl()
 	peerID, err := peer.Decode(cctx.String("peer"))
 	if err != nil {
 		return err
@@ -345,3 +653,82 @@ func unassignAction(cctx *cli.Context) error {
 	fmt.Println("Restart assigner service see this change. To prevent re-assignment to this indexer, first block the peer on this indexer or configure a pre-set assignment.")
 	return nil
 }
+
+// adminClient returns an admin client that uses the protocol given by the
+// protocol flag, and a function to close the client.
+func adminClient(cctx *cli.Context) (client.Admin, func(), error) {
+	switch protocol := cctx.String("protocol"); protocol {
+	case "http":
+		cl, err := adminHTTPClient(cctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cl, func() {}, nil
+	case "libp2p":
+		keyFile := cctx.String("keyfile")
+		if keyFile == "" {
+			return nil, nil, errors.New("keyfile required when protocol=libp2p")
+		}
+		keyBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		privKey, err := crypto.UnmarshalPrivateKey(keyBytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode private key: %w", err)
+		}
+
+		var peerID peer.ID
+		if idStr := cctx.String("indexerid"); idStr != "" {
+			peerID, err = peer.Decode(idStr)
+		} else {
+			peerID, err = localIndexerID()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		p2pHost, err := libp2p.New(libp2p.Identity(privKey), libp2p.NoListenAddrs)
+		if err != nil {
+			return nil, nil, err
+		}
+		cl, err := p2pclient.New(p2pHost, peerID)
+		if err != nil {
+			p2pHost.Close()
+			return nil, nil, err
+		}
+		closeClient := func() {
+			cl.Close()
+			p2pHost.Close()
+		}
+		if err = cl.Connect(cctx.Context, cliIndexer(cctx, "p2p")); err != nil {
+			closeClient()
+			return nil, nil, err
+		}
+		return cl, closeClient, nil
+	default:
+		return nil, nil, fmt.Errorf("unrecognized protocol type for client interaction: %s", protocol)
+	}
+}
+
+// adminHTTPClient returns an admin http client that sends the token given by
+// the token flag.
+func adminHTTPClient(cctx *cli.Context) (*httpclient.Client, error) {
+	var opts []apihttp.Option
+	if token := cctx.String("token"); token != "" {
+		opts = append(opts, apihttp.WithBearerToken(token))
+	}
+	return httpclient.New(cliIndexer(cctx, "admin"), opts...)
+}
+
+// localIndexerID returns the peer ID of the indexer in the local config.
+func localIndexerID() (peer.ID, error) {
+	cfg, err := config.Load("")
+	if err != nil {
+		if errors.Is(err, config.ErrNotInitialized) {
+			return "", errors.New("indexerid required when there is no local config")
+		}
+		return "", fmt.Errorf("cannot load config file: %w", err)
+	}
+	return peer.Decode(cfg.Identity.PeerID)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/ipfs/kubo/core/bootstrap"
 	"github.com/ipfs/kubo/peering"
 	sth "github.com/ipld/go-storethehash/store"
+	"github.com/ipni/dhstore"
 	"github.com/ipni/go-indexer-core"
 	"github.com/ipni/go-indexer-core/cache"
 	"github.com/ipni/go-indexer-core/cache/radixcache"
@@ -26,18 +29,27 @@ import (
 	"github.com/ipni/go-indexer-core/store/pogreb"
 	"github.com/ipni/go-indexer-core/store/storethehash"
 	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/filestore"
 	"github.com/ipni/storetheindex/fsutil"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
+	"github.com/ipni/storetheindex/internal/findcache"
 	"github.com/ipni/storetheindex/internal/ingest"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/ipni/storetheindex/internal/readonly"
 	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/ipni/storetheindex/internal/snapshot"
+	"github.com/ipni/storetheindex/internal/tracing"
 	"github.com/ipni/storetheindex/mautil"
 	httpadminserver "github.com/ipni/storetheindex/server/admin/http"
+	p2padminserver "github.com/ipni/storetheindex/server/admin/libp2p"
 	httpfinderserver "github.com/ipni/storetheindex/server/finder/http"
 	p2pfinderserver "github.com/ipni/storetheindex/server/finder/libp2p"
 	httpingestserver "github.com/ipni/storetheindex/server/ingest/http"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/urfave/cli/v2"
 )
@@ -70,6 +82,18 @@ var daemonFlags = []cli.Flag{
 	listenFinderFlag,
 	listenIngestFlag,
 	listenP2PFlag,
+	&cli.BoolFlag{
+		Name:     "publisher-metrics",
+		Usage:    "Record ingest metrics labeled by publisher, overrides config",
+		EnvVars:  []string{"STORETHEINDEX_PUBLISHER_METRICS"},
+		Required: false,
+	},
+	&cli.BoolFlag{
+		Name:     "read-only",
+		Usage:    "Run as a read-only finder replica that does not ingest",
+		EnvVars:  []string{"STORETHEINDEX_READ_ONLY"},
+		Required: false,
+	},
 	&cli.BoolFlag{
 		Name:     "watch-config",
 		Usage:    "Watch for changes to config file and automatically reload",
@@ -95,10 +119,35 @@ func daemonAction(cctx *cli.Context) error {
 		return err
 	}
 
+	if cfg.Metrics.PublisherLabels || cctx.Bool("publisher-metrics") {
+		metrics.EnablePublisherMetrics(cfg.Metrics.MaxPublisherLabels)
+	}
+
+	stopTracing, err := tracing.Start(cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := stopTracing(context.Background()); err != nil {
+			log.Errorw("Error stopping tracing", "err", err)
+		}
+	}()
+
 	if cfg.Version != config.Version {
 		log.Warn("Configuration file out-of-date. Upgrade by running: ./storetheindex init --upgrade")
 	}
 
+	readOnly := cctx.Bool("read-only")
+	if readOnly {
+		// Do not run garbage collection on a read-only value store.
+		cfg.Indexer.GCInterval = -1
+		// Snapshots do not include the local double-hashed index, so a
+		// replica that follows snapshots would serve a stale one.
+		if cfg.ReadOnly.FollowSnapshots && cfg.Indexer.DHStoreDir != "" {
+			return errors.New("cannot follow snapshots with Indexer.DHStoreDir configured")
+		}
+	}
+
 	// Create a valuestore of the configured type.
 	valueStore, minKeyLen, vsDir, err := createValueStore(cctx.Context, cfg.Indexer)
 	if err != nil {
@@ -106,6 +155,16 @@ func daemonAction(cctx *cli.Context) error {
 	}
 	log.Info("Valuestore initialized")
 
+	// Create local double-hashed index, if configured.
+	var dhStore *dhstore.PebbleDHStore
+	if cfg.Indexer.DHStoreDir != "" {
+		dhStore, err = createDHStore(cfg.Indexer.DHStoreDir, readOnly)
+		if err != nil {
+			return err
+		}
+		log.Info("Double-hashed index initialized")
+	}
+
 	// Create datastore
 	dstore, dstoreAds, err := createDatastore(cfg.Datastore)
 	if err != nil {
@@ -116,7 +175,7 @@ func daemonAction(cctx *cli.Context) error {
 		defer dstoreAds.Close()
 	}
 
-	if cfg.Indexer.UnfreezeOnStart {
+	if cfg.Indexer.UnfreezeOnStart && !readOnly {
 		unfrozen, err := registry.Unfreeze(cctx.Context, vsDir, cfg.Indexer.FreezeAtPercent, dstore)
 		if err != nil {
 			return fmt.Errorf("cannot unfreeze registry: %w", err)
@@ -134,12 +193,30 @@ func daemonAction(cctx *cli.Context) error {
 		cfg.Ingest.MinimumKeyLength = minKeyLen
 	}
 
+	// A read-only replica reads its datastore and value store through
+	// wrappers that reject writes, and that let the snapshot follower replace
+	// the underlying stores.
+	var ds datastore.Datastore = dstore
+	var roDS *readonly.Datastore
+	var roVS *readonly.ValueStore
+	if readOnly {
+		roDS = readonly.NewDatastore(dstore)
+		roVS = readonly.NewValueStore(valueStore)
+		ds = roDS
+		valueStore = roVS
+		log.Info("Running as read-only finder replica")
+	}
+
 	// Create result cache
 	var resultCache cache.Interface
 	cacheSize := int(cctx.Int64("cachesize"))
 	if cacheSize == 0 {
 		cacheSize = cfg.Indexer.CacheSize
 	}
+	if readOnly && cfg.ReadOnly.FollowSnapshots {
+		// Cached results would be stale after loading a new snapshot.
+		cacheSize = 0
+	}
 	if cacheSize > 0 {
 		resultCache = radixcache.New(cacheSize)
 		log.Infow("Result cache enabled", "size", cacheSize)
@@ -148,45 +225,55 @@ func daemonAction(cctx *cli.Context) error {
 	}
 
 	// Create indexer core
-	indexerCore := engine.New(resultCache, valueStore,
+	var indexerCore indexer.Interface = engine.New(resultCache, valueStore,
 		engine.WithDHBatchSize(cfg.Indexer.DHBatchSize),
 		engine.WithDHStore(cfg.Indexer.DHStoreURL),
 		engine.WithVSNoNewMH(cfg.Indexer.VSNoNewMH),
 		engine.WithHttpClientTimeout(time.Duration(cfg.Indexer.DHStoreHttpClientTimeout)),
 	)
 
-	indexCounts := counter.NewIndexCounts(dstore)
+	// The finder cache wraps the indexer core so that writes by ingestion
+	// invalidate cached results.
+	var findCache *findcache.Cache
+	if cfg.Finder.CacheSize > 0 {
+		negativeTTL := time.Duration(cfg.Finder.CacheNegativeTTL)
+		if negativeTTL < 0 {
+			negativeTTL = 0
+		}
+		findCache, err = findcache.New(indexerCore,
+			findcache.WithSize(cfg.Finder.CacheSize),
+			findcache.WithTTL(time.Duration(cfg.Finder.CacheTTL)),
+			findcache.WithNegativeTTL(negativeTTL))
+		if err != nil {
+			return fmt.Errorf("cannot create finder cache: %w", err)
+		}
+		indexerCore = findCache
+		log.Infow("Finder cache enabled", "size", cfg.Finder.CacheSize)
+	}
+
+	// Load content denylist. The denylist is created even if there are no
+	// sources, so that sources can be added by reloading the config.
+	dl, err := denylist.New(cctx.Context, cfg.Denylist)
+	if err != nil {
+		return err
+	}
+
+	indexCounts := counter.NewIndexCounts(ds)
 	indexCounts.SetTotalAddend(cfg.Indexer.IndexCountTotalAddend)
 
 	// Create registry
-	reg, err := registry.New(cctx.Context, cfg.Discovery, dstore,
-		registry.WithFreezer(vsDir, cfg.Indexer.FreezeAtPercent))
+	reg, err := registry.New(cctx.Context, cfg.Discovery, ds,
+		registry.WithFreezer(vsDir, cfg.Indexer.FreezeAtPercent),
+		registry.WithReadOnly(readOnly))
 	if err != nil {
 		return fmt.Errorf("cannot create provider registry: %s", err)
 	}
 
-	// Create finder HTTP server
-	var finderSvr *httpfinderserver.Server
 	finderAddr := cfg.Addresses.Finder
 	if cctx.String("listen-finder") != "" {
 		finderAddr = cctx.String("listen-finder")
 	}
-	if finderAddr != "" && finderAddr != "none" {
-		finderNetAddr, err := mautil.MultiaddrStringToNetAddr(finderAddr)
-		if err != nil {
-			return fmt.Errorf("bad finder address %s: %s", finderAddr, err)
-		}
-		finderSvr, err = httpfinderserver.New(finderNetAddr.String(), indexerCore, reg,
-			httpfinderserver.WithReadTimeout(time.Duration(cfg.Finder.ApiReadTimeout)),
-			httpfinderserver.WithWriteTimeout(time.Duration(cfg.Finder.ApiWriteTimeout)),
-			httpfinderserver.WithMaxConnections(cfg.Finder.MaxConnections),
-			httpfinderserver.WithHomepage(cfg.Finder.Webpage),
-			httpfinderserver.WithIndexCounts(indexCounts),
-		)
-		if err != nil {
-			return err
-		}
-	}
+	finderEnabled := finderAddr != "" && finderAddr != "none"
 
 	var (
 		cancelP2pServers context.CancelFunc
@@ -231,20 +318,31 @@ func daemonAction(cctx *cli.Context) error {
 			return err
 		}
 
-		if finderSvr != nil {
-			p2pfinderserver.New(ctx, p2pHost, indexerCore, reg, indexCounts)
+		if finderEnabled {
+			p2pfinderserver.New(ctx, p2pHost, indexerCore, reg, indexCounts, dl)
 		}
 
-		// Do not resend direct announce messages if using an assigner service.
-		if cfg.Discovery.UseAssigner {
-			cfg.Ingest.ResendDirectAnnounce = false
-		}
+		// A read-only replica does not ingest.
+		if !readOnly {
+			// Do not resend direct announce messages if using an assigner
+			// service.
+			if cfg.Discovery.UseAssigner {
+				cfg.Ingest.ResendDirectAnnounce = false
+			}
 
-		// Initialize ingester.
-		ingester, err = ingest.NewIngester(cfg.Ingest, p2pHost, indexerCore, reg, dstore,
-			ingest.WithAdsDatastore(dstoreAds), ingest.WithIndexCounts(indexCounts))
-		if err != nil {
-			return err
+			// Initialize ingester.
+			ingestOpts := []ingest.Option{
+				ingest.WithAdsDatastore(dstoreAds),
+				ingest.WithIndexCounts(indexCounts),
+				ingest.WithDenylist(dl),
+			}
+			if dhStore != nil {
+				ingestOpts = append(ingestOpts, ingest.WithDHStore(dhStore))
+			}
+			ingester, err = ingest.NewIngester(cfg.Ingest, p2pHost, indexerCore, reg, dstore, ingestOpts...)
+			if err != nil {
+				return err
+			}
 		}
 
 		// If there are bootstrap peers and bootstrapping is enabled, then try to
@@ -275,13 +373,43 @@ func daemonAction(cctx *cli.Context) error {
 		log.Infow("libp2p servers initialized", "host_id", p2pHost.ID(), "multiaddr", p2pmaddr)
 	}
 
+	// Create finder HTTP server. This is created after the ingester so that
+	// ingest stats can be reported.
+	var finderSvr *httpfinderserver.Server
+	if finderEnabled {
+		finderNetAddr, err := mautil.MultiaddrStringToNetAddr(finderAddr)
+		if err != nil {
+			return fmt.Errorf("bad finder address %s: %s", finderAddr, err)
+		}
+		finderOpts := []httpfinderserver.Option{
+			httpfinderserver.WithReadTimeout(time.Duration(cfg.Finder.ApiReadTimeout)),
+			httpfinderserver.WithWriteTimeout(time.Duration(cfg.Finder.ApiWriteTimeout)),
+			httpfinderserver.WithMaxConnections(cfg.Finder.MaxConnections),
+			httpfinderserver.WithHomepage(cfg.Finder.Webpage),
+			httpfinderserver.WithIndexCounts(indexCounts),
+			httpfinderserver.WithIngester(ingester),
+			httpfinderserver.WithStatsRefresh(time.Duration(cfg.Finder.StatsRefreshInterval)),
+			httpfinderserver.WithStatsDetailsRefresh(time.Duration(cfg.Finder.StatsDetailsRefreshInterval)),
+			httpfinderserver.WithRateLimit(cfg.Finder.RateLimit),
+			httpfinderserver.WithFederation(cfg.Finder.Federation),
+			httpfinderserver.WithDenylist(dl),
+		}
+		if dhStore != nil {
+			finderOpts = append(finderOpts, httpfinderserver.WithDHStore(dhStore))
+		}
+		finderSvr, err = httpfinderserver.New(finderNetAddr.String(), indexerCore, reg, finderOpts...)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create ingest HTTP server
 	var ingestSvr *httpingestserver.Server
 	ingestAddr := cfg.Addresses.Ingest
 	if cctx.String("listen-ingest") != "" {
 		ingestAddr = cctx.String("listen-ingest")
 	}
-	if ingestAddr != "" && ingestAddr != "none" {
+	if ingestAddr != "" && ingestAddr != "none" && !readOnly {
 		ingestNetAddr, err := mautil.MultiaddrStringToNetAddr(ingestAddr)
 		if err != nil {
 			return fmt.Errorf("bad ingest address %s: %s", ingestAddr, err)
@@ -305,7 +433,46 @@ func daemonAction(cctx *cli.Context) error {
 		if err != nil {
 			return fmt.Errorf("bad admin address %s: %s", adminAddr, err)
 		}
-		adminSvr, err = httpadminserver.New(adminNetAddr.String(), peerID, indexerCore, ingester, reg, reloadErrsChan)
+		adminOpts := []httpadminserver.Option{
+			httpadminserver.WithTokens(cfg.Admin.Tokens),
+			httpadminserver.WithReadOnly(readOnly),
+		}
+		if !readOnly {
+			snapshotter, err := createSnapshotter(cfg, peerID, dstore, dstoreAds, valueStore, ingester)
+			if err != nil {
+				return err
+			}
+			if snapshotter != nil {
+				adminOpts = append(adminOpts, httpadminserver.WithSnapshotter(snapshotter))
+			}
+		}
+		adminSvr, err = httpadminserver.New(adminNetAddr.String(), peerID, indexerCore, ingester, reg, reloadErrsChan, adminOpts...)
+		if err != nil {
+			return err
+		}
+		if len(cfg.Admin.Tokens) == 0 {
+			if tcpAddr, ok := adminNetAddr.(*net.TCPAddr); !ok || !tcpAddr.IP.IsLoopback() {
+				log.Warnw("Admin server does not require authentication and is not listening on a loopback address", "addr", adminAddr)
+			}
+		}
+	}
+
+	// Create libp2p admin server if there are any authorized admin peers. A
+	// read-only replica only has the admin HTTP server.
+	var p2pAdminSvr *p2padminserver.AdminServer
+	if p2pHost != nil && len(cfg.Admin.AuthorizedPeers) != 0 && !readOnly {
+		authorizedPeers, err := cfg.Admin.AuthorizedPeerIDs()
+		if err != nil {
+			return err
+		}
+		p2pAdminSvr = p2padminserver.New(ctx, p2pHost, ingester, reg, reloadErrsChan, authorizedPeers)
+		log.Infow("libp2p admin server enabled", "authorizedPeers", len(authorizedPeers))
+	}
+
+	// Follow snapshots taken by a primary indexer.
+	var follower *readonly.Follower
+	if readOnly && cfg.ReadOnly.FollowSnapshots {
+		follower, err = createFollower(cfg, roDS, roVS, reg, indexCounts, findCache)
 		if err != nil {
 			return err
 		}
@@ -339,6 +506,10 @@ func daemonAction(cctx *cli.Context) error {
 		fmt.Println("Admin server:\t disabled")
 	}
 
+	if ingester != nil && ingester.ReplicationAddr() != nil {
+		fmt.Println("Replication:\t", ingester.ReplicationAddr())
+	}
+
 	reloadSig := make(chan os.Signal, 1)
 	signal.Notify(reloadSig, syscall.SIGHUP)
 
@@ -349,6 +520,9 @@ func daemonAction(cctx *cli.Context) error {
 	if reg.Frozen() {
 		fmt.Println("Indexer is frozen")
 	}
+	if readOnly {
+		fmt.Println("Indexer is read-only")
+	}
 	fmt.Println("Indexer is ready")
 
 	var cfgPath string
@@ -395,7 +569,7 @@ func daemonAction(cctx *cli.Context) error {
 				ticker.Reset(time.Duration(cfg.Indexer.ConfigCheckInterval))
 			}
 
-			cfg, err = reloadConfig(cfgPath, ingester, reg, valueStore)
+			cfg, err = reloadConfig(cfgPath, ingester, reg, valueStore, dl)
 			if err != nil {
 				log.Errorw("Error reloading conifg", "err", err)
 				if errChan != nil {
@@ -420,6 +594,28 @@ func daemonAction(cctx *cli.Context) error {
 				indexCounts.SetTotalAddend(cfg.Indexer.IndexCountTotalAddend)
 			}
 
+			if adminSvr != nil {
+				if err = adminSvr.SetTokens(cfg.Admin.Tokens); err != nil {
+					log.Errorw("Error reloading admin tokens", "err", err)
+					if errChan != nil {
+						errChan <- errors.New("could not reload admin tokens")
+					}
+					continue
+				}
+			}
+
+			if p2pAdminSvr != nil {
+				authorizedPeers, err := cfg.Admin.AuthorizedPeerIDs()
+				if err != nil {
+					log.Errorw("Error reloading authorized admin peers", "err", err)
+					if errChan != nil {
+						errChan <- errors.New("could not reload authorized admin peers")
+					}
+					continue
+				}
+				p2pAdminSvr.SetAuthorizedPeers(authorizedPeers)
+			}
+
 			if errChan != nil {
 				errChan <- nil
 			}
@@ -490,6 +686,16 @@ func daemonAction(cctx *cli.Context) error {
 			finalErr = ErrDaemonStop
 		}
 	}
+	if p2pAdminSvr != nil {
+		if err = p2pAdminSvr.Close(); err != nil {
+			log.Errorw("Error shutting down libp2p admin server", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
+
+	if follower != nil {
+		follower.Close()
+	}
 
 	// If ingester set, close ingester
 	if ingester != nil {
@@ -503,14 +709,41 @@ func daemonAction(cctx *cli.Context) error {
 		log.Errorw("Error closing value store", "err", err)
 		finalErr = ErrDaemonStop
 	}
+	if dhStore != nil {
+		if err = dhStore.Close(); err != nil {
+			log.Errorw("Error closing double-hashed index", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 
 	reg.Close()
+	if roDS != nil {
+		// Close the datastore that the follower may have swapped in.
+		roDS.Close()
+	}
 	dstore.Close()
 
 	log.Info("Indexer stopped")
 	return finalErr
 }
 
+func createDHStore(dhStoreDir string, readOnly bool) (*dhstore.PebbleDHStore, error) {
+	dir, err := config.Path("", dhStoreDir)
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("Double-hashed index initializing/opening", "path", dir)
+
+	if !readOnly {
+		if err = fsutil.DirWritable(dir); err != nil {
+			return nil, err
+		}
+	}
+	return dhstore.NewPebbleDHStore(dir, &pbl.Options{
+		ReadOnly: readOnly,
+	})
+}
+
 func createValueStore(ctx context.Context, cfgIndexer config.Indexer) (indexer.Interface, int, string, error) {
 	const sthMinKeyLen = 4
 
@@ -631,7 +864,7 @@ func loadConfig(filePath string) (*config.Config, error) {
 	return cfg, nil
 }
 
-func reloadConfig(cfgPath string, ingester *ingest.Ingester, reg *registry.Registry, valueStore indexer.Interface) (*config.Config, error) {
+func reloadConfig(cfgPath string, ingester *ingest.Ingester, reg *registry.Registry, valueStore indexer.Interface, dl *denylist.Denylist) (*config.Config, error) {
 	cfg, err := loadConfig(cfgPath)
 	if err != nil {
 		return nil, err
@@ -647,10 +880,19 @@ func reloadConfig(cfgPath string, ingester *ingest.Ingester, reg *registry.Regis
 		if err != nil {
 			return nil, fmt.Errorf("failed to set rate limit config: %w", err)
 		}
+		err = ingester.SetPublisherOverrides(cfg.Ingest.PublisherOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set publisher overrides config: %w", err)
+		}
 		ingester.SetBatchSize(cfg.Ingest.StoreBatchSize)
 		ingester.RunWorkers(cfg.Ingest.IngestWorkerCount)
 	}
 
+	err = dl.Reload(context.Background(), cfg.Denylist)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload denylist: %w", err)
+	}
+
 	err = setLoggingConfig(cfg.Logging)
 	if err != nil {
 		return nil, fmt.Errorf("failed to configure logging: %w", err)
@@ -719,6 +961,81 @@ func reloadPeering(cfg config.Peering, peeringService *peering.PeeringService, p
 	return peeringService, nil
 }
 
+// createSnapshotter creates a Snapshotter if a snapshot destination is
+// configured, or returns nil if not.
+func createSnapshotter(cfg *config.Config, peerID peer.ID, dstore, dstoreAds datastore.Batching, valueStore indexer.Interface, ingester *ingest.Ingester) (*snapshot.Snapshotter, error) {
+	dest, err := filestore.New(cfg.Snapshot.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create snapshot file store: %w", err)
+	}
+	if dest == nil {
+		return nil, nil
+	}
+	if cfg.Indexer.DHStoreDir != "" {
+		return nil, errors.New("cannot take snapshots with Indexer.DHStoreDir configured, since the double-hashed index is not included in snapshots")
+	}
+	opts := []snapshot.Option{
+		snapshot.WithAdsDatastore(dstoreAds),
+		snapshot.WithValueStoreType(cfg.Indexer.ValueStoreType),
+	}
+	if ingester != nil {
+		opts = append(opts, snapshot.WithPause(ingester.PauseIngest))
+	}
+	log.Infow("Snapshots enabled", "destination", dest.Type())
+	return snapshot.New(dest, peerID, dstore, valueStore, opts...)
+}
+
+// createFollower creates a Follower that loads snapshots from the snapshot
+// destination into the read-only datastore and value store.
+func createFollower(cfg *config.Config, ds *readonly.Datastore, vs *readonly.ValueStore, reg *registry.Registry, indexCounts *counter.IndexCounts, findCache *findcache.Cache) (*readonly.Follower, error) {
+	src, err := filestore.New(cfg.Snapshot.Destination)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create snapshot file store: %w", err)
+	}
+	if src == nil {
+		return nil, errors.New("cannot follow snapshots: snapshot destination not configured")
+	}
+	dir, err := config.Path("", cfg.ReadOnly.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgIndexer := cfg.Indexer
+	open := func(snapDir string) (datastore.Batching, indexer.Interface, error) {
+		if err := os.MkdirAll(snapDir, 0o775); err != nil {
+			return nil, nil, err
+		}
+		dstore, _, err := createDatastore(config.Datastore{
+			Type: "levelds",
+			Dir:  filepath.Join(snapDir, "datastore"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		cfgIndexer.ValueStoreDir = filepath.Join(snapDir, "valuestore")
+		valueStore, _, _, err := createValueStore(context.Background(), cfgIndexer)
+		if err != nil {
+			dstore.Close()
+			return nil, nil, err
+		}
+		return dstore, valueStore, nil
+	}
+
+	// Discard anything cached from the previous snapshot.
+	onLoad := func(ctx context.Context) error {
+		indexCounts.ClearCache()
+		if findCache != nil {
+			findCache.Purge()
+		}
+		return reg.Reload(ctx)
+	}
+
+	log.Infow("Following snapshots", "source", src.Type(), "dir", dir)
+	return readonly.NewFollower(src, dir, ds, vs, open,
+		readonly.WithCheckInterval(time.Duration(cfg.ReadOnly.CheckInterval)),
+		readonly.WithOnLoad(onLoad))
+}
+
 func createDatastore(cfg config.Datastore) (datastore.Batching, datastore.Batching, error) {
 	if cfg.Type != "levelds" {
 		return nil, nil, fmt.Errorf("only levelds datastore type supported, %q not supported", cfg.Type)
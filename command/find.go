@@ -1,13 +1,14 @@
 package command
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 
 	"github.com/ipfs/go-cid"
-	"github.com/ipni/storetheindex/api/v0/finder/client"
 	httpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
 	p2pclient "github.com/ipni/storetheindex/api/v0/finder/client/libp2p"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multihash"
 	"github.com/urfave/cli/v2"
@@ -45,6 +46,10 @@ var findFlags = []cli.Flag{
 		Value:    "http",
 		Required: false,
 	},
+	&cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Maximum time to wait for find results. Zero means no limit",
+	},
 }
 
 func findAction(cctx *cli.Context) error {
@@ -68,53 +73,76 @@ func findAction(cctx *cli.Context) error {
 		mhs = append(mhs, c.Hash())
 	}
 
-	var cl client.Finder
-	var err error
+	ctx := cctx.Context
+	if timeout := cctx.Duration("timeout"); timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	switch protocol {
 	case "http":
-		cl, err = httpclient.New(cliIndexer(cctx, "finder"))
+		cl, err := httpclient.New(cliIndexer(cctx, "finder"))
+		if err != nil {
+			return err
+		}
+		resp, err := cl.FindBatch(ctx, mhs)
 		if err != nil {
 			return err
 		}
+		if len(resp.MultihashResults) == 0 {
+			fmt.Println("index not found")
+			return nil
+		}
+		fmt.Println("Content providers:")
+		for i := range resp.MultihashResults {
+			showMultihashResult(resp.MultihashResults[i])
+		}
 	case "libp2p":
-		peerID, err := peer.Decode(cctx.String("peerid"))
+		peerID, err := peer.Decode(cctx.String("indexerid"))
+		if err != nil {
+			return err
+		}
+
+		cl, err := p2pclient.New(nil, peerID)
 		if err != nil {
 			return err
 		}
+		defer cl.Close()
 
-		c, err := p2pclient.New(nil, peerID)
+		err = cl.Connect(ctx, cliIndexer(cctx, "p2p"))
 		if err != nil {
 			return err
 		}
 
-		err = c.Connect(cctx.Context, cliIndexer(cctx, "finder"))
+		// Stream the results, so that large lookups show results as they
+		// arrive instead of waiting for the entire response.
+		var count int
+		err = cl.FindStream(ctx, mhs, func(result model.MultihashResult) error {
+			if count == 0 {
+				fmt.Println("Content providers:")
+			}
+			count++
+			showMultihashResult(result)
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		cl = c
+		if count == 0 {
+			fmt.Println("index not found")
+		}
 	default:
 		return fmt.Errorf("unrecognized protocol type for client interaction: %s", protocol)
 	}
+	return nil
+}
 
-	resp, err := cl.FindBatch(cctx.Context, mhs)
-	if err != nil {
-		return err
-	}
-
-	if len(resp.MultihashResults) == 0 {
-		fmt.Println("index not found")
-		return nil
-	}
-
-	fmt.Println("Content providers:")
-	for i := range resp.MultihashResults {
-		fmt.Println("   Multihash:", resp.MultihashResults[i].Multihash.B58String(), "==>")
-		for _, pr := range resp.MultihashResults[i].ProviderResults {
-			fmt.Println("       Provider:", pr.Provider)
-			fmt.Println("       ContextID:", base64.StdEncoding.EncodeToString(pr.ContextID))
-			fmt.Println("       Metadata:", base64.StdEncoding.EncodeToString(pr.Metadata))
-		}
+func showMultihashResult(result model.MultihashResult) {
+	fmt.Println("   Multihash:", result.Multihash.B58String(), "==>")
+	for _, pr := range result.ProviderResults {
+		fmt.Println("       Provider:", pr.Provider)
+		fmt.Println("       ContextID:", base64.StdEncoding.EncodeToString(pr.ContextID))
+		fmt.Println("       Metadata:", base64.StdEncoding.EncodeToString(pr.Metadata))
 	}
-	return nil
 }
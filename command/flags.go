@@ -16,6 +16,12 @@ var indexerHostFlag = &cli.StringFlag{
 	Required: false,
 }
 
+var adminTokenFlag = &cli.StringFlag{
+	Name:    "token",
+	Usage:   "Bearer token for the indexer admin server, when the indexer requires one",
+	EnvVars: []string{"INDEXER_ADMIN_TOKEN"},
+}
+
 var cacheSizeFlag = &cli.Int64Flag{
 	Name:     "cachesize",
 	Usage:    "Maximum number of multihashes that result cache can hold, -1 to disable cache",
@@ -93,6 +99,8 @@ func indexerHost(addrType string) string {
 		maddr, err = multiaddr.NewMultiaddr(cfg.Addresses.Admin)
 	case "ingest":
 		maddr, err = multiaddr.NewMultiaddr(cfg.Addresses.Ingest)
+	case "p2p":
+		maddr, err = multiaddr.NewMultiaddr(cfg.Addresses.P2PAddr)
 	default:
 		return ""
 	}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 
-	httpclient "github.com/ipni/storetheindex/api/v0/admin/client/http"
 	"github.com/urfave/cli/v2"
 )
 
@@ -29,7 +28,7 @@ following command will set the level for all subsystems to debug:
 var LogCmd = &cli.Command{
 	Name:  "log",
 	Usage: "Show log subsystems and modify log levels",
-	Flags: []cli.Flag{indexerHostFlag},
+	Flags: []cli.Flag{indexerHostFlag, adminTokenFlag},
 	Subcommands: []*cli.Command{
 		subsystemsCmd,
 		setLevelCmd,
@@ -37,7 +36,7 @@ var LogCmd = &cli.Command{
 }
 
 func setLogLevelAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, err := adminHTTPClient(cctx)
 	if err != nil {
 		return err
 	}
@@ -61,7 +60,7 @@ func setLogLevelAction(cctx *cli.Context) error {
 }
 
 func listLogSubsystemsAction(cctx *cli.Context) error {
-	cl, err := httpclient.New(cliIndexer(cctx, "admin"))
+	cl, err := adminHTTPClient(cctx)
 	if err != nil {
 		return err
 	}
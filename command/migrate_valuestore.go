@@ -0,0 +1,159 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipni/storetheindex/command/migratevs"
+	"github.com/ipni/storetheindex/config"
+	"github.com/urfave/cli/v2"
+)
+
+var MigrateValueStoreCmd = &cli.Command{
+	Name:  "migrate-valuestore",
+	Usage: "Copy index data from one type of value store to another",
+	Description: "Copies every multihash and its values from the value store configured in the indexer's\n" +
+		"config file to a new value store of a different type. The indexer must not be running.\n" +
+		"An interrupted migration resumes from its last checkpoint when run again with the same\n" +
+		"arguments. After migrating, set Indexer.ValueStoreType and Indexer.ValueStoreDir in the\n" +
+		"config file to use the new value store.",
+	Flags:  migrateValueStoreFlags,
+	Action: migrateValueStoreAction,
+}
+
+var migrateValueStoreFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "from",
+		Usage:    "Type of value store to migrate from (pebble, pogreb, sth)",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:     "to",
+		Usage:    "Type of value store to migrate to (pebble, pogreb, sth)",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:  "from-dir",
+		Usage: "Directory of value store to migrate from. Default is Indexer.ValueStoreDir from config",
+	},
+	&cli.StringFlag{
+		Name:  "to-dir",
+		Usage: "Directory of value store to migrate to. Default is Indexer.ValueStoreDir from config with \"-<to>\" appended",
+	},
+	&cli.IntFlag{
+		Name:  "batch-size",
+		Usage: "Number of multihashes to write to the new value store at once",
+		Value: 16384,
+	},
+	&cli.Uint64Flag{
+		Name:  "checkpoint",
+		Usage: "Number of multihashes to migrate between saving progress",
+		Value: 1000000,
+	},
+	&cli.BoolFlag{
+		Name:  "no-verify",
+		Usage: "Do not count the multihashes and values in the new value store after migrating",
+	},
+}
+
+func migrateValueStoreAction(cctx *cli.Context) error {
+	fromType := cctx.String("from")
+	toType := cctx.String("to")
+	for _, vsType := range []string{fromType, toType} {
+		switch vsType {
+		case vstorePebble, vstorePogreb, vstoreStorethehash:
+		case vstoreMemory:
+			return errors.New("cannot migrate to or from memory value store")
+		default:
+			return fmt.Errorf("unrecognized store type: %s", vsType)
+		}
+	}
+
+	cfg, err := loadConfig("")
+	if err != nil {
+		if errors.Is(err, config.ErrNotInitialized) {
+			return errors.New("indexer is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return err
+	}
+
+	fromCfg := cfg.Indexer
+	fromCfg.ValueStoreType = fromType
+	if dir := cctx.String("from-dir"); dir != "" {
+		fromCfg.ValueStoreDir = dir
+	}
+	toCfg := cfg.Indexer
+	toCfg.ValueStoreType = toType
+	if dir := cctx.String("to-dir"); dir != "" {
+		toCfg.ValueStoreDir = dir
+	} else {
+		toCfg.ValueStoreDir = cfg.Indexer.ValueStoreDir + "-" + toType
+	}
+	// Do not run garbage collection while migrating.
+	fromCfg.GCInterval = -1
+	toCfg.GCInterval = -1
+
+	fromDir, err := config.Path("", fromCfg.ValueStoreDir)
+	if err != nil {
+		return err
+	}
+	toDir, err := config.Path("", toCfg.ValueStoreDir)
+	if err != nil {
+		return err
+	}
+	if fromDir == toDir {
+		return errors.New("cannot migrate value store into the same directory")
+	}
+
+	src, _, _, err := createValueStore(cctx.Context, fromCfg)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, _, _, err := createValueStore(cctx.Context, toCfg)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	fmt.Printf("Migrating %s value store at %s to %s value store at %s\n", fromType, fromDir, toType, toDir)
+
+	progressFile := toDir + ".migrate"
+	report, err := migratevs.Migrate(cctx.Context, src, dst, progressFile,
+		migratevs.WithBatchSize(cctx.Int("batch-size")),
+		migratevs.WithCheckpointInterval(cctx.Uint64("checkpoint")),
+		migratevs.WithVerify(!cctx.Bool("no-verify")),
+		migratevs.WithProgress(func(p migratevs.Progress) {
+			var rate float64
+			if p.Elapsed > 0 {
+				rate = float64(p.Multihashes) / p.Elapsed.Seconds()
+			}
+			fmt.Printf("    Migrated %d multihashes, %d values (%.0f multihashes/s)\n", p.Multihashes, p.Values, rate)
+		}))
+	if err != nil {
+		if cctx.Context.Err() != nil {
+			fmt.Println("Migration interrupted. Run again with the same arguments to resume.")
+		}
+		return err
+	}
+
+	fmt.Println("Multihashes:", report.Multihashes)
+	fmt.Println("Values:", report.Values)
+	if report.Resumed != 0 {
+		fmt.Println("Resumed after:", report.Resumed)
+	}
+	fmt.Println("Elapsed:", report.Elapsed.Round(time.Second))
+	if report.Verified {
+		fmt.Println("Destination multihashes:", report.DstMultihashes)
+		fmt.Println("Destination values:", report.DstValues)
+		if !report.OK() {
+			return errors.New("destination value store counts do not match source")
+		}
+		fmt.Println("Counts verified")
+	}
+	fmt.Printf("To use the new value store, set Indexer.ValueStoreType to %q and Indexer.ValueStoreDir to %q\n",
+		toType, toCfg.ValueStoreDir)
+	return nil
+}
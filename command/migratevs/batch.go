@@ -0,0 +1,59 @@
+package migratevs
+
+import (
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/multiformats/go-multihash"
+)
+
+// Batch collects multihashes and their values, to write them to a value store
+// with one Put for each value instead of one for each multihash.
+type Batch struct {
+	values map[string]*valueBatch
+	count  int
+}
+
+// valueBatch is the multihashes waiting to be written for one value.
+type valueBatch struct {
+	value indexer.Value
+	mhs   []multihash.Multihash
+}
+
+// NewBatch creates an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{
+		values: make(map[string]*valueBatch),
+	}
+}
+
+// Add adds a multihash and its values to the batch. The multihash is retained,
+// so it must not be modified after calling Add.
+func (b *Batch) Add(mh multihash.Multihash, values []indexer.Value) {
+	for _, value := range values {
+		key := string(value.ProviderID) + string(value.ContextID)
+		vb, ok := b.values[key]
+		if !ok {
+			vb = &valueBatch{value: value}
+			b.values[key] = vb
+		}
+		vb.mhs = append(vb.mhs, mh)
+	}
+	b.count++
+}
+
+// Len returns the number of multihashes in the batch.
+func (b *Batch) Len() int {
+	return b.count
+}
+
+// Write puts the batched multihashes and values into the value store, and
+// empties the batch.
+func (b *Batch) Write(valueStore indexer.Interface) error {
+	for _, vb := range b.values {
+		if err := valueStore.Put(vb.value, vb.mhs...); err != nil {
+			return err
+		}
+	}
+	b.values = make(map[string]*valueBatch)
+	b.count = 0
+	return nil
+}
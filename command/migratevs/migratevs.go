@@ -0,0 +1,250 @@
+// Package migratevs copies all multihashes and values from one value store to
+// another. This allows changing the type of value store that an indexer uses
+// without re-ingesting advertisements.
+package migratevs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/multiformats/go-multihash"
+)
+
+// Progress is the state of a migration. It is saved at each checkpoint, and is
+// read when resuming an interrupted migration.
+type Progress struct {
+	// Multihashes is the number of multihashes migrated.
+	Multihashes uint64
+	// Values is the number of multihash to value mappings migrated.
+	Values uint64
+	// Elapsed is the time spent migrating, including previous runs.
+	Elapsed time.Duration
+}
+
+// Report describes the result of a migration.
+type Report struct {
+	// Multihashes is the number of multihashes in the source value store.
+	Multihashes uint64
+	// Values is the number of multihash to value mappings in the source value
+	// store.
+	Values uint64
+	// Resumed is the number of multihashes that were migrated by a previous
+	// run that was interrupted.
+	Resumed uint64
+	// Elapsed is the time spent migrating, including previous runs.
+	Elapsed time.Duration
+
+	// Verified is true if the destination value store was counted after
+	// migrating.
+	Verified bool
+	// DstMultihashes is the number of multihashes in the destination value
+	// store, if verified.
+	DstMultihashes uint64
+	// DstValues is the number of multihash to value mappings in the
+	// destination value store, if verified.
+	DstValues uint64
+}
+
+// OK returns true if the destination value store was not verified, or if it
+// has the same counts as the source.
+func (r *Report) OK() bool {
+	if !r.Verified {
+		return true
+	}
+	return r.DstMultihashes == r.Multihashes && r.DstValues == r.Values
+}
+
+// Migrate copies every multihash and its values from the src value store to
+// the dst value store. Progress is saved to progressFile at each checkpoint. If
+// progressFile already exists, then the migration resumes after the
+// multihashes that were already migrated. The progress file is removed when
+// the migration completes.
+//
+// The source value store must not be modified while migrating or between runs
+// of an interrupted migration, since resuming relies on the source store
+// iterating multihashes in the same order.
+func Migrate(ctx context.Context, src, dst indexer.Interface, progressFile string, options ...Option) (*Report, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+
+	prev, err := readProgress(progressFile)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := src.Iter()
+	if err != nil {
+		return nil, fmt.Errorf("cannot iterate source value store: %w", err)
+	}
+	defer iter.Close()
+
+	report := &Report{
+		Resumed: prev.Multihashes,
+	}
+	start := time.Now()
+	elapsed := func() time.Duration {
+		return prev.Elapsed + time.Since(start)
+	}
+
+	batch := NewBatch()
+	var written Progress
+
+	writeBatch := func() error {
+		if err := batch.Write(dst); err != nil {
+			return fmt.Errorf("cannot write to destination value store: %w", err)
+		}
+		written.Multihashes = report.Multihashes
+		written.Values = report.Values
+		return nil
+	}
+
+	checkpoint := func() error {
+		if err := writeBatch(); err != nil {
+			return err
+		}
+		if err := dst.Flush(); err != nil {
+			return fmt.Errorf("cannot flush destination value store: %w", err)
+		}
+		written.Elapsed = elapsed()
+		if err := writeProgress(progressFile, written); err != nil {
+			return err
+		}
+		if opts.onProgress != nil {
+			opts.onProgress(written)
+		}
+		return nil
+	}
+
+	for {
+		if ctx.Err() != nil {
+			// Save what has been migrated so far, so that the migration can
+			// resume from there.
+			if err = checkpoint(); err != nil {
+				return nil, err
+			}
+			return nil, ctx.Err()
+		}
+
+		mh, values, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("cannot read source value store: %w", err)
+		}
+		report.Multihashes++
+		report.Values += uint64(len(values))
+
+		// Skip multihashes migrated by a previous run.
+		if report.Multihashes <= prev.Multihashes {
+			continue
+		}
+
+		batch.Add(append(multihash.Multihash(nil), mh...), values)
+
+		if report.Multihashes%opts.checkpointInterval == 0 {
+			err = checkpoint()
+		} else if batch.Len() >= opts.batchSize {
+			err = writeBatch()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if report.Multihashes < prev.Multihashes {
+		return nil, fmt.Errorf("source value store has fewer multihashes, %d, than already migrated, %d",
+			report.Multihashes, prev.Multihashes)
+	}
+
+	if err = checkpoint(); err != nil {
+		return nil, err
+	}
+	report.Elapsed = elapsed()
+
+	if opts.verify {
+		report.DstMultihashes, report.DstValues, err = count(ctx, dst)
+		if err != nil {
+			return nil, fmt.Errorf("cannot verify destination value store: %w", err)
+		}
+		report.Verified = true
+		if !report.OK() {
+			// Keep the progress file, since the migration is not complete.
+			return report, nil
+		}
+	}
+
+	if err = os.Remove(progressFile); err != nil {
+		return nil, fmt.Errorf("cannot remove progress file: %w", err)
+	}
+	return report, nil
+}
+
+// count returns the number of multihashes and values in a value store.
+func count(ctx context.Context, vs indexer.Interface) (uint64, uint64, error) {
+	iter, err := vs.Iter()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer iter.Close()
+
+	var mhCount, valueCount uint64
+	for {
+		if ctx.Err() != nil {
+			return 0, 0, ctx.Err()
+		}
+		_, values, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, 0, err
+		}
+		mhCount++
+		valueCount += uint64(len(values))
+	}
+	return mhCount, valueCount, nil
+}
+
+// readProgress reads the progress of a previous migration. If there is no
+// progress file, then the zero value is returned.
+func readProgress(fileName string) (Progress, error) {
+	var progress Progress
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return progress, nil
+		}
+		return progress, fmt.Errorf("cannot read progress file: %w", err)
+	}
+	if err = json.Unmarshal(data, &progress); err != nil {
+		return progress, fmt.Errorf("cannot decode progress file %s: %w", fileName, err)
+	}
+	return progress, nil
+}
+
+// writeProgress saves the progress of a migration. The progress is written to
+// a temporary file that is then renamed, so that the progress file is not left
+// partially written if interrupted.
+func writeProgress(fileName string, progress Progress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmpName := fileName + ".tmp"
+	if err = os.WriteFile(tmpName, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write progress file: %w", err)
+	}
+	if err = os.Rename(tmpName, fileName); err != nil {
+		return fmt.Errorf("cannot write progress file: %w", err)
+	}
+	return nil
+}
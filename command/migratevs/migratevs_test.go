@@ -0,0 +1,108 @@
+package migratevs
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/store/memory"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func populate(t *testing.T, vs indexer.Interface) {
+	provA := peer.ID("provider-a")
+	provB := peer.ID("provider-b")
+	mhs := util.RandomMultihashes(50, rand.New(rand.NewSource(1413)))
+
+	valueA := indexer.Value{ProviderID: provA, ContextID: []byte("ctx-a"), MetadataBytes: []byte("meta-a")}
+	valueB := indexer.Value{ProviderID: provB, ContextID: []byte("ctx-b"), MetadataBytes: []byte("meta-b")}
+	require.NoError(t, vs.Put(valueA, mhs[:30]...))
+	require.NoError(t, vs.Put(valueB, mhs[20:]...))
+}
+
+func TestMigrate(t *testing.T) {
+	src := memory.New()
+	populate(t, src)
+	dst := memory.New()
+	progressFile := filepath.Join(t.TempDir(), "progress")
+
+	var checkpoints int
+	report, err := Migrate(context.Background(), src, dst, progressFile,
+		WithBatchSize(7),
+		WithCheckpointInterval(10),
+		WithProgress(func(Progress) { checkpoints++ }))
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.True(t, report.Verified)
+	require.Equal(t, uint64(50), report.Multihashes)
+	require.Equal(t, uint64(60), report.Values)
+	require.Equal(t, report.Multihashes, report.DstMultihashes)
+	require.Equal(t, report.Values, report.DstValues)
+	require.Zero(t, report.Resumed)
+	require.Equal(t, 6, checkpoints)
+
+	// Progress file is removed after successful migration.
+	_, err = os.Stat(progressFile)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	iter, err := src.Iter()
+	require.NoError(t, err)
+	for {
+		mh, values, err := iter.Next()
+		if err != nil {
+			break
+		}
+		dstValues, found, err := dst.Get(mh)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.ElementsMatch(t, values, dstValues)
+	}
+}
+
+func TestMigrateResume(t *testing.T) {
+	src := memory.New()
+	populate(t, src)
+	dst := memory.New()
+	progressFile := filepath.Join(t.TempDir(), "progress")
+
+	// Cancel the migration at the first checkpoint.
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := Migrate(ctx, src, dst, progressFile,
+		WithCheckpointInterval(20),
+		WithProgress(func(Progress) { cancel() }))
+	require.ErrorIs(t, err, context.Canceled)
+
+	progress, err := readProgress(progressFile)
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), progress.Multihashes)
+
+	report, err := Migrate(context.Background(), src, dst, progressFile)
+	require.NoError(t, err)
+	require.True(t, report.OK())
+	require.Equal(t, uint64(20), report.Resumed)
+	require.Equal(t, uint64(50), report.DstMultihashes)
+	require.Equal(t, uint64(60), report.DstValues)
+}
+
+func TestMigrateVerifyMismatch(t *testing.T) {
+	src := memory.New()
+	populate(t, src)
+	dst := memory.New()
+	extra := indexer.Value{ProviderID: peer.ID("provider-c"), ContextID: []byte("ctx-c"), MetadataBytes: []byte("meta-c")}
+	require.NoError(t, dst.Put(extra, util.RandomMultihashes(1, rand.New(rand.NewSource(2048)))...))
+	progressFile := filepath.Join(t.TempDir(), "progress")
+
+	report, err := Migrate(context.Background(), src, dst, progressFile)
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Equal(t, uint64(51), report.DstMultihashes)
+
+	// Progress file is kept since migration did not verify.
+	_, err = os.Stat(progressFile)
+	require.NoError(t, err)
+}
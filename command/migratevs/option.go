@@ -0,0 +1,82 @@
+package migratevs
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultBatchSize          = 16384
+	defaultCheckpointInterval = 1000000
+)
+
+// migrateConfig contains all options for migrating a value store.
+type migrateConfig struct {
+	batchSize          int
+	checkpointInterval uint64
+	onProgress         func(Progress)
+	verify             bool
+}
+
+// Option is a function that sets a value in a migrateConfig.
+type Option func(*migrateConfig) error
+
+// getOpts creates a migrateConfig and applies Options to it.
+func getOpts(opts []Option) (migrateConfig, error) {
+	cfg := migrateConfig{
+		batchSize:          defaultBatchSize,
+		checkpointInterval: defaultCheckpointInterval,
+		verify:             true,
+	}
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return migrateConfig{}, fmt.Errorf("option %d error: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithBatchSize sets the number of multihashes that are written to the
+// destination value store at once.
+func WithBatchSize(size int) Option {
+	return func(c *migrateConfig) error {
+		if size < 1 {
+			return errors.New("batch size must be greater than 0")
+		}
+		c.batchSize = size
+		return nil
+	}
+}
+
+// WithCheckpointInterval sets the number of multihashes migrated between
+// checkpoints. At each checkpoint the destination value store is flushed and
+// the progress is saved, so that an interrupted migration can resume from
+// that point.
+func WithCheckpointInterval(interval uint64) Option {
+	return func(c *migrateConfig) error {
+		if interval == 0 {
+			return errors.New("checkpoint interval must be greater than 0")
+		}
+		c.checkpointInterval = interval
+		return nil
+	}
+}
+
+// WithProgress sets a function that is called with the progress of the
+// migration at each checkpoint.
+func WithProgress(onProgress func(Progress)) Option {
+	return func(c *migrateConfig) error {
+		c.onProgress = onProgress
+		return nil
+	}
+}
+
+// WithVerify sets whether the multihashes and values in the destination
+// value store are counted and compared with the source after migrating.
+// Verification is enabled by default.
+func WithVerify(verify bool) Option {
+	return func(c *migrateConfig) error {
+		c.verify = verify
+		return nil
+	}
+}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"encoding/base64"
 	"fmt"
 
 	httpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
@@ -24,6 +25,10 @@ var getProvidersCmd = &cli.Command{
 	Flags: []cli.Flag{
 		indexerHostFlag,
 		providerFlag,
+		&cli.BoolFlag{
+			Name:  "contexts",
+			Usage: "Show the index count for each of the provider's context IDs",
+		},
 	},
 	Action: getProvidersAction,
 }
@@ -46,7 +51,12 @@ func getProvidersAction(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	prov, err := cl.GetProvider(cctx.Context, peerID)
+	var prov *model.ProviderInfo
+	if cctx.Bool("contexts") {
+		prov, err = cl.GetProviderWithContexts(cctx.Context, peerID)
+	} else {
+		prov, err = cl.GetProvider(cctx.Context, peerID)
+	}
 	if err != nil {
 		return err
 	}
@@ -104,7 +114,16 @@ func showProviderInfo(pinfo *model.ProviderInfo) {
 		fmt.Println("    FrozenAtTime:", pinfo.FrozenAtTime)
 	}
 	fmt.Println("    IndexCount:", pinfo.IndexCount)
+	if len(pinfo.ContextCounts) != 0 {
+		fmt.Println("    ContextCounts:")
+		for _, cc := range pinfo.ContextCounts {
+			fmt.Printf("        %s: %d\n", base64.StdEncoding.EncodeToString(cc.ContextID), cc.Count)
+		}
+	}
 	if pinfo.Inactive {
 		fmt.Println("    Inactive: true")
 	}
+	if pinfo.Paused {
+		fmt.Println("    Paused: true")
+	}
 }
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/filestore"
+	"github.com/ipni/storetheindex/internal/snapshot"
+	"github.com/urfave/cli/v2"
+)
+
+var RestoreCmd = &cli.Command{
+	Name:  "restore",
+	Usage: "Restore indexer state from a snapshot",
+	Description: "Reads a snapshot from the snapshot destination in the indexer's config file, and writes\n" +
+		"it into the configured datastore and value store. The indexer must not be running, and\n" +
+		"the datastore and value store directories must be empty or not exist. The checksum of\n" +
+		"each snapshot file is verified; if verification fails, the restored data must be removed.",
+	Flags:  restoreFlags,
+	Action: restoreAction,
+}
+
+var restoreFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "name",
+		Usage:    "Name of the snapshot to restore",
+		Required: true,
+	},
+}
+
+func restoreAction(cctx *cli.Context) error {
+	cfg, err := loadConfig("")
+	if err != nil {
+		if errors.Is(err, config.ErrNotInitialized) {
+			return errors.New("indexer is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return err
+	}
+	if cfg.Indexer.ValueStoreType == vstoreMemory {
+		return errors.New("cannot restore into memory value store")
+	}
+
+	src, err := filestore.New(cfg.Snapshot.Destination)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot file store: %w", err)
+	}
+	if src == nil {
+		return errors.New("snapshot destination not configured")
+	}
+
+	manifest, err := snapshot.ReadManifest(cctx.Context, src, cctx.String("name"))
+	if err != nil {
+		return err
+	}
+
+	peerID, _, err := cfg.Identity.Decode()
+	if err != nil {
+		return err
+	}
+	if manifest.IndexerID != peerID {
+		fmt.Fprintln(os.Stderr, "WARNING: snapshot was taken from indexer", manifest.IndexerID, "which is not this indexer's identity")
+	}
+
+	// Only restore into new stores, so that restored data is not mixed with
+	// existing data.
+	dirs := []string{cfg.Datastore.Dir, cfg.Indexer.ValueStoreDir}
+	if cfg.Datastore.DirAdvertisements != "" {
+		dirs = append(dirs, cfg.Datastore.DirAdvertisements)
+	}
+	for _, dir := range dirs {
+		dir, err = config.Path("", dir)
+		if err != nil {
+			return err
+		}
+		if err = checkDirEmpty(dir); err != nil {
+			return err
+		}
+	}
+
+	dstore, dstoreAds, err := createDatastore(cfg.Datastore)
+	if err != nil {
+		return err
+	}
+	defer dstore.Close()
+	if dstoreAds != nil {
+		defer dstoreAds.Close()
+	}
+
+	// Do not run garbage collection while restoring.
+	cfg.Indexer.GCInterval = -1
+	valueStore, _, _, err := createValueStore(cctx.Context, cfg.Indexer)
+	if err != nil {
+		return err
+	}
+	defer valueStore.Close()
+
+	fmt.Println("Restoring snapshot", manifest.Name, "created", manifest.Created.Format(time.RFC3339), "from", manifest.ValueStoreType, "value store")
+	start := time.Now()
+	if err = snapshot.Restore(cctx.Context, src, manifest, dstore, dstoreAds, valueStore); err != nil {
+		return fmt.Errorf("%w\nRemove the datastore and value store directories before restoring again", err)
+	}
+
+	for _, info := range manifest.Files {
+		fmt.Printf("Restored %d %s records\n", info.Records, info.Kind)
+	}
+	fmt.Println("Finished restoring snapshot in", time.Since(start).Round(time.Second))
+	return nil
+}
+
+// checkDirEmpty returns an error if the directory exists and is not empty.
+func checkDirEmpty(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Readdirnames(1); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("cannot restore into non-empty directory %s", dir)
+	}
+	return nil
+}
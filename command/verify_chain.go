@@ -0,0 +1,142 @@
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/command/verifychain"
+	"github.com/ipni/storetheindex/config"
+	"github.com/libp2p/go-libp2p"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+var VerifyChainCmd = &cli.Command{
+	Name:  "verify-chain",
+	Usage: "Verify the advertisement chain and entries of a publisher",
+	Description: "Walks a publisher's advertisement chain and entries, keeping data only in memory, and\n" +
+		"reports signature failures, missing entries, depth limit violations, duplicate context IDs,\n" +
+		"and multihash counts. Nothing is written to a value store.",
+	Flags:  verifyChainFlags,
+	Action: verifyChainAction,
+}
+
+var verifyChainFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "addr",
+		Usage:    "Publisher multiaddr, including /p2p/ publisher ID. May be libp2p or HTTP multiaddr",
+		Aliases:  []string{"a"},
+		Required: true,
+	},
+	&cli.IntFlag{
+		Name:  "ad-depth-limit",
+		Usage: "Maximum number of advertisements to walk, 0 for no limit",
+		Value: config.NewIngest().AdvertisementDepthLimit,
+	},
+	&cli.IntFlag{
+		Name:  "entries-depth-limit",
+		Usage: "Maximum number of entry chunks to walk for each advertisement, 0 for no limit",
+		Value: config.NewIngest().EntriesDepthLimit,
+	},
+	&cli.StringFlag{
+		Name:  "topic",
+		Usage: "Topic on which the publisher publishes advertisements",
+		Value: config.NewIngest().PubSubTopic,
+	},
+	&cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Maximum time allowed to verify the chain, 0 for no timeout",
+	},
+}
+
+func verifyChainAction(cctx *cli.Context) error {
+	pubAddr, err := multiaddr.NewMultiaddr(cctx.String("addr"))
+	if err != nil {
+		return fmt.Errorf("bad publisher address: %w", err)
+	}
+
+	ctx := cctx.Context
+	if timeout := cctx.Duration("timeout"); timeout != 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	h, err := libp2p.New()
+	if err != nil {
+		return fmt.Errorf("cannot create libp2p host: %w", err)
+	}
+	defer h.Close()
+
+	start := time.Now()
+	report, err := verifychain.Verify(ctx, h, pubAddr,
+		verifychain.WithAdDepthLimit(cctx.Int("ad-depth-limit")),
+		verifychain.WithEntriesDepthLimit(cctx.Int("entries-depth-limit")),
+		verifychain.WithTopic(cctx.String("topic")))
+	if err != nil {
+		return err
+	}
+
+	showVerifyReport(report, time.Since(start))
+	if !report.OK() {
+		return errors.New("advertisement chain has errors")
+	}
+	return nil
+}
+
+func showVerifyReport(r *verifychain.Report, elapsed time.Duration) {
+	fmt.Println("Publisher:", r.Publisher)
+	if r.Head == cid.Undef {
+		fmt.Println("    Publisher has no advertisements")
+		return
+	}
+	fmt.Println("    Head:", r.Head)
+	fmt.Println("    Advertisements:", r.AdCount)
+	fmt.Println("        Removal:", r.RmCount)
+	fmt.Println("        No entries:", r.NoEntriesCount)
+	fmt.Println("    Multihashes:", r.MultihashCount)
+	fmt.Println("        Entry chunks:", r.ChunkCount)
+	fmt.Println("        HAMT entries:", r.HamtCount)
+	fmt.Println("        HAMT multihashes:", r.HamtMultihashCount)
+	fmt.Println("    Elapsed:", elapsed.Round(time.Millisecond))
+
+	if r.ChainDepthExceeded {
+		fmt.Println("Advertisement chain exceeds depth limit")
+	}
+	if r.MissingAd != cid.Undef {
+		fmt.Println("Missing advertisement:", r.MissingAd)
+	}
+	showAdErrors("Invalid advertisements", r.InvalidAds)
+	showAdErrors("Signature failures", r.SignatureFailures)
+	showAdErrors("Missing entries", r.MissingEntries)
+	if len(r.EntriesDepthExceeded) != 0 {
+		fmt.Println("Entries exceeding depth limit:")
+		for _, adCid := range r.EntriesDepthExceeded {
+			fmt.Println("   ", adCid)
+		}
+	}
+	if len(r.DuplicateContextIDs) != 0 {
+		fmt.Println("Duplicate context IDs:")
+		for _, dup := range r.DuplicateContextIDs {
+			fmt.Printf("    %s: provider %s context ID %s previously advertised by %s\n",
+				dup.AdCid, dup.Provider, base64.StdEncoding.EncodeToString(dup.ContextID), dup.PrevAdCid)
+		}
+	}
+	if r.OK() {
+		fmt.Println("No errors found")
+	}
+}
+
+func showAdErrors(title string, adErrs []verifychain.AdError) {
+	if len(adErrs) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, adErr := range adErrs {
+		fmt.Printf("    %s: %s\n", adErr.AdCid, adErr.Err)
+	}
+}
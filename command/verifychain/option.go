@@ -0,0 +1,66 @@
+package verifychain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipni/storetheindex/config"
+)
+
+// verifyConfig contains all options for verifying an advertisement chain.
+type verifyConfig struct {
+	adDepthLimit      int
+	entriesDepthLimit int
+	topic             string
+}
+
+// Option is a function that sets a value in a verifyConfig.
+type Option func(*verifyConfig) error
+
+// getOpts creates a verifyConfig and applies Options to it. Defaults are
+// the same as those used by the indexer for ingestion.
+func getOpts(opts []Option) (verifyConfig, error) {
+	ingestCfg := config.NewIngest()
+	cfg := verifyConfig{
+		adDepthLimit:      ingestCfg.AdvertisementDepthLimit,
+		entriesDepthLimit: ingestCfg.EntriesDepthLimit,
+		topic:             ingestCfg.PubSubTopic,
+	}
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return verifyConfig{}, fmt.Errorf("option %d error: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithAdDepthLimit sets the maximum number of advertisements to walk. A chain
+// longer than this is reported as exceeding the depth limit. A value less
+// than 1 means no limit.
+func WithAdDepthLimit(limit int) Option {
+	return func(c *verifyConfig) error {
+		c.adDepthLimit = limit
+		return nil
+	}
+}
+
+// WithEntriesDepthLimit sets the maximum number of entry chunks to walk for
+// each advertisement. An entries chain longer than this is reported as
+// exceeding the depth limit. A value less than 1 means no limit.
+func WithEntriesDepthLimit(limit int) Option {
+	return func(c *verifyConfig) error {
+		c.entriesDepthLimit = limit
+		return nil
+	}
+}
+
+// WithTopic sets the topic name used by the publisher.
+func WithTopic(topic string) Option {
+	return func(c *verifyConfig) error {
+		if topic == "" {
+			return errors.New("empty topic")
+		}
+		c.topic = topic
+		return nil
+	}
+}
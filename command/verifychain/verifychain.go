@@ -0,0 +1,446 @@
+package verifychain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	hamt "github.com/ipld/go-ipld-adl-hamt"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipni/storetheindex/api/v0/ingest/schema"
+	"github.com/ipni/storetheindex/dagsync"
+	"github.com/ipni/storetheindex/internal/ingest"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	// Import so these codecs get registered.
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+)
+
+// AdError records a problem found with a specific advertisement.
+type AdError struct {
+	AdCid cid.Cid
+	Err   error
+}
+
+// DuplicateContext records an advertisement that re-advertises entries under
+// a context ID that already has entries, without first removing them.
+type DuplicateContext struct {
+	Provider  string
+	ContextID []byte
+	AdCid     cid.Cid
+	// PrevAdCid is the earlier advertisement that advertised entries using
+	// the same provider and context ID.
+	PrevAdCid cid.Cid
+}
+
+// Report is the result of verifying a publisher's advertisement chain.
+type Report struct {
+	// Publisher is the ID of the verified publisher.
+	Publisher peer.ID
+	// Head is the head advertisement CID reported by the publisher.
+	Head cid.Cid
+
+	// AdCount is the number of advertisements walked.
+	AdCount int
+	// RmCount is the number of removal advertisements walked.
+	RmCount int
+	// NoEntriesCount is the number of non-removal advertisements that do not
+	// have entries, which are metadata updates.
+	NoEntriesCount int
+	// MultihashCount is the total number of multihashes in all entries,
+	// including those stored in HAMTs.
+	MultihashCount uint64
+	// HamtCount is the number of advertisements whose entries are a HAMT.
+	HamtCount int
+	// HamtMultihashCount is the number of multihashes stored in HAMTs.
+	HamtMultihashCount uint64
+	// ChunkCount is the number of entry chunks walked.
+	ChunkCount int
+
+	// ChainDepthExceeded is true if the advertisement chain is longer than
+	// the advertisement depth limit.
+	ChainDepthExceeded bool
+	// MissingAd, if not undefined, is the CID of an advertisement that is
+	// linked from the chain but could not be retrieved.
+	MissingAd cid.Cid
+
+	// InvalidAds lists advertisements that could not be decoded or that
+	// failed validation.
+	InvalidAds []AdError
+	// SignatureFailures lists advertisements with an invalid signature.
+	SignatureFailures []AdError
+	// MissingEntries lists advertisements with entries blocks that could not
+	// be retrieved.
+	MissingEntries []AdError
+	// EntriesDepthExceeded lists advertisements with entry chunk chains that
+	// are longer than the entries depth limit.
+	EntriesDepthExceeded []cid.Cid
+	// DuplicateContextIDs lists advertisements that reuse a context ID.
+	DuplicateContextIDs []DuplicateContext
+}
+
+// OK returns true if no problems were found in the advertisement chain.
+func (r *Report) OK() bool {
+	return !r.ChainDepthExceeded && r.MissingAd == cid.Undef &&
+		len(r.InvalidAds) == 0 && len(r.SignatureFailures) == 0 &&
+		len(r.MissingEntries) == 0 && len(r.EntriesDepthExceeded) == 0 &&
+		len(r.DuplicateContextIDs) == 0
+}
+
+type verifier struct {
+	ds         datastore.Batching
+	lsys       ipld.LinkSystem
+	sub        *dagsync.Subscriber
+	publisher  peer.ID
+	adDepth    int
+	entsDepth  int
+	entriesSel ipld.Node
+	report     *Report
+}
+
+// Verify syncs the advertisement chain, and the entries of each
+// advertisement, from the publisher at pubAddr and reports any problems found
+// in them. Nothing is written to a value store; all synced data is kept in
+// memory, and entries are discarded as soon as each advertisement has been
+// checked.
+//
+// The publisher address must include the publisher's peer ID, as a /p2p
+// component, and may be a libp2p or an HTTP multiaddr.
+func Verify(ctx context.Context, h host.Host, pubAddr multiaddr.Multiaddr, options ...Option) (*Report, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+
+	addrInfo, err := peer.AddrInfoFromP2pAddr(pubAddr)
+	if err != nil {
+		return nil, fmt.Errorf("publisher address must contain publisher peer id: %w", err)
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	lsys := mkLinkSystem(ds)
+
+	sub, err := dagsync.NewSubscriber(h, ds, lsys, opts.topic, ingest.Selectors.AdSequence,
+		dagsync.SyncRecursionLimit(recursionLimit(opts.adDepthLimit)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create subscriber: %w", err)
+	}
+	defer sub.Close()
+
+	v := &verifier{
+		ds:         ds,
+		lsys:       lsys,
+		sub:        sub,
+		publisher:  addrInfo.ID,
+		adDepth:    opts.adDepthLimit,
+		entsDepth:  opts.entriesDepthLimit,
+		entriesSel: ingest.Selectors.EntriesWithLimit(recursionLimit(opts.entriesDepthLimit)),
+		report: &Report{
+			Publisher: addrInfo.ID,
+		},
+	}
+
+	adCids, err := v.syncAds(ctx, pubAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the advertisements in the order the indexer ingests them, from
+	// oldest to newest, so that duplicate context IDs are detected in
+	// relation to earlier ads.
+	ctxAds := make(map[string]cid.Cid)
+	for i := len(adCids) - 1; i >= 0; i-- {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		v.checkAd(ctx, adCids[i], ctxAds)
+	}
+
+	return v.report, nil
+}
+
+// syncAds syncs the advertisement chain and returns the CIDs of the
+// advertisements in the chain, ordered from newest to oldest.
+func (v *verifier) syncAds(ctx context.Context, pubAddr multiaddr.Multiaddr) ([]cid.Cid, error) {
+	sel := dagsync.ExploreRecursiveWithStopNode(recursionLimit(v.adDepth), ingest.Selectors.AdSequence, nil)
+	head, err := v.sub.Sync(ctx, v.publisher, cid.Undef, sel, pubAddr)
+	if err != nil {
+		// Nothing can be reported unless at least the head is retrieved.
+		head, err = v.recoverHead(ctx, pubAddr, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+	v.report.Head = head
+	if head == cid.Undef {
+		return nil, nil
+	}
+
+	var adCids []cid.Cid
+	for next := head; next != cid.Undef; {
+		has, err := v.ds.Has(ctx, dsKey(next))
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			if v.adDepth > 0 && len(adCids) >= v.adDepth {
+				v.report.ChainDepthExceeded = true
+			} else {
+				v.report.MissingAd = next
+			}
+			break
+		}
+		adCids = append(adCids, next)
+
+		// The chain cannot be followed past an undecodable ad. The ad is
+		// reported as invalid when it is checked.
+		adNode, err := v.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: next}, schema.AdvertisementPrototype)
+		if err != nil {
+			break
+		}
+		ad, err := schema.UnwrapAdvertisement(adNode)
+		if err != nil || ad.PreviousID == nil {
+			break
+		}
+		next = ad.PreviousID.(cidlink.Link).Cid
+	}
+	return adCids, nil
+}
+
+// recoverHead handles a failed advertisement chain sync. A sync can fail part
+// way through the chain when an advertisement is missing, in which case the
+// blocks that were synced are still checked.
+func (v *verifier) recoverHead(ctx context.Context, pubAddr multiaddr.Multiaddr, syncErr error) (cid.Cid, error) {
+	head, err := v.sub.Sync(ctx, v.publisher, cid.Undef, ingest.Selectors.One, pubAddr)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to sync advertisement chain: %w", syncErr)
+	}
+	return head, nil
+}
+
+func (v *verifier) checkAd(ctx context.Context, adCid cid.Cid, ctxAds map[string]cid.Cid) {
+	v.report.AdCount++
+
+	adNode, err := v.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+	if err != nil {
+		v.report.InvalidAds = append(v.report.InvalidAds, AdError{adCid, err})
+		return
+	}
+	ad, err := schema.UnwrapAdvertisement(adNode)
+	if err != nil {
+		v.report.InvalidAds = append(v.report.InvalidAds, AdError{adCid, err})
+		return
+	}
+	if err = ad.Validate(); err != nil {
+		v.report.InvalidAds = append(v.report.InvalidAds, AdError{adCid, err})
+	}
+	if _, err = ad.VerifySignature(); err != nil {
+		v.report.SignatureFailures = append(v.report.SignatureFailures, AdError{adCid, err})
+	}
+
+	ctxKey := ad.Provider + "/" + string(ad.ContextID)
+	if ad.IsRm {
+		v.report.RmCount++
+		delete(ctxAds, ctxKey)
+		return
+	}
+
+	if ad.Entries == nil || ad.Entries == schema.NoEntries {
+		v.report.NoEntriesCount++
+		return
+	}
+
+	if prevAdCid, ok := ctxAds[ctxKey]; ok {
+		v.report.DuplicateContextIDs = append(v.report.DuplicateContextIDs, DuplicateContext{
+			Provider:  ad.Provider,
+			ContextID: ad.ContextID,
+			AdCid:     adCid,
+			PrevAdCid: prevAdCid,
+		})
+	}
+	ctxAds[ctxKey] = adCid
+
+	if err = v.checkEntries(ctx, adCid, ad.Entries.(cidlink.Link).Cid); err != nil {
+		v.report.MissingEntries = append(v.report.MissingEntries, AdError{adCid, err})
+	}
+}
+
+// checkEntries syncs and counts the entries of an advertisement. The synced
+// entries blocks are removed from memory when finished.
+func (v *verifier) checkEntries(ctx context.Context, adCid, entriesCid cid.Cid) error {
+	syncedCids := []cid.Cid{entriesCid}
+	gatherCids := func(_ peer.ID, c cid.Cid, _ dagsync.SegmentSyncActions) {
+		syncedCids = append(syncedCids, c)
+	}
+	defer func() {
+		for _, c := range syncedCids {
+			v.ds.Delete(ctx, dsKey(c))
+		}
+	}()
+
+	// The entries link can point to either a chain of EntryChunks or a HAMT.
+	// Sync the very first entry so that we can check which type it is.
+	_, err := v.sub.Sync(ctx, v.publisher, entriesCid, ingest.Selectors.One, nil,
+		dagsync.ScopedBlockHook(gatherCids))
+	if err != nil {
+		return fmt.Errorf("failed to sync first entry: %w", err)
+	}
+	node, err := v.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: entriesCid}, basicnode.Prototype.Any)
+	if err != nil {
+		return fmt.Errorf("failed to load first entry: %w", err)
+	}
+
+	if isHAMT(node) {
+		return v.checkHamt(ctx, entriesCid, gatherCids)
+	}
+	return v.checkEntryChunks(ctx, adCid, entriesCid, gatherCids)
+}
+
+func (v *verifier) checkHamt(ctx context.Context, rootCid cid.Cid, gatherCids dagsync.BlockHookFunc) error {
+	v.report.HamtCount++
+
+	node, err := v.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: rootCid}, hamt.HashMapRootPrototype)
+	if err != nil {
+		return fmt.Errorf("failed to load entries as HAMT root node: %w", err)
+	}
+	root := bindnode.Unwrap(node).(*hamt.HashMapRoot)
+	if root == nil {
+		return errors.New("cannot unwrap node as hamt.HashMapRoot")
+	}
+	hn := hamt.Node{
+		HashMapRoot: *root,
+	}.WithLinking(v.lsys, schema.Linkproto)
+
+	// Sync all the links in the hamt, since so far only the root is synced.
+	for _, e := range hn.Hamt.Data {
+		if e.HashMapNode != nil {
+			nodeCid := (*e.HashMapNode).(cidlink.Link).Cid
+			_, err = v.sub.Sync(ctx, v.publisher, nodeCid, ingest.Selectors.All, nil,
+				dagsync.ScopedBlockHook(gatherCids),
+				dagsync.ScopedSegmentDepthLimit(-1))
+			if err != nil {
+				return fmt.Errorf("failed to sync remaining HAMT: %w", err)
+			}
+		}
+	}
+
+	var count uint64
+	mi := hn.MapIterator()
+	for !mi.Done() {
+		if _, _, err = mi.Next(); err != nil {
+			return fmt.Errorf("failed to iterate through HAMT: %w", err)
+		}
+		count++
+	}
+	v.report.HamtMultihashCount += count
+	v.report.MultihashCount += count
+	return nil
+}
+
+func (v *verifier) checkEntryChunks(ctx context.Context, adCid, firstCid cid.Cid, gatherCids dagsync.BlockHookFunc) error {
+	chunk, err := v.loadEntryChunk(ctx, firstCid)
+	if err != nil {
+		return err
+	}
+
+	var syncErr error
+	if chunk.Next != nil {
+		// Sync remaining entry chunks using the entries selector that limits
+		// recursion depth. If the sync fails part way, count the chunks that
+		// were synced to find where the chain is broken.
+		_, syncErr = v.sub.Sync(ctx, v.publisher, chunk.Next.(cidlink.Link).Cid, v.entriesSel, nil,
+			dagsync.ScopedBlockHook(gatherCids),
+			dagsync.ScopedSegmentDepthLimit(-1))
+	}
+
+	// The first chunk is synced separately, so the entries selector allows
+	// up to one more than the depth limit.
+	var chunkCount int
+	for {
+		chunkCount++
+		v.report.ChunkCount++
+		v.report.MultihashCount += uint64(len(chunk.Entries))
+		if chunk.Next == nil {
+			return nil
+		}
+		nextCid := chunk.Next.(cidlink.Link).Cid
+		has, err := v.ds.Has(ctx, dsKey(nextCid))
+		if err != nil {
+			return err
+		}
+		if !has {
+			if v.entsDepth > 0 && chunkCount > v.entsDepth {
+				v.report.EntriesDepthExceeded = append(v.report.EntriesDepthExceeded, adCid)
+				return nil
+			}
+			if syncErr != nil {
+				return fmt.Errorf("failed to sync entries: %w", syncErr)
+			}
+			return fmt.Errorf("missing entry chunk %s", nextCid)
+		}
+		chunk, err = v.loadEntryChunk(ctx, nextCid)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (v *verifier) loadEntryChunk(ctx context.Context, c cid.Cid) (*schema.EntryChunk, error) {
+	node, err := v.lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.EntryChunkPrototype)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load entry chunk %s: %w", c, err)
+	}
+	chunk, err := schema.UnwrapEntryChunk(node)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode entry chunk %s: %w", c, err)
+	}
+	return chunk, nil
+}
+
+// isHAMT checks if the given IPLD node is a HAMT root node by looking for a
+// field named "hamt".
+func isHAMT(n ipld.Node) bool {
+	h, _ := n.LookupByString("hamt")
+	return h != nil
+}
+
+func mkLinkSystem(ds datastore.Batching) ipld.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		val, err := ds.Get(lctx.Ctx, dsKey(lnk.(cidlink.Link).Cid))
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(val), nil
+	}
+	lsys.StorageWriteOpener = func(lctx ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		buf := bytes.NewBuffer(nil)
+		return buf, func(lnk ipld.Link) error {
+			return ds.Put(lctx.Ctx, dsKey(lnk.(cidlink.Link).Cid), buf.Bytes())
+		}, nil
+	}
+	return lsys
+}
+
+func dsKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(c.String())
+}
+
+func recursionLimit(depth int) selector.RecursionLimit {
+	if depth < 1 {
+		return selector.RecursionLimitNone()
+	}
+	return selector.RecursionLimitDepth(int64(depth))
+}
@@ -0,0 +1,152 @@
+package verifychain_test
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/storetheindex/api/v0/ingest/schema"
+	"github.com/ipni/storetheindex/command/verifychain"
+	"github.com/ipni/storetheindex/dagsync/httpsync"
+	"github.com/ipni/storetheindex/dagsync/test"
+	"github.com/ipni/storetheindex/test/typehelpers"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyChain(t *testing.T) {
+	privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	pubAddr := newPublisher(t, privKey, typehelpers.RandomAdBuilder{
+		EntryBuilders: []typehelpers.EntryBuilder{
+			typehelpers.RandomEntryChunkBuilder{ChunkCount: 3, EntriesPerChunk: 10, Seed: 1},
+			typehelpers.RandomHamtEntryBuilder{MultihashCount: 25, BitWidth: 3, BucketSize: 2, Seed: 2},
+			typehelpers.RandomEntryChunkBuilder{ChunkCount: 1, EntriesPerChunk: 5, Seed: 3},
+		},
+		AddRmWithNoEntries: true,
+	})
+
+	report := verify(t, pubAddr)
+	require.True(t, report.OK())
+	require.Equal(t, 4, report.AdCount)
+	require.Equal(t, 1, report.RmCount)
+	require.Equal(t, uint64(30+25+5), report.MultihashCount)
+	require.Equal(t, 1, report.HamtCount)
+	require.Equal(t, uint64(25), report.HamtMultihashCount)
+	require.Equal(t, 4, report.ChunkCount)
+}
+
+func TestVerifyChainErrors(t *testing.T) {
+	privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	providerID, err := peer.IDFromPrivateKey(privKey)
+	require.NoError(t, err)
+
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	lsys := test.MkLinkSystem(srcStore)
+
+	// Entries chain that is longer than the entries depth limit.
+	longEnts := typehelpers.RandomEntryChunkBuilder{ChunkCount: 5, EntriesPerChunk: 2, Seed: 1}.Build(t, lsys)
+	// Entries with a missing chunk.
+	missingEnts := typehelpers.RandomEntryChunkBuilder{ChunkCount: 2, EntriesPerChunk: 2, Seed: 2}.Build(t, lsys)
+	missingChunk := loadChunk(t, lsys, missingEnts).Next
+	require.NoError(t, srcStore.Delete(context.Background(), datastore.NewKey(missingChunk.String())))
+
+	var prev ipld.Link
+	storeAd := func(ad schema.Advertisement, sign bool) ipld.Link {
+		ad.PreviousID = prev
+		ad.Provider = providerID.String()
+		ad.Addresses = []string{"/ip4/127.0.0.1/tcp/9999"}
+		ad.Metadata = []byte("test-metadata")
+		if sign {
+			require.NoError(t, ad.Sign(privKey))
+		}
+		node, err := ad.ToNode()
+		require.NoError(t, err)
+		prev, err = lsys.Store(ipld.LinkContext{}, schema.Linkproto, node)
+		require.NoError(t, err)
+		return prev
+	}
+
+	longAd := storeAd(schema.Advertisement{Entries: longEnts, ContextID: []byte("ctx-1")}, true)
+	missingAd := storeAd(schema.Advertisement{Entries: missingEnts, ContextID: []byte("ctx-2")}, true)
+	dupEnts := typehelpers.RandomEntryChunkBuilder{ChunkCount: 1, EntriesPerChunk: 2, Seed: 3}.Build(t, lsys)
+	dupAd := storeAd(schema.Advertisement{Entries: dupEnts, ContextID: []byte("ctx-1")}, true)
+	badSigAd := storeAd(schema.Advertisement{Entries: schema.NoEntries, ContextID: []byte("ctx-3")}, false)
+
+	pubAddr := startPublisher(t, privKey, lsys, prev)
+	report := verify(t, pubAddr, verifychain.WithEntriesDepthLimit(2))
+	require.False(t, report.OK())
+	require.Equal(t, 4, report.AdCount)
+
+	require.Len(t, report.SignatureFailures, 1)
+	require.Equal(t, cidOf(badSigAd), report.SignatureFailures[0].AdCid)
+
+	require.Len(t, report.MissingEntries, 1)
+	require.Equal(t, cidOf(missingAd), report.MissingEntries[0].AdCid)
+
+	require.Len(t, report.EntriesDepthExceeded, 1)
+	require.Equal(t, cidOf(longAd), report.EntriesDepthExceeded[0])
+
+	require.Len(t, report.DuplicateContextIDs, 1)
+	require.Equal(t, cidOf(dupAd), report.DuplicateContextIDs[0].AdCid)
+	require.Equal(t, cidOf(longAd), report.DuplicateContextIDs[0].PrevAdCid)
+
+	// Check that a chain longer than the ad depth limit is reported.
+	report = verify(t, pubAddr, verifychain.WithAdDepthLimit(2))
+	require.True(t, report.ChainDepthExceeded)
+}
+
+func verify(t *testing.T, pubAddr multiaddr.Multiaddr, options ...verifychain.Option) *verifychain.Report {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h := test.MkTestHost()
+	t.Cleanup(func() {
+		h.Close()
+	})
+	report, err := verifychain.Verify(ctx, h, pubAddr, options...)
+	require.NoError(t, err)
+	return report
+}
+
+func newPublisher(t *testing.T, privKey crypto.PrivKey, adBuilder typehelpers.RandomAdBuilder) multiaddr.Multiaddr {
+	lsys := test.MkLinkSystem(dssync.MutexWrap(datastore.NewMapDatastore()))
+	head := adBuilder.Build(t, lsys, privKey)
+	return startPublisher(t, privKey, lsys, head)
+}
+
+func startPublisher(t *testing.T, privKey crypto.PrivKey, lsys ipld.LinkSystem, head ipld.Link) multiaddr.Multiaddr {
+	pubID, err := peer.IDFromPrivateKey(privKey)
+	require.NoError(t, err)
+	pub, err := httpsync.NewPublisher("127.0.0.1:0", lsys, pubID, privKey)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		pub.Close()
+	})
+	require.NoError(t, pub.SetRoot(context.Background(), cidOf(head)))
+
+	p2pAddr, err := multiaddr.NewComponent("p2p", pubID.String())
+	require.NoError(t, err)
+	return pub.Addrs()[0].Encapsulate(p2pAddr)
+}
+
+func loadChunk(t *testing.T, lsys ipld.LinkSystem, lnk ipld.Link) *schema.EntryChunk {
+	node, err := lsys.Load(ipld.LinkContext{}, lnk, schema.EntryChunkPrototype)
+	require.NoError(t, err)
+	chunk, err := schema.UnwrapEntryChunk(node)
+	require.NoError(t, err)
+	return chunk
+}
+
+func cidOf(lnk ipld.Link) cid.Cid {
+	return lnk.(cidlink.Link).Cid
+}
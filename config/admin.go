@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Admin roles, from least to most privileged. Each role is also allowed the
+// operations of the less privileged roles.
+const (
+	// AdminRoleReadOnly allows reading indexer status, assignments, metrics,
+	// and events.
+	AdminRoleReadOnly = "read-only"
+	// AdminRoleOperator allows syncing, allowing and blocking peers,
+	// assigning and unassigning publishers, reloading config, and changing log
+	// levels.
+	AdminRoleOperator = "operator"
+	// AdminRoleSuperuser allows freezing the indexer, importing data and
+	// providers, handoff, and profiling.
+	AdminRoleSuperuser = "superuser"
+)
+
+// Admin holds configuration for the admin server.
+type Admin struct {
+	// AuthorizedPeers is a list of peer IDs that are allowed to perform admin
+	// operations using the libp2p admin protocol. The libp2p admin protocol is
+	// only enabled when this list is not empty.
+	AuthorizedPeers []string
+	// Tokens are the bearer tokens accepted by the admin HTTP server, and the
+	// role granted to each. When no tokens are configured, the admin HTTP
+	// server does not require authentication, and should only listen on a
+	// loopback address.
+	Tokens []AdminToken
+}
+
+// AdminToken is a bearer token for the admin HTTP server.
+type AdminToken struct {
+	// Token is the secret value sent in the Authorization header as
+	// "Bearer <token>".
+	Token string
+	// Role is the role granted to requests that present the token. It is one
+	// of "read-only", "operator", or "superuser".
+	Role string
+}
+
+// NewAdmin returns Admin with values set to their defaults.
+func NewAdmin() Admin {
+	return Admin{}
+}
+
+// AuthorizedPeerIDs decodes the AuthorizedPeers peer ID strings.
+func (a Admin) AuthorizedPeerIDs() ([]peer.ID, error) {
+	if len(a.AuthorizedPeers) == 0 {
+		return nil, nil
+	}
+	peerIDs := make([]peer.ID, len(a.AuthorizedPeers))
+	for i, idStr := range a.AuthorizedPeers {
+		peerID, err := peer.Decode(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("bad authorized peer id %q: %w", idStr, err)
+		}
+		peerIDs[i] = peerID
+	}
+	return peerIDs, nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import "testing"
+
+func TestAdminAuthorizedPeerIDs(t *testing.T) {
+	a := Admin{
+		AuthorizedPeers: []string{"12D3KooWBckWLKiYoUX4k3HTrbrSe4DD5SPNTKgP6vKTva1NaRkJ"},
+	}
+	peerIDs, err := a.AuthorizedPeerIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peerIDs) != 1 || peerIDs[0].String() != a.AuthorizedPeers[0] {
+		t.Fatalf("unexpected peer IDs: %v", peerIDs)
+	}
+
+	a.AuthorizedPeers = append(a.AuthorizedPeers, "not-a-peer-id")
+	if _, err = a.AuthorizedPeerIDs(); err == nil {
+		t.Fatal("expected error decoding bad peer id")
+	}
+}
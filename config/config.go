@@ -15,14 +15,20 @@ type Config struct {
 	Version   int       // config version
 	Identity  Identity  // peer identity
 	Addresses Addresses // addresses to listen on
+	Admin     Admin     // admin server configuration
 	Bootstrap Bootstrap // Peers to connect to for gossip
 	Datastore Datastore // datastore config
+	Denylist  Denylist  // content denylist configuration.
 	Discovery Discovery // provider pubsub peers
 	Finder    Finder    // finder code configuration
 	Indexer   Indexer   // indexer code configuration
 	Ingest    Ingest    // ingestion related configuration.
 	Logging   Logging   // logging configuration.
+	Metrics   Metrics   // metrics configuration.
 	Peering   Peering   // peering service configuration.
+	ReadOnly  ReadOnly  // read-only replica configuration.
+	Snapshot  Snapshot  // snapshot destination configuration.
+	Tracing   Tracing   // tracing configuration.
 }
 
 const (
@@ -103,14 +109,20 @@ func Load(filePath string) (*Config, error) {
 	// Populate with initial values in case they are not present in config.
 	cfg := Config{
 		Addresses: NewAddresses(),
+		Admin:     NewAdmin(),
 		Bootstrap: NewBootstrap(),
 		Datastore: NewDatastore(),
+		Denylist:  NewDenylist(),
 		Discovery: NewDiscovery(),
 		Finder:    NewFinder(),
 		Indexer:   NewIndexer(),
 		Ingest:    NewIngest(),
 		Logging:   NewLogging(),
+		Metrics:   NewMetrics(),
 		Peering:   NewPeering(),
+		ReadOnly:  NewReadOnly(),
+		Snapshot:  NewSnapshot(),
+		Tracing:   NewTracing(),
 	}
 
 	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
@@ -186,4 +198,7 @@ func (c *Config) populateUnset() {
 	c.Indexer.populateUnset()
 	c.Ingest.populateUnset()
 	c.Logging.populateUnset()
+	c.Metrics.populateUnset()
+	c.ReadOnly.populateUnset()
+	c.Tracing.populateUnset()
 }
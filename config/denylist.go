@@ -0,0 +1,21 @@
+package config
+
+// Denylist configures content that is not returned in find results. The
+// denylist is reloaded when the indexer's config is reloaded.
+type Denylist struct {
+	// Sources is a list of denylist files and HTTP(S) URLs. A file path that
+	// is not absolute is relative to the indexer repo directory. Each source
+	// is in the compact denylist format, with one entry per line, or is a
+	// legacy badbits JSON list of anchors. Entries may be CIDs, "/ipfs/<cid>"
+	// paths, base58 multihashes, or double-hashed "//<hash>" entries.
+	Sources []string
+	// SkipIngest, when true, skips indexing denylisted multihashes when
+	// ingesting advertisements. When false, denylisted multihashes are
+	// indexed but are not returned in find results.
+	SkipIngest bool
+}
+
+// NewDenylist returns Denylist with values set to their defaults.
+func NewDenylist() Denylist {
+	return Denylist{}
+}
@@ -13,10 +13,35 @@ type Finder struct {
 	// out writes of the response. A value of zero sets the default and a
 	// negative value means there will be no timeout.
 	ApiWriteTimeout Duration
+	// CacheSize is the maximum number of multihash lookup results kept in the
+	// finder cache. This cache holds both results that have values and
+	// results that have none, unlike Indexer.CacheSize which only caches
+	// values. A value of zero disables the finder cache.
+	CacheSize int
+	// CacheTTL is how long a lookup result that has values is kept in the
+	// finder cache. Cached results are also removed when values for the
+	// multihash, or for a provider or provider context in the result, are
+	// changed by ingestion. A value of zero sets the default.
+	CacheTTL Duration
+	// CacheNegativeTTL is how long a lookup result that has no values is kept
+	// in the finder cache. A value of zero sets the default, and a negative
+	// value disables caching results that have no values.
+	CacheNegativeTTL Duration
+	// Federation configures other indexers that are queried, along with this
+	// indexer, to answer find requests.
+	Federation FinderFederation
 	// MaxConnections is maximum number of simultaneous connections that the
 	// HTTP server will accept. A value of zero sets the default and a negative
 	// value means there is no limit.
 	MaxConnections int
+	// RateLimit configures rate limiting of find requests from each client.
+	RateLimit FinderRateLimit
+	// StatsDetailsRefreshInterval is how long the detailed stats, returned by
+	// /stats?details=true, are cached before they are collected again.
+	StatsDetailsRefreshInterval Duration
+	// StatsRefreshInterval is how often the indexer entries stats, returned by
+	// /stats, are refreshed.
+	StatsRefreshInterval Duration
 	// Webpage is a domain to display when the homepage of the finder is
 	// accessed over HTTP.
 	Webpage string
@@ -24,10 +49,17 @@ type Finder struct {
 
 func NewFinder() Finder {
 	return Finder{
-		ApiReadTimeout:  Duration(30 * time.Second),
-		ApiWriteTimeout: Duration(30 * time.Second),
-		MaxConnections:  8_000,
-		Webpage:         "https://web-ipni.cid.contact/",
+		ApiReadTimeout:   Duration(30 * time.Second),
+		ApiWriteTimeout:  Duration(30 * time.Second),
+		CacheTTL:         Duration(5 * time.Minute),
+		CacheNegativeTTL: Duration(30 * time.Second),
+		Federation:       NewFinderFederation(),
+		MaxConnections:   8_000,
+		RateLimit:        NewFinderRateLimit(),
+		Webpage:          "https://web-ipni.cid.contact/",
+
+		StatsDetailsRefreshInterval: Duration(time.Minute),
+		StatsRefreshInterval:        Duration(time.Hour),
 	}
 }
 
@@ -40,10 +72,147 @@ func (f *Finder) populateUnset() {
 	if f.ApiWriteTimeout == 0 {
 		f.ApiWriteTimeout = def.ApiWriteTimeout
 	}
+	if f.CacheTTL == 0 {
+		f.CacheTTL = def.CacheTTL
+	}
+	if f.CacheNegativeTTL == 0 {
+		f.CacheNegativeTTL = def.CacheNegativeTTL
+	}
+	f.Federation.populateUnset()
 	if f.MaxConnections == 0 {
 		f.MaxConnections = def.MaxConnections
 	}
+	if f.StatsDetailsRefreshInterval == 0 {
+		f.StatsDetailsRefreshInterval = def.StatsDetailsRefreshInterval
+	}
+	if f.StatsRefreshInterval == 0 {
+		f.StatsRefreshInterval = def.StatsRefreshInterval
+	}
 	if f.Webpage == "" {
 		f.Webpage = def.Webpage
 	}
+	f.RateLimit.populateUnset()
+}
+
+// FinderFederation configures remote indexers that are queried, in parallel
+// with the local value store, to answer find requests. Results from all
+// indexers are merged into a single response.
+type FinderFederation struct {
+	// Backends are the remote indexers to query.
+	Backends []FederationBackend
+	// Timeout is the maximum time to wait for a response from a backend that
+	// does not set its own timeout. A backend that does not respond in time
+	// is reported as failed, and the response has results from the others.
+	Timeout Duration
+}
+
+// FederationBackend configures a remote indexer that is queried for find
+// requests.
+type FederationBackend struct {
+	// URL is the base URL of the remote indexer's HTTP finder API.
+	URL string
+	// Timeout, if non-zero, is the maximum time to wait for a response from
+	// this backend.
+	Timeout Duration
+}
+
+// NewFinderFederation returns FinderFederation with values set to their
+// defaults.
+func NewFinderFederation() FinderFederation {
+	return FinderFederation{
+		Timeout: Duration(5 * time.Second),
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *FinderFederation) populateUnset() {
+	def := NewFinderFederation()
+	if c.Timeout == 0 {
+		c.Timeout = def.Timeout
+	}
+}
+
+// FinderRateLimit configures token-bucket rate limits for find requests. Each
+// client is limited separately. A client is identified by its API key if the
+// request has one, or by its IP address otherwise.
+type FinderRateLimit struct {
+	// RequestsPerSecond is the number of single multihash or CID find
+	// requests allowed per second from each client. A value of zero disables
+	// rate limiting of these requests.
+	RequestsPerSecond int
+	// BurstSize is the number of find requests that a client can make at once
+	// before being limited to RequestsPerSecond. A value of zero results in 5
+	// times RequestsPerSecond.
+	BurstSize int
+	// BatchMultihashesPerSecond is the number of multihashes, looked up by
+	// batch find requests, allowed per second from each client. A value of
+	// zero disables rate limiting of batch find requests.
+	BatchMultihashesPerSecond int
+	// BatchBurstSize is the number of multihashes that a client can look up
+	// at once, using batch requests, before being limited to
+	// BatchMultihashesPerSecond. Batch requests with more multihashes than
+	// this are rejected. A value of zero results in 5 times
+	// BatchMultihashesPerSecond.
+	BatchBurstSize int
+	// APIKeyHeader is the HTTP header that holds a client's API key.
+	APIKeyHeader string
+	// APIKeys configures separate limits for clients that present an API key.
+	// A request with an API key that is not listed here is limited by the
+	// client's IP address.
+	APIKeys []FinderAPIKey
+	// TrustForwardedFor, if true, identifies a client by the last address in
+	// the X-Forwarded-For header, when present. This is the address added by
+	// the proxy in front of the finder. Only enable this when the finder is
+	// behind a single proxy that appends to this header.
+	TrustForwardedFor bool
+}
+
+// FinderAPIKey configures the rate limits for a client with an API key. A
+// limit of zero means the client is not limited.
+type FinderAPIKey struct {
+	// Key is the API key that the client sends in the APIKeyHeader.
+	Key string
+	// RequestsPerSecond is the number of find requests per second allowed.
+	RequestsPerSecond int
+	// BurstSize is the number of find requests allowed at once. A value of
+	// zero results in 5 times RequestsPerSecond.
+	BurstSize int
+	// BatchMultihashesPerSecond is the number of multihashes per second
+	// allowed in batch find requests.
+	BatchMultihashesPerSecond int
+	// BatchBurstSize is the number of multihashes allowed at once in batch
+	// find requests. Batch requests with more multihashes than this are
+	// rejected. A value of zero results in 5 times BatchMultihashesPerSecond.
+	BatchBurstSize int
+}
+
+// NewFinderRateLimit returns FinderRateLimit with values set to their
+// defaults.
+func NewFinderRateLimit() FinderRateLimit {
+	return FinderRateLimit{
+		APIKeyHeader: "X-Api-Key",
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *FinderRateLimit) populateUnset() {
+	def := NewFinderRateLimit()
+	if c.BurstSize == 0 {
+		c.BurstSize = 5 * c.RequestsPerSecond
+	}
+	if c.BatchBurstSize == 0 {
+		c.BatchBurstSize = 5 * c.BatchMultihashesPerSecond
+	}
+	if c.APIKeyHeader == "" {
+		c.APIKeyHeader = def.APIKeyHeader
+	}
+	for i := range c.APIKeys {
+		k := &c.APIKeys[i]
+		if k.BurstSize == 0 {
+			k.BurstSize = 5 * k.RequestsPerSecond
+		}
+		if k.BatchBurstSize == 0 {
+			k.BatchBurstSize = 5 * k.BatchMultihashesPerSecond
+		}
+	}
 }
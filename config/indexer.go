@@ -20,6 +20,13 @@ type Indexer struct {
 	// requests to the DHStore service. A value < 1 results in the default
 	// size.
 	DHBatchSize int
+	// DHStoreDir is the directory where a local double-hashed index is kept.
+	// If set, the index is populated at ingest and the finder serves
+	// reader-privacy lookups from it, without an external DHStore service.
+	// If this is not an absolute path then the location is relative to the
+	// indexer repo directory. The index is not included in snapshots, so this
+	// cannot be set along with Snapshot.Destination.
+	DHStoreDir string
 	// DHStoreURL is the base URL for the DHStore service. This option value
 	// tells the indexer core to use a DHStore service, if configured.
 	DHStoreURL string
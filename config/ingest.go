@@ -51,8 +51,25 @@ type Ingest struct {
 	// PubSubTopic sets the topic name to which to subscribe for ingestion
 	// announcements.
 	PubSubTopic string
+	// PublisherOverrides configures ingest limits and timeouts for specific
+	// publishers. This allows large trusted publishers to have higher limits
+	// than other publishers.
+	PublisherOverrides []PublisherOverride
+	// PurgeRemovedProviders enables a background process that deletes the
+	// values, for each context ID, of providers that have been removed from
+	// the indexer. This requires index counts, which record the context IDs.
+	// The mappings from multihashes to the deleted values are not deleted by
+	// the purge, and are still removed only when they appear in find results.
+	PurgeRemovedProviders bool
+	// PurgeRateLimit is the maximum number of provider contexts, per second,
+	// that are removed from the value store by the background purge process.
+	// Must not be negative.
+	PurgeRateLimit int
 	// RateLimit contains rate-limiting configuration.
 	RateLimit RateLimit
+	// Replication configures publishing ingested advertisements to, and
+	// replicating advertisements from, other indexers.
+	Replication Replication
 	// ResendDirectAnnounce determines whether or not to re-publish direct
 	// announce messages over gossip pubsub. When a single indexer receives an
 	// announce message via HTTP, enabling this lets the indexers re-publish
@@ -76,6 +93,32 @@ type Ingest struct {
 	SyncTimeout Duration
 }
 
+// PublisherOverride configures ingest limits and timeouts for a specific
+// publisher. A zero value for any setting means that the corresponding Ingest
+// setting is used.
+type PublisherOverride struct {
+	// PublisherID identifies the publisher that this override applies to.
+	PublisherID string
+	// AdvertisementDepthLimit overrides Ingest.AdvertisementDepthLimit. The
+	// value -1 means no limit.
+	AdvertisementDepthLimit int
+	// EntriesDepthLimit overrides Ingest.EntriesDepthLimit. The value -1
+	// means no limit.
+	EntriesDepthLimit int
+	// SyncSegmentDepthLimit overrides Ingest.SyncSegmentDepthLimit. The value
+	// -1 disables segmented sync.
+	SyncSegmentDepthLimit int
+	// SyncTimeout overrides Ingest.SyncTimeout.
+	SyncTimeout Duration
+	// BlocksPerSecond overrides Ingest.RateLimit.BlocksPerSecond, and rate
+	// limits the publisher regardless of the RateLimit Apply and Except
+	// settings. The value -1 means that the publisher is not rate limited.
+	BlocksPerSecond int
+	// BurstSize overrides Ingest.RateLimit.BurstSize when BlocksPerSecond is
+	// overridden. A value of 0 results in 5 times BlocksPerSecond.
+	BurstSize int
+}
+
 // FileStore configures a particular file store implementation.
 type FileStore struct {
 	// Type of file store to use: "", "local", "s3"
@@ -116,7 +159,9 @@ func NewIngest() Ingest {
 		HttpSyncTimeout:         Duration(10 * time.Second),
 		IngestWorkerCount:       10,
 		PubSubTopic:             "/indexer/ingest/mainnet",
+		PurgeRateLimit:          100,
 		RateLimit:               NewRateLimit(),
+		Replication:             NewReplication(),
 		StoreBatchSize:          4096,
 		SyncSegmentDepthLimit:   2_000,
 		SyncTimeout:             Duration(2 * time.Hour),
@@ -151,7 +196,11 @@ func (c *Ingest) populateUnset() {
 	if c.PubSubTopic == "" {
 		c.PubSubTopic = def.PubSubTopic
 	}
+	if c.PurgeRateLimit == 0 {
+		c.PurgeRateLimit = def.PurgeRateLimit
+	}
 	c.RateLimit.populateUnset()
+	c.Replication.populateUnset()
 	if c.StoreBatchSize == 0 {
 		c.StoreBatchSize = def.StoreBatchSize
 	}
@@ -23,14 +23,20 @@ func InitWithIdentity(identity Identity) (*Config, error) {
 	conf := &Config{
 		Version:   Version,
 		Addresses: NewAddresses(),
+		Admin:     NewAdmin(),
 		Bootstrap: NewBootstrap(),
 		Datastore: NewDatastore(),
+		Denylist:  NewDenylist(),
 		Discovery: NewDiscovery(),
 		Finder:    NewFinder(),
 		Identity:  identity,
 		Indexer:   NewIndexer(),
 		Ingest:    NewIngest(),
 		Logging:   NewLogging(),
+		Metrics:   NewMetrics(),
+		ReadOnly:  NewReadOnly(),
+		Snapshot:  NewSnapshot(),
+		Tracing:   NewTracing(),
 	}
 
 	return conf, nil
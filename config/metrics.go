@@ -0,0 +1,31 @@
+package config
+
+// Metrics configures the metrics that are served by the admin server.
+type Metrics struct {
+	// PublisherLabels enables ingest metrics that are labeled by publisher.
+	// These include counts of ingested advertisements and multihashes, sync
+	// errors, the time of the last successful sync, and ingest lag, for each
+	// publisher.
+	PublisherLabels bool
+	// MaxPublisherLabels is the maximum number of publishers that have their
+	// own series when PublisherLabels is enabled. This caps the cardinality of
+	// the per-publisher metrics. Metrics for publishers beyond this number are
+	// recorded under the publisher label "other".
+	MaxPublisherLabels int
+}
+
+// NewMetrics returns Metrics with values set to their defaults.
+func NewMetrics() Metrics {
+	return Metrics{
+		MaxPublisherLabels: 1000,
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *Metrics) populateUnset() {
+	def := NewMetrics()
+
+	if c.MaxPublisherLabels == 0 {
+		c.MaxPublisherLabels = def.MaxPublisherLabels
+	}
+}
@@ -31,6 +31,40 @@ type Policy struct {
 	// PublishExcept. If Publish is true, then all allowed peers can publish
 	// advertisements for any provider, unless listed in PublishExcept.
 	PublishExcept []string
+
+	// Addrs configures which publisher and provider addresses are allowed,
+	// by IP network, DNS name, and transport. This applies to addresses in
+	// announce messages, to addresses used to sync with publishers, and to
+	// provider addresses returned by the finder.
+	Addrs AddrPolicy
+}
+
+// AddrPolicy configures rules that allow or block multiaddrs. An address is
+// blocked if it matches any of the block rules. If there are any AllowCIDRs
+// or AllowDNSSuffixes, then an address is only allowed if its IP address or
+// DNS name matches one of them. If there are any AllowTransports, then an
+// address is only allowed if it uses one of them. An empty AddrPolicy allows
+// all addresses.
+type AddrPolicy struct {
+	// AllowCIDRs is a list of IP networks, in CIDR notation such as
+	// "203.0.113.0/24", that IP addresses must be in.
+	AllowCIDRs []string
+	// BlockCIDRs is a list of IP networks, in CIDR notation, that IP
+	// addresses are blocked from.
+	BlockCIDRs []string
+	// AllowDNSSuffixes is a list of domain names, such as "example.com", that
+	// DNS addresses must be in. A name matches a suffix if it is the same
+	// name or is a subdomain of it.
+	AllowDNSSuffixes []string
+	// BlockDNSSuffixes is a list of domain names that DNS addresses are
+	// blocked from.
+	BlockDNSSuffixes []string
+	// AllowTransports is a list of multiaddr protocol names, such as "tcp",
+	// "quic-v1", "ws", or "http", that addresses must use one of.
+	AllowTransports []string
+	// BlockTransports is a list of multiaddr protocol names that addresses
+	// are blocked from using.
+	BlockTransports []string
 }
 
 // NewPolicy returns Policy with values set to their defaults.
@@ -0,0 +1,38 @@
+package config
+
+import "time"
+
+// ReadOnly configures how a read-only finder replica, started with the daemon
+// --read-only flag, follows updates from a primary indexer.
+type ReadOnly struct {
+	// FollowSnapshots, when true, loads the newest snapshot in the
+	// Snapshot.Destination file store each time a new snapshot appears there.
+	// When false, the replica serves the data in its configured datastore and
+	// value store directories.
+	FollowSnapshots bool
+	// CheckInterval is how often to check for a new snapshot.
+	CheckInterval Duration
+	// Dir is the directory that snapshots are restored into. A relative path
+	// is relative to the indexer's config directory.
+	Dir string
+}
+
+// NewReadOnly returns ReadOnly with values set to their defaults.
+func NewReadOnly() ReadOnly {
+	return ReadOnly{
+		CheckInterval: Duration(5 * time.Minute),
+		Dir:           "replica",
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *ReadOnly) populateUnset() {
+	def := NewReadOnly()
+
+	if c.CheckInterval == 0 {
+		c.CheckInterval = def.CheckInterval
+	}
+	if c.Dir == "" {
+		c.Dir = def.Dir
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+// Replication configures replicating ingested advertisements between
+// indexers. An indexer can publish the advertisement chains it has ingested,
+// and can follow another indexer to replicate the chains of selected
+// publishers from that indexer instead of syncing them from the publishers.
+type Replication struct {
+	// ListenMultiaddr is the address on which to serve ingested
+	// advertisements and entries, over HTTP, to indexers that follow this
+	// indexer. An empty value disables publishing. When enabled,
+	// advertisements and entries are kept in the datastore after they are
+	// ingested, including when a CAR mirror is used. Only data ingested while
+	// publishing is enabled is available to followers.
+	ListenMultiaddr string
+	// FollowIndexer is the HTTP multiaddr of the replication publisher of the
+	// indexer to follow, such as "/dns4/indexer.example.com/tcp/3004/http".
+	FollowIndexer string
+	// FollowPublishers is the list of publisher IDs whose advertisement
+	// chains are replicated from FollowIndexer. Announcements from these
+	// publishers are ignored, and they are never synced with directly.
+	FollowPublishers []string
+	// FollowInterval is how often to check FollowIndexer for new
+	// advertisements from the followed publishers.
+	FollowInterval Duration
+}
+
+// NewReplication returns Replication with values set to their defaults.
+func NewReplication() Replication {
+	return Replication{
+		FollowInterval: Duration(time.Minute),
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *Replication) populateUnset() {
+	def := NewReplication()
+
+	if c.FollowInterval == 0 {
+		c.FollowInterval = def.FollowInterval
+	}
+}
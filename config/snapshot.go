@@ -0,0 +1,14 @@
+package config
+
+// Snapshot configures where snapshots of the indexer's state are written.
+type Snapshot struct {
+	// Destination is the file store that snapshots are written to, and
+	// restored from. Snapshots are disabled if no file store type is
+	// configured.
+	Destination FileStore
+}
+
+// NewSnapshot returns Snapshot with values set to their defaults.
+func NewSnapshot() Snapshot {
+	return Snapshot{}
+}
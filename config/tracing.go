@@ -0,0 +1,45 @@
+package config
+
+// Tracing configures OpenTelemetry tracing.
+type Tracing struct {
+	// Exporter selects where spans are sent. Values are "none", which
+	// disables tracing, "otlp", which sends spans to an OTLP/HTTP collector at
+	// Endpoint, and "stdout", which writes spans to stdout. The default value
+	// is "none".
+	Exporter string
+	// Endpoint is the base URL of the OTLP/HTTP collector that spans are sent
+	// to when Exporter is "otlp". Spans are posted to the "/v1/traces" path of
+	// this URL.
+	Endpoint string
+	// SampleRatio is the fraction, from 0 to 1, of traces that are sampled.
+	// Spans with a sampled parent, such as from a traced announce or sync
+	// request, are always sampled.
+	SampleRatio float64
+	// ServiceName is the service name that spans are reported with.
+	ServiceName string
+}
+
+// NewTracing returns Tracing with values set to their defaults.
+func NewTracing() Tracing {
+	return Tracing{
+		Exporter:    "none",
+		Endpoint:    "http://localhost:4318",
+		SampleRatio: 1.0,
+		ServiceName: "storetheindex",
+	}
+}
+
+// populateUnset replaces zero-values in the config with default values.
+func (c *Tracing) populateUnset() {
+	def := NewTracing()
+
+	if c.Exporter == "" {
+		c.Exporter = def.Exporter
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = def.Endpoint
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = def.ServiceName
+	}
+}
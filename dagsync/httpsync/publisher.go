@@ -81,6 +81,29 @@ func NewPublisher(address string, lsys ipld.LinkSystem, peerID peer.ID, privKey
 	return pub, nil
 }
 
+// NewPublisherWithoutServer creates a new http publisher that does not run its
+// own HTTP server. The publisher is an http.Handler that the caller serves,
+// at the given address, using its own server.
+func NewPublisherWithoutServer(addr multiaddr.Multiaddr, lsys ipld.LinkSystem, peerID peer.ID, privKey ic.PrivKey, options ...Option) (*publisher, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if privKey == nil {
+		return nil, errors.New("private key required to sign head requests")
+	}
+
+	return &publisher{
+		addr:      addr,
+		lsys:      lsys,
+		peerID:    peerID,
+		privKey:   privKey,
+		senders:   opts.senders,
+		extraData: opts.extraData,
+	}, nil
+}
+
 // Addrs returns the addresses, as []multiaddress, that the publisher is
 // listening on.
 func (p *publisher) Addrs() []multiaddr.Multiaddr {
@@ -125,12 +148,13 @@ func (p *publisher) UpdateRootWithAddrs(ctx context.Context, c cid.Cid, addrs []
 
 func (p *publisher) Close() error {
 	var errs error
-	err := p.closer.Close()
-	if err != nil {
-		errs = multierror.Append(errs, err)
+	if p.closer != nil {
+		if err := p.closer.Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
 	}
 	for _, sender := range p.senders {
-		if err = sender.Close(); err != nil {
+		if err := sender.Close(); err != nil {
 			errs = multierror.Append(errs, err)
 		}
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/ipni/storetheindex/announce"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 	"golang.org/x/time/rate"
 )
 
@@ -30,9 +31,11 @@ const (
 
 // config contains all options for configuring Subscriber.
 type config struct {
-	addrTTL   time.Duration
-	allowPeer announce.AllowPeerFunc
-	filterIPs bool
+	addrTTL      time.Duration
+	allowAddr    announce.AllowAddrFunc
+	allowPeer    announce.AllowPeerFunc
+	announceHook AnnounceHookFunc
+	filterIPs    bool
 
 	topic *pubsub.Topic
 
@@ -50,6 +53,8 @@ type config struct {
 	rateLimiterFor RateLimiterFor
 	resendAnnounce bool
 
+	syncLimitsFor SyncLimitsFor
+
 	segDepthLimit int64
 }
 
@@ -115,6 +120,22 @@ func HttpClient(client *http.Client) Option {
 	}
 }
 
+// AnnounceHookFunc is called for each announce message that is received,
+// before the announced advertisement is synced. Returning false means that
+// the announce is not handled.
+type AnnounceHookFunc func(peerID peer.ID, c cid.Cid, addrs []multiaddr.Multiaddr) bool
+
+// AnnounceHook sets a function that is called for each received announce
+// message. If the function returns false, then the advertisement in the
+// announce message is not synced, and a later announce of the same CID is not
+// ignored.
+func AnnounceHook(hook AnnounceHookFunc) Option {
+	return func(c *config) error {
+		c.announceHook = hook
+		return nil
+	}
+}
+
 // BlockHook adds a hook that is run when a block is received via Subscriber.Sync along with a
 // SegmentSyncActions to control the sync flow if segmented sync is enabled.
 // Note that if segmented sync is disabled, calls on SegmentSyncActions will have no effect.
@@ -126,6 +147,16 @@ func BlockHook(blockHook BlockHookFunc) Option {
 	}
 }
 
+// AllowAddr sets the function that determines whether a publisher multiaddr is
+// allowed. Addresses that are not allowed are removed from announce messages
+// and from the peerstores, and are not used to sync with a publisher.
+func AllowAddr(allowAddr announce.AllowAddrFunc) Option {
+	return func(c *config) error {
+		c.allowAddr = allowAddr
+		return nil
+	}
+}
+
 // FilterIPs removes any private, loopback, or unspecified IP multiaddrs from
 // addresses supplied in announce messages.
 func FilterIPs(enable bool) Option {
@@ -184,6 +215,21 @@ func RateLimiter(limiterFor RateLimiterFor) Option {
 	}
 }
 
+// SyncLimitsFor is called for each sync to get the recursion limit and the
+// segment depth limit to use when syncing from a specific peer.
+type SyncLimitsFor func(publisher peer.ID) (selector.RecursionLimit, int64)
+
+// SyncLimits configures a function that is called for each sync to get the
+// recursion limit and segment depth limit for a specific peer. This overrides
+// the SyncRecursionLimit and SegmentDepthLimit options, and the segment depth
+// limit can still be overridden for a single sync by ScopedSegmentDepthLimit.
+func SyncLimits(limitsFor SyncLimitsFor) Option {
+	return func(c *config) error {
+		c.syncLimitsFor = limitsFor
+		return nil
+	}
+}
+
 // LatestSyncHandler defines how to store the latest synced cid for a given
 // peer and how to fetch it. dagsync guarantees this will not be called
 // concurrently for the same peer, but it may be called concurrently for
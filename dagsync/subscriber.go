@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -22,15 +23,25 @@ import (
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	"github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
 var log = logging.Logger("dagsync")
 
+var tracer = otel.Tracer("github.com/ipni/storetheindex/dagsync")
+
 const (
 	tempAddrTTL = 24 * time.Hour // must be long enough for ad chain to sync
 )
 
+// errNotAllowedAddrs is returned when none of the publisher addresses are
+// allowed by policy.
+var errNotAllowedAddrs = errors.New("publisher addresses not allowed by policy")
+
 // BlockHookFunc is the signature of a function that is called when a received.
 type BlockHookFunc func(peer.ID, cid.Cid, SegmentSyncActions)
 
@@ -51,7 +62,9 @@ type Subscriber struct {
 	dss  ipld.Node
 	host host.Host
 
-	addrTTL time.Duration
+	addrTTL      time.Duration
+	allowAddr    announce.AllowAddrFunc
+	announceHook AnnounceHookFunc
 
 	handlers      map[peer.ID]*handler
 	handlersMutex sync.Mutex
@@ -79,6 +92,9 @@ type Subscriber struct {
 	// watchDone signals that the watch function exited.
 	watchDone chan struct{}
 	asyncWG   sync.WaitGroup
+	// syncsInProgress is the number of syncs that are running or waiting to
+	// run.
+	syncsInProgress int32
 
 	dtSync       *dtsync.Sync
 	httpSync     *httpsync.Sync
@@ -98,6 +114,7 @@ type Subscriber struct {
 	segDepthLimit int64
 
 	rateLimiterFor RateLimiterFor
+	syncLimitsFor  SyncLimitsFor
 
 	receiver *announce.Receiver
 }
@@ -114,6 +131,9 @@ type SyncFinished struct {
 	// A list of cids that this sync acquired. In order from latest to oldest.
 	// The latest cid will always be at the beginning.
 	SyncedCids []cid.Cid
+	// SpanContext identifies the span of the sync, so that processing of the
+	// synced CIDs can be linked to it.
+	SpanContext trace.SpanContext
 }
 
 // handler holds state that is specific to a peer
@@ -132,7 +152,9 @@ type handler struct {
 	pendingCid cid.Cid
 	// pendingSyncer is a syncer queued for handling pendingCid.
 	pendingSyncer Syncer
-	// qlock protects the pendingCid and pendingSyncer.
+	// pendingSpanCtx is the span context of the announce for pendingCid.
+	pendingSpanCtx trace.SpanContext
+	// qlock protects the pendingCid, pendingSyncer, and pendingSpanCtx.
 	qlock sync.Mutex
 	// expires is the time the handler is removed if it remains idle.
 	expires time.Time
@@ -187,6 +209,7 @@ func NewSubscriber(host host.Host, ds datastore.Batching, lsys ipld.LinkSystem,
 
 	rcvr, err := announce.NewReceiver(host, topic,
 		announce.WithAllowPeer(opts.allowPeer),
+		announce.WithAllowAddr(opts.allowAddr),
 		announce.WithFilterIPs(opts.filterIPs),
 		announce.WithResend(opts.resendAnnounce),
 		announce.WithTopic(opts.topic))
@@ -198,9 +221,11 @@ func NewSubscriber(host host.Host, ds datastore.Batching, lsys ipld.LinkSystem,
 		dss:  dss,
 		host: host,
 
-		addrTTL:   opts.addrTTL,
-		closing:   make(chan struct{}),
-		watchDone: make(chan struct{}),
+		addrTTL:      opts.addrTTL,
+		allowAddr:    opts.allowAddr,
+		announceHook: opts.announceHook,
+		closing:      make(chan struct{}),
+		watchDone:    make(chan struct{}),
 
 		handlers: make(map[peer.ID]*handler),
 		inEvents: make(chan SyncFinished, 1),
@@ -220,6 +245,7 @@ func NewSubscriber(host host.Host, ds datastore.Batching, lsys ipld.LinkSystem,
 
 		segDepthLimit:  opts.segDepthLimit,
 		rateLimiterFor: opts.rateLimiterFor,
+		syncLimitsFor:  opts.syncLimitsFor,
 
 		receiver: rcvr,
 	}
@@ -250,6 +276,12 @@ func (s *Subscriber) GetLatestSync(peerID peer.ID) ipld.Link {
 	return cidlink.Link{Cid: v}
 }
 
+// SyncsInProgress returns the number of syncs, explicit or started by an
+// announce, that are running or waiting to run.
+func (s *Subscriber) SyncsInProgress() int {
+	return int(atomic.LoadInt32(&s.syncsInProgress))
+}
+
 // SetLatestSync sets the latest synced CID for a specified peer. If there is
 // no handler for the peer, then one is created without consulting any
 // AllowPeerFunc.
@@ -392,11 +424,17 @@ func (s *Subscriber) RemoveHandler(peerID peer.ID) bool {
 // only specify the selection sequence itself.
 //
 // See: ExploreRecursiveWithStopNode.
-func (s *Subscriber) Sync(ctx context.Context, peerID peer.ID, nextCid cid.Cid, sel ipld.Node, peerAddr multiaddr.Multiaddr, options ...SyncOption) (cid.Cid, error) {
-	defaultOptions := []SyncOption{
-		ScopedBlockHook(s.generalBlockHook),
-		ScopedSegmentDepthLimit(s.segDepthLimit)}
-	opts := getSyncOpts(append(defaultOptions, options...))
+func (s *Subscriber) Sync(ctx context.Context, peerID peer.ID, nextCid cid.Cid, sel ipld.Node, peerAddr multiaddr.Multiaddr, options ...SyncOption) (_ cid.Cid, err error) {
+	atomic.AddInt32(&s.syncsInProgress, 1)
+	defer atomic.AddInt32(&s.syncsInProgress, -1)
+
+	ctx, span := tracer.Start(ctx, "Subscriber.Sync")
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
 	var peerAddrs []multiaddr.Multiaddr
 	if peerAddr != nil {
@@ -413,6 +451,13 @@ func (s *Subscriber) Sync(ctx context.Context, peerID peer.ID, nextCid cid.Cid,
 	}
 
 	log := log.With("peer", peerID)
+	span.SetAttributes(attribute.String("peer", peerID.String()))
+
+	_, segDepthLimit := s.syncLimits(peerID)
+	defaultOptions := []SyncOption{
+		ScopedBlockHook(s.generalBlockHook),
+		ScopedSegmentDepthLimit(segDepthLimit)}
+	opts := getSyncOpts(append(defaultOptions, options...))
 
 	syncer, isHttp, err := s.makeSyncer(peerID, peerAddrs, tempAddrTTL, opts.rateLimiter)
 	if err != nil {
@@ -441,6 +486,7 @@ func (s *Subscriber) Sync(ctx context.Context, peerID peer.ID, nextCid cid.Cid,
 		}
 	}
 	log = log.With("cid", nextCid)
+	span.SetAttributes(attribute.String("cid", nextCid.String()))
 
 	log.Info("Start sync")
 
@@ -478,7 +524,12 @@ func (s *Subscriber) Sync(ctx context.Context, peerID peer.ID, nextCid cid.Cid,
 
 	if updateLatest {
 		hnd.subscriber.latestSyncHander.SetLatestSync(hnd.peerID, nextCid)
-		hnd.subscriber.inEvents <- SyncFinished{Cid: nextCid, PeerID: hnd.peerID, SyncedCids: syncedCids}
+		hnd.subscriber.inEvents <- SyncFinished{
+			Cid:         nextCid,
+			PeerID:      hnd.peerID,
+			SyncedCids:  syncedCids,
+			SpanContext: span.SpanContext(),
+		}
 	}
 
 	// The sync succeeded, so let's remember this address in the appropriate
@@ -584,6 +635,12 @@ func (s *Subscriber) watch() {
 			break
 		}
 
+		if s.announceHook != nil && !s.announceHook(amsg.PeerID, amsg.Cid, amsg.Addrs) {
+			// Allow the same CID to be announced again.
+			s.receiver.UncacheCid(amsg.Cid)
+			continue
+		}
+
 		hnd, err := s.getOrCreateHandler(amsg.PeerID)
 		if err != nil {
 			log.Errorw("Cannot create handler for announce", "err", err)
@@ -598,7 +655,7 @@ func (s *Subscriber) watch() {
 
 		// Start a new goroutine to handle this message instead of having a
 		// persistent goroutine for each peer.
-		hnd.handleAsync(ctx, amsg.Cid, syncer)
+		hnd.handleAsync(ctx, amsg.Cid, syncer, amsg.SpanContext)
 	}
 }
 
@@ -610,6 +667,20 @@ func (s *Subscriber) Announce(ctx context.Context, nextCid cid.Cid, peerID peer.
 }
 
 func (s *Subscriber) makeSyncer(peerID peer.ID, peerAddrs []multiaddr.Multiaddr, addrTTL time.Duration, rateLimiter *rate.Limiter) (Syncer, bool, error) {
+	if s.allowAddr != nil {
+		if len(peerAddrs) != 0 {
+			peerAddrs = multiaddr.FilterAddrs(peerAddrs, s.allowAddr)
+			if len(peerAddrs) == 0 {
+				return nil, false, errNotAllowedAddrs
+			}
+		}
+		// The syncer may also dial addresses that are already known for the
+		// peer, so those must be allowed as well.
+		if !s.removeDeniedAddrs(peerID) && len(peerAddrs) == 0 {
+			return nil, false, errNotAllowedAddrs
+		}
+	}
+
 	// Check for an HTTP address in peerAddrs, or if not given, in the http
 	// peerstore. This gives a preference to use httpsync over dtsync.
 	var httpAddr multiaddr.Multiaddr
@@ -653,6 +724,42 @@ func (s *Subscriber) makeSyncer(peerID peer.ID, peerAddrs []multiaddr.Multiaddr,
 	return s.dtSync.NewSyncer(peerID, s.receiver.TopicName(), rateLimiter), false, nil
 }
 
+// removeDeniedAddrs removes the addresses of a peer that are not allowed by
+// policy from the http and host peerstores, and closes any connections to the
+// peer that use an address that is not allowed. Returns false if the peer has
+// known addresses and none of them are allowed.
+func (s *Subscriber) removeDeniedAddrs(peerID peer.ID) bool {
+	var known, allowed int
+	for _, ps := range []peerstore.Peerstore{s.httpPeerstore, s.host.Peerstore()} {
+		if ps == nil {
+			continue
+		}
+		for _, addr := range ps.Addrs(peerID) {
+			known++
+			if s.allowAddr(addr) {
+				allowed++
+				continue
+			}
+			ps.SetAddr(peerID, addr, 0)
+		}
+	}
+	for _, conn := range s.host.Network().ConnsToPeer(peerID) {
+		if !s.allowAddr(conn.RemoteMultiaddr()) {
+			conn.Close()
+		}
+	}
+	return known == 0 || allowed != 0
+}
+
+// syncLimits returns the recursion limit and segment depth limit to use when
+// syncing from the peer.
+func (s *Subscriber) syncLimits(peerID peer.ID) (selector.RecursionLimit, int64) {
+	if s.syncLimitsFor != nil {
+		return s.syncLimitsFor(peerID)
+	}
+	return s.syncRecLimit, s.segDepthLimit
+}
+
 func firstHTTPAddr(peerAddrs []multiaddr.Multiaddr) multiaddr.Multiaddr {
 	for _, addr := range peerAddrs {
 		if addr == nil {
@@ -670,8 +777,8 @@ func firstHTTPAddr(peerAddrs []multiaddr.Multiaddr) multiaddr.Multiaddr {
 // handleAsync starts a goroutine to process the latest announce message
 // received over pubsub or HTTP. If there is already a goroutine handling a
 // sync, then there will be at most one more goroutine waiting to handle the
-// pending sync.
-func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Syncer) {
+// pending sync. The sync is traced as part of the announce's span, if any.
+func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Syncer, spanCtx trace.SpanContext) {
 	h.qlock.Lock()
 	// If pendingSync is undef, then previous goroutine has already handled any
 	// pendingSync, so start a new go routine to handle the pending sync. If
@@ -679,11 +786,13 @@ func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Synce
 	// not yet handled the pending sync.
 	if h.pendingCid == cid.Undef {
 		h.subscriber.asyncWG.Add(1)
+		atomic.AddInt32(&h.subscriber.syncsInProgress, 1)
 		go func() {
 			// Wait for any previous handler goroutine to finish.
 			h.latestSyncMu.Lock()
 			defer h.latestSyncMu.Unlock()
 			defer h.subscriber.asyncWG.Done()
+			defer atomic.AddInt32(&h.subscriber.syncsInProgress, -1)
 
 			if ctx.Err() != nil {
 				log.Warnw("Abandoned pending sync", "err", ctx.Err(), "publisher", h.peerID)
@@ -696,13 +805,23 @@ func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Synce
 			h.pendingCid = cid.Undef
 			syncer := h.pendingSyncer
 			h.pendingSyncer = nil
+			spanCtx := h.pendingSpanCtx
+			h.pendingSpanCtx = trace.SpanContext{}
 			h.qlock.Unlock()
 
+			ctx, span := tracer.Start(trace.ContextWithSpanContext(ctx, spanCtx), "Subscriber.handleAsync",
+				trace.WithAttributes(
+					attribute.String("cid", c.String()),
+					attribute.String("peer", h.peerID.String())))
+			defer span.End()
+
 			// Wait for this handler to become available. This only wraps the
 			// handler. This is to free up the handler in case someone else
 			// needs it while we wait to send on the events chan.
-			syncedCids, err := h.handle(ctx, c, h.subscriber.dss, true, syncer, h.subscriber.generalBlockHook, h.subscriber.segDepthLimit)
+			_, segDepthLimit := h.subscriber.syncLimits(h.peerID)
+			syncedCids, err := h.handle(ctx, c, h.subscriber.dss, true, syncer, h.subscriber.generalBlockHook, segDepthLimit)
 			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
 				// Failed to handle the sync, so allow another announce for the same CID.
 				h.subscriber.receiver.UncacheCid(c)
 				// Log error for now.
@@ -712,7 +831,12 @@ func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Synce
 
 			// Update latest head seen.
 			h.subscriber.latestSyncHander.SetLatestSync(h.peerID, c)
-			h.subscriber.inEvents <- SyncFinished{Cid: c, PeerID: h.peerID, SyncedCids: syncedCids}
+			h.subscriber.inEvents <- SyncFinished{
+				Cid:         c,
+				PeerID:      h.peerID,
+				SyncedCids:  syncedCids,
+				SpanContext: span.SpanContext(),
+			}
 		}()
 	} else {
 		log.Infow("Pending announce replaced by new", "previous_cid", h.pendingCid, "new_cid", nextCid, "publisher", h.peerID)
@@ -720,6 +844,7 @@ func (h *handler) handleAsync(ctx context.Context, nextCid cid.Cid, syncer Synce
 	// Set the CID to be handled by the waiting goroutine.
 	h.pendingCid = nextCid
 	h.pendingSyncer = syncer
+	h.pendingSpanCtx = spanCtx
 	h.qlock.Unlock()
 }
 
@@ -811,7 +936,8 @@ func (h *handler) handle(ctx context.Context, nextCid cid.Cid, sel ipld.Node, wr
 		if ok && latestSync != cid.Undef {
 			latestSyncLink = cidlink.Link{Cid: latestSync}
 		}
-		sel = ExploreRecursiveWithStopNode(h.subscriber.syncRecLimit, sel, latestSyncLink)
+		recLimit, _ := h.subscriber.syncLimits(h.peerID)
+		sel = ExploreRecursiveWithStopNode(recLimit, sel, latestSyncLink)
 	}
 
 	stopNode, stopNodeOK := getStopNode(sel)
@@ -848,7 +974,7 @@ func (h *handler) handle(ctx context.Context, nextCid cid.Cid, sel ipld.Node, wr
 	//   segment depth limit.
 	if !syncBySegment {
 		log.Debugw("Falling back on sync in one go", "segDepthLimit", segdl)
-		err := syncer.Sync(ctx, nextCid, sel)
+		err := tracedSync(ctx, syncer, nextCid, sel)
 		if err != nil {
 			return nil, err
 		}
@@ -869,7 +995,7 @@ SegSyncLoop:
 		}
 		nextCid = *segSync.nextSyncCid
 		segSync.reset()
-		err := syncer.Sync(ctx, nextCid, segmentSel)
+		err := tracedSync(ctx, syncer, nextCid, segmentSel, attribute.Int64("segmentDepth", nextDepth))
 		if err != nil {
 			return nil, err
 		}
@@ -910,3 +1036,17 @@ SegSyncLoop:
 	log.Infow("Segmented sync completed", "syncedCidCount", len(syncedCids))
 	return syncedCids, nil
 }
+
+// tracedSync calls syncer.Sync in its own span, so that each segment of a
+// segmented sync is traced separately.
+func tracedSync(ctx context.Context, syncer Syncer, nextCid cid.Cid, sel ipld.Node, attrs ...attribute.KeyValue) error {
+	ctx, span := tracer.Start(ctx, "Syncer.Sync", trace.WithAttributes(attribute.String("cid", nextCid.String())))
+	defer span.End()
+	span.SetAttributes(attrs...)
+
+	err := syncer.Sync(ctx, nextCid, sel)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
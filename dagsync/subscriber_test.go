@@ -520,6 +520,68 @@ func TestHttpPeerAddrPeerstore(t *testing.T) {
 	}
 }
 
+func TestAllowAddr(t *testing.T) {
+	pubHostSys := newHostSystem(t)
+	subHostSys := newHostSystem(t)
+	defer pubHostSys.close()
+	defer subHostSys.close()
+
+	var allow bool
+	allowAddr := func(multiaddr.Multiaddr) bool { return allow }
+
+	pubAddr, pub, sub := dagsyncPubSubBuilder{
+		IsHttp: true,
+	}.Build(t, testTopic, pubHostSys, subHostSys, []dagsync.Option{dagsync.AllowAddr(allowAddr)})
+	defer sub.Close()
+
+	ll := llBuilder{
+		Length: 1,
+		Seed:   1,
+	}.Build(t, pubHostSys.lsys)
+	require.NoError(t, pub.UpdateRoot(context.Background(), ll.(cidlink.Link).Cid))
+
+	// Sync fails when the publisher address is not allowed.
+	_, err := sub.Sync(context.Background(), pubHostSys.host.ID(), cid.Undef, nil, pubAddr)
+	require.ErrorContains(t, err, "not allowed")
+
+	allow = true
+	_, err = sub.Sync(context.Background(), pubHostSys.host.ID(), cid.Undef, nil, pubAddr)
+	require.NoError(t, err)
+}
+
+func TestAllowAddrPeerstore(t *testing.T) {
+	pubHostSys := newHostSystem(t)
+	subHostSys := newHostSystem(t)
+	defer pubHostSys.close()
+	defer subHostSys.close()
+
+	var allow bool
+	allowAddr := func(multiaddr.Multiaddr) bool { return allow }
+
+	_, pub, sub := dagsyncPubSubBuilder{}.Build(t, testTopic, pubHostSys, subHostSys, []dagsync.Option{dagsync.AllowAddr(allowAddr)})
+	defer pub.Close()
+	defer sub.Close()
+
+	ll := llBuilder{
+		Length: 1,
+		Seed:   1,
+	}.Build(t, pubHostSys.lsys)
+	require.NoError(t, pub.UpdateRoot(context.Background(), ll.(cidlink.Link).Cid))
+
+	// Sync without addresses fails when the known publisher addresses are
+	// not allowed, and the addresses are removed from the peerstore.
+	pubID := pubHostSys.host.ID()
+	subHostSys.host.Peerstore().AddAddrs(pubID, pubHostSys.host.Addrs(), time.Hour)
+	_, err := sub.Sync(context.Background(), pubID, cid.Undef, nil, nil)
+	require.ErrorContains(t, err, "not allowed")
+	require.Empty(t, subHostSys.host.Peerstore().Addrs(pubID))
+
+	allow = true
+	subHostSys.host.Peerstore().AddAddrs(pubID, pubHostSys.host.Addrs(), time.Hour)
+	_, err = sub.Sync(context.Background(), pubID, cid.Undef, nil, nil)
+	require.NoError(t, err)
+}
+
 func TestRateLimiter(t *testing.T) {
 	t.Parallel()
 	type testCase struct {
@@ -692,7 +754,7 @@ func waitForSync(t *testing.T, logPrefix string, store *dssync.MutexDatastore, e
 		t.Fatal("timed out waiting for sync to propogate")
 	case downstream := <-watcher:
 		if !downstream.Cid.Equals(expectedCid.Cid) {
-			t.Fatalf("sync'd cid unexpected %s vs %s", downstream, expectedCid.Cid)
+			t.Fatalf("sync'd cid unexpected %s vs %s", downstream.Cid, expectedCid.Cid)
 		}
 		if _, err := store.Get(context.Background(), datastore.NewKey(downstream.Cid.String())); err != nil {
 			t.Fatalf("data not in receiver store: %v", err)
@@ -865,3 +927,30 @@ func (b llBuilder) BuildWithPrev(t *testing.T, lsys ipld.LinkSystem, prev datamo
 
 	return prev
 }
+
+func TestSyncsInProgress(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	pubHost := test.MkTestHost()
+	lsys := test.MkLinkSystem(ds)
+	pub, err := dtsync.NewPublisher(pubHost, ds, lsys, testTopic)
+	require.NoError(t, err)
+	defer pub.Close()
+
+	head := llBuilder{Length: 3, Seed: 1}.Build(t, lsys)
+	require.NoError(t, pub.UpdateRoot(context.Background(), head.(cidlink.Link).Cid))
+
+	subHost := test.MkTestHost()
+	subDS := dssync.MutexWrap(datastore.NewMapDatastore())
+	sub, err := dagsync.NewSubscriber(subHost, subDS, test.MkLinkSystem(subDS), testTopic, nil)
+	require.NoError(t, err)
+	defer sub.Close()
+	require.Zero(t, sub.SyncsInProgress())
+
+	inSync := make(chan int, 3)
+	_, err = sub.Sync(context.Background(), pubHost.ID(), cid.Undef, nil, pubHost.Addrs()[0], dagsync.ScopedBlockHook(func(peer.ID, cid.Cid, dagsync.SegmentSyncActions) {
+		inSync <- sub.SyncsInProgress()
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 1, <-inSync)
+	require.Zero(t, sub.SyncsInProgress())
+}
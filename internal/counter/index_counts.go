@@ -62,28 +62,30 @@ func (c *IndexCounts) AddCount(providerID peer.ID, contextID []byte, count uint6
 		return
 	}
 
-	// Update in-mem values if they are present.
-	c.mutex.Lock()
-	prevCtxTotal, ok := c.counts[providerID]
-	if ok {
-		c.counts[providerID] = prevCtxTotal + count
-	}
-	if c.total != 0 {
-		c.total += count
-	}
-	c.mutex.Unlock()
-
 	key := makeIndexCountKey(providerID, contextID)
 
 	// Get any previous count for this contextID and add to it.
 	prevCtxCount, err := c.loadContextCount(context.Background(), key)
 	if err != nil {
+		log.Errorw("Cannot update index count", "err", err)
 		return
 	}
 	err = c.ds.Put(context.Background(), key, varint.ToUvarint(prevCtxCount+count))
 	if err != nil {
 		log.Errorw("Cannot update index count", "err", err)
+		return
+	}
+
+	// Update in-mem values if they are present.
+	c.mutex.Lock()
+	prevCtxTotal, ok := c.counts[providerID]
+	if ok {
+		c.counts[providerID] = prevCtxTotal + count
+	}
+	if c.total != 0 {
+		c.total += count
 	}
+	c.mutex.Unlock()
 }
 
 // AddMissingCount stores the count only if there is no existing count for the
@@ -106,6 +108,7 @@ func (c *IndexCounts) AddMissingCount(providerID peer.ID, contextID []byte, coun
 	err = c.ds.Put(context.Background(), key, varint.ToUvarint(count))
 	if err != nil {
 		log.Errorw("Cannot store index count", "err", err)
+		return
 	}
 
 	// Update in-mem values if they are present.
@@ -120,38 +123,102 @@ func (c *IndexCounts) AddMissingCount(providerID peer.ID, contextID []byte, coun
 	c.mutex.Unlock()
 }
 
-// RemoveCtx removes the index count for a provider's contextID.
+// RemoveCtx removes the index count for a provider's contextID, and returns
+// the count that was removed.
 func (c *IndexCounts) RemoveCtx(providerID peer.ID, contextID []byte) (uint64, error) {
 	key := makeIndexCountKey(providerID, contextID)
 
 	count, err := c.loadContextCount(context.Background(), key)
-	if derr := c.ds.Delete(context.Background(), key); derr != nil {
-		log.Errorw("Cannot delete index count", "err", derr)
-	}
 	if err != nil {
+		// The in-mem values cannot be adjusted without knowing the count, so
+		// reload them from the datastore when next needed.
+		c.invalidate(providerID)
+		if derr := c.ds.Delete(context.Background(), key); derr != nil {
+			log.Errorw("Cannot delete index count", "err", derr)
+		}
 		return 0, err
 	}
+	if count == 0 {
+		return 0, nil
+	}
+	if err = c.ds.Delete(context.Background(), key); err != nil {
+		return 0, fmt.Errorf("cannot delete index count: %w", err)
+	}
 
 	// Update in-mem values if they are present.
 	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	ptotal, ok := c.counts[providerID]
 	if ok {
-		if count < ptotal {
-			c.counts[providerID] = ptotal - count
+		if count > ptotal {
+			log.Error("Index count in data store is greater than in memory")
+			c.invalidateLocked(providerID)
+			return count, nil
+		}
+		c.counts[providerID] = ptotal - count
+	}
+	if c.total != 0 {
+		if count > c.total {
+			log.Error("Index count in data store is greater than in-memory total")
+			c.total = 0
 		} else {
-			if count > ptotal {
-				log.Error("Index count in data store is greater than in memory")
-				count = ptotal
-			}
-			delete(c.counts, providerID)
+			c.total -= count
+		}
+	}
+
+	return count, nil
+}
+
+// ReplaceProvider replaces all of a provider's index counts with the given
+// context counts, and returns the provider's previous total index count. This
+// is used to correct a provider's index counts after recounting them.
+func (c *IndexCounts) ReplaceProvider(providerID peer.ID, ctxCounts []ContextCount) (uint64, error) {
+	ctx := context.Background()
+
+	prevTotal, err := c.loadProvider(ctx, providerID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = c.deletePrefix(ctx, indexCountPrefix+providerID.String()+"/"); err != nil {
+		c.invalidate(providerID)
+		return 0, fmt.Errorf("cannot delete provider index counts: %w", err)
+	}
+
+	var total uint64
+	for _, cc := range ctxCounts {
+		if cc.Count == 0 {
+			continue
+		}
+		err = c.ds.Put(ctx, makeIndexCountKey(providerID, cc.ContextID), varint.ToUvarint(cc.Count))
+		if err != nil {
+			c.invalidate(providerID)
+			return 0, fmt.Errorf("cannot store index count: %w", err)
 		}
+		total += cc.Count
 	}
+
+	c.mutex.Lock()
+	c.counts[providerID] = total
 	if c.total != 0 {
-		c.total -= count
+		if prevTotal > c.total {
+			c.total = 0
+		} else {
+			c.total = c.total - prevTotal + total
+		}
 	}
 	c.mutex.Unlock()
 
-	return count, nil
+	return prevTotal, nil
+}
+
+// ClearCache discards the in-mem index counts, so that counts are read from
+// the datastore. This is used after the datastore is changed by something
+// else.
+func (c *IndexCounts) ClearCache() {
+	c.mutex.Lock()
+	c.counts = make(map[peer.ID]uint64)
+	c.total = 0
+	c.mutex.Unlock()
 }
 
 // Provider reads all index counts for a provider.
@@ -177,6 +244,49 @@ func (c *IndexCounts) Provider(providerID peer.ID) (uint64, error) {
 	return total, nil
 }
 
+// ContextCount is the index count for a single provider context ID.
+type ContextCount struct {
+	ContextID []byte
+	Count     uint64
+}
+
+// ProviderContexts reads the index counts for each of a provider's context
+// IDs.
+func (c *IndexCounts) ProviderContexts(providerID peer.ID) ([]ContextCount, error) {
+	prefix := indexCountPrefix + providerID.String() + "/"
+	q := query.Query{
+		Prefix: prefix,
+	}
+	results, err := c.ds.Query(context.Background(), q)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query provider index counts: %w", err)
+	}
+	defer results.Close()
+
+	var ctxCounts []ContextCount
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, fmt.Errorf("cannot read index: %w", r.Error)
+		}
+		contextID, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Entry.Key, prefix))
+		if err != nil {
+			log.Errorw("Cannot decode context ID in index count key", "err", err)
+			continue
+		}
+		count, _, err := varint.FromUvarint(r.Entry.Value)
+		if err != nil {
+			log.Errorw("Cannot decode index count", "err", err)
+			continue
+		}
+		ctxCounts = append(ctxCounts, ContextCount{
+			ContextID: contextID,
+			Count:     count,
+		})
+	}
+
+	return ctxCounts, nil
+}
+
 // Total returns the total of all index counts for all providers.
 func (c *IndexCounts) Total() (uint64, error) {
 	// Return in-mem value if available.
@@ -269,6 +379,19 @@ func (c *IndexCounts) RemoveProvider(providerID peer.ID) uint64 {
 	return count
 }
 
+// invalidate removes in-mem values that are affected by a change to the
+// provider's counts, so that they are reloaded from the datastore.
+func (c *IndexCounts) invalidate(providerID peer.ID) {
+	c.mutex.Lock()
+	c.invalidateLocked(providerID)
+	c.mutex.Unlock()
+}
+
+func (c *IndexCounts) invalidateLocked(providerID peer.ID) {
+	delete(c.counts, providerID)
+	c.total = 0
+}
+
 func (c *IndexCounts) loadContextCount(ctx context.Context, key datastore.Key) (uint64, error) {
 	data, err := c.ds.Get(context.Background(), key)
 	if err != nil {
@@ -151,3 +151,87 @@ func TestAddCount(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, 17, int(total))
 }
+
+func TestProviderContexts(t *testing.T) {
+	providerPriv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	providerID, err := peer.IDFromPrivateKey(providerPriv)
+	require.NoError(t, err)
+
+	c := counter.NewIndexCounts(datastore.NewMapDatastore())
+
+	ctxCounts, err := c.ProviderContexts(providerID)
+	require.NoError(t, err)
+	require.Empty(t, ctxCounts)
+
+	c.AddCount(providerID, []byte("ctxid1"), 5)
+	c.AddCount(providerID, []byte("ctxid2"), 2)
+
+	ctxCounts, err = c.ProviderContexts(providerID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []counter.ContextCount{
+		{ContextID: []byte("ctxid1"), Count: 5},
+		{ContextID: []byte("ctxid2"), Count: 2},
+	}, ctxCounts)
+
+	c.RemoveProvider(providerID)
+	ctxCounts, err = c.ProviderContexts(providerID)
+	require.NoError(t, err)
+	require.Empty(t, ctxCounts)
+}
+
+func TestReplaceProvider(t *testing.T) {
+	providerPriv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	providerID1, err := peer.IDFromPrivateKey(providerPriv)
+	require.NoError(t, err)
+	providerPriv, _, err = test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	providerID2, err := peer.IDFromPrivateKey(providerPriv)
+	require.NoError(t, err)
+
+	ds := datastore.NewMapDatastore()
+	c := counter.NewIndexCounts(ds)
+
+	c.AddCount(providerID1, []byte("ctxid1"), 5)
+	c.AddCount(providerID1, []byte("ctxid2"), 2)
+	c.AddCount(providerID2, []byte("ctxid3"), 7)
+	total, err := c.Total()
+	require.NoError(t, err)
+	require.Equal(t, 14, int(total))
+
+	prev, err := c.ReplaceProvider(providerID1, []counter.ContextCount{
+		{ContextID: []byte("ctxid2"), Count: 4},
+		{ContextID: []byte("ctxid4"), Count: 9},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 7, int(prev))
+
+	total, err = c.Provider(providerID1)
+	require.NoError(t, err)
+	require.Equal(t, 13, int(total))
+	total, err = c.Total()
+	require.NoError(t, err)
+	require.Equal(t, 20, int(total))
+
+	ctxCounts, err := c.ProviderContexts(providerID1)
+	require.NoError(t, err)
+	require.Len(t, ctxCounts, 2)
+
+	// Removing a replaced context decrements the counts by the new value.
+	count, err := c.RemoveCtx(providerID1, []byte("ctxid4"))
+	require.NoError(t, err)
+	require.Equal(t, 9, int(count))
+	total, err = c.Provider(providerID1)
+	require.NoError(t, err)
+	require.Equal(t, 4, int(total))
+	total, err = c.Total()
+	require.NoError(t, err)
+	require.Equal(t, 11, int(total))
+
+	// Check that counts reloaded from the datastore agree.
+	c = counter.NewIndexCounts(ds)
+	total, err = c.Total()
+	require.NoError(t, err)
+	require.Equal(t, 11, int(total))
+}
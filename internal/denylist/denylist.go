@@ -0,0 +1,304 @@
+// Package denylist provides a list of denied content that is loaded from
+// denylist files and URLs. Entries are exact multihashes or double-hashed
+// entries, as used by the badbits list, so that content can be denied
+// without publishing what the content is.
+package denylist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("indexer/denylist")
+
+const fetchTimeout = time.Minute
+
+// anchorCodecs are the codecs of the CIDs that are checked against legacy
+// badbits anchors. An anchor is a hash of a CID, and a multihash may be
+// referenced by CIDs with any of these codecs.
+var anchorCodecs = []multicodec.Code{
+	multicodec.DagPb,
+	multicodec.Raw,
+	multicodec.DagCbor,
+}
+
+// Denylist is a set of denied multihashes. It is safe for concurrent use.
+type Denylist struct {
+	mutex      sync.RWMutex
+	entries    entries
+	skipIngest bool
+	client     *http.Client
+}
+
+type entries struct {
+	// exact are denied multihashes.
+	exact map[string]struct{}
+	// doubleHashed are multihashes of the sha256 of a denied base58
+	// multihash, from "//<multihash>" entries.
+	doubleHashed map[string]struct{}
+	// anchors are the sha256 of a denied CIDv1 in base32 followed by "/", from
+	// legacy badbits "//<hex>" entries.
+	anchors map[string]struct{}
+}
+
+func (e entries) len() int {
+	return len(e.exact) + len(e.doubleHashed) + len(e.anchors)
+}
+
+// New creates a Denylist and loads the entries from the sources in the given
+// config.
+func New(ctx context.Context, cfg config.Denylist) (*Denylist, error) {
+	d := &Denylist{
+		client: &http.Client{
+			Timeout: fetchTimeout,
+		},
+	}
+	if err := d.Reload(ctx, cfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload replaces the denylist entries with those loaded from the sources in
+// the given config. If any source cannot be loaded, then an error is returned
+// and the current entries are kept.
+func (d *Denylist) Reload(ctx context.Context, cfg config.Denylist) error {
+	ents := entries{
+		exact:        make(map[string]struct{}),
+		doubleHashed: make(map[string]struct{}),
+		anchors:      make(map[string]struct{}),
+	}
+	for _, src := range cfg.Sources {
+		data, err := d.read(ctx, src)
+		if err != nil {
+			return fmt.Errorf("cannot read denylist %s: %w", src, err)
+		}
+		if err = ents.parse(data); err != nil {
+			return fmt.Errorf("cannot parse denylist %s: %w", src, err)
+		}
+	}
+
+	d.mutex.Lock()
+	d.entries = ents
+	d.skipIngest = cfg.SkipIngest
+	d.mutex.Unlock()
+	metrics.SetDenylistEntries(ents.len())
+
+	if len(cfg.Sources) != 0 {
+		log.Infow("Loaded denylist", "sources", len(cfg.Sources), "entries", ents.len())
+	}
+	return nil
+}
+
+// Len returns the number of entries in the denylist.
+func (d *Denylist) Len() int {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.entries.len()
+}
+
+// SkipIngest returns true if denied multihashes are not indexed when
+// ingesting advertisements.
+func (d *Denylist) SkipIngest() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.skipIngest
+}
+
+// Denied returns true if the multihash is denied.
+func (d *Denylist) Denied(mh multihash.Multihash) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.entries.denied(mh)
+}
+
+// Filter returns the multihashes that are not denied, and the number of
+// multihashes that were removed. The given slice is returned if nothing is
+// denied.
+func (d *Denylist) Filter(mhs []multihash.Multihash) ([]multihash.Multihash, int) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.entries.len() == 0 {
+		return mhs, 0
+	}
+	var allowed []multihash.Multihash
+	for i, mh := range mhs {
+		if !d.entries.denied(mh) {
+			if allowed != nil {
+				allowed = append(allowed, mh)
+			}
+			continue
+		}
+		if allowed == nil {
+			allowed = make([]multihash.Multihash, i, len(mhs)-1)
+			copy(allowed, mhs[:i])
+		}
+	}
+	if allowed == nil {
+		return mhs, 0
+	}
+	return allowed, len(mhs) - len(allowed)
+}
+
+func (e entries) denied(mh multihash.Multihash) bool {
+	if len(e.exact) != 0 {
+		if _, ok := e.exact[string(mh)]; ok {
+			return true
+		}
+	}
+	if len(e.doubleHashed) != 0 {
+		dmh, err := multihash.Sum([]byte(mh.B58String()), multihash.SHA2_256, -1)
+		if err == nil {
+			if _, ok := e.doubleHashed[string(dmh)]; ok {
+				return true
+			}
+		}
+	}
+	if len(e.anchors) != 0 {
+		for _, codec := range anchorCodecs {
+			cidStr, err := cid.NewCidV1(uint64(codec), mh).StringOfBase(multibase.Base32)
+			if err != nil {
+				continue
+			}
+			anchor := sha256.Sum256([]byte(cidStr + "/"))
+			if _, ok := e.anchors[string(anchor[:])]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// read reads the denylist data from a file or URL.
+func (d *Denylist) read(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		path, err := config.Path("", src)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d %s", rsp.StatusCode, http.StatusText(rsp.StatusCode))
+	}
+	return io.ReadAll(rsp.Body)
+}
+
+// parse adds the entries in the denylist data. The data is either a legacy
+// badbits JSON list of anchors, or a compact denylist with one entry per line.
+func (e entries) parse(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) != 0 && data[0] == '[' {
+		var anchors []struct {
+			Anchor string `json:"anchor"`
+		}
+		if err := json.Unmarshal(data, &anchors); err != nil {
+			return err
+		}
+		for _, a := range anchors {
+			if err := e.addAnchor(a.Anchor); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var skipped int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case line == "---":
+			// Lines before this are the header of a compact denylist.
+			skipped = 0
+		case strings.HasPrefix(line, "//"):
+			if err := e.addDoubleHashed(line[2:]); err != nil {
+				skipped++
+			}
+		default:
+			if err := e.addExact(line); err != nil {
+				skipped++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if skipped != 0 {
+		log.Warnw("Skipped unsupported denylist entries", "count", skipped)
+	}
+	return nil
+}
+
+func (e entries) addAnchor(anchor string) error {
+	b, err := hex.DecodeString(anchor)
+	if err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("bad anchor %q", anchor)
+	}
+	e.anchors[string(b)] = struct{}{}
+	return nil
+}
+
+func (e entries) addDoubleHashed(hash string) error {
+	if len(hash) == 2*sha256.Size {
+		return e.addAnchor(hash)
+	}
+	mh, err := multihash.FromB58String(hash)
+	if err != nil {
+		return err
+	}
+	e.doubleHashed[string(mh)] = struct{}{}
+	return nil
+}
+
+func (e entries) addExact(entry string) error {
+	if strings.HasPrefix(entry, "/ipfs/") {
+		entry = entry[len("/ipfs/"):]
+		// Entries for paths within a DAG are not supported.
+		if strings.Contains(entry, "/") {
+			return fmt.Errorf("unsupported path entry %q", entry)
+		}
+	}
+	c, err := cid.Decode(entry)
+	if err == nil {
+		e.exact[string(c.Hash())] = struct{}{}
+		return nil
+	}
+	mh, err := multihash.FromB58String(entry)
+	if err != nil {
+		return err
+	}
+	e.exact[string(mh)] = struct{}{}
+	return nil
+}
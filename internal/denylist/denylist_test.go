@@ -0,0 +1,108 @@
+package denylist
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/multiformats/go-multibase"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylist(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	mhs := util.RandomMultihashes(6, rng)
+
+	// Double-hashed entry, as a multihash of the base58 multihash.
+	dmh, err := multihash.Sum([]byte(mhs[2].B58String()), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+
+	// Legacy badbits anchor of a CIDv1 with raw codec.
+	cidStr, err := cid.NewCidV1(cid.Raw, mhs[3]).StringOfBase(multibase.Base32)
+	require.NoError(t, err)
+	anchor := sha256.Sum256([]byte(cidStr + "/"))
+
+	lines := []string{
+		"version: 1",
+		"name: test",
+		"---",
+		"# Comment",
+		mhs[0].B58String(),
+		"/ipfs/" + cid.NewCidV1(cid.DagProtobuf, mhs[1]).String(),
+		"//" + dmh.B58String(),
+		"//" + hex.EncodeToString(anchor[:]),
+		"/ipfs/" + cid.NewCidV1(cid.Raw, mhs[5]).String() + "/some/path",
+		"!/ipfs/" + cid.NewCidV1(cid.Raw, mhs[5]).String(),
+	}
+	listFile := filepath.Join(t.TempDir(), "test.deny")
+	require.NoError(t, os.WriteFile(listFile, []byte(strings.Join(lines, "\n")), 0o644))
+
+	d, err := New(context.Background(), config.Denylist{
+		Sources:    []string{listFile},
+		SkipIngest: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, d.Len())
+	require.True(t, d.SkipIngest())
+
+	for i := 0; i < 4; i++ {
+		require.True(t, d.Denied(mhs[i]), "multihash %d should be denied", i)
+	}
+	require.False(t, d.Denied(mhs[4]))
+	require.False(t, d.Denied(mhs[5]))
+
+	allowed, denied := d.Filter(mhs)
+	require.Equal(t, 4, denied)
+	require.Equal(t, mhs[4:], allowed)
+
+	allowed, denied = d.Filter(mhs[4:])
+	require.Zero(t, denied)
+	require.Equal(t, mhs[4:], allowed)
+
+	// Check that a failed reload keeps the current entries.
+	err = d.Reload(context.Background(), config.Denylist{
+		Sources: []string{filepath.Join(t.TempDir(), "missing")},
+	})
+	require.Error(t, err)
+	require.Equal(t, 4, d.Len())
+
+	// Reload with no sources removes all entries.
+	require.NoError(t, d.Reload(context.Background(), config.Denylist{}))
+	require.Zero(t, d.Len())
+	require.False(t, d.SkipIngest())
+	require.False(t, d.Denied(mhs[0]))
+}
+
+func TestDenylistURL(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	mhs := util.RandomMultihashes(2, rng)
+
+	cidStr, err := cid.NewCidV1(cid.DagProtobuf, mhs[0]).StringOfBase(multibase.Base32)
+	require.NoError(t, err)
+	anchor := sha256.Sum256([]byte(cidStr + "/"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"anchor": "%s"}]`, hex.EncodeToString(anchor[:]))
+	}))
+	defer ts.Close()
+
+	d, err := New(context.Background(), config.Denylist{
+		Sources: []string{ts.URL},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, d.Len())
+	require.True(t, d.Denied(mhs[0]))
+	require.False(t, d.Denied(mhs[1]))
+}
@@ -0,0 +1,343 @@
+// Package findcache provides an in-process cache of multihash lookup results,
+// including lookups that found nothing, in front of an indexer.
+package findcache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-indexer-core"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+var log = logging.Logger("indexer/findcache")
+
+// Cache is an indexer.Interface that caches the results of Get. Results that
+// have values are cached for the configured TTL, and results that have no
+// values are cached for the shorter negative TTL.
+//
+// Cached results are invalidated when values are put or removed for a cached
+// multihash, or for a multihash that is being read from the indexer, and when values are removed for a provider, or for a provider
+// context, that is in a cached result. To see these changes, all writes to the
+// indexer must be done through the Cache.
+type Cache struct {
+	indexer.Interface
+
+	negativeTTL time.Duration
+	size        int
+	ttl         time.Duration
+
+	mutex sync.Mutex
+	// entries maps a multihash to its element in lru.
+	entries map[string]*list.Element
+	// lru holds *entry, with the most recently used at the front.
+	lru *list.List
+	// byProvider maps a provider ID to the multihashes of cached results that
+	// contain a value from that provider.
+	byProvider map[peer.ID]map[string]struct{}
+	// reading maps a multihash to the lookup of its values from the indexer
+	// that is in progress, so that a result read before its multihash is
+	// invalidated is not cached after it.
+	reading map[string]*pendingRead
+	// readers is the number of lookups from the indexer in progress.
+	readers int
+	// provSeq is incremented each time a provider's results are invalidated.
+	provSeq uint64
+	// provInvalidated maps a provider ID to the provSeq at which its results
+	// were last invalidated, while any lookups are in progress.
+	provInvalidated map[peer.ID]uint64
+
+	hits   uint64
+	misses uint64
+
+	now func() time.Time
+}
+
+// pendingRead is a lookup of a multihash from the indexer that is in progress.
+type pendingRead struct {
+	count int
+	stale bool
+}
+
+type entry struct {
+	key     string
+	values  []indexer.Value
+	expires time.Time
+}
+
+// Stats describes the cache state.
+type Stats struct {
+	// Hits is the number of lookups answered from the cache.
+	Hits uint64
+	// Misses is the number of lookups that were read from the indexer.
+	Misses uint64
+	// Size is the number of cached results.
+	Size int
+}
+
+// New creates a Cache in front of the given indexer.
+func New(ind indexer.Interface, options ...Option) (*Cache, error) {
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{
+		Interface:   ind,
+		negativeTTL: opts.negativeTTL,
+		size:        opts.size,
+		ttl:         opts.ttl,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		byProvider:  make(map[peer.ID]map[string]struct{}),
+		reading:     make(map[string]*pendingRead),
+		now:         time.Now,
+
+		provInvalidated: make(map[peer.ID]uint64),
+	}, nil
+}
+
+// Get returns the cached values for a multihash, or reads them from the
+// indexer and caches them if not cached.
+func (c *Cache) Get(mh multihash.Multihash) ([]indexer.Value, bool, error) {
+	key := string(mh)
+
+	c.mutex.Lock()
+	if elem, ok := c.entries[key]; ok {
+		ent := elem.Value.(*entry)
+		if c.now().Before(ent.expires) {
+			c.lru.MoveToFront(elem)
+			c.hits++
+			c.mutex.Unlock()
+			recordLookup(true, len(ent.values) != 0)
+			return ent.values, len(ent.values) != 0, nil
+		}
+		c.removeElement(elem)
+	}
+	c.misses++
+	pr, ok := c.reading[key]
+	if !ok {
+		pr = &pendingRead{}
+		c.reading[key] = pr
+	}
+	pr.count++
+	c.readers++
+	provSeq := c.provSeq
+	c.mutex.Unlock()
+
+	values, found, err := c.Interface.Get(mh)
+	if !found {
+		values = nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Do not cache the result if it was invalidated while reading it.
+	cache := !pr.stale && !c.providerInvalidated(values, provSeq)
+	pr.count--
+	if pr.count == 0 && c.reading[key] == pr {
+		delete(c.reading, key)
+	}
+	c.readers--
+	if c.readers == 0 && len(c.provInvalidated) != 0 {
+		c.provInvalidated = make(map[peer.ID]uint64)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	recordLookup(false, found)
+	if cache {
+		c.add(key, values)
+	}
+	return values, found, nil
+}
+
+// Put stores the value in the indexer and invalidates cached results that the
+// value changes.
+func (c *Cache) Put(value indexer.Value, mhs ...multihash.Multihash) error {
+	defer c.invalidate(value, mhs)
+	return c.Interface.Put(value, mhs...)
+}
+
+// Remove removes the value from the indexer and invalidates cached results for
+// the multihashes.
+func (c *Cache) Remove(value indexer.Value, mhs ...multihash.Multihash) error {
+	defer c.invalidate(value, mhs)
+	return c.Interface.Remove(value, mhs...)
+}
+
+// RemoveProvider removes the provider's values from the indexer and
+// invalidates all cached results that contain values from the provider.
+func (c *Cache) RemoveProvider(ctx context.Context, providerID peer.ID) error {
+	defer c.invalidateProvider(providerID, nil)
+	return c.Interface.RemoveProvider(ctx, providerID)
+}
+
+// RemoveProviderContext removes the provider's values for the context from the
+// indexer and invalidates cached results that contain those values.
+func (c *Cache) RemoveProviderContext(providerID peer.ID, contextID []byte) error {
+	defer c.invalidateProvider(providerID, contextID)
+	return c.Interface.RemoveProviderContext(providerID, contextID)
+}
+
+// Purge removes all cached results.
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.byProvider = make(map[peer.ID]map[string]struct{})
+	for _, pr := range c.reading {
+		pr.stale = true
+	}
+	c.reading = make(map[string]*pendingRead)
+}
+
+// CacheStats returns the cache hit and miss counts and the number of cached
+// results.
+func (c *Cache) CacheStats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return Stats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Size:   c.lru.Len(),
+	}
+}
+
+// add caches the values for a multihash. The cache mutex must be held.
+func (c *Cache) add(key string, values []indexer.Value) {
+	ttl := c.ttl
+	if len(values) == 0 {
+		if c.negativeTTL == 0 {
+			return
+		}
+		ttl = c.negativeTTL
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.removeElement(elem)
+	}
+	ent := &entry{
+		key:     key,
+		values:  values,
+		expires: c.now().Add(ttl),
+	}
+	c.entries[key] = c.lru.PushFront(ent)
+	for i := range values {
+		keys, ok := c.byProvider[values[i].ProviderID]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.byProvider[values[i].ProviderID] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for c.lru.Len() > c.size {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// removeElement removes a cached result. The cache mutex must be held.
+func (c *Cache) removeElement(elem *list.Element) {
+	ent := c.lru.Remove(elem).(*entry)
+	delete(c.entries, ent.key)
+	for i := range ent.values {
+		provID := ent.values[i].ProviderID
+		keys, ok := c.byProvider[provID]
+		if !ok {
+			continue
+		}
+		delete(keys, ent.key)
+		if len(keys) == 0 {
+			delete(c.byProvider, provID)
+		}
+	}
+}
+
+// invalidate removes the cached results for the multihashes. If there are no
+// multihashes, then the value's metadata may have changed, so the cached
+// results containing the value's provider and context are removed.
+func (c *Cache) invalidate(value indexer.Value, mhs []multihash.Multihash) {
+	if len(mhs) == 0 {
+		c.invalidateProvider(value.ProviderID, value.ContextID)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, mh := range mhs {
+		key := string(mh)
+		if elem, ok := c.entries[key]; ok {
+			c.removeElement(elem)
+		}
+		if pr, ok := c.reading[key]; ok {
+			pr.stale = true
+			delete(c.reading, key)
+		}
+	}
+}
+
+// invalidateProvider removes the cached results that contain values from the
+// provider. If contextID is not nil, then only results that contain a value
+// with that context ID are removed.
+func (c *Cache) invalidateProvider(providerID peer.ID, contextID []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.readers != 0 {
+		c.provSeq++
+		c.provInvalidated[providerID] = c.provSeq
+	}
+	var count int
+	for key := range c.byProvider[providerID] {
+		elem := c.entries[key]
+		if contextID != nil && !hasValue(elem.Value.(*entry).values, providerID, contextID) {
+			continue
+		}
+		c.removeElement(elem)
+		count++
+	}
+	if count != 0 {
+		log.Debugw("Invalidated cached results", "provider", providerID, "count", count)
+	}
+}
+
+// providerInvalidated returns true if results containing values from any of
+// the values' providers were invalidated after provSeq. The cache mutex must
+// be held.
+func (c *Cache) providerInvalidated(values []indexer.Value, provSeq uint64) bool {
+	for i := range values {
+		if c.provInvalidated[values[i].ProviderID] > provSeq {
+			return true
+		}
+	}
+	return false
+}
+
+func hasValue(values []indexer.Value, providerID peer.ID, contextID []byte) bool {
+	for i := range values {
+		if values[i].ProviderID == providerID && bytes.Equal(values[i].ContextID, contextID) {
+			return true
+		}
+	}
+	return false
+}
+
+func recordLookup(hit, found bool) {
+	measure := metrics.FindCacheMiss
+	if hit {
+		measure = metrics.FindCacheHit
+	}
+	_ = stats.RecordWithOptions(context.Background(),
+		stats.WithTags(tag.Insert(metrics.Found, strconv.FormatBool(found))),
+		stats.WithMeasurements(measure.M(1)))
+}
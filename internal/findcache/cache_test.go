@@ -0,0 +1,217 @@
+package findcache
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/engine"
+	"github.com/ipni/go-indexer-core/store/memory"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+var rng = rand.New(rand.NewSource(1413))
+
+func newCache(t *testing.T, options ...Option) *Cache {
+	c, err := New(engine.New(nil, memory.New()), options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCacheHitMiss(t *testing.T) {
+	c := newCache(t)
+	mhs := util.RandomMultihashes(2, rng)
+	p, _, _ := util.RandomIdentity(t)
+	value := indexer.Value{
+		ProviderID:    p,
+		ContextID:     []byte("ctx1"),
+		MetadataBytes: []byte("meta"),
+	}
+	require.NoError(t, c.Put(value, mhs[0]))
+
+	for i := 0; i < 2; i++ {
+		values, found, err := c.Get(mhs[0])
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Len(t, values, 1)
+
+		_, found, err = c.Get(mhs[1])
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+	stats := c.CacheStats()
+	require.Equal(t, uint64(2), stats.Hits)
+	require.Equal(t, uint64(2), stats.Misses)
+	require.Equal(t, 2, stats.Size)
+
+	// Putting a value for a cached negative result invalidates it.
+	require.NoError(t, c.Put(value, mhs[1]))
+	_, found, err := c.Get(mhs[1])
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestCacheExpire(t *testing.T) {
+	c := newCache(t, WithTTL(time.Minute), WithNegativeTTL(time.Second))
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	mhs := util.RandomMultihashes(2, rng)
+	p, _, _ := util.RandomIdentity(t)
+	require.NoError(t, c.Put(indexer.Value{ProviderID: p, ContextID: []byte("ctx1"), MetadataBytes: []byte("meta")}, mhs[0]))
+
+	_, _, err := c.Get(mhs[0])
+	require.NoError(t, err)
+	_, _, err = c.Get(mhs[1])
+	require.NoError(t, err)
+	require.Equal(t, 2, c.CacheStats().Size)
+
+	// Negative result expires first.
+	now = now.Add(2 * time.Second)
+	_, _, err = c.Get(mhs[0])
+	require.NoError(t, err)
+	_, _, err = c.Get(mhs[1])
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), c.CacheStats().Hits)
+
+	now = now.Add(2 * time.Minute)
+	_, _, err = c.Get(mhs[0])
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), c.CacheStats().Hits)
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := newCache(t, WithSize(2))
+	mhs := util.RandomMultihashes(3, rng)
+	for _, mh := range mhs {
+		_, _, err := c.Get(mh)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, c.CacheStats().Size)
+
+	// Least recently used result was evicted.
+	_, _, err := c.Get(mhs[0])
+	require.NoError(t, err)
+	require.Zero(t, c.CacheStats().Hits)
+	_, _, err = c.Get(mhs[2])
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), c.CacheStats().Hits)
+}
+
+func TestCacheInvalidateProvider(t *testing.T) {
+	c := newCache(t)
+	mhs := util.RandomMultihashes(3, rng)
+	p1, _, _ := util.RandomIdentity(t)
+	p2, _, _ := util.RandomIdentity(t)
+	v1 := indexer.Value{ProviderID: p1, ContextID: []byte("ctx1"), MetadataBytes: []byte("meta")}
+	v2 := indexer.Value{ProviderID: p1, ContextID: []byte("ctx2"), MetadataBytes: []byte("meta")}
+	v3 := indexer.Value{ProviderID: p2, ContextID: []byte("ctx1"), MetadataBytes: []byte("meta")}
+	require.NoError(t, c.Put(v1, mhs[0]))
+	require.NoError(t, c.Put(v2, mhs[1]))
+	require.NoError(t, c.Put(v3, mhs[2]))
+	for _, mh := range mhs {
+		_, found, err := c.Get(mh)
+		require.NoError(t, err)
+		require.True(t, found)
+	}
+	require.Equal(t, 3, c.CacheStats().Size)
+
+	// Removing a provider context only invalidates results with that context.
+	require.NoError(t, c.RemoveProviderContext(p1, []byte("ctx1")))
+	require.Equal(t, 2, c.CacheStats().Size)
+	_, found, err := c.Get(mhs[0])
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, c.RemoveProvider(context.Background(), p1))
+	require.Equal(t, 2, c.CacheStats().Size)
+	_, found, err = c.Get(mhs[1])
+	require.NoError(t, err)
+	require.False(t, found)
+
+	values, found, err := c.Get(mhs[2])
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, p2, values[0].ProviderID)
+
+	c.Purge()
+	require.Zero(t, c.CacheStats().Size)
+}
+
+// blockingIndexer blocks Get, after reading from the indexer, until unblocked.
+type blockingIndexer struct {
+	indexer.Interface
+	reading chan struct{}
+	unblock chan struct{}
+}
+
+func (b *blockingIndexer) Get(mh multihash.Multihash) ([]indexer.Value, bool, error) {
+	values, found, err := b.Interface.Get(mh)
+	b.reading <- struct{}{}
+	<-b.unblock
+	return values, found, err
+}
+
+func TestCacheInvalidateWhileReading(t *testing.T) {
+	bi := &blockingIndexer{
+		Interface: engine.New(nil, memory.New()),
+		reading:   make(chan struct{}),
+		unblock:   make(chan struct{}),
+	}
+	c, err := New(bi)
+	require.NoError(t, err)
+	defer c.Close()
+
+	mhs := util.RandomMultihashes(2, rng)
+	p1, _, _ := util.RandomIdentity(t)
+	p2, _, _ := util.RandomIdentity(t)
+	v1 := indexer.Value{ProviderID: p1, ContextID: []byte("ctx1"), MetadataBytes: []byte("meta")}
+	v2 := indexer.Value{ProviderID: p2, ContextID: []byte("ctx1"), MetadataBytes: []byte("meta")}
+	require.NoError(t, c.Put(v1, mhs[0]))
+
+	// getWhile reads the multihash from the cache while calling fn.
+	getWhile := func(mh multihash.Multihash, fn func()) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _, err := c.Get(mh)
+			require.NoError(t, err)
+		}()
+		<-bi.reading
+		fn()
+		bi.unblock <- struct{}{}
+		<-done
+	}
+
+	// Putting a value for an unrelated multihash does not stop the result
+	// from being cached.
+	getWhile(mhs[0], func() {
+		require.NoError(t, c.Put(v2, mhs[1]))
+	})
+	require.Equal(t, 1, c.CacheStats().Size)
+
+	// Putting a value for the multihash being read does.
+	c.Purge()
+	getWhile(mhs[0], func() {
+		require.NoError(t, c.Put(v2, mhs[0]))
+	})
+	require.Zero(t, c.CacheStats().Size)
+
+	// Removing an unrelated provider does not.
+	getWhile(mhs[1], func() {
+		require.NoError(t, c.RemoveProvider(context.Background(), p1))
+	})
+	require.Equal(t, 1, c.CacheStats().Size)
+
+	// Removing a provider in the result does.
+	c.Purge()
+	getWhile(mhs[1], func() {
+		require.NoError(t, c.RemoveProviderContext(p2, []byte("ctx1")))
+	})
+	require.Zero(t, c.CacheStats().Size)
+}
@@ -0,0 +1,73 @@
+package findcache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultNegativeTTL = 30 * time.Second
+	defaultSize        = 100_000
+	defaultTTL         = 5 * time.Minute
+)
+
+// config contains all options for the cache.
+type config struct {
+	negativeTTL time.Duration
+	size        int
+	ttl         time.Duration
+}
+
+// Option is a function that sets a value in a config.
+type Option func(*config) error
+
+// getOpts creates a config and applies Options to it.
+func getOpts(opts []Option) (config, error) {
+	cfg := config{
+		negativeTTL: defaultNegativeTTL,
+		size:        defaultSize,
+		ttl:         defaultTTL,
+	}
+
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return config{}, fmt.Errorf("option %d failed: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithSize sets the maximum number of multihash results held in the cache.
+// When the cache is full, the least recently used result is evicted.
+func WithSize(size int) Option {
+	return func(c *config) error {
+		if size < 1 {
+			return fmt.Errorf("cache size must be at least 1")
+		}
+		c.size = size
+		return nil
+	}
+}
+
+// WithTTL sets how long a multihash result that has values is cached.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) error {
+		if ttl <= 0 {
+			return fmt.Errorf("ttl must be greater than zero")
+		}
+		c.ttl = ttl
+		return nil
+	}
+}
+
+// WithNegativeTTL sets how long a result for a multihash that has no values is
+// cached. A value of zero disables caching results with no values.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(c *config) error {
+		if ttl < 0 {
+			return fmt.Errorf("negative ttl cannot be negative")
+		}
+		c.negativeTTL = ttl
+		return nil
+	}
+}
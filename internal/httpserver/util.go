@@ -10,6 +10,8 @@ import (
 
 	logging "github.com/ipfs/go-log/v2"
 	v0 "github.com/ipni/storetheindex/api/v0"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 var log = logging.Logger("indexer/http")
@@ -48,3 +50,12 @@ func HandleError(w http.ResponseWriter, err error, reqType string) {
 	log.Infow(msg, "err", err, "status", status)
 	http.Error(w, err.Error(), status)
 }
+
+// TraceContext wraps a handler so that each request's context carries the
+// trace context sent in the request headers.
+func TraceContext(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
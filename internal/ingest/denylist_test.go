@@ -0,0 +1,42 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/denylist"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipDenylistedAtIngest(t *testing.T) {
+	te := setupTestEnv(t, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, mhs, providerID, _ := publishRandomIndexAndAdv(t, te.publisher, te.publisherLinkSys, false, nil)
+	denied := mhs[:3]
+
+	listFile := filepath.Join(t.TempDir(), "test.deny")
+	var list []byte
+	for _, mh := range denied {
+		list = append(list, mh.B58String()+"\n"...)
+	}
+	require.NoError(t, os.WriteFile(listFile, list, 0o644))
+	dl, err := denylist.New(ctx, config.Denylist{
+		Sources:    []string{listFile},
+		SkipIngest: true,
+	})
+	require.NoError(t, err)
+	te.ingester.denylist = dl
+
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, te.core, providerID, mhs[3:])
+
+	requireNotIndexed(t, te.core, providerID, denied, "denylisted multihash should not be indexed")
+}
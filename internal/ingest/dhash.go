@@ -0,0 +1,77 @@
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/ipni/dhstore"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/store/dhash"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// putDoubleHashed writes the value's encrypted metadata and, for each
+// multihash, the value key encrypted with that multihash into the local
+// double-hashed index. Each multihash is stored under its second hash so that
+// the index does not reveal the original multihashes.
+//
+// Encrypted value keys cannot be removed from the double-hashed index, since
+// the multihashes they are stored under are not known when content is
+// removed. Instead, tombstoneDoubleHashed replaces the encrypted metadata of a
+// removed value with a tombstone, so that the remaining encrypted value keys
+// cannot be resolved to a result.
+func (ing *Ingester) putDoubleHashed(value indexer.Value, mhs []multihash.Multihash) error {
+	valueKey := dhash.CreateValueKey(value.ProviderID, value.ContextID)
+	encMetadata, err := dhash.EncryptMetadata(value.MetadataBytes, valueKey)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt metadata: %w", err)
+	}
+	err = ing.dhStore.PutMetadata(dhash.SHA256(valueKey, nil), encMetadata)
+	if err != nil {
+		return fmt.Errorf("cannot put encrypted metadata: %w", err)
+	}
+
+	for _, mh := range mhs {
+		mh2, err := dhash.SecondMultihash(mh)
+		if err != nil {
+			return fmt.Errorf("cannot hash multihash: %w", err)
+		}
+		encValueKey, err := dhash.EncryptValueKey(valueKey, mh)
+		if err != nil {
+			return fmt.Errorf("cannot encrypt value key: %w", err)
+		}
+		if err = ing.dhStore.MergeIndex(mh2, dhstore.EncryptedValueKey(encValueKey)); err != nil {
+			return fmt.Errorf("cannot merge encrypted value key: %w", err)
+		}
+	}
+	return nil
+}
+
+// tombstoneDoubleHashed replaces the encrypted metadata for a provider's
+// context ID, in the local double-hashed index, with a tombstone. The
+// tombstone is empty metadata, since dhstore has no way to delete metadata.
+// Readers of the double-hashed index treat empty metadata as not found.
+func (ing *Ingester) tombstoneDoubleHashed(providerID peer.ID, contextID []byte) error {
+	valueKey := dhash.CreateValueKey(providerID, contextID)
+	if err := ing.dhStore.PutMetadata(dhash.SHA256(valueKey, nil), nil); err != nil {
+		return fmt.Errorf("cannot write encrypted metadata tombstone: %w", err)
+	}
+	return nil
+}
+
+// tombstoneProviderDoubleHashed writes a tombstone for the encrypted metadata
+// of each of a removed provider's context IDs in the local double-hashed
+// index. The context IDs are read from the index counts, so this must be
+// called before the provider's index counts are removed.
+func (ing *Ingester) tombstoneProviderDoubleHashed(providerID peer.ID) error {
+	ctxCounts, err := ing.indexCounts.ProviderContexts(providerID)
+	if err != nil {
+		return err
+	}
+	for _, cc := range ctxCounts {
+		if err = ing.tombstoneDoubleHashed(providerID, cc.ContextID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
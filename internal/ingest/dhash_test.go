@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipni/dhstore"
+	"github.com/ipni/go-indexer-core/store/dhash"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoubleHashedIndex(t *testing.T) {
+	te := setupTestEnv(t, true)
+
+	dhs, err := dhstore.NewPebbleDHStore(t.TempDir(), nil)
+	require.NoError(t, err)
+	defer dhs.Close()
+	te.ingester.dhStore = dhs
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, mhs, providerID, providerPriv := publishRandomIndexAndAdv(t, te.publisher, te.publisherLinkSys, false, nil)
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, te.core, providerID, mhs)
+
+	for _, mh := range mhs {
+		values, found, err := te.core.Get(mh)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Len(t, values, 1)
+
+		mh2, err := dhash.SecondMultihash(mh)
+		require.NoError(t, err)
+		evks, err := dhs.Lookup(mh2)
+		require.NoError(t, err)
+		require.Len(t, evks, 1)
+
+		valueKey, err := dhash.DecryptValueKey(evks[0], mh)
+		require.NoError(t, err)
+		pid, ctxID, err := dhash.SplitValueKey(valueKey)
+		require.NoError(t, err)
+		require.Equal(t, providerID, pid)
+		require.Equal(t, values[0].ContextID, ctxID)
+
+		encMetadata, err := dhs.GetMetadata(dhash.SHA256(valueKey, nil))
+		require.NoError(t, err)
+		metadata, err := dhash.DecryptMetadata(encMetadata, valueKey)
+		require.NoError(t, err)
+		require.Equal(t, values[0].MetadataBytes, metadata)
+	}
+
+	// Check that a removal advertisement deletes the encrypted metadata, so
+	// that the remaining encrypted value keys do not resolve to a result.
+	publishRemovalAd(t, te.publisher, te.publisherLinkSys, false, providerID, providerPriv)
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireTrueEventually(t, func() bool {
+		return checkAllIndexed(te.core, providerID, mhs) != nil
+	}, testRetryInterval, testRetryTimeout, "Expected removed context to not be indexed")
+	requireNoEncryptedMetadata(t, dhs, providerID, []byte("test-context-id"))
+}
+
+func TestDoubleHashedIndexRemoveProvider(t *testing.T) {
+	te := setupTestEnv(t, true)
+
+	dhs, err := dhstore.NewPebbleDHStore(t.TempDir(), nil)
+	require.NoError(t, err)
+	defer dhs.Close()
+	te.ingester.dhStore = dhs
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, mhs, providerID, _ := publishRandomIndexAndAdv(t, te.publisher, te.publisherLinkSys, false, nil)
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, te.core, providerID, mhs)
+
+	valueKey := dhash.CreateValueKey(providerID, []byte("test-context-id"))
+	encMetadata, err := dhs.GetMetadata(dhash.SHA256(valueKey, nil))
+	require.NoError(t, err)
+	require.NotEmpty(t, encMetadata)
+
+	require.NoError(t, te.reg.RemoveProvider(ctx, providerID))
+	requireTrueEventually(t, func() bool {
+		encMetadata, err := dhs.GetMetadata(dhash.SHA256(valueKey, nil))
+		require.NoError(t, err)
+		return len(encMetadata) == 0
+	}, testRetryInterval, testRetryTimeout, "Expected encrypted metadata of removed provider to be tombstoned")
+}
+
+func requireNoEncryptedMetadata(t *testing.T, dhs dhstore.DHStore, providerID peer.ID, contextID []byte) {
+	valueKey := dhash.CreateValueKey(providerID, contextID)
+	encMetadata, err := dhs.GetMetadata(dhash.SHA256(valueKey, nil))
+	require.NoError(t, err)
+	require.Empty(t, encMetadata)
+}
@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrDraining is returned when an announce or sync request is received while
+// the ingester is draining.
+var ErrDraining = errors.New("indexer draining")
+
+// Drain stops the ingester from taking new work, so that the indexer can be
+// restarted without interrupting ingestion. Announces and sync requests are
+// rejected, auto-sync is paused, and ingest workers stop after finishing the
+// ad stack that they are currently processing. Ads that are already synced
+// remain queued until Undrain is called. The finder is not affected.
+func (ing *Ingester) Drain() {
+	ing.drainMutex.Lock()
+	defer ing.drainMutex.Unlock()
+
+	if ing.undrained != nil {
+		return
+	}
+	ing.undrained = make(chan struct{})
+	log.Info("Ingester draining")
+}
+
+// Undrain resumes taking new work after Drain, and handles any announces that
+// were deferred while draining.
+func (ing *Ingester) Undrain() {
+	ing.drainMutex.Lock()
+	defer ing.drainMutex.Unlock()
+
+	if ing.undrained == nil {
+		return
+	}
+	close(ing.undrained)
+	ing.undrained = nil
+	log.Info("Ingester resumed from draining")
+
+	// Schedule a worker for each provider with a pending announce. The worker
+	// has no ads to ingest, so it only handles the pending announce.
+	ing.providersPendingAnnounce.Range(func(key, _ any) bool {
+		ing.toWorkers.Push(providerID(key.(peer.ID)))
+		return true
+	})
+}
+
+// Draining returns true if the ingester is draining.
+func (ing *Ingester) Draining() bool {
+	ing.drainMutex.Lock()
+	defer ing.drainMutex.Unlock()
+	return ing.undrained != nil
+}
+
+// Drained returns true if the ingester is draining, no ingest workers are
+// busy, and no advertisement syncs are in progress, meaning that it is safe to
+// restart the indexer.
+func (ing *Ingester) Drained() bool {
+	return ing.Draining() && atomic.LoadInt32(&ing.activeWorkers) == 0 && ing.sub.SyncsInProgress() == 0
+}
+
+// undrainedChan returns a channel that is closed when draining ends, or nil if
+// the ingester is not draining.
+func (ing *Ingester) undrainedChan() <-chan struct{} {
+	ing.drainMutex.Lock()
+	defer ing.drainMutex.Unlock()
+	return ing.undrained
+}
+
+// PauseIngest waits for ingest workers to finish the advertisements they are
+// currently ingesting, and stops them from starting any more. Purges, recounts,
+// and removals of provider data are also paused. This lets a consistent
+// snapshot of the indexer's state be taken. The returned function resumes
+// ingestion, and must be called.
+func (ing *Ingester) PauseIngest() func() {
+	ing.pauseMutex.Lock()
+	log.Info("Ingestion paused")
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ing.pauseMutex.Unlock()
+			log.Info("Ingestion resumed")
+		})
+	}
+}
+
+// allowPeer rejects announces from all peers while draining, and from
+// publishers that are replicated from another indexer. Otherwise it allows
+// announces from peers that the registry allows.
+func (ing *Ingester) allowPeer(peerID peer.ID) bool {
+	if ing.Draining() {
+		return false
+	}
+	// Announces from publishers that are replicated from another indexer are
+	// ignored, since their advertisements are synced from that indexer.
+	if ing.following(peerID) {
+		return false
+	}
+	return ing.reg.Allowed(peerID)
+}
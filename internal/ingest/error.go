@@ -22,6 +22,10 @@ const (
 	adIngestEntryChunkErr adIngestState = "ingestEntryChunkErr"
 )
 
+// adChainSyncErr is the kind of error recorded when syncing a publisher's
+// advertisement chain fails.
+const adChainSyncErr = "adChainSyncErr"
+
 func (e adIngestError) Error() string {
 	return fmt.Sprintf("%s: %s", e.state, e.err)
 }
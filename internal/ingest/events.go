@@ -0,0 +1,149 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/api/v0/ingest/schema"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// AdStatus is the result of processing an advertisement.
+type AdStatus string
+
+const (
+	// AdProcessed means that the advertisement was successfully ingested.
+	AdProcessed AdStatus = "processed"
+	// AdSkipped means that the advertisement was not ingested, either because
+	// its content was removed by a later advertisement or because it has a
+	// permanent error, and will not be processed again.
+	AdSkipped AdStatus = "skipped"
+	// AdFailed means that processing the advertisement failed and will be
+	// retried on the next sync.
+	AdFailed AdStatus = "failed"
+)
+
+// adEventChanBuffer is the number of events that can be buffered for an
+// OnAdEvent reader. A reader that does not keep up is dropped.
+const adEventChanBuffer = 256
+
+// AdEvent describes the result of processing an advertisement.
+type AdEvent struct {
+	// Publisher is the peer that published the advertisement.
+	Publisher peer.ID
+	// Provider is the provider in the advertisement. This is empty if the
+	// advertisement does not have a valid provider ID.
+	Provider peer.ID
+	// AdCid is the CID of the advertisement.
+	AdCid cid.Cid
+	// Status is the result of processing the advertisement.
+	Status AdStatus
+	// MultihashCount is the number of multihashes indexed from the
+	// advertisement's entries.
+	MultihashCount int
+	// Elapsed is how long it took to process the advertisement.
+	Elapsed time.Duration
+	// Err is the reason a skipped or failed advertisement was not ingested.
+	// This is nil for processed advertisements, and for advertisements that
+	// were skipped because their content was removed.
+	Err error
+	// Time is when processing the advertisement finished.
+	Time time.Time
+}
+
+// OnAdEvent returns a channel that receives an AdEvent each time an
+// advertisement is processed, skipped, or fails. If publisher is not empty,
+// then only events for advertisements from that publisher are delivered.
+//
+// The channel is closed when the returned cancel function is called, when the
+// ingester is closed, or when the reader does not keep up with events.
+func (ing *Ingester) OnAdEvent(publisher peer.ID) (<-chan AdEvent, context.CancelFunc) {
+	ch := make(chan AdEvent, adEventChanBuffer)
+
+	ing.adEventsMutex.Lock()
+	defer ing.adEventsMutex.Unlock()
+
+	if ing.adEventsClosed {
+		close(ch)
+		return ch, func() {}
+	}
+	if ing.adEventChans == nil {
+		ing.adEventChans = make(map[chan AdEvent]peer.ID)
+	}
+	ing.adEventChans[ch] = publisher
+
+	cancel := func() {
+		ing.adEventsMutex.Lock()
+		defer ing.adEventsMutex.Unlock()
+		if _, ok := ing.adEventChans[ch]; ok {
+			delete(ing.adEventChans, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// sendAdEvent delivers an adProcessedEvent to all OnAdEvent readers.
+func (ing *Ingester) sendAdEvent(event adProcessedEvent) {
+	ing.adEventsMutex.Lock()
+	defer ing.adEventsMutex.Unlock()
+
+	if len(ing.adEventChans) == 0 {
+		return
+	}
+
+	adEvent := AdEvent{
+		Publisher:      event.publisher,
+		Provider:       event.provider,
+		AdCid:          event.adCid,
+		MultihashCount: event.mhCount,
+		Elapsed:        event.elapsed,
+		Time:           time.Now(),
+	}
+	switch {
+	case event.err != nil:
+		adEvent.Status = AdFailed
+		adEvent.Err = event.err
+	case event.skipped:
+		adEvent.Status = AdSkipped
+		adEvent.Err = event.skipErr
+	default:
+		adEvent.Status = AdProcessed
+	}
+
+	for ch, publisher := range ing.adEventChans {
+		if publisher != "" && publisher != event.publisher {
+			continue
+		}
+		select {
+		case ch <- adEvent:
+		default:
+			log.Warnw("Ad event reader not keeping up, dropping reader", "publisher", publisher)
+			delete(ing.adEventChans, ch)
+			close(ch)
+		}
+	}
+}
+
+// closeAdEvents closes all OnAdEvent channels.
+func (ing *Ingester) closeAdEvents() {
+	ing.adEventsMutex.Lock()
+	defer ing.adEventsMutex.Unlock()
+
+	for ch := range ing.adEventChans {
+		close(ch)
+	}
+	ing.adEventChans = nil
+	ing.adEventsClosed = true
+}
+
+// adProvider returns the provider ID in an advertisement, or an empty ID if
+// the advertisement's provider ID is not valid.
+func adProvider(ad schema.Advertisement) peer.ID {
+	providerID, err := peer.Decode(ad.Provider)
+	if err != nil {
+		return ""
+	}
+	return providerID
+}
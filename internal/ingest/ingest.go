@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,7 @@ import (
 	"github.com/ipld/go-ipld-prime/datamodel"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipni/dhstore"
 	indexer "github.com/ipni/go-indexer-core"
 	coremetrics "github.com/ipni/go-indexer-core/metrics"
 	"github.com/ipni/storetheindex/api/v0/ingest/schema"
@@ -27,6 +29,7 @@ import (
 	"github.com/ipni/storetheindex/dagsync"
 	"github.com/ipni/storetheindex/filestore"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
 	"github.com/ipni/storetheindex/internal/metrics"
 	"github.com/ipni/storetheindex/internal/registry"
 	"github.com/ipni/storetheindex/peerutil"
@@ -35,11 +38,15 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
 var log = logging.Logger("indexer/ingest")
 
+var tracer = otel.Tracer("github.com/ipni/storetheindex/internal/ingest")
+
 // prefix used to track latest sync in datastore.
 const (
 	// syncPrefix identifies the latest sync for each provider.
@@ -61,6 +68,18 @@ type adProcessedEvent struct {
 	adCid cid.Cid
 	// A non-nil value indicates failure to process the ad for adCid.
 	err error
+
+	// provider is the provider in the advertisement, if known.
+	provider peer.ID
+	// mhCount is the number of multihashes indexed from the ad's entries.
+	mhCount int
+	// elapsed is how long it took to process the ad.
+	elapsed time.Duration
+	// skipped is true if the ad was not ingested, either because its content
+	// was removed by a later advertisement or because of a permanent error.
+	skipped bool
+	// skipErr is the permanent error that caused the ad to be skipped.
+	skipErr error
 }
 
 type providerID peer.ID
@@ -84,6 +103,8 @@ type workerAssignment struct {
 	adInfos   []adInfo
 	publisher peer.ID
 	provider  peer.ID
+	// syncSpan is the span context of the sync that fetched the ads.
+	syncSpan trace.SpanContext
 }
 
 // Ingester is a type that uses dagsync for the ingestion protocol.
@@ -97,12 +118,18 @@ type Ingester struct {
 	batchSize uint32
 	closeOnce sync.Once
 
-	sub         *dagsync.Subscriber
-	syncTimeout time.Duration
+	sub           *dagsync.Subscriber
+	syncTimeout   time.Duration
+	adDepthLimit  int
+	segDepthLimit int64
 
 	entriesSel datamodel.Node
 	reg        *registry.Registry
 
+	// overrides are the ingest settings configured for specific publishers.
+	overrides      map[peer.ID]publisherOverride
+	overridesMutex sync.RWMutex
+
 	// inEvents is used to send a adProcessedEvent to the distributeEvents
 	// goroutine, when an advertisement in marked complete or err'd.
 	inEvents chan adProcessedEvent
@@ -112,6 +139,12 @@ type Ingester struct {
 	outEventsChans map[peer.ID][]chan adProcessedEvent
 	outEventsMutex sync.Mutex
 
+	// adEventChans maps each OnAdEvent channel to the publisher it is
+	// filtered by, or to an empty ID if not filtered.
+	adEventChans   map[chan AdEvent]peer.ID
+	adEventsClosed bool
+	adEventsMutex  sync.Mutex
+
 	waitForPendingSyncs sync.WaitGroup
 	closePendingSyncs   chan struct{}
 	cancelWorkers       context.CancelFunc
@@ -133,8 +166,12 @@ type Ingester struct {
 	toWorkers      *Queue
 	waitForWorkers sync.WaitGroup
 	workerPoolSize int
+	workerCount    int32
 	activeWorkers  int32
 
+	// processedAds counts recently processed advertisements.
+	processedAds adCounter
+
 	// RateLimiting
 	rateApply peerutil.Policy
 	rateBurst int
@@ -151,6 +188,45 @@ type Ingester struct {
 
 	indexCounts *counter.IndexCounts
 	carWriter   *carstore.CarWriter
+	// dhStore is the local double-hashed index that is written along with
+	// the value store, if reader privacy is served locally.
+	dhStore dhstore.DHStore
+	// denylist is used to skip indexing denied multihashes.
+	denylist *denylist.Denylist
+	// keepAdData is true if advertisement and entries data is kept in the
+	// datastore after it is ingested.
+	keepAdData bool
+
+	// httpSyncTimeout is the time limit for HTTP requests to publishers.
+	httpSyncTimeout time.Duration
+
+	// replServer serves ingested advertisements to indexers that replicate
+	// from this indexer, at replAddr.
+	replServer *http.Server
+	replAddr   multiaddr.Multiaddr
+
+	// followPubs are the publishers whose advertisements are replicated from
+	// the indexer at followAddr.
+	followAddr   multiaddr.Multiaddr
+	followURL    *url.URL
+	followPubs   map[peer.ID]struct{}
+	followClient *http.Client
+	cancelFollow context.CancelFunc
+	followDone   chan struct{}
+
+	// purgeSignal wakes the background purge of removed provider data.
+	purgeSignal chan struct{}
+	purgeDone   chan struct{}
+	cancelPurge context.CancelFunc
+
+	// undrained is non-nil while draining, and is closed when draining ends.
+	undrained  chan struct{}
+	drainMutex sync.Mutex
+
+	// pauseMutex is read-locked by workers while ingesting an advertisement,
+	// and by anything else that changes the indexer's state, such as purges
+	// and recounts. It is write-locked to pause all of these.
+	pauseMutex sync.RWMutex
 }
 
 // NewIngester creates a new Ingester that uses a dagsync Subscriber to handle
@@ -163,18 +239,23 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 	if opts.dsAds == nil {
 		opts.dsAds = ds
 	}
+	if cfg.PurgeRateLimit < 0 {
+		return nil, errors.New("purge rate limit must be >= 0")
+	}
 
 	ing := &Ingester{
-		host:        h,
-		ds:          ds,
-		dsAds:       opts.dsAds,
-		lsys:        mkLinkSystem(opts.dsAds, reg),
-		indexer:     idxr,
-		batchSize:   uint32(cfg.StoreBatchSize),
-		syncTimeout: time.Duration(cfg.SyncTimeout),
-		entriesSel:  Selectors.EntriesWithLimit(recursionLimit(cfg.EntriesDepthLimit)),
-		reg:         reg,
-		inEvents:    make(chan adProcessedEvent, 1),
+		host:          h,
+		ds:            ds,
+		dsAds:         opts.dsAds,
+		lsys:          mkLinkSystem(opts.dsAds, reg),
+		indexer:       idxr,
+		batchSize:     uint32(cfg.StoreBatchSize),
+		syncTimeout:   time.Duration(cfg.SyncTimeout),
+		adDepthLimit:  cfg.AdvertisementDepthLimit,
+		segDepthLimit: int64(cfg.SyncSegmentDepthLimit),
+		entriesSel:    Selectors.EntriesWithLimit(recursionLimit(cfg.EntriesDepthLimit)),
+		reg:           reg,
+		inEvents:      make(chan adProcessedEvent, 1),
 
 		closePendingSyncs: make(chan struct{}),
 
@@ -186,6 +267,11 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 		minKeyLen: cfg.MinimumKeyLength,
 
 		indexCounts: opts.idxCounts,
+		dhStore:     opts.dhStore,
+		denylist:    opts.denylist,
+		keepAdData:  cfg.CarMirrorDestination.Type != "" || cfg.Replication.ListenMultiaddr != "",
+
+		httpSyncTimeout: time.Duration(cfg.HttpSyncTimeout),
 	}
 
 	ing.workersCtx, ing.cancelWorkers = context.WithCancel(context.Background())
@@ -196,7 +282,12 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 			return nil, fmt.Errorf("cannot create file store for car failes: %w", err)
 		}
 
-		ing.carWriter = carstore.NewWriter(ing.dsAds, fileStore)
+		// Keep advertisement data in the datastore if it is also served to
+		// indexers that replicate from this indexer.
+		ing.carWriter, err = carstore.NewWriter(ing.dsAds, fileStore, carstore.WithKeepData(cfg.Replication.ListenMultiaddr != ""))
+		if err != nil {
+			return nil, fmt.Errorf("cannot create car writer: %w", err)
+		}
 
 		// Start writing existing ads to car files in background. Process
 		// canceled if workers are canceled.
@@ -211,6 +302,11 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 		log.Error(err.Error())
 	}
 
+	ing.overrides, err = configPublisherOverrides(cfg.PublisherOverrides)
+	if err != nil {
+		return nil, err
+	}
+
 	// Instantiate retryable HTTP client used by dagsync httpsync.
 	rclient := &retryablehttp.Client{
 		HTTPClient: &http.Client{
@@ -226,12 +322,13 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 	// Create and start pubsub subscriber. This also registers the storage hook
 	// to index data as it is received.
 	sub, err := dagsync.NewSubscriber(h, ds, ing.lsys, cfg.PubSubTopic, Selectors.AdSequence,
-		dagsync.AllowPeer(reg.Allowed),
+		dagsync.AllowPeer(ing.allowPeer),
 		dagsync.FilterIPs(reg.FilterIPsEnabled()),
-		dagsync.SyncRecursionLimit(recursionLimit(cfg.AdvertisementDepthLimit)),
+		dagsync.AllowAddr(reg.AddrAllowed),
+		dagsync.AnnounceHook(ing.announceHook),
+		dagsync.SyncLimits(ing.syncLimits),
 		dagsync.UseLatestSyncHandler(&syncHandler{ing}),
 		dagsync.RateLimiter(ing.getRateLimiter),
-		dagsync.SegmentDepthLimit(int64(cfg.SyncSegmentDepthLimit)),
 		dagsync.HttpClient(rclient.StandardClient()),
 		dagsync.BlockHook(ing.generalDagsyncBlockHook),
 		dagsync.ResendAnnounce(cfg.ResendDirectAnnounce),
@@ -245,6 +342,14 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 
 	ing.toStaging, ing.cancelOnSyncFinished = ing.sub.OnSyncFinished()
 
+	if err = ing.startReplication(cfg.Replication); err != nil {
+		if ing.replServer != nil {
+			ing.replServer.Close()
+		}
+		ing.sub.Close()
+		return nil, err
+	}
+
 	if cfg.IngestWorkerCount == 0 {
 		return nil, errors.New("ingester worker count must be > 0")
 	}
@@ -260,6 +365,18 @@ func NewIngester(cfg config.Ingest, h host.Host, idxr indexer.Interface, reg *re
 
 	go ing.autoSync()
 
+	if cfg.PurgeRemovedProviders {
+		if ing.indexCounts == nil {
+			log.Warn("Cannot purge removed provider data without index counts")
+		} else {
+			var purgeCtx context.Context
+			purgeCtx, ing.cancelPurge = context.WithCancel(context.Background())
+			ing.purgeSignal = make(chan struct{}, 1)
+			ing.purgeDone = make(chan struct{})
+			go ing.runPurge(purgeCtx, cfg.PurgeRateLimit)
+		}
+	}
+
 	log.Debugf("Ingester started and all hooks and linksystem registered")
 
 	return ing, nil
@@ -286,6 +403,14 @@ func (ing *Ingester) generalDagsyncBlockHook(_ peer.ID, c cid.Cid, actions dagsy
 }
 
 func (ing *Ingester) getRateLimiter(publisher peer.ID) *rate.Limiter {
+	// Use the rate limit configured for the publisher, if any.
+	if ovr, ok := ing.getOverride(publisher); ok && ovr.rateSet {
+		if ovr.rateLimit == 0 {
+			return rate.NewLimiter(rate.Inf, 0)
+		}
+		return rate.NewLimiter(ovr.rateLimit, ovr.rateBurst)
+	}
+
 	ing.rateMutex.Lock()
 	defer ing.rateMutex.Unlock()
 
@@ -355,6 +480,15 @@ func (ing *Ingester) Close() error {
 	// Tell workers to stop ingestion in progress.
 	ing.cancelWorkers()
 
+	// Stop replication.
+	if ing.cancelFollow != nil {
+		ing.cancelFollow()
+		<-ing.followDone
+	}
+	if ing.replServer != nil {
+		ing.replServer.Close()
+	}
+
 	// Close dagsync transport.
 	err := ing.sub.Close()
 	log.Info("dagsync subscriber stopped")
@@ -368,6 +502,7 @@ func (ing *Ingester) Close() error {
 	}
 	ing.outEventsChans = nil
 	ing.outEventsMutex.Unlock()
+	ing.closeAdEvents()
 
 	ing.closeOnce.Do(func() {
 		ing.cancelOnSyncFinished()
@@ -382,6 +517,12 @@ func (ing *Ingester) Close() error {
 		// Stop the distribution goroutine.
 		close(ing.inEvents)
 
+		if ing.cancelPurge != nil {
+			ing.cancelPurge()
+			<-ing.purgeDone
+			log.Info("Purge of removed providers stopped")
+		}
+
 		log.Info("Ingester stopped")
 	})
 
@@ -484,6 +625,13 @@ func (ing *Ingester) Sync(ctx context.Context, peerID peer.ID, peerAddr multiadd
 		return cid.Undef, errors.New("invalid provider id")
 	}
 
+	if ing.Draining() {
+		return cid.Undef, ErrDraining
+	}
+	if ing.reg.PublisherPaused(peerID) {
+		return cid.Undef, ErrPublisherPaused
+	}
+
 	log := log.With("publisher", peerID, "address", peerAddr, "depth", depth, "resync", resync)
 	log.Info("Explicitly syncing the latest advertisement from peer")
 
@@ -531,8 +679,25 @@ func (ing *Ingester) Sync(ctx context.Context, peerID peer.ID, peerAddr multiadd
 			ing.generalDagsyncBlockHook(i, c, actions)
 		}))
 	}
-	c, err := ing.sub.Sync(ctx, peerID, cid.Undef, sel, peerAddr, opts...)
+	nextCid := cid.Undef
+	if ing.following(peerID) {
+		// The publisher's advertisements are replicated from another
+		// indexer, so sync from that indexer up to the latest advertisement
+		// it has from the publisher.
+		nextCid, err = ing.followHead(ctx, peerID)
+		if err != nil {
+			metrics.RecordSyncError(peerID, adChainSyncErr)
+			return cid.Undef, fmt.Errorf("cannot get head from followed indexer: %w", err)
+		}
+		if nextCid == cid.Undef {
+			log.Info("Followed indexer has no advertisements from publisher")
+			return cid.Undef, nil
+		}
+		peerAddr = ing.followAddr
+	}
+	c, err := ing.sub.Sync(ctx, peerID, nextCid, sel, peerAddr, opts...)
 	if err != nil {
+		metrics.RecordSyncError(peerID, adChainSyncErr)
 		return cid.Undef, fmt.Errorf("failed to sync: %w", err)
 	}
 	// Do not persist the latest sync here, because that is done after
@@ -575,6 +740,9 @@ func (ing *Ingester) Sync(ctx context.Context, peerID peer.ID, peerAddr multiadd
 // Announce send an announce message to directly to dagsync, instead of through
 // pubsub.
 func (ing *Ingester) Announce(ctx context.Context, nextCid cid.Cid, addrInfo peer.AddrInfo) error {
+	if ing.Draining() {
+		return ErrDraining
+	}
 	provider := addrInfo.ID
 	log := log.With("provider", provider, "cid", nextCid, "addrs", addrInfo.Addrs)
 
@@ -605,6 +773,10 @@ func (ing *Ingester) Announce(ctx context.Context, nextCid cid.Cid, addrInfo pee
 }
 
 func (ing *Ingester) makeLimitedDepthSelector(peerID peer.ID, depth int, resync bool) (ipld.Node, error) {
+	// Use the depth limit configured for the publisher if depth is 0.
+	if depth == 0 {
+		depth = ing.limitsFor(peerID).adDepthLimit
+	}
 	// Consider the value of < 1 as no-limit.
 	rLimit := recursionLimit(depth)
 	log := log.With("depth", depth)
@@ -688,9 +860,13 @@ func (ing *Ingester) markAdProcessed(publisher peer.ID, adCid cid.Cid, frozen, k
 
 // distributeEvents reads a adProcessedEvent, sent by a peer handler, and
 // copies the event to all channels in outEventsChans. This delivers the event
-// to all onAdProcessed channel readers.
+// to all onAdProcessed channel readers, and then to all OnAdEvent readers.
 func (ing *Ingester) distributeEvents() {
 	for event := range ing.inEvents {
+		if event.err == nil && !event.skipped {
+			ing.processedAds.add(time.Now())
+		}
+
 		// Send update to all change notification channels.
 		ing.outEventsMutex.Lock()
 		outEventsChans, ok := ing.outEventsChans[event.publisher]
@@ -700,6 +876,8 @@ func (ing *Ingester) distributeEvents() {
 			}
 		}
 		ing.outEventsMutex.Unlock()
+
+		ing.sendAdEvent(event)
 	}
 }
 
@@ -830,12 +1008,25 @@ func (ing *Ingester) autoSync() {
 				log.Errorw("Error removing provider", "err", err, "provider", provInfo.AddrInfo.ID)
 			}
 			if ing.indexCounts != nil {
+				ing.pauseMutex.RLock()
+				if ing.dhStore != nil {
+					if err := ing.tombstoneProviderDoubleHashed(provInfo.AddrInfo.ID); err != nil {
+						log.Errorw("Cannot remove double-hashed data of removed provider", "err", err, "provider", provInfo.AddrInfo.ID)
+					}
+				}
+				if ing.purgeSignal != nil {
+					if err := ing.schedulePurge(ctx, provInfo.AddrInfo.ID); err != nil {
+						log.Errorw("Cannot schedule purge of removed provider", "err", err, "provider", provInfo.AddrInfo.ID)
+					}
+				}
 				ing.indexCounts.RemoveProvider(provInfo.AddrInfo.ID)
+				ing.pauseMutex.RUnlock()
 			}
 			// Do not remove provider info from core, because that requires
 			// scanning the entire core valuestore. Instead, let the finder
 			// delete provider contexts as deleted providers appear in find
-			// results.
+			// results, or let the background purge, if enabled, delete the
+			// provider's contexts.
 			continue
 		}
 
@@ -846,7 +1037,6 @@ func (ing *Ingester) autoSync() {
 			log.Infow("Auto-sync already in progress", "provider", provInfo.AddrInfo.ID)
 			continue
 		}
-		autoSyncInProgress[provInfo.AddrInfo.ID] = struct{}{}
 
 		if stopCid := provInfo.StopCid(); stopCid != cid.Undef {
 			err := ing.markAdProcessed(provInfo.Publisher, stopCid, false, false)
@@ -860,6 +1050,20 @@ func (ing *Ingester) autoSync() {
 			}
 		}
 
+		// Auto-sync is paused while draining. The provider is polled again
+		// after draining ends.
+		if ing.Draining() {
+			log.Infow("Skipping auto-sync while draining", "provider", provInfo.AddrInfo.ID)
+			continue
+		}
+		if ing.reg.PublisherPaused(provInfo.Publisher) {
+			log.Infow("Skipping auto-sync of paused publisher", "provider", provInfo.AddrInfo.ID, "publisher", provInfo.Publisher)
+			continue
+		}
+		autoSyncMutex.Lock()
+		autoSyncInProgress[provInfo.AddrInfo.ID] = struct{}{}
+		autoSyncMutex.Unlock()
+
 		// Attempt to sync the provider at its last know publisher, in a
 		// separate goroutine.
 		ing.waitForPendingSyncs.Add(1)
@@ -873,9 +1077,15 @@ func (ing *Ingester) autoSync() {
 			log := log.With("provider", provID, "publisher", pubID, "addr", pubAddr)
 			log.Info("Auto-syncing the latest advertisement with publisher")
 
-			_, err := ing.sub.Sync(ctx, pubID, cid.Undef, nil, pubAddr)
+			var err error
+			if ing.following(pubID) {
+				_, err = ing.syncFollowed(ctx, pubID)
+			} else {
+				_, err = ing.sub.Sync(ctx, pubID, cid.Undef, nil, pubAddr)
+			}
 			if err != nil {
 				log.Errorw("Failed to auto-sync with publisher", "err", err)
+				metrics.RecordSyncError(pubID, adChainSyncErr)
 				return
 			}
 			ing.reg.Saw(provID)
@@ -932,6 +1142,7 @@ func (ing *Ingester) RunWorkers(n int) {
 		ing.closeWorkers <- struct{}{}
 		ing.workerPoolSize--
 	}
+	atomic.StoreInt32(&ing.workerCount, int32(ing.workerPoolSize))
 }
 
 func (ing *Ingester) runIngesterLoop() {
@@ -942,6 +1153,7 @@ func (ing *Ingester) runIngesterLoop() {
 
 func (ing *Ingester) runIngestStep(syncFinishedEvent dagsync.SyncFinished) {
 	log := log.With("publisher", syncFinishedEvent.PeerID)
+	metrics.RecordSync(syncFinishedEvent.PeerID, time.Now())
 	// 1. Group the incoming CIDs by provider.
 	adsGroupedByProvider := map[peer.ID][]adInfo{}
 	for _, c := range syncFinishedEvent.SyncedCids {
@@ -992,6 +1204,7 @@ func (ing *Ingester) runIngestStep(syncFinishedEvent dagsync.SyncFinished) {
 			adInfos:   adInfos,
 			publisher: syncFinishedEvent.PeerID,
 			provider:  p,
+			syncSpan:  syncFinishedEvent.SpanContext,
 		})
 
 		if oldAssignment == nil || oldAssignment.(workerAssignment).none {
@@ -1016,6 +1229,18 @@ func (ing *Ingester) ingestWorker(ctx context.Context) {
 			return
 		case provider := <-ing.toWorkers.PopChan():
 			stats.Record(context.Background(), metrics.AdIngestQueued.M(int64(ing.toWorkers.Length())))
+			// While draining, hold the provider until draining ends. If the
+			// worker is stopped, then put the provider back in the queue for
+			// another worker.
+			if undrained := ing.undrainedChan(); undrained != nil {
+				select {
+				case <-undrained:
+				case <-ing.closeWorkers:
+					ing.toWorkers.Push(provider)
+					log.Debug("stopped ingest worker")
+					return
+				}
+			}
 			pid := peer.ID(provider)
 			ing.providersBeingProcessedMu.Lock()
 			pc := ing.providersBeingProcessed[pid]
@@ -1052,6 +1277,10 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 	var skips []int
 	skip := -1
 
+	// Ingestion can only be paused between advertisements.
+	ing.pauseMutex.RLock()
+	defer ing.pauseMutex.RUnlock()
+
 	frozen := ing.reg.Frozen()
 
 	// Filter out ads that are already processed, and any earlier ads.
@@ -1105,6 +1334,10 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 		ai := assignment.adInfos[i]
 		count++
 
+		// Let ingestion pause here, if requested, before the next ad.
+		ing.pauseMutex.RUnlock()
+		ing.pauseMutex.RLock()
+
 		if ctx.Err() != nil {
 			log.Infow("Ingest worker canceled while processing ads", "provider", provider, "err", ctx.Err())
 			ing.inEvents <- adProcessedEvent{
@@ -1126,11 +1359,10 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 				"adCid", ai.cid,
 				"progress", fmt.Sprintf("%d of %d", count, splitAtIndex))
 
-			keep := ing.carWriter != nil
-			if markErr := ing.markAdProcessed(assignment.publisher, ai.cid, frozen, keep); markErr != nil {
+			if markErr := ing.markAdProcessed(assignment.publisher, ai.cid, frozen, ing.keepAdData); markErr != nil {
 				log.Errorw("Failed to mark ad as processed", "err", markErr)
 			}
-			if !frozen && keep {
+			if !frozen && ing.carWriter != nil {
 				// Write the advertisement to a CAR file, but omit the entries.
 				carInfo, err := ing.carWriter.Write(ctx, ai.cid, true)
 				if err != nil {
@@ -1151,6 +1383,8 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 				publisher: assignment.publisher,
 				headAdCid: assignment.adInfos[0].cid,
 				adCid:     ai.cid,
+				provider:  adProvider(ai.ad),
+				skipped:   true,
 			}
 			continue
 		}
@@ -1165,19 +1399,24 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 		}
 
 		lag := splitAtIndex - count
+		metrics.RecordLag(assignment.publisher, lag)
 		log.Infow("Processing advertisement",
 			"adCid", ai.cid,
 			"entriesCid", entsCid,
 			"progress", fmt.Sprintf("%d of %d", count, splitAtIndex),
 			"lag", lag)
 
-		err := ing.ingestAd(assignment.publisher, ai.cid, ai.ad, ai.resync, frozen, lag)
+		ingestStart := time.Now()
+		mhCount, err := ing.ingestAd(assignment.publisher, ai.cid, ai.ad, ai.resync, frozen, lag, assignment.syncSpan)
+		elapsed := time.Since(ingestStart)
 		if err == nil {
 			// No error at all, this ad was processed successfully.
 			stats.Record(context.Background(), metrics.AdIngestSuccessCount.M(1))
+			metrics.RecordAdIngested(assignment.publisher, mhCount)
 		}
 
 		var adIngestErr adIngestError
+		var skipErr error
 		if errors.As(err, &adIngestErr) {
 			switch adIngestErr.state {
 			case adIngestDecodingErr, adIngestMalformedErr, adIngestEntryChunkErr, adIngestContentNotFound:
@@ -1185,15 +1424,18 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 				// error will happen. So log and drop this error.
 				log.Errorw("Skipping ad because of a permanent error", "adCid", ai.cid, "err", err, "errKind", adIngestErr.state)
 				stats.Record(context.Background(), metrics.AdIngestSkippedCount.M(1))
+				skipErr = err
 				err = nil
 			}
 			stats.RecordWithOptions(context.Background(),
 				stats.WithMeasurements(metrics.AdIngestErrorCount.M(1)),
 				stats.WithTags(tag.Insert(metrics.ErrKind, string(adIngestErr.state))))
+			metrics.RecordSyncError(assignment.publisher, string(adIngestErr.state))
 		} else if err != nil {
 			stats.RecordWithOptions(context.Background(),
 				stats.WithMeasurements(metrics.AdIngestErrorCount.M(1)),
 				stats.WithTags(tag.Insert(metrics.ErrKind, "other error")))
+			metrics.RecordSyncError(assignment.publisher, "other error")
 		}
 
 		if err != nil {
@@ -1205,16 +1447,18 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 				headAdCid: assignment.adInfos[0].cid,
 				adCid:     ai.cid,
 				err:       err,
+				provider:  adProvider(ai.ad),
+				mhCount:   mhCount,
+				elapsed:   elapsed,
 			}
 			return
 		}
 
-		keep := ing.carWriter != nil
-		if markErr := ing.markAdProcessed(assignment.publisher, ai.cid, frozen, keep); markErr != nil {
+		if markErr := ing.markAdProcessed(assignment.publisher, ai.cid, frozen, ing.keepAdData); markErr != nil {
 			log.Errorw("Failed to mark ad as processed", "err", markErr)
 		}
 
-		if !frozen && keep {
+		if !frozen && ing.carWriter != nil {
 			carInfo, err := ing.carWriter.Write(ctx, ai.cid, false)
 			if err != nil {
 				// Log the error, but do not return. Continue on to save the procesed ad.
@@ -1235,12 +1479,18 @@ func (ing *Ingester) ingestWorkerLogic(ctx context.Context, provider peer.ID) {
 			publisher: assignment.publisher,
 			headAdCid: assignment.adInfos[0].cid,
 			adCid:     ai.cid,
+			provider:  adProvider(ai.ad),
+			mhCount:   mhCount,
+			elapsed:   elapsed,
+			skipped:   skipErr != nil,
+			skipErr:   skipErr,
 		}
 	}
 }
 
 func (ing *Ingester) handlePendingAnnounce(ctx context.Context, pid peer.ID) {
-	if ctx.Err() != nil {
+	// Pending announces are kept while draining, and handled by Undrain.
+	if ctx.Err() != nil || ing.Draining() {
 		return
 	}
 	log := log.With("provider", pid)
@@ -13,6 +13,7 @@ import (
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipld/go-ipld-prime"
 	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
@@ -28,8 +29,10 @@ import (
 	"github.com/ipni/storetheindex/dagsync"
 	"github.com/ipni/storetheindex/dagsync/dtsync"
 	dstest "github.com/ipni/storetheindex/dagsync/test"
+	"github.com/ipni/storetheindex/filestore"
 	"github.com/ipni/storetheindex/internal/counter"
 	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/ipni/storetheindex/internal/snapshot"
 	"github.com/ipni/storetheindex/test/typehelpers"
 	"github.com/ipni/storetheindex/test/util"
 	"github.com/libp2p/go-libp2p"
@@ -802,6 +805,254 @@ func TestSync(t *testing.T) {
 	require.Zero(t, count)
 }
 
+func TestPurgeRemovedProvider(t *testing.T) {
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	h := mkTestHost()
+	pubHost := mkTestHost()
+	cfg := defaultTestIngestConfig
+	cfg.PurgeRemovedProviders = true
+	cfg.PurgeRateLimit = 1000
+	i, core, reg, indexCounts := mkIngestWithConfig(t, h, cfg)
+	defer core.Close()
+	defer i.Close()
+	pub, lsys := mkMockPublisher(t, pubHost, srcStore)
+	defer pub.Close()
+	connectHosts(t, h, pubHost)
+
+	_, mhs, providerID, _ := publishRandomIndexAndAdv(t, pub, lsys, false, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := i.Sync(ctx, pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, i.indexer, providerID, mhs)
+
+	err = reg.RemoveProvider(ctx, providerID)
+	require.NoError(t, err)
+
+	requireTrueEventually(t, func() bool {
+		return checkAllIndexed(i.indexer, providerID, mhs) != nil
+	}, testRetryInterval, testRetryTimeout, "Expected removed provider data to be purged")
+	requireNotIndexed(t, i.indexer, providerID, mhs)
+
+	count, err := indexCounts.Provider(providerID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Check that there are no pending purges left.
+	requireTrueEventually(t, func() bool {
+		results, err := i.ds.Query(ctx, query.Query{Prefix: purgePrefix, KeysOnly: true})
+		require.NoError(t, err)
+		ents, err := results.Rest()
+		require.NoError(t, err)
+		return len(ents) == 0
+	}, testRetryInterval, testRetryTimeout, "Expected no pending purges")
+}
+
+func TestPurgeWhileSnapshot(t *testing.T) {
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	h := mkTestHost()
+	pubHost := mkTestHost()
+	cfg := defaultTestIngestConfig
+	cfg.PurgeRemovedProviders = true
+	cfg.PurgeRateLimit = 1000
+	i, core, reg, _ := mkIngestWithConfig(t, h, cfg)
+	defer core.Close()
+	defer i.Close()
+	pub, lsys := mkMockPublisher(t, pubHost, srcStore)
+	defer pub.Close()
+	connectHosts(t, h, pubHost)
+
+	_, mhs, providerID, _ := publishRandomIndexAndAdv(t, pub, lsys, false, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := i.Sync(ctx, pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, i.indexer, providerID, mhs)
+
+	fs, err := filestore.New(config.FileStore{
+		Type:  "local",
+		Local: config.LocalFileStore{BasePath: t.TempDir()},
+	})
+	require.NoError(t, err)
+	snapper, err := snapshot.New(fs, h.ID(), i.ds, i.indexer, snapshot.WithPause(i.PauseIngest))
+	require.NoError(t, err)
+
+	// The removed provider is not purged while ingestion is paused.
+	resume := i.PauseIngest()
+	err = reg.RemoveProvider(ctx, providerID)
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, checkAllIndexed(i.indexer, providerID, mhs))
+	resume()
+
+	// Take snapshots while the provider is purged, and check that each one
+	// has the provider's values only if it has the provider's index counts or
+	// a pending purge of them.
+	for n := 0; ; n++ {
+		manifest, err := snapper.Snapshot(ctx, fmt.Sprint("snap", n))
+		require.NoError(t, err)
+		newDS := dssync.MutexWrap(datastore.NewMapDatastore())
+		newVS := memory.New()
+		require.NoError(t, snapshot.Restore(ctx, fs, manifest, newDS, nil, newVS))
+
+		results, err := newDS.Query(ctx, query.Query{Prefix: purgePrefix, KeysOnly: true})
+		require.NoError(t, err)
+		ents, err := results.Rest()
+		require.NoError(t, err)
+		purgePending := len(ents) != 0
+		count, err := counter.NewIndexCounts(newDS).Provider(providerID)
+		require.NoError(t, err)
+		_, found, err := newVS.Get(mhs[0])
+		require.NoError(t, err)
+
+		require.False(t, purgePending && count != 0, "snapshot %d has index counts of purged provider", n)
+		require.Equal(t, purgePending || count != 0, found, "snapshot %d has inconsistent purge", n)
+		if !found {
+			break
+		}
+	}
+}
+
+func TestPurgeRateLimitConfig(t *testing.T) {
+	h := mkTestHost()
+	defer h.Close()
+	cfg := defaultTestIngestConfig
+	cfg.PurgeRemovedProviders = true
+	cfg.PurgeRateLimit = -1
+	core := mkIndexer(t, true)
+	defer core.Close()
+	reg := mkRegistry(t)
+	defer reg.Close()
+	_, err := NewIngester(cfg, h, core, reg, datastore.NewMapDatastore())
+	require.ErrorContains(t, err, "purge rate limit")
+}
+
+func TestRecountProvider(t *testing.T) {
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	h := mkTestHost()
+	pubHost := mkTestHost()
+	i, core, _, indexCounts := mkIngest(t, h)
+	defer core.Close()
+	defer i.Close()
+	pub, lsys := mkMockPublisher(t, pubHost, srcStore)
+	defer pub.Close()
+	connectHosts(t, h, pubHost)
+
+	_, mhs, providerID, privKey := publishRandomIndexAndAdv(t, pub, lsys, false, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := i.Sync(ctx, pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, i.indexer, providerID, mhs)
+
+	expectCount := uint64(testEntriesChunkCount * testEntriesChunkSize)
+	count, err := indexCounts.Provider(providerID)
+	require.NoError(t, err)
+	require.Equal(t, expectCount, count)
+
+	// Make the count inaccurate and check that recounting corrects it.
+	indexCounts.AddCount(providerID, []byte("bad-context"), 100)
+	count, err = indexCounts.Provider(providerID)
+	require.NoError(t, err)
+	require.Equal(t, expectCount+100, count)
+
+	prevCount, count, err := i.RecountProvider(ctx, providerID)
+	require.NoError(t, err)
+	require.Equal(t, expectCount+100, prevCount)
+	require.Equal(t, expectCount, count)
+	count, err = indexCounts.Provider(providerID)
+	require.NoError(t, err)
+	require.Equal(t, expectCount, count)
+	ctxCounts, err := indexCounts.ProviderContexts(providerID)
+	require.NoError(t, err)
+	require.Len(t, ctxCounts, 1)
+	require.Equal(t, expectCount, ctxCounts[0].Count)
+
+	// Check that recounting does not count content removed by a later
+	// advertisement.
+	publishRemovalAd(t, pub, lsys, false, providerID, privKey)
+	_, err = i.Sync(ctx, pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireTrueEventually(t, func() bool {
+		count, err = indexCounts.Provider(providerID)
+		require.NoError(t, err)
+		return count == 0
+	}, testRetryInterval, testRetryTimeout, "Expected removal to reduce index count to zero")
+
+	indexCounts.AddCount(providerID, []byte("bad-context"), 100)
+	_, count, err = i.RecountProvider(ctx, providerID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	count, err = indexCounts.Provider(providerID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestAdChainCountAddOlder(t *testing.T) {
+	older := &adChainCount{
+		counts:  map[string]uint64{"a": 1, "b": 2},
+		removed: map[string]struct{}{"c": {}},
+		newest:  cid.MustParse("bafkqaaa"),
+	}
+	newer := &adChainCount{
+		counts:  map[string]uint64{"a": 10},
+		removed: map[string]struct{}{"b": {}},
+	}
+	newer.addOlder(older)
+	require.Equal(t, map[string]uint64{"a": 11}, newer.counts)
+	require.Len(t, newer.removed, 2)
+	require.Equal(t, older.newest, newer.newest)
+	require.Len(t, newer.contextCounts(), 1)
+}
+
+func TestOnAdEvent(t *testing.T) {
+	srcStore := dssync.MutexWrap(datastore.NewMapDatastore())
+	h := mkTestHost()
+	pubHost := mkTestHost()
+	i, core, _, _ := mkIngest(t, h)
+	defer core.Close()
+	pub, lsys := mkMockPublisher(t, pubHost, srcStore)
+	defer pub.Close()
+	connectHosts(t, h, pubHost)
+
+	events, cancelEvents := i.OnAdEvent(pubHost.ID())
+	defer cancelEvents()
+	otherEvents, cancelOther := i.OnAdEvent(h.ID())
+	defer cancelOther()
+
+	adCid, mhs, providerID, _ := publishRandomIndexAndAdv(t, pub, lsys, false, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := i.Sync(ctx, pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+
+	select {
+	case event := <-events:
+		require.Equal(t, AdProcessed, event.Status)
+		require.Equal(t, pubHost.ID(), event.Publisher)
+		require.Equal(t, providerID, event.Provider)
+		require.Equal(t, adCid, event.AdCid)
+		require.Equal(t, len(mhs), event.MultihashCount)
+		require.NoError(t, event.Err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for ad event")
+	}
+
+	select {
+	case event := <-otherEvents:
+		t.Fatalf("unexpected event for publisher %s", event.Publisher)
+	default:
+	}
+
+	// Check that closing the ingester closes the event channels.
+	require.NoError(t, i.Close())
+	_, open := <-events
+	require.False(t, open)
+}
+
 func testSyncWithExtendedProviders(t *testing.T,
 	testFunc func(crypto.PrivKey, crypto.PubKey, peer.ID, *registry.Registry, linking.LinkSystem, host.Host, *Ingester, dagsync.Publisher)) {
 	privKey, pubKey, err := test.RandTestKeyPair(crypto.Ed25519, 256)
@@ -1396,6 +1647,114 @@ func TestRateLimitConfig(t *testing.T) {
 	ingester.Close()
 }
 
+func TestPublisherOverrides(t *testing.T) {
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+	defer store.Close()
+	reg := mkRegistry(t)
+	defer reg.Close()
+	core := mkIndexer(t, true)
+	defer core.Close()
+	pubHost := mkTestHost()
+	otherHost := mkTestHost()
+	h := mkTestHost()
+
+	cfg := defaultTestIngestConfig
+	cfg.PublisherOverrides = []config.PublisherOverride{{
+		PublisherID:             pubHost.ID().String(),
+		AdvertisementDepthLimit: 5,
+		SyncTimeout:             config.Duration(time.Hour),
+		BlocksPerSecond:         -1,
+	}}
+	ingester, err := NewIngester(cfg, h, core, reg, store)
+	require.NoError(t, err)
+	defer ingester.Close()
+
+	limits := ingester.limitsFor(pubHost.ID())
+	require.Equal(t, 5, limits.adDepthLimit)
+	require.Equal(t, time.Hour, limits.syncTimeout)
+	require.Equal(t, int64(cfg.SyncSegmentDepthLimit), limits.segDepthLimit)
+	recLimit, _ := ingester.syncLimits(pubHost.ID())
+	require.Equal(t, int64(5), recLimit.Depth())
+	require.Equal(t, rate.Inf, ingester.getRateLimiter(pubHost.ID()).Limit())
+
+	limits = ingester.limitsFor(otherHost.ID())
+	require.Equal(t, cfg.AdvertisementDepthLimit, limits.adDepthLimit)
+	require.Equal(t, time.Duration(cfg.SyncTimeout), limits.syncTimeout)
+	require.Equal(t, rate.Limit(cfg.RateLimit.BlocksPerSecond), ingester.getRateLimiter(otherHost.ID()).Limit())
+
+	// Check that a bad override does not change the current overrides.
+	err = ingester.SetPublisherOverrides([]config.PublisherOverride{{
+		PublisherID: "bad-id",
+	}})
+	require.Error(t, err)
+	require.Equal(t, 5, ingester.limitsFor(pubHost.ID()).adDepthLimit)
+
+	// Check that reloaded overrides replace the previous ones.
+	err = ingester.SetPublisherOverrides([]config.PublisherOverride{{
+		PublisherID:     otherHost.ID().String(),
+		BlocksPerSecond: 1000,
+	}})
+	require.NoError(t, err)
+	require.Equal(t, cfg.AdvertisementDepthLimit, ingester.limitsFor(pubHost.ID()).adDepthLimit)
+	limiter := ingester.getRateLimiter(otherHost.ID())
+	require.Equal(t, rate.Limit(1000), limiter.Limit())
+	require.Equal(t, 5000, limiter.Burst())
+}
+
+func TestPausePublisher(t *testing.T) {
+	te := setupTestEnv(t, true)
+	ctx := context.Background()
+	pubID := te.pubHost.ID()
+
+	// Sync an advertisement before pausing.
+	adHead := typehelpers.RandomAdBuilder{
+		EntryBuilders: []typehelpers.EntryBuilder{
+			typehelpers.RandomEntryChunkBuilder{ChunkCount: 2, EntriesPerChunk: 10, Seed: 1},
+		}}.Build(t, te.publisherLinkSys, te.publisherPriv)
+	err := te.publisher.UpdateRoot(ctx, adHead.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	_, err = te.ingester.Sync(ctx, pubID, nil, 0, false)
+	require.NoError(t, err)
+	mhs := typehelpers.AllMultihashesFromAdLink(t, adHead, te.publisherLinkSys)
+	requireIndexedEventually(t, te.ingester.indexer, pubID, mhs)
+
+	require.NoError(t, te.ingester.PausePublisher(pubID))
+	require.True(t, te.ingester.PublisherPaused(pubID))
+
+	_, err = te.ingester.Sync(ctx, pubID, nil, 0, false)
+	require.ErrorIs(t, err, ErrPublisherPaused)
+
+	// Announce a new advertisement while paused.
+	adHead2 := typehelpers.RandomAdBuilder{
+		EntryBuilders: []typehelpers.EntryBuilder{
+			typehelpers.RandomEntryChunkBuilder{ChunkCount: 2, EntriesPerChunk: 10, Seed: 2},
+		}}.Build(t, te.publisherLinkSys, te.publisherPriv)
+	adCid2 := adHead2.(cidlink.Link).Cid
+	err = te.publisher.UpdateRoot(ctx, adCid2)
+	require.NoError(t, err)
+	addrInfo := peer.AddrInfo{
+		ID:    pubID,
+		Addrs: te.pubHost.Addrs(),
+	}
+	err = te.ingester.Announce(ctx, adCid2, addrInfo)
+	require.NoError(t, err)
+
+	requireTrueEventually(t, func() bool {
+		pa, _ := te.reg.PausedAnnounceFor(pubID)
+		return pa.Cid == adCid2
+	}, testRetryInterval, testRetryTimeout, "announce from paused publisher not recorded")
+
+	// Existing index is still served, and announced ad is not ingested.
+	require.NoError(t, checkAllIndexed(te.ingester.indexer, pubID, mhs))
+	mhs2 := typehelpers.AllMultihashesFromAdLink(t, adHead2, te.publisherLinkSys)
+	requireNotIndexed(t, te.ingester.indexer, pubID, mhs2)
+
+	// Resuming syncs the advertisement announced while paused.
+	require.NoError(t, te.ingester.ResumePublisher(pubID))
+	require.False(t, te.ingester.PublisherPaused(pubID))
+	requireIndexedEventually(t, te.ingester.indexer, pubID, mhs2)
+}
+
 func mkTestHost(opts ...libp2p.Option) host.Host {
 	// 10x Faster than the default identity option in libp2p.New
 	var defaultIdentity libp2p.Option = func(cfg *libp2p.Config) error {
@@ -1978,3 +2337,22 @@ func setupTestEnv(t *testing.T, shouldConnectHosts bool, opts ...func(*testEnvOp
 
 	return te
 }
+
+func TestAdCounter(t *testing.T) {
+	var c adCounter
+	now := time.Now()
+
+	c.add(now)
+	c.add(now.Add(-30 * time.Minute))
+	c.add(now.Add(-2 * time.Hour))
+	c.add(now.Add(-23 * time.Hour))
+	c.add(now.Add(-25 * time.Hour))
+
+	require.Equal(t, uint64(2), c.countSince(now, time.Hour))
+	require.Equal(t, uint64(4), c.countSince(now, adCountWindow))
+
+	// Counts older than the window are dropped when their bucket is reused.
+	c.add(now.Add(-time.Hour))
+	require.Equal(t, uint64(5), c.countSince(now, adCountWindow))
+	require.Equal(t, uint64(0), c.countSince(now.Add(48*time.Hour), adCountWindow))
+}
@@ -28,6 +28,9 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
 	"go.opencensus.io/stats"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	// Import so these codecs get registered.
@@ -139,9 +142,26 @@ func verifyAdvertisement(n ipld.Node, reg *registry.Registry) (peer.ID, error) {
 // source of the indexed content, the provider is where content can be
 // retrieved from. It is the provider ID that needs to be stored by the
 // indexer.
-func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Advertisement, resync, frozen bool, lag int) error {
+func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Advertisement, resync, frozen bool, lag int, syncSpan trace.SpanContext) (mhCount int, err error) {
+	// The ad is ingested some time after the sync that fetched it, and
+	// possibly along with ads from other syncs, so link to the sync span
+	// instead of making this a child of it.
+	ctx, span := tracer.Start(context.Background(), "Ingester.ingestAd",
+		trace.WithLinks(trace.Link{SpanContext: syncSpan}),
+		trace.WithAttributes(
+			attribute.String("publisher", publisherID.String()),
+			attribute.String("adCid", adCid.String()),
+			attribute.Bool("resync", resync),
+			attribute.Int("lag", lag)))
+	defer func() {
+		span.SetAttributes(attribute.Int("multihashes", mhCount))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	stats.Record(context.Background(), metrics.IngestChange.M(1))
-	var mhCount int
 	var entsSyncStart time.Time
 	var entsStoreElapsed time.Duration
 	ingestStart := time.Now()
@@ -175,7 +195,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 	// Get provider ID from advertisement.
 	providerID, err := peer.Decode(ad.Provider)
 	if err != nil {
-		return adIngestError{adIngestDecodingErr, fmt.Errorf("failed to read provider id: %w", err)}
+		return mhCount, adIngestError{adIngestDecodingErr, fmt.Errorf("failed to read provider id: %w", err)}
 	}
 
 	// Register provider or update existing registration. The provider must be
@@ -199,16 +219,14 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		Addrs: maddrs,
 	}
 
-	ctx := context.Background()
-
 	var extendedProviders *registry.ExtendedProviders
 	if ad.ExtendedProvider != nil {
 		if ad.IsRm {
-			return adIngestError{adIngestIndexerErr, fmt.Errorf("rm ads can not have extended providers")}
+			return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("rm ads can not have extended providers")}
 		}
 
 		if len(ad.ContextID) == 0 && ad.ExtendedProvider.Override {
-			return adIngestError{adIngestIndexerErr, fmt.Errorf("override can not be set on extended provider without context id")}
+			return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("override can not be set on extended provider without context id")}
 		}
 
 		// Fetching the existing ExtendedProvider record or creating a new one
@@ -229,7 +247,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		for _, ep := range ad.ExtendedProvider.Providers {
 			epID, err := peer.Decode(ep.ID)
 			if err != nil {
-				return adIngestError{adIngestRegisterProviderErr, fmt.Errorf("could not register/update extended provider info: %w", err)}
+				return mhCount, adIngestError{adIngestRegisterProviderErr, fmt.Errorf("could not register/update extended provider info: %w", err)}
 			}
 
 			eProvs = append(eProvs, registry.ExtendedProviderInfo{
@@ -253,17 +271,23 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 
 	err = ing.reg.Update(ctx, provider, publisher, adCid, extendedProviders, lag)
 	if err != nil {
-		return adIngestError{adIngestRegisterProviderErr, fmt.Errorf("could not register/update provider info: %w", err)}
+		return mhCount, adIngestError{adIngestRegisterProviderErr, fmt.Errorf("could not register/update provider info: %w", err)}
 	}
 
 	log = log.With("contextID", base64.StdEncoding.EncodeToString(ad.ContextID), "provider", providerID)
+	span.SetAttributes(attribute.String("provider", providerID.String()))
 
 	if ad.IsRm {
 		log.Infow("Advertisement is for removal by context id")
 
 		err = ing.indexer.RemoveProviderContext(providerID, ad.ContextID)
 		if err != nil {
-			return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to remove provider context: %w", err)}
+			return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to remove provider context: %w", err)}
+		}
+		if ing.dhStore != nil {
+			if err = ing.tombstoneDoubleHashed(providerID, ad.ContextID); err != nil {
+				return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to tombstone double-hashed provider context: %w", err)}
+			}
 		}
 		if ing.indexCounts != nil {
 			rmCount, err := ing.indexCounts.RemoveCtx(providerID, ad.ContextID)
@@ -273,16 +297,16 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 				log.Debugf("Removal ad reduced index count by %d", rmCount)
 			}
 		}
-		return nil
+		return mhCount, nil
 	}
 
 	if len(ad.Metadata) == 0 {
 		// If the ad has no metadata and no entries, then the ad is only for
 		// updating provider addresses. Otherwise it is an error.
 		if ad.Entries != schema.NoEntries {
-			return adIngestError{adIngestMalformedErr, fmt.Errorf("advertisement missing metadata")}
+			return mhCount, adIngestError{adIngestMalformedErr, fmt.Errorf("advertisement missing metadata")}
 		}
-		return nil
+		return mhCount, nil
 	}
 
 	// If advertisement has no entries, then it is for updating metadata only.
@@ -301,19 +325,26 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		}
 		err = ing.indexer.Put(value)
 		if err != nil {
-			return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to update metadata: %w", err)}
+			return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to update metadata: %w", err)}
 		}
-		return nil
+		// Do not write more data when frozen.
+		if ing.dhStore != nil && !frozen {
+			if err = ing.putDoubleHashed(value, nil); err != nil {
+				return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to update double-hashed metadata: %w", err)}
+			}
+		}
+		return mhCount, nil
 	}
 
 	entriesCid := ad.Entries.(cidlink.Link).Cid
 	if entriesCid == cid.Undef {
-		return adIngestError{adIngestMalformedErr, errors.New("advertisement entries link is undefined")}
+		return mhCount, adIngestError{adIngestMalformedErr, errors.New("advertisement entries link is undefined")}
 	}
 
-	if ing.syncTimeout != 0 {
+	limits := ing.limitsFor(publisherID)
+	if limits.syncTimeout != 0 {
 		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, ing.syncTimeout)
+		ctx, cancel = context.WithTimeout(ctx, limits.syncTimeout)
 		defer cancel()
 	}
 
@@ -342,15 +373,15 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		case
 			strings.Contains(msg, "content not found"),
 			strings.Contains(msg, "graphsync request failed to complete: skip"):
-			return adIngestError{adIngestContentNotFound, wrappedErr}
+			return mhCount, adIngestError{adIngestContentNotFound, wrappedErr}
 		default:
-			return adIngestError{adIngestSyncEntriesErr, wrappedErr}
+			return mhCount, adIngestError{adIngestSyncEntriesErr, wrappedErr}
 		}
 	}
 
 	node, err := ing.loadNode(syncedFirstEntryCid, basicnode.Prototype.Any)
 	if err != nil {
-		return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to load first entry after sync: %w", err)}
+		return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to load first entry after sync: %w", err)}
 	}
 
 	var errsIngestingEntryChunks []error
@@ -363,7 +394,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		gatherCids := func(_ peer.ID, c cid.Cid, _ dagsync.SegmentSyncActions) {
 			hamtCids = append(hamtCids, c)
 		}
-		if ing.carWriter == nil {
+		if !ing.keepAdData {
 			defer func() {
 				for _, c := range hamtCids {
 					err := ing.dsAds.Delete(ctx, datastore.NewKey(c.String()))
@@ -377,7 +408,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		// Load the CID as HAMT root node.
 		hn, err := ing.loadHamt(syncedFirstEntryCid)
 		if err != nil {
-			return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to load entries as HAMT root node: %w", err)}
+			return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to load entries as HAMT root node: %w", err)}
 		}
 
 		// Sync all the links in the hamt, since so far we have only synced the root.
@@ -396,9 +427,9 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 				if err != nil {
 					wrappedErr := fmt.Errorf("failed to sync remaining HAMT: %w", err)
 					if strings.Contains(err.Error(), "content not found") {
-						return adIngestError{adIngestContentNotFound, wrappedErr}
+						return mhCount, adIngestError{adIngestContentNotFound, wrappedErr}
 					}
-					return adIngestError{adIngestSyncEntriesErr, wrappedErr}
+					return mhCount, adIngestError{adIngestSyncEntriesErr, wrappedErr}
 				}
 			}
 		}
@@ -415,11 +446,11 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		for !mi.Done() {
 			k, _, err := mi.Next()
 			if err != nil {
-				return adIngestError{adIngestIndexerErr, fmt.Errorf("faild to iterate through HAMT: %w", err)}
+				return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("faild to iterate through HAMT: %w", err)}
 			}
 			ks, err := k.AsString()
 			if err != nil {
-				return adIngestError{adIngestMalformedErr, fmt.Errorf("HAMT key must be of type string: %w", err)}
+				return mhCount, adIngestError{adIngestMalformedErr, fmt.Errorf("HAMT key must be of type string: %w", err)}
 			}
 			mhs = append(mhs, multihash.Multihash(ks))
 			// The reason we need batching here is because here we are
@@ -435,8 +466,8 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 			// TODO: See how we can refactor code to make batching logic more
 			// flexible in indexContentBlock.
 			if len(mhs) >= int(ing.batchSize) {
-				if err = ing.indexAdMultihashes(ad, mhs, log); err != nil {
-					return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to index content from HAMT: %w", err)}
+				if err = ing.indexAdMultihashes(ctx, ad, mhs, log); err != nil {
+					return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to index content from HAMT: %w", err)}
 				}
 				mhCount += len(mhs)
 				mhs = mhs[:0]
@@ -444,8 +475,8 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 		}
 		// Process any remaining multihashes from the batch cut-off.
 		if len(mhs) > 0 {
-			if err = ing.indexAdMultihashes(ad, mhs, log); err != nil {
-				return adIngestError{adIngestIndexerErr, fmt.Errorf("failed to index content from HAMT: %w", err)}
+			if err = ing.indexAdMultihashes(ctx, ad, mhs, log); err != nil {
+				return mhCount, adIngestError{adIngestIndexerErr, fmt.Errorf("failed to index content from HAMT: %w", err)}
 			}
 			mhCount += len(mhs)
 		}
@@ -472,7 +503,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 			nextChunkCid := chunk.Next.(cidlink.Link).Cid
 			// Traverse remaining entry chunks based on the entries selector
 			// that limits recursion depth.
-			_, err = ing.sub.Sync(ctx, publisherID, nextChunkCid, ing.entriesSel, nil, dagsync.ScopedBlockHook(func(p peer.ID, c cid.Cid, actions dagsync.SegmentSyncActions) {
+			_, err = ing.sub.Sync(ctx, publisherID, nextChunkCid, limits.entriesSel, nil, dagsync.ScopedBlockHook(func(p peer.ID, c cid.Cid, actions dagsync.SegmentSyncActions) {
 				chunkStart := time.Now()
 
 				// Load CID as entry chunk since the selector should only
@@ -501,9 +532,9 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 			if err != nil {
 				wrappedErr := fmt.Errorf("failed to sync entries: %w", err)
 				if strings.Contains(err.Error(), "content not found") {
-					return adIngestError{adIngestContentNotFound, wrappedErr}
+					return mhCount, adIngestError{adIngestContentNotFound, wrappedErr}
 				}
-				return adIngestError{adIngestSyncEntriesErr, wrappedErr}
+				return mhCount, adIngestError{adIngestSyncEntriesErr, wrappedErr}
 			}
 		}
 	}
@@ -517,9 +548,9 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 	}
 
 	if len(errsIngestingEntryChunks) > 0 {
-		return adIngestError{adIngestEntryChunkErr, fmt.Errorf("failed to ingest entry chunks: %v", errsIngestingEntryChunks)}
+		return mhCount, adIngestError{adIngestEntryChunkErr, fmt.Errorf("failed to ingest entry chunks: %v", errsIngestingEntryChunks)}
 	}
-	return nil
+	return mhCount, nil
 }
 
 // ingestEntryChunk ingests a block of entries as that block is received
@@ -530,7 +561,7 @@ func (ing *Ingester) ingestAd(publisherID peer.ID, adCid cid.Cid, ad schema.Adve
 // operation. This function is used as a scoped block hook, and is called for
 // each block that is received.
 func (ing *Ingester) ingestEntryChunk(ctx context.Context, ad schema.Advertisement, entryChunkCid cid.Cid, chunk schema.EntryChunk, log *zap.SugaredLogger) error {
-	if ing.carWriter == nil {
+	if !ing.keepAdData {
 		defer func() {
 			// Remove the content block from the data store now that processing it
 			// has finished. This prevents storing redundant information in several
@@ -542,7 +573,7 @@ func (ing *Ingester) ingestEntryChunk(ctx context.Context, ad schema.Advertiseme
 			}
 		}()
 	}
-	err := ing.indexAdMultihashes(ad, chunk.Entries, log)
+	err := ing.indexAdMultihashes(ctx, ad, chunk.Entries, log)
 	if err != nil {
 		return fmt.Errorf("failed processing entries for advertisement: %w", err)
 	}
@@ -552,7 +583,10 @@ func (ing *Ingester) ingestEntryChunk(ctx context.Context, ad schema.Advertiseme
 
 // indexAdMultihashes indexes filters out invalid multihashed and indexes those
 // remaining in the indexer core.
-func (ing *Ingester) indexAdMultihashes(ad schema.Advertisement, mhs []multihash.Multihash, log *zap.SugaredLogger) error {
+func (ing *Ingester) indexAdMultihashes(ctx context.Context, ad schema.Advertisement, mhs []multihash.Multihash, log *zap.SugaredLogger) error {
+	_, span := tracer.Start(ctx, "Ingester.indexAdMultihashes", trace.WithAttributes(attribute.Int("multihashes", len(mhs))))
+	defer span.End()
+
 	// Build indexer.Value from ad data.
 	providerID, err := peer.Decode(ad.Provider)
 	if err != nil {
@@ -591,6 +625,16 @@ func (ing *Ingester) indexAdMultihashes(ad schema.Advertisement, mhs []multihash
 	}
 	if badMultihashCount != 0 {
 		log.Warnw("Ignored bad multihashes", "ignored", badMultihashCount)
+		span.SetAttributes(attribute.Int("ignored", badMultihashCount))
+	}
+	if ing.denylist != nil && ing.denylist.SkipIngest() {
+		var denied int
+		mhs, denied = ing.denylist.Filter(mhs)
+		if denied != 0 {
+			log.Infow("Skipped denylisted multihashes", "skipped", denied)
+			span.SetAttributes(attribute.Int("denied", denied))
+			metrics.RecordDenied("ingest", denied)
+		}
 	}
 	if len(mhs) == 0 {
 		return nil
@@ -603,8 +647,15 @@ func (ing *Ingester) indexAdMultihashes(ad schema.Advertisement, mhs []multihash
 	}
 
 	if err = ing.indexer.Put(value, mhs...); err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return fmt.Errorf("cannot put multihashes into indexer: %w", err)
 	}
+	if ing.dhStore != nil {
+		if err = ing.putDoubleHashed(value, mhs); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("cannot put multihashes into double-hashed index: %w", err)
+		}
+	}
 	log.Infow("Put multihashes in entry chunk", "count", len(mhs))
 
 	return nil
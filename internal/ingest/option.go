@@ -4,13 +4,17 @@ import (
 	"fmt"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/ipni/dhstore"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
 )
 
 // configIngest contains all options for the ingester.
 type configIngest struct {
-	idxCounts *counter.IndexCounts
+	denylist  *denylist.Denylist
+	dhStore   dhstore.DHStore
 	dsAds     datastore.Batching
+	idxCounts *counter.IndexCounts
 }
 
 // Option is a function that sets a value in a config.
@@ -42,3 +46,22 @@ func WithIndexCounts(ic *counter.IndexCounts) Option {
 		return nil
 	}
 }
+
+// WithDHStore configures a local double-hashed index that is populated with
+// encrypted value keys and metadata when multihashes are indexed. This allows
+// the indexer to serve reader-privacy lookups itself.
+func WithDHStore(dhs dhstore.DHStore) Option {
+	return func(c *configIngest) error {
+		c.dhStore = dhs
+		return nil
+	}
+}
+
+// WithDenylist configures the denylist that is used to skip indexing denied
+// multihashes, when the denylist is configured to skip them at ingest.
+func WithDenylist(dl *denylist.Denylist) Option {
+	return func(c *configIngest) error {
+		c.denylist = dl
+		return nil
+	}
+}
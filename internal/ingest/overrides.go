@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipni/storetheindex/config"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+// publisherOverride holds the ingest settings that are configured for a
+// specific publisher. Zero values mean that the ingester's setting is used.
+type publisherOverride struct {
+	adDepthLimit  int
+	entriesSel    datamodel.Node
+	segDepthLimit int64
+	syncTimeout   time.Duration
+	// rateSet is true if the rate limit is overridden, in which case a zero
+	// rateLimit means that the publisher is not rate limited.
+	rateSet   bool
+	rateLimit rate.Limit
+	rateBurst int
+}
+
+// ingestLimits are the limits and timeouts that apply to ingesting from a
+// publisher.
+type ingestLimits struct {
+	adDepthLimit  int
+	entriesSel    datamodel.Node
+	segDepthLimit int64
+	syncTimeout   time.Duration
+}
+
+// SetPublisherOverrides replaces the ingest settings that are configured for
+// specific publishers. If there is an error in any override, then none are
+// changed.
+func (ing *Ingester) SetPublisherOverrides(cfgOverrides []config.PublisherOverride) error {
+	overrides, err := configPublisherOverrides(cfgOverrides)
+	if err != nil {
+		return err
+	}
+
+	ing.overridesMutex.Lock()
+	ing.overrides = overrides
+	ing.overridesMutex.Unlock()
+	return nil
+}
+
+func configPublisherOverrides(cfgOverrides []config.PublisherOverride) (map[peer.ID]publisherOverride, error) {
+	if len(cfgOverrides) == 0 {
+		return nil, nil
+	}
+	overrides := make(map[peer.ID]publisherOverride, len(cfgOverrides))
+	for _, cfgOvr := range cfgOverrides {
+		pubID, err := peer.Decode(cfgOvr.PublisherID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode publisher id %q in override: %w", cfgOvr.PublisherID, err)
+		}
+		if cfgOvr.SyncTimeout < 0 {
+			return nil, errors.New("SyncTimeout override must be greater than or equal to 0")
+		}
+		if cfgOvr.BurstSize < 0 {
+			return nil, errors.New("BurstSize override must be greater than or equal to 0")
+		}
+		ovr := publisherOverride{
+			adDepthLimit:  cfgOvr.AdvertisementDepthLimit,
+			segDepthLimit: int64(cfgOvr.SyncSegmentDepthLimit),
+			syncTimeout:   time.Duration(cfgOvr.SyncTimeout),
+		}
+		if cfgOvr.EntriesDepthLimit != 0 {
+			ovr.entriesSel = Selectors.EntriesWithLimit(recursionLimit(cfgOvr.EntriesDepthLimit))
+		}
+		if cfgOvr.BlocksPerSecond != 0 {
+			ovr.rateSet = true
+			if cfgOvr.BlocksPerSecond > 0 {
+				ovr.rateLimit = rate.Limit(cfgOvr.BlocksPerSecond)
+				ovr.rateBurst = cfgOvr.BurstSize
+				if ovr.rateBurst == 0 {
+					ovr.rateBurst = 5 * cfgOvr.BlocksPerSecond
+				}
+			}
+		}
+		overrides[pubID] = ovr
+	}
+	return overrides, nil
+}
+
+func (ing *Ingester) getOverride(publisher peer.ID) (publisherOverride, bool) {
+	ing.overridesMutex.RLock()
+	defer ing.overridesMutex.RUnlock()
+	ovr, ok := ing.overrides[publisher]
+	return ovr, ok
+}
+
+// limitsFor returns the ingest limits for the publisher, which are the
+// ingester's settings with any overrides for the publisher applied.
+func (ing *Ingester) limitsFor(publisher peer.ID) ingestLimits {
+	limits := ingestLimits{
+		adDepthLimit:  ing.adDepthLimit,
+		entriesSel:    ing.entriesSel,
+		segDepthLimit: ing.segDepthLimit,
+		syncTimeout:   ing.syncTimeout,
+	}
+	ovr, ok := ing.getOverride(publisher)
+	if !ok {
+		return limits
+	}
+	if ovr.adDepthLimit != 0 {
+		limits.adDepthLimit = ovr.adDepthLimit
+	}
+	if ovr.entriesSel != nil {
+		limits.entriesSel = ovr.entriesSel
+	}
+	if ovr.segDepthLimit != 0 {
+		limits.segDepthLimit = ovr.segDepthLimit
+	}
+	if ovr.syncTimeout != 0 {
+		limits.syncTimeout = ovr.syncTimeout
+	}
+	return limits
+}
+
+// syncLimits returns the advertisement recursion limit and the segment depth
+// limit that dagsync uses when syncing from the publisher.
+func (ing *Ingester) syncLimits(publisher peer.ID) (selector.RecursionLimit, int64) {
+	limits := ing.limitsFor(publisher)
+	return recursionLimit(limits.adDepthLimit), limits.segDepthLimit
+}
@@ -0,0 +1,97 @@
+package ingest
+
+import (
+	"errors"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/dagsync"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// ErrPublisherPaused is returned when syncing with a publisher that has
+// ingestion paused.
+var ErrPublisherPaused = errors.New("publisher ingestion paused")
+
+// PausePublisher pauses ingestion from a publisher. While paused, announces
+// from the publisher are recorded but not synced, and the publisher is not
+// auto-synced. Advertisements that were already synced are still ingested,
+// and the publisher's existing index continues to be served. The paused state
+// is kept when the indexer restarts.
+func (ing *Ingester) PausePublisher(publisherID peer.ID) error {
+	paused, err := ing.reg.PausePublisher(publisherID)
+	if err != nil {
+		return err
+	}
+	if paused {
+		log.Infow("Paused ingestion from publisher", "publisher", publisherID)
+	}
+	return nil
+}
+
+// ResumePublisher resumes ingestion from a paused publisher. If any announce
+// was received while the publisher was paused, then the publisher is synced,
+// in the background, from the latest announced advertisement.
+func (ing *Ingester) ResumePublisher(publisherID peer.ID) error {
+	pa, ok, err := ing.reg.ResumePublisher(publisherID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	log.Infow("Resumed ingestion from publisher", "publisher", publisherID, "announced", pa.Cid)
+	if pa.Cid == cid.Undef {
+		return nil
+	}
+
+	ing.waitForPendingSyncs.Add(1)
+	go func() {
+		defer ing.waitForPendingSyncs.Done()
+		log := log.With("publisher", publisherID, "cid", pa.Cid)
+		_, err := ing.sub.Sync(ing.workersCtx, publisherID, pa.Cid, nil, announceAddr(pa.Addrs), dagsync.AlwaysUpdateLatest())
+		if err != nil {
+			log.Errorw("Failed to sync resumed publisher", "err", err)
+			metrics.RecordSyncError(publisherID, adChainSyncErr)
+			return
+		}
+		log.Info("Synced advertisement announced while publisher was paused")
+	}()
+	return nil
+}
+
+// PublisherPaused returns true if ingestion from the publisher is paused.
+func (ing *Ingester) PublisherPaused(publisherID peer.ID) bool {
+	return ing.reg.PublisherPaused(publisherID)
+}
+
+// announceHook records announces from paused publishers, instead of letting
+// dagsync sync the announced advertisements.
+func (ing *Ingester) announceHook(publisherID peer.ID, adCid cid.Cid, addrs []multiaddr.Multiaddr) bool {
+	paused, err := ing.reg.RecordPausedAnnounce(publisherID, adCid, addrs)
+	if err != nil {
+		log.Errorw("Cannot record announce from paused publisher", "err", err, "publisher", publisherID)
+	}
+	if paused {
+		log.Infow("Recorded announce from paused publisher", "publisher", publisherID, "cid", adCid)
+		return false
+	}
+	return true
+}
+
+// announceAddr returns the address to sync with from the addresses in an
+// announce, preferring an HTTP address as dagsync does.
+func announceAddr(addrs []multiaddr.Multiaddr) multiaddr.Multiaddr {
+	for _, addr := range addrs {
+		for _, p := range addr.Protocols() {
+			if p.Code == multiaddr.P_HTTP || p.Code == multiaddr.P_HTTPS {
+				return addr
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0]
+}
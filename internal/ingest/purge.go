@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-varint"
+	"go.opencensus.io/stats"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// purgePrefix identifies provider contexts that are waiting to be purged
+	// from the value store.
+	purgePrefix = "/purge/"
+	// purgeQueryLimit is the maximum number of pending purges read from the
+	// datastore at one time.
+	purgeQueryLimit = 1024
+	// purgeRetryInterval is the time to wait before retrying purges after an
+	// error.
+	purgeRetryInterval = time.Minute
+)
+
+// schedulePurge records the context IDs of a removed provider, so that the
+// values for those contexts are deleted from the value store by the
+// background purge process. This must be called before the provider's index
+// counts are removed, since those are the source of the context IDs.
+//
+// Pending purges are persisted, so that purging resumes where it left off
+// after the indexer is restarted.
+func (ing *Ingester) schedulePurge(ctx context.Context, providerID peer.ID) error {
+	ctxCounts, err := ing.indexCounts.ProviderContexts(providerID)
+	if err != nil {
+		return err
+	}
+	if len(ctxCounts) == 0 {
+		return nil
+	}
+
+	batch, err := ing.ds.Batch(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot create datastore batch: %w", err)
+	}
+	for _, cc := range ctxCounts {
+		err = batch.Put(ctx, makePurgeKey(providerID, cc.ContextID), varint.ToUvarint(cc.Count))
+		if err != nil {
+			return fmt.Errorf("cannot write pending purge: %w", err)
+		}
+	}
+	if err = batch.Commit(ctx); err != nil {
+		return fmt.Errorf("cannot commit pending purges: %w", err)
+	}
+	log.Infow("Scheduled purge of removed provider", "provider", providerID, "contexts", len(ctxCounts))
+
+	// Wake up the purge process if it is waiting.
+	select {
+	case ing.purgeSignal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// runPurge runs the background purge process. It deletes the values of all
+// pending provider contexts from the value store, at a limited rate, and then
+// waits for more to be scheduled.
+//
+// Only the values are deleted. The value store does not index multihashes by
+// value, so the multihash mappings to a deleted value are removed when the
+// multihash is looked up. The advertisement chain cannot be walked to find the
+// multihashes, because a removed provider's publisher is usually no longer
+// reachable and its advertisement entries are not kept after ingestion.
+func (ing *Ingester) runPurge(ctx context.Context, rateLimit int) {
+	defer close(ing.purgeDone)
+
+	limiter := rate.NewLimiter(rate.Limit(rateLimit), 1)
+	for {
+		var retry <-chan time.Time
+		if err := ing.purgeRemoved(ctx, limiter); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorw("Cannot purge removed provider contexts", "err", err)
+			retry = time.After(purgeRetryInterval)
+		}
+		select {
+		case <-ing.purgeSignal:
+		case <-retry:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// purgeRemoved purges all pending provider contexts.
+func (ing *Ingester) purgeRemoved(ctx context.Context, limiter *rate.Limiter) error {
+	for {
+		// Read a limited number of pending purges, so that the query is not
+		// held open while the purges are slowly processed.
+		q := query.Query{
+			Prefix: purgePrefix,
+			Limit:  purgeQueryLimit,
+		}
+		results, err := ing.ds.Query(ctx, q)
+		if err != nil {
+			return fmt.Errorf("cannot query pending purges: %w", err)
+		}
+		ents, err := results.Rest()
+		if err != nil {
+			return fmt.Errorf("cannot read pending purges: %w", err)
+		}
+		if len(ents) == 0 {
+			return nil
+		}
+
+		for _, ent := range ents {
+			if err = ing.purgeContext(ctx, limiter, ent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (ing *Ingester) purgeContext(ctx context.Context, limiter *rate.Limiter, ent query.Entry) error {
+	key := datastore.NewKey(ent.Key)
+	providerID, contextID, err := parsePurgeKey(ent.Key)
+	if err != nil {
+		log.Errorw("Discarding bad pending purge", "err", err, "key", ent.Key)
+		return ing.ds.Delete(ctx, key)
+	}
+
+	// If the provider has been registered again since it was removed, then
+	// any values for its contexts may have been re-ingested.
+	if pinfo, _ := ing.reg.ProviderInfo(providerID); pinfo != nil {
+		log.Infow("Not purging context for provider that is registered", "provider", providerID)
+		return ing.ds.Delete(ctx, key)
+	}
+
+	if err = limiter.Wait(ctx); err != nil {
+		return err
+	}
+	if err = ing.purgeValues(ctx, key, providerID, contextID); err != nil {
+		return err
+	}
+
+	count, _, err := varint.FromUvarint(ent.Value)
+	if err != nil {
+		log.Errorw("Cannot decode pending purge index count", "err", err)
+	}
+	stats.Record(context.Background(),
+		metrics.PurgedContextCount.M(1),
+		metrics.PurgedValueIndexes.M(int64(count)))
+	log.Debugw("Purged removed provider context", "provider", providerID, "indexes", count)
+	return nil
+}
+
+// purgeValues removes the values of a provider context from the value store,
+// and removes the pending purge. This is not done while ingestion is paused.
+func (ing *Ingester) purgeValues(ctx context.Context, key datastore.Key, providerID peer.ID, contextID []byte) error {
+	ing.pauseMutex.RLock()
+	defer ing.pauseMutex.RUnlock()
+
+	if err := ing.indexer.RemoveProviderContext(providerID, contextID); err != nil {
+		return fmt.Errorf("cannot remove provider context from value store: %w", err)
+	}
+	if err := ing.ds.Delete(ctx, key); err != nil {
+		return fmt.Errorf("cannot delete pending purge: %w", err)
+	}
+	return nil
+}
+
+func makePurgeKey(providerID peer.ID, contextID []byte) datastore.Key {
+	// Use URL encoding so that the encoded context ID does not contain any
+	// "/" characters.
+	return datastore.NewKey(purgePrefix + providerID.String() + "/" + base64.RawURLEncoding.EncodeToString(contextID))
+}
+
+func parsePurgeKey(key string) (peer.ID, []byte, error) {
+	provStr, ctxStr, ok := strings.Cut(strings.TrimPrefix(key, purgePrefix), "/")
+	if !ok {
+		return "", nil, errors.New("bad purge key")
+	}
+	providerID, err := peer.Decode(provStr)
+	if err != nil {
+		return "", nil, fmt.Errorf("bad provider id in purge key: %w", err)
+	}
+	contextID, err := base64.RawURLEncoding.DecodeString(ctxStr)
+	if err != nil {
+		return "", nil, fmt.Errorf("bad context id in purge key: %w", err)
+	}
+	return providerID, contextID, nil
+}
@@ -0,0 +1,360 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipni/storetheindex/api/v0/ingest/schema"
+	"github.com/ipni/storetheindex/dagsync"
+	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// errEntriesNotFound is returned when a publisher no longer has an
+// advertisement's entries.
+var errEntriesNotFound = errors.New("entries not found")
+
+// RecountProvider recomputes the index counts for a provider by walking the
+// advertisement chain, from the latest advertisement ingested from the
+// provider's publisher, and counting the multihashes in the entries of each
+// of the provider's advertisements. Entries of advertisements whose context ID
+// is removed by a later advertisement are not counted. The provider's index
+// counts are then replaced with the recomputed counts.
+//
+// Advertisements and entries are synced from the publisher, since they are not
+// kept after they are ingested. Only advertisements that are already ingested
+// are counted, since the others add to the index counts when they are
+// ingested. Returns the provider's previous and new total index counts.
+func (ing *Ingester) RecountProvider(ctx context.Context, providerID peer.ID) (uint64, uint64, error) {
+	if ing.indexCounts == nil {
+		return 0, 0, errors.New("index counts not enabled")
+	}
+	pinfo, _ := ing.reg.ProviderInfo(providerID)
+	if pinfo == nil {
+		return 0, 0, errors.New("provider not registered")
+	}
+	publisher := pinfo.Publisher
+	if publisher.Validate() != nil {
+		return 0, 0, errors.New("provider has no publisher")
+	}
+	log := log.With("provider", providerID, "publisher", publisher)
+
+	head, err := ing.GetLatestSync(publisher)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot get latest sync: %w", err)
+	}
+
+	// Count without holding the provider's ingest slot, since syncing the
+	// advertisement chain and entries can take a long time, and ingestion from
+	// the provider would be blocked until done.
+	var counted *adChainCount
+	if head != cid.Undef {
+		log.Infow("Recounting provider indexes", "head", head)
+		counted, err = ing.countAdChain(ctx, providerID, publisher, pinfo.PublisherAddr, head, cid.Undef, pinfo.FrozenAt)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// Hold the slot while counting the advertisements that were ingested since
+	// counting, and while replacing the counts, so that the counts do not
+	// change until they are replaced.
+	ing.providersBeingProcessedMu.Lock()
+	pc, ok := ing.providersBeingProcessed[providerID]
+	if !ok {
+		pc = make(chan struct{}, 1)
+		ing.providersBeingProcessed[providerID] = pc
+	}
+	ing.providersBeingProcessedMu.Unlock()
+	select {
+	case pc <- struct{}{}:
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+	defer func() {
+		<-pc
+	}()
+
+	head, err = ing.GetLatestSync(publisher)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot get latest sync: %w", err)
+	}
+	var stopAt cid.Cid
+	if counted != nil {
+		stopAt = counted.newest
+	}
+	if head != cid.Undef && head != stopAt {
+		newer, err := ing.countAdChain(ctx, providerID, publisher, pinfo.PublisherAddr, head, stopAt, pinfo.FrozenAt)
+		if err != nil {
+			return 0, 0, err
+		}
+		if counted != nil {
+			newer.addOlder(counted)
+		}
+		counted = newer
+	}
+
+	var ctxCounts []counter.ContextCount
+	if counted != nil {
+		ctxCounts = counted.contextCounts()
+	}
+
+	var total uint64
+	for _, cc := range ctxCounts {
+		total += cc.Count
+	}
+	// Do not change the counts while ingestion is paused.
+	ing.pauseMutex.RLock()
+	prevTotal, err := ing.indexCounts.ReplaceProvider(providerID, ctxCounts)
+	ing.pauseMutex.RUnlock()
+	if err != nil {
+		return 0, 0, err
+	}
+	log.Infow("Recounted provider indexes", "previous", prevTotal, "count", total, "contexts", len(ctxCounts))
+	return prevTotal, total, nil
+}
+
+// adChainCount is the index count for each of a provider's context IDs, from
+// part of an advertisement chain.
+type adChainCount struct {
+	counts map[string]uint64
+	// removed are the context IDs removed in this part of the chain.
+	removed map[string]struct{}
+	// newest is the newest counted advertisement.
+	newest cid.Cid
+}
+
+// addOlder adds the counts from an older part of the advertisement chain,
+// except for contexts that are removed in this part of the chain.
+func (c *adChainCount) addOlder(older *adChainCount) {
+	for ctxID, count := range older.counts {
+		if _, ok := c.removed[ctxID]; !ok {
+			c.counts[ctxID] += count
+		}
+	}
+	for ctxID := range older.removed {
+		c.removed[ctxID] = struct{}{}
+	}
+	if c.newest == cid.Undef {
+		c.newest = older.newest
+	}
+}
+
+func (c *adChainCount) contextCounts() []counter.ContextCount {
+	ctxCounts := make([]counter.ContextCount, 0, len(c.counts))
+	for ctxID, count := range c.counts {
+		ctxCounts = append(ctxCounts, counter.ContextCount{
+			ContextID: []byte(ctxID),
+			Count:     count,
+		})
+	}
+	return ctxCounts
+}
+
+// countAdChain counts the indexes of a provider's ingested advertisements in
+// the advertisement chain from head to stopAt, not including stopAt.
+// Advertisements that are not yet ingested are not counted.
+func (ing *Ingester) countAdChain(ctx context.Context, providerID, publisher peer.ID, pubAddr multiaddr.Multiaddr, head, stopAt, frozenAt cid.Cid) (*adChainCount, error) {
+	var stopLink ipld.Link
+	if stopAt != cid.Undef {
+		stopLink = cidlink.Link{Cid: stopAt}
+	}
+	adDepthLimit := ing.limitsFor(publisher).adDepthLimit
+	sel := dagsync.ExploreRecursiveWithStopNode(recursionLimit(adDepthLimit), Selectors.AdSequence, stopLink)
+	_, err := ing.sub.Sync(ctx, publisher, head, sel, pubAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sync advertisement chain: %w", err)
+	}
+
+	var adCids []cid.Cid
+	if !ing.keepAdData {
+		// Ads are not kept after they are processed, so remove the processed
+		// ones synced for recounting. Ads that are not yet processed are left
+		// for ingestion.
+		defer func() {
+			for _, adCid := range adCids {
+				if processed, _ := ing.adAlreadyProcessed(adCid); !processed {
+					continue
+				}
+				if err := ing.dsAds.Delete(context.Background(), datastore.NewKey(adCid.String())); err != nil {
+					log.Errorw("Cannot delete advertisement from datastore", "err", err)
+				}
+			}
+		}()
+	}
+
+	// Walk the chain from newest to oldest, as the worker does, so that ads
+	// whose contexts are removed later in the chain are not counted.
+	provStr := providerID.String()
+	chainCount := &adChainCount{
+		counts:  make(map[string]uint64),
+		removed: make(map[string]struct{}),
+	}
+	// If the indexer was frozen, then ads after the frozen-at ad did not have
+	// their entries indexed.
+	indexed := frozenAt == cid.Undef
+	for adCid := head; adCid != cid.Undef && adCid != stopAt; {
+		if adDepthLimit > 0 && len(adCids) == adDepthLimit {
+			log.Warnw("Advertisement chain exceeds depth limit, not all advertisements recounted", "publisher", publisher)
+			break
+		}
+		ad, err := ing.loadAd(adCid)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				// The sync stopped at the depth limit.
+				break
+			}
+			return nil, fmt.Errorf("cannot load advertisement %s: %w", adCid, err)
+		}
+		adCids = append(adCids, adCid)
+		if adCid == frozenAt {
+			indexed = true
+		}
+
+		curCid := adCid
+		adCid = cid.Undef
+		if ad.PreviousID != nil {
+			adCid = ad.PreviousID.(cidlink.Link).Cid
+		}
+
+		if ad.Provider != provStr {
+			continue
+		}
+		// The provider's ads are ingested in order, so all ads older than
+		// the newest ingested ad are also ingested.
+		if chainCount.newest == cid.Undef {
+			if processed, _ := ing.adAlreadyProcessed(curCid); !processed {
+				continue
+			}
+			chainCount.newest = curCid
+		}
+		ctxIdStr := string(ad.ContextID)
+		if _, ok := chainCount.removed[ctxIdStr]; ok {
+			continue
+		}
+		if ad.IsRm {
+			chainCount.removed[ctxIdStr] = struct{}{}
+			continue
+		}
+		if !indexed || len(ad.Metadata) == 0 || ad.Entries == nil || ad.Entries == schema.NoEntries {
+			continue
+		}
+
+		n, err := ing.countEntries(ctx, publisher, pubAddr, ad.Entries.(cidlink.Link).Cid)
+		if err != nil {
+			if errors.Is(err, errEntriesNotFound) {
+				// Ads with missing entries were skipped during ingestion.
+				log.Warnw("Cannot recount advertisement entries", "adCid", curCid, "err", err)
+				continue
+			}
+			return nil, fmt.Errorf("cannot count entries for advertisement %s: %w", curCid, err)
+		}
+		chainCount.counts[ctxIdStr] += n
+	}
+	return chainCount, nil
+}
+
+// countEntries syncs an advertisement's entries and counts the multihashes in
+// them, without indexing them.
+func (ing *Ingester) countEntries(ctx context.Context, publisher peer.ID, pubAddr multiaddr.Multiaddr, entriesCid cid.Cid) (uint64, error) {
+	limits := ing.limitsFor(publisher)
+	if limits.syncTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.syncTimeout)
+		defer cancel()
+	}
+
+	entCids := []cid.Cid{entriesCid}
+	if !ing.keepAdData {
+		defer func() {
+			for _, c := range entCids {
+				if err := ing.dsAds.Delete(context.Background(), datastore.NewKey(c.String())); err != nil {
+					log.Errorw("Cannot delete entries from datastore", "err", err)
+				}
+			}
+		}()
+	}
+
+	_, err := ing.sub.Sync(ctx, publisher, entriesCid, Selectors.One, pubAddr)
+	if err != nil {
+		return 0, entriesSyncError(err)
+	}
+	node, err := ing.loadNode(entriesCid, basicnode.Prototype.Any)
+	if err != nil {
+		return 0, fmt.Errorf("cannot load first entry: %w", err)
+	}
+
+	var count uint64
+	if isHAMT(node) {
+		hn, err := ing.loadHamt(entriesCid)
+		if err != nil {
+			return 0, fmt.Errorf("cannot load entries as HAMT root node: %w", err)
+		}
+		for _, e := range hn.Hamt.Data {
+			if e.HashMapNode == nil {
+				continue
+			}
+			nodeCid := (*e.HashMapNode).(cidlink.Link).Cid
+			_, err = ing.sub.Sync(ctx, publisher, nodeCid, Selectors.All, pubAddr,
+				dagsync.ScopedBlockHook(func(_ peer.ID, c cid.Cid, _ dagsync.SegmentSyncActions) {
+					entCids = append(entCids, c)
+				}),
+				dagsync.ScopedSegmentDepthLimit(-1))
+			if err != nil {
+				return 0, entriesSyncError(err)
+			}
+		}
+		mi := hn.MapIterator()
+		for !mi.Done() {
+			if _, _, err = mi.Next(); err != nil {
+				return 0, fmt.Errorf("cannot iterate through HAMT: %w", err)
+			}
+			count++
+		}
+		return count, nil
+	}
+
+	chunk, err := ing.loadEntryChunk(entriesCid)
+	if err != nil {
+		return 0, err
+	}
+	count = uint64(len(chunk.Entries))
+	if chunk.Next == nil {
+		return count, nil
+	}
+
+	_, err = ing.sub.Sync(ctx, publisher, chunk.Next.(cidlink.Link).Cid, limits.entriesSel, pubAddr,
+		dagsync.ScopedBlockHook(func(_ peer.ID, c cid.Cid, actions dagsync.SegmentSyncActions) {
+			entCids = append(entCids, c)
+			chunk, err := ing.loadEntryChunk(c)
+			if err != nil {
+				actions.FailSync(err)
+				return
+			}
+			count += uint64(len(chunk.Entries))
+			if chunk.Next != nil {
+				actions.SetNextSyncCid(chunk.Next.(cidlink.Link).Cid)
+			} else {
+				actions.SetNextSyncCid(cid.Undef)
+			}
+		}))
+	if err != nil {
+		return 0, entriesSyncError(err)
+	}
+	return count, nil
+}
+
+func entriesSyncError(err error) error {
+	if strings.Contains(err.Error(), "content not found") {
+		return fmt.Errorf("%w: %s", errEntriesNotFound, err)
+	}
+	return fmt.Errorf("cannot sync entries: %w", err)
+}
@@ -0,0 +1,263 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/dagsync"
+	"github.com/ipni/storetheindex/dagsync/httpsync"
+	"github.com/ipni/storetheindex/dagsync/httpsync/maconv"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/ipni/storetheindex/mautil"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// replicationHeadsPath is the path, on the replication publisher, under which
+// the latest processed advertisement of each publisher is served. The rest of
+// the paths serve advertisement and entries blocks by CID.
+const replicationHeadsPath = "/heads/"
+
+// startReplication starts the replication publisher, if configured, and
+// configures following another indexer's replication publisher.
+func (ing *Ingester) startReplication(cfg config.Replication) error {
+	if cfg.ListenMultiaddr != "" {
+		if err := ing.startReplicationPublisher(cfg.ListenMultiaddr); err != nil {
+			return fmt.Errorf("cannot start replication publisher: %w", err)
+		}
+	}
+
+	if len(cfg.FollowPublishers) == 0 {
+		return nil
+	}
+	if cfg.FollowIndexer == "" {
+		return errors.New("replication follow publishers configured without indexer to follow")
+	}
+	followAddr, err := multiaddr.NewMultiaddr(cfg.FollowIndexer)
+	if err != nil {
+		return fmt.Errorf("bad replication follow indexer address: %w", err)
+	}
+	followURL, err := maconv.ToURL(followAddr)
+	if err != nil {
+		return fmt.Errorf("replication follow indexer address must be an http address: %w", err)
+	}
+
+	followPubs := make(map[peer.ID]struct{}, len(cfg.FollowPublishers))
+	for _, pubStr := range cfg.FollowPublishers {
+		pubID, err := peer.Decode(pubStr)
+		if err != nil {
+			return fmt.Errorf("bad replication follow publisher id %s: %w", pubStr, err)
+		}
+		followPubs[pubID] = struct{}{}
+		// Always fetch the publisher's advertisements and entries from the
+		// followed indexer, including when syncing entries without an
+		// explicit address.
+		ing.sub.HttpPeerStore().AddAddr(pubID, followAddr, peerstore.PermanentAddrTTL)
+	}
+
+	ing.followAddr = followAddr
+	ing.followURL = followURL
+	ing.followPubs = followPubs
+	ing.followClient = &http.Client{
+		Timeout: ing.httpSyncTimeout,
+	}
+
+	var followCtx context.Context
+	followCtx, ing.cancelFollow = context.WithCancel(context.Background())
+	ing.followDone = make(chan struct{})
+	go ing.runFollow(followCtx, time.Duration(cfg.FollowInterval))
+
+	log.Infow("Replicating publishers from indexer", "indexer", followAddr, "publishers", len(followPubs))
+	return nil
+}
+
+func (ing *Ingester) startReplicationPublisher(listenAddr string) error {
+	netAddr, err := mautil.MultiaddrStringToNetAddr(listenAddr)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen(netAddr.Network(), netAddr.String())
+	if err != nil {
+		return err
+	}
+	maddr, err := manet.FromNetAddr(l.Addr())
+	if err != nil {
+		l.Close()
+		return err
+	}
+	httpProto, _ := multiaddr.NewMultiaddr("/http")
+	maddr = multiaddr.Join(maddr, httpProto)
+
+	privKey := ing.host.Peerstore().PrivKey(ing.host.ID())
+	pub, err := httpsync.NewPublisherWithoutServer(maddr, ing.lsys, ing.host.ID(), privKey)
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(replicationHeadsPath, ing.serveReplicationHead)
+	mux.Handle("/", pub)
+
+	ing.replAddr = maddr
+	ing.replServer = &http.Server{
+		Handler: mux,
+	}
+	go ing.replServer.Serve(l)
+
+	log.Infow("Replication publisher listening", "addr", maddr)
+	return nil
+}
+
+// ReplicationAddr returns the address of the replication publisher, or nil if
+// the replication publisher is not enabled.
+func (ing *Ingester) ReplicationAddr() multiaddr.Multiaddr {
+	return ing.replAddr
+}
+
+// serveReplicationHead serves the CID of the latest advertisement processed
+// from a publisher. This is the head of the publisher's advertisement chain
+// that is available from this indexer.
+func (ing *Ingester) serveReplicationHead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	pubID, err := peer.Decode(path.Base(r.URL.Path))
+	if err != nil {
+		http.Error(w, "invalid publisher id", http.StatusBadRequest)
+		return
+	}
+	head, err := ing.GetLatestSync(pubID)
+	if err != nil {
+		log.Errorw("Cannot get latest sync for replication", "err", err, "publisher", pubID)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if head == cid.Undef {
+		http.Error(w, "publisher not found", http.StatusNotFound)
+		return
+	}
+	_, _ = io.WriteString(w, head.String())
+}
+
+// following returns true if the publisher's advertisements are replicated
+// from another indexer.
+func (ing *Ingester) following(publisher peer.ID) bool {
+	_, ok := ing.followPubs[publisher]
+	return ok
+}
+
+// followHead gets the latest advertisement from the publisher that is
+// available from the followed indexer. cid.Undef is returned if the followed
+// indexer has nothing from the publisher.
+func (ing *Ingester) followHead(ctx context.Context, publisher peer.ID) (cid.Cid, error) {
+	return fetchReplicationHead(ctx, ing.followClient, ing.followURL, publisher)
+}
+
+// fetchReplicationHead gets the publisher's head from the replication
+// publisher at the given URL.
+func fetchReplicationHead(ctx context.Context, client *http.Client, replURL *url.URL, publisher peer.ID) (cid.Cid, error) {
+	headURL := replURL.JoinPath(replicationHeadsPath, publisher.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, headURL.String(), nil)
+	if err != nil {
+		return cid.Undef, err
+	}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return cid.Undef, err
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return cid.Undef, nil
+	default:
+		return cid.Undef, fmt.Errorf("%d %s: %s", rsp.StatusCode, http.StatusText(rsp.StatusCode), strings.TrimSpace(string(body)))
+	}
+	head, err := cid.Decode(strings.TrimSpace(string(body)))
+	if err != nil {
+		return cid.Undef, fmt.Errorf("bad head from followed indexer: %w", err)
+	}
+	return head, nil
+}
+
+// syncFollowed syncs the publisher's advertisement chain from the followed
+// indexer, up to the latest advertisement available from that indexer. The
+// synced advertisements are ingested as if they were synced from the
+// publisher. Returns the synced head, or cid.Undef if there was nothing new to
+// sync.
+func (ing *Ingester) syncFollowed(ctx context.Context, publisher peer.ID) (cid.Cid, error) {
+	head, err := ing.followHead(ctx, publisher)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot get head from followed indexer: %w", err)
+	}
+	if head == cid.Undef {
+		return cid.Undef, nil
+	}
+	latest, err := ing.GetLatestSync(publisher)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to get latest sync: %w", err)
+	}
+	if head == latest {
+		return cid.Undef, nil
+	}
+	// Update the latest sync so that the synced advertisements are ingested,
+	// since the head is given explicitly.
+	return ing.sub.Sync(ctx, publisher, head, nil, ing.followAddr, dagsync.AlwaysUpdateLatest())
+}
+
+// runFollow periodically syncs the followed publishers from the followed
+// indexer.
+func (ing *Ingester) runFollow(ctx context.Context, interval time.Duration) {
+	defer close(ing.followDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if !ing.Draining() {
+			for pubID := range ing.followPubs {
+				if ing.reg.PublisherPaused(pubID) {
+					continue
+				}
+				head, err := ing.syncFollowed(ctx, pubID)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					log.Errorw("Failed to sync publisher from followed indexer", "err", err, "publisher", pubID)
+					metrics.RecordSyncError(pubID, adChainSyncErr)
+					continue
+				}
+				if head != cid.Undef {
+					log.Infow("Synced publisher from followed indexer", "publisher", pubID, "head", head)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
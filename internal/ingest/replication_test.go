@@ -0,0 +1,94 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/dagsync/httpsync/maconv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	cfg := defaultTestIngestConfig
+	cfg.Replication.ListenMultiaddr = "/ip4/127.0.0.1/tcp/0"
+	te := setupTestEnv(t, true, func(opts *testEnvOpts) {
+		opts.ingestConfig = &cfg
+	})
+	replAddr := te.ingester.ReplicationAddr()
+	require.NotNil(t, replAddr)
+	replURL, err := maconv.ToURL(replAddr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c1, mhs1, providerID, _ := publishRandomIndexAndAdv(t, te.publisher, te.publisherLinkSys, false, nil)
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, te.core, providerID, mhs1)
+
+	// Check that the replication publisher serves the publisher's head.
+	head, err := fetchReplicationHead(ctx, http.DefaultClient, replURL, te.pubHost.ID())
+	require.NoError(t, err)
+	require.Equal(t, c1, head)
+
+	// Create an indexer that replicates the publisher from the first indexer.
+	// It is not connected to the publisher.
+	followCfg := defaultTestIngestConfig
+	followCfg.Replication = config.Replication{
+		FollowIndexer:    replAddr.String(),
+		FollowPublishers: []string{te.pubHost.ID().String()},
+		FollowInterval:   config.Duration(100 * time.Millisecond),
+	}
+	followHost := mkTestHost()
+	follower, core, _, _ := mkIngestWithConfig(t, followHost, followCfg)
+	defer core.Close()
+	defer follower.Close()
+
+	requireIndexedEventually(t, core, providerID, mhs1)
+	requireTrueEventually(t, func() bool {
+		latest, err := follower.GetLatestSync(te.pubHost.ID())
+		require.NoError(t, err)
+		return latest == c1
+	}, testRetryInterval, testRetryTimeout, "Expected latest sync to be replicated head")
+
+	// Check that new advertisements are replicated after the first indexer
+	// ingests them.
+	c2, mhs2, providerID2, _ := publishRandomIndexAndAdv(t, te.publisher, te.publisherLinkSys, false, nil)
+	_, err = te.ingester.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	requireIndexedEventually(t, core, providerID2, mhs2)
+
+	// Check that an explicit sync of a followed publisher syncs from the
+	// followed indexer.
+	synced, err := follower.Sync(ctx, te.pubHost.ID(), nil, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, c2, synced)
+
+	// Check that announces from a followed publisher are not allowed.
+	require.False(t, follower.allowPeer(te.pubHost.ID()))
+	require.True(t, te.ingester.allowPeer(te.pubHost.ID()))
+}
+
+func TestReplicationHeadNotFound(t *testing.T) {
+	cfg := defaultTestIngestConfig
+	cfg.Replication.ListenMultiaddr = "/ip4/127.0.0.1/tcp/0"
+	te := setupTestEnv(t, false, func(opts *testEnvOpts) {
+		opts.ingestConfig = &cfg
+	})
+
+	replURL, err := maconv.ToURL(te.ingester.ReplicationAddr())
+	require.NoError(t, err)
+
+	head, err := fetchReplicationHead(context.Background(), http.DefaultClient, replURL, te.pubHost.ID())
+	require.NoError(t, err)
+	require.False(t, head.Defined())
+
+	rsp, err := http.Get(replURL.JoinPath(replicationHeadsPath, "not-a-peer-id").String())
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+}
@@ -0,0 +1,83 @@
+package ingest
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// adCountWindow is the longest time over which processed advertisements are
+// counted.
+const adCountWindow = 24 * time.Hour
+
+// Stats describes the state of the ingestion pipeline.
+type Stats struct {
+	// QueueDepth is the number of providers waiting for a worker to process
+	// their advertisements.
+	QueueDepth int
+	// Workers is the number of ingest workers.
+	Workers int
+	// ActiveWorkers is the number of workers currently processing
+	// advertisements.
+	ActiveWorkers int
+	// AdsProcessedLastHour is the number of advertisements successfully
+	// processed in the last hour.
+	AdsProcessedLastHour uint64
+	// AdsProcessedLastDay is the number of advertisements successfully
+	// processed in the last 24 hours.
+	AdsProcessedLastDay uint64
+}
+
+// Stats returns statistics about the ingestion pipeline.
+func (ing *Ingester) Stats() Stats {
+	now := time.Now()
+	return Stats{
+		QueueDepth:           ing.toWorkers.Length(),
+		Workers:              int(atomic.LoadInt32(&ing.workerCount)),
+		ActiveWorkers:        int(atomic.LoadInt32(&ing.activeWorkers)),
+		AdsProcessedLastHour: ing.processedAds.countSince(now, time.Hour),
+		AdsProcessedLastDay:  ing.processedAds.countSince(now, adCountWindow),
+	}
+}
+
+// adCounter counts events in one-minute buckets over the last adCountWindow.
+type adCounter struct {
+	mutex   sync.Mutex
+	counts  [adCountBuckets]uint64
+	minutes [adCountBuckets]int64
+}
+
+const adCountBuckets = int(adCountWindow / time.Minute)
+
+// add counts an event that happened at time t.
+func (c *adCounter) add(t time.Time) {
+	minute := t.Unix() / 60
+	i := int(minute % int64(adCountBuckets))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.minutes[i] != minute {
+		// Bucket holds counts from a previous window, so reuse it.
+		c.minutes[i] = minute
+		c.counts[i] = 0
+	}
+	c.counts[i]++
+}
+
+// countSince returns the number of events that happened within d before now.
+func (c *adCounter) countSince(now time.Time, d time.Duration) uint64 {
+	nowMinute := now.Unix() / 60
+	oldest := nowMinute - int64(d/time.Minute)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var total uint64
+	for i := range c.counts {
+		if c.minutes[i] > oldest && c.minutes[i] <= nowMinute {
+			total += c.counts[i]
+		}
+	}
+	return total
+}
@@ -24,10 +24,28 @@ const streamIdleTimeout = time.Minute
 var log = logging.Logger("indexer/libp2p")
 
 type Handler interface {
+	// HandleMessage handles a request and returns the response message. If the
+	// response message is nil, then no response is sent.
 	HandleMessage(ctx context.Context, msgPeer peer.ID, msgbytes []byte) (proto.Message, error)
 	ProtocolID() protocol.ID
 }
 
+// StreamHandler is implemented by a Handler that responds to some requests
+// with a stream of messages instead of a single message.
+type StreamHandler interface {
+	Handler
+	// StreamRequest returns a StreamFunc that sends the response messages for a
+	// request, if the request has a streamed response. Otherwise, it returns nil
+	// and the request is handled by HandleMessage.
+	StreamRequest(msgPeer peer.ID, msgbytes []byte) StreamFunc
+}
+
+// StreamFunc sends the messages of a streamed response by calling send for
+// each message. The context is canceled if the client cancels the request, by
+// sending any message while the response is streamed or by resetting the
+// stream. A StreamFunc returns an error only if sending a message fails.
+type StreamFunc func(ctx context.Context, send func(proto.Message) error) error
+
 // Server handles client requests over libp2p
 type Server struct {
 	ctx     context.Context
@@ -91,19 +109,49 @@ func (s *Server) handleNewMessages(stream network.Stream) bool {
 	timer := time.AfterFunc(streamIdleTimeout, func() { _ = stream.Reset() })
 	defer timer.Stop()
 
+	streamHandler, _ := handler.(StreamHandler)
+
+	// A read started while streaming a response, that has not completed.
+	var pending <-chan readResult
+
 	for {
-		msgbytes, err := r.ReadMsg()
+		var msgbytes []byte
+		var err error
+		if pending != nil {
+			res := <-pending
+			pending = nil
+			msgbytes, err = res.data, res.err
+		} else {
+			msgbytes, err = r.ReadMsg()
+		}
 		if err != nil {
 			r.ReleaseMsg(msgbytes)
 			return err == io.EOF
 		}
 		timer.Reset(streamIdleTimeout)
 
+		if streamHandler != nil {
+			if streamFunc := streamHandler.StreamRequest(mPeer, msgbytes); streamFunc != nil {
+				r.ReleaseMsg(msgbytes)
+				// Do not close an idle stream while streaming a response.
+				timer.Stop()
+				pending, err = sendStream(ctx, stream, r, streamFunc)
+				if err != nil {
+					return false
+				}
+				timer.Reset(streamIdleTimeout)
+				continue
+			}
+		}
+
 		resp, err := handler.HandleMessage(ctx, mPeer, msgbytes)
 		r.ReleaseMsg(msgbytes)
 		if err != nil {
 			return true
 		}
+		if resp == nil {
+			continue
+		}
 
 		// send out response msg
 		err = writeMsg(stream, resp)
@@ -112,3 +160,62 @@ func (s *Server) handleNewMessages(stream network.Stream) bool {
 		}
 	}
 }
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// sendStream sends a streamed response, while reading from the stream to
+// detect cancellation by the client. Any message received while sending the
+// response cancels it, and is discarded. If the read has not completed when the
+// response is finished, then the channel that receives the read result is
+// returned.
+func sendStream(ctx context.Context, stream network.Stream, r msgio.ReadCloser, streamFunc StreamFunc) (<-chan readResult, error) {
+	readDone := make(chan readResult, 1)
+	go func() {
+		data, err := r.ReadMsg()
+		readDone <- readResult{data, err}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watchDone := make(chan struct{})
+	var readRes *readResult
+	go func() {
+		defer close(watchDone)
+		select {
+		case res := <-readDone:
+			readRes = &res
+			// The client closing its side of the stream does not cancel
+			// the response.
+			if res.err != io.EOF {
+				cancel()
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	err := streamFunc(ctx, func(msg proto.Message) error {
+		return writeMsg(stream, msg)
+	})
+	cancel()
+	<-watchDone
+	if err != nil {
+		return nil, err
+	}
+
+	if readRes == nil {
+		return readDone, nil
+	}
+	if readRes.err != nil {
+		// Return the read error to be handled as the next read.
+		resChan := make(chan readResult, 1)
+		resChan <- *readRes
+		return resChan, nil
+	}
+	// Discard the cancel message.
+	r.ReleaseMsg(readRes.data)
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+)
+
+const promNamespace = "storetheindex"
+
+// Prometheus native metrics. These are registered with the default Prometheus
+// registry by Start, and are served along with the OpenCensus views.
+var (
+	findLatencySeconds = promclient.NewHistogramVec(promclient.HistogramOpts{
+		Namespace: promNamespace,
+		Subsystem: "find",
+		Name:      "latency_seconds",
+		Help:      "Time to respond to a find request, by endpoint",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"endpoint"})
+	findResultSize = promclient.NewHistogramVec(promclient.HistogramOpts{
+		Namespace: promNamespace,
+		Subsystem: "find",
+		Name:      "result_size",
+		Help:      "Number of provider results returned by a find request, by endpoint",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+	}, []string{"endpoint"})
+
+	federationLatencySeconds = promclient.NewHistogramVec(promclient.HistogramOpts{
+		Namespace: promNamespace,
+		Subsystem: "federation",
+		Name:      "latency_seconds",
+		Help:      "Time for a federated indexer to respond to a find request, by backend and result",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"backend", "result"})
+
+	denylistDenied = promclient.NewCounterVec(promclient.CounterOpts{
+		Namespace: promNamespace,
+		Subsystem: "denylist",
+		Name:      "denied_total",
+		Help:      "Number of denylisted multihashes removed from find requests or ingested advertisements",
+	}, []string{"where"})
+	denylistEntries = promclient.NewGauge(promclient.GaugeOpts{
+		Namespace: promNamespace,
+		Subsystem: "denylist",
+		Name:      "entries",
+		Help:      "Number of entries in the loaded denylist",
+	})
+)
+
+// RecordFind records the latency and the number of provider results of a find
+// request to the endpoint.
+func RecordFind(endpoint string, elapsed time.Duration, resultSize int) {
+	findLatencySeconds.WithLabelValues(endpoint).Observe(elapsed.Seconds())
+	findResultSize.WithLabelValues(endpoint).Observe(float64(resultSize))
+}
+
+// RecordFederatedFind records the latency of a find request to a federated
+// indexer, and whether the request failed.
+func RecordFederatedFind(backend string, elapsed time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	federationLatencySeconds.WithLabelValues(backend, result).Observe(elapsed.Seconds())
+}
+
+// RecordDenied records the number of denylisted multihashes that were removed
+// from a find request or from ingestion, where is "find" or "ingest".
+func RecordDenied(where string, count int) {
+	denylistDenied.WithLabelValues(where).Add(float64(count))
+}
+
+// SetDenylistEntries sets the number of entries in the loaded denylist.
+func SetDenylistEntries(count int) {
+	denylistEntries.Set(float64(count))
+}
+
+// registerPrometheus registers the Prometheus native metrics. Metrics that are
+// already registered are ignored.
+func registerPrometheus(reg promclient.Registerer) {
+	for _, c := range []promclient.Collector{
+		findLatencySeconds,
+		findResultSize,
+		federationLatencySeconds,
+		denylistDenied,
+		denylistEntries,
+		publisherAdsIngested,
+		publisherMhsIngested,
+		publisherSyncErrors,
+		publisherLastSync,
+		publisherLag,
+	} {
+		err := reg.Register(c)
+		if err != nil {
+			var are promclient.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				log.Errorw("Cannot register prometheus metric", "err", err)
+			}
+		}
+	}
+}
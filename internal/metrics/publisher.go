@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	promclient "github.com/prometheus/client_golang/prometheus"
+)
+
+// OtherPublishers is the publisher label value used for publishers beyond the
+// maximum number of publishers that have their own series.
+const OtherPublishers = "other"
+
+// Prometheus native metrics labeled by publisher.
+var (
+	publisherAdsIngested = promclient.NewCounterVec(promclient.CounterOpts{
+		Namespace: promNamespace,
+		Subsystem: "publisher",
+		Name:      "ads_ingested_total",
+		Help:      "Number of advertisements ingested from a publisher",
+	}, []string{"publisher"})
+	publisherMhsIngested = promclient.NewCounterVec(promclient.CounterOpts{
+		Namespace: promNamespace,
+		Subsystem: "publisher",
+		Name:      "multihashes_ingested_total",
+		Help:      "Number of multihashes ingested from a publisher",
+	}, []string{"publisher"})
+	publisherSyncErrors = promclient.NewCounterVec(promclient.CounterOpts{
+		Namespace: promNamespace,
+		Subsystem: "publisher",
+		Name:      "sync_errors_total",
+		Help:      "Number of errors syncing or ingesting advertisements from a publisher, by kind of error",
+	}, []string{"publisher", "errKind"})
+	publisherLastSync = promclient.NewGaugeVec(promclient.GaugeOpts{
+		Namespace: promNamespace,
+		Subsystem: "publisher",
+		Name:      "last_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful advertisement sync with a publisher",
+	}, []string{"publisher"})
+	publisherLag = promclient.NewGaugeVec(promclient.GaugeOpts{
+		Namespace: promNamespace,
+		Subsystem: "publisher",
+		Name:      "lag",
+		Help:      "Number of synced advertisements from a publisher that are waiting to be ingested",
+	}, []string{"publisher"})
+)
+
+// publisherLabels limits the number of distinct publisher label values. The
+// labels map is nil when per-publisher metrics are disabled.
+var publisherLabels struct {
+	mutex  sync.Mutex
+	max    int
+	labels map[peer.ID]string
+}
+
+// EnablePublisherMetrics enables recording metrics labeled by publisher. At
+// most maxPublishers publishers get their own series. Metrics for any other
+// publishers are recorded with the OtherPublishers label.
+func EnablePublisherMetrics(maxPublishers int) {
+	publisherLabels.mutex.Lock()
+	defer publisherLabels.mutex.Unlock()
+
+	publisherLabels.max = maxPublishers
+	if publisherLabels.labels == nil {
+		publisherLabels.labels = make(map[peer.ID]string)
+	}
+}
+
+// publisherLabel returns the label value for the publisher, and false if
+// per-publisher metrics are disabled.
+func publisherLabel(publisher peer.ID) (string, bool) {
+	publisherLabels.mutex.Lock()
+	defer publisherLabels.mutex.Unlock()
+
+	if publisherLabels.labels == nil {
+		return "", false
+	}
+	label, ok := publisherLabels.labels[publisher]
+	if ok {
+		return label, true
+	}
+	if len(publisherLabels.labels) >= publisherLabels.max {
+		return OtherPublishers, true
+	}
+	label = publisher.String()
+	publisherLabels.labels[publisher] = label
+	return label, true
+}
+
+// RecordAdIngested records an advertisement, with mhCount multihashes, that
+// was ingested from the publisher.
+func RecordAdIngested(publisher peer.ID, mhCount int) {
+	label, ok := publisherLabel(publisher)
+	if !ok {
+		return
+	}
+	publisherAdsIngested.WithLabelValues(label).Inc()
+	publisherMhsIngested.WithLabelValues(label).Add(float64(mhCount))
+}
+
+// RecordSyncError records an error of the given kind that happened while
+// syncing or ingesting advertisements from the publisher.
+func RecordSyncError(publisher peer.ID, errKind string) {
+	label, ok := publisherLabel(publisher)
+	if !ok {
+		return
+	}
+	publisherSyncErrors.WithLabelValues(label, errKind).Inc()
+}
+
+// RecordSync records a successful advertisement sync with the publisher.
+func RecordSync(publisher peer.ID, syncTime time.Time) {
+	label, ok := publisherLabel(publisher)
+	if !ok || label == OtherPublishers {
+		return
+	}
+	publisherLastSync.WithLabelValues(label).Set(float64(syncTime.Unix()))
+}
+
+// RecordLag records the number of synced advertisements from the publisher
+// that are waiting to be ingested.
+func RecordLag(publisher peer.ID, lag int) {
+	label, ok := publisherLabel(publisher)
+	if !ok || label == OtherPublishers {
+		return
+	}
+	publisherLag.WithLabelValues(label).Set(float64(lag))
+}
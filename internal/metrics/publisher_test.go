@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisherMetrics(t *testing.T) {
+	pubA := peer.ID("publisher-a")
+	pubB := peer.ID("publisher-b")
+	pubC := peer.ID("publisher-c")
+
+	// Nothing is recorded while per-publisher metrics are disabled.
+	RecordAdIngested(pubA, 10)
+	require.Zero(t, testutil.CollectAndCount(publisherAdsIngested))
+
+	EnablePublisherMetrics(2)
+	t.Cleanup(func() {
+		publisherLabels.labels = nil
+		publisherAdsIngested.Reset()
+		publisherMhsIngested.Reset()
+		publisherSyncErrors.Reset()
+		publisherLastSync.Reset()
+		publisherLag.Reset()
+	})
+
+	RecordAdIngested(pubA, 10)
+	RecordAdIngested(pubB, 5)
+	RecordAdIngested(pubC, 7)
+	RecordAdIngested(pubC, 3)
+	require.Equal(t, 3, testutil.CollectAndCount(publisherAdsIngested))
+	require.Equal(t, float64(1), testutil.ToFloat64(publisherAdsIngested.WithLabelValues(pubA.String())))
+	require.Equal(t, float64(2), testutil.ToFloat64(publisherAdsIngested.WithLabelValues(OtherPublishers)))
+	require.Equal(t, float64(10), testutil.ToFloat64(publisherMhsIngested.WithLabelValues(OtherPublishers)))
+
+	RecordSyncError(pubB, "adChainSyncErr")
+	require.Equal(t, float64(1), testutil.ToFloat64(publisherSyncErrors.WithLabelValues(pubB.String(), "adChainSyncErr")))
+
+	// Gauges are not recorded for publishers over the limit.
+	now := time.Now()
+	RecordSync(pubA, now)
+	RecordSync(pubC, now)
+	RecordLag(pubA, 4)
+	RecordLag(pubC, 9)
+	require.Equal(t, 1, testutil.CollectAndCount(publisherLastSync))
+	require.Equal(t, float64(now.Unix()), testutil.ToFloat64(publisherLastSync.WithLabelValues(pubA.String())))
+	require.Equal(t, 1, testutil.CollectAndCount(publisherLag))
+	require.Equal(t, float64(4), testutil.ToFloat64(publisherLag.WithLabelValues(pubA.String())))
+}
+
+func TestRecordFind(t *testing.T) {
+	RecordFind("http/multihash", 5*time.Millisecond, 3)
+	RecordFind("reframe", time.Millisecond, 0)
+	require.Equal(t, 2, testutil.CollectAndCount(findLatencySeconds))
+	require.Equal(t, 2, testutil.CollectAndCount(findResultSize))
+}
+
+func TestRecordFederatedFind(t *testing.T) {
+	RecordFederatedFind("http://indexer-a", 5*time.Millisecond, nil)
+	RecordFederatedFind("http://indexer-a", time.Second, errors.New("timeout"))
+	RecordFederatedFind("http://indexer-b", time.Millisecond, nil)
+	require.Equal(t, 3, testutil.CollectAndCount(federationLatencySeconds))
+}
+
+func TestRecordDenied(t *testing.T) {
+	RecordDenied("find", 2)
+	RecordDenied("ingest", 1)
+	RecordDenied("find", 1)
+	require.Equal(t, 2, testutil.CollectAndCount(denylistDenied))
+	require.Equal(t, float64(3), testutil.ToFloat64(denylistDenied.WithLabelValues("find")))
+
+	SetDenylistEntries(5)
+	require.Equal(t, float64(5), testutil.ToFloat64(denylistEntries))
+}
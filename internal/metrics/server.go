@@ -18,11 +18,17 @@ var (
 	Method, _  = tag.NewKey("method")
 	Found, _   = tag.NewKey("found")
 	Version, _ = tag.NewKey("version")
+
+	ClientKind, _  = tag.NewKey("clientKind")
+	LimitBucket, _ = tag.NewKey("limitBucket")
 )
 
 // Measures
 var (
 	FindLatency          = stats.Float64("find/latency", "Time to respond to a find request", stats.UnitMilliseconds)
+	FindCacheHit         = stats.Int64("find/cachehit", "Number of multihash lookups answered from the find cache", stats.UnitDimensionless)
+	FindCacheMiss        = stats.Int64("find/cachemiss", "Number of multihash lookups not answered from the find cache", stats.UnitDimensionless)
+	FindRateLimited      = stats.Int64("find/ratelimited", "Number of find requests rejected by rate limiting", stats.UnitDimensionless)
 	IngestChange         = stats.Int64("ingest/change", "Number of syncAdEntries started", stats.UnitDimensionless)
 	AdIngestLatency      = stats.Float64("ingest/adsynclatency", "latency of syncAdEntries completed successfully", stats.UnitDimensionless)
 	AdIngestErrorCount   = stats.Int64("ingest/adingestError", "Number of errors encountered while processing an ad", stats.UnitDimensionless)
@@ -37,6 +43,8 @@ var (
 	MhStoreNanoseconds   = stats.Int64("ingest/mhstorenanoseconds", "Average nanoseconds to store one multihash", stats.UnitDimensionless)
 	IndexCount           = stats.Int64("provider/indexCount", "Number of indexes stored for all providers", stats.UnitDimensionless)
 	PercentUsage         = stats.Float64("ingest/percentusage", "Percent usage of storage available in value store", stats.UnitDimensionless)
+	PurgedContextCount   = stats.Int64("ingest/purgedContexts", "Number of removed provider contexts purged from value store", stats.UnitDimensionless)
+	PurgedValueIndexes   = stats.Int64("ingest/purgedValueIndexes", "Number of indexes whose value was deleted by purging removed provider contexts", stats.UnitDimensionless)
 )
 
 // Views
@@ -46,6 +54,21 @@ var (
 		Aggregation: view.Distribution(0, 1, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 200, 300, 400, 500, 1000, 2000, 5000),
 		TagKeys:     []tag.Key{Method, Found},
 	}
+	findCacheHitView = &view.View{
+		Measure:     FindCacheHit,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{Found},
+	}
+	findCacheMissView = &view.View{
+		Measure:     FindCacheMiss,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{Found},
+	}
+	findRateLimitedView = &view.View{
+		Measure:     FindRateLimited,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{LimitBucket, ClientKind},
+	}
 	adIngestLatencyView = &view.View{
 		Measure:     AdIngestLatency,
 		Aggregation: view.Distribution(0, 1, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 200, 300, 400, 500, 1000, 2000, 5000),
@@ -104,6 +127,14 @@ var (
 		Measure:     PercentUsage,
 		Aggregation: view.LastValue(),
 	}
+	purgedContextView = &view.View{
+		Measure:     PurgedContextCount,
+		Aggregation: view.Sum(),
+	}
+	purgedValueIndexesView = &view.View{
+		Measure:     PurgedValueIndexes,
+		Aggregation: view.Sum(),
+	}
 )
 
 var log = logging.Logger("indexer/metrics")
@@ -113,6 +144,9 @@ func Start(views []*view.View) http.Handler {
 	// Register default views
 	err := view.Register(
 		findLatencyView,
+		findCacheHitView,
+		findCacheMissView,
+		findRateLimitedView,
 		ingestChangeView,
 		providerView,
 		entriesSyncLatencyView,
@@ -127,6 +161,8 @@ func Start(views []*view.View) http.Handler {
 		mhStoreNanosecondsView,
 		indexCountView,
 		percentUsageView,
+		purgedContextView,
+		purgedValueIndexesView,
 	)
 	if err != nil {
 		log.Errorf("cannot register metrics default views: %s", err)
@@ -140,6 +176,8 @@ func Start(views []*view.View) http.Handler {
 	if !ok {
 		log.Warnf("failed to export default prometheus registry; some metrics will be unavailable; unexpected type: %T", promclient.DefaultRegisterer)
 	}
+	registerPrometheus(promclient.DefaultRegisterer)
+
 	exporter, err := prometheus.NewExporter(prometheus.Options{
 		Registry:  registry,
 		Namespace: "storetheindex",
@@ -0,0 +1,132 @@
+package readonly
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// Datastore is a read-only datastore whose underlying datastore can be
+// replaced while it is in use.
+type Datastore struct {
+	ds    datastore.Datastore
+	mutex sync.RWMutex
+	// users counts the open query results of ds.
+	users *sync.WaitGroup
+}
+
+var _ datastore.Datastore = (*Datastore)(nil)
+
+// NewDatastore creates a read-only Datastore that reads from ds.
+func NewDatastore(ds datastore.Datastore) *Datastore {
+	return &Datastore{
+		ds:    ds,
+		users: new(sync.WaitGroup),
+	}
+}
+
+// Swap replaces the underlying datastore and returns the previous one, and a
+// channel that is closed when the previous datastore is no longer in use. The
+// previous datastore is no longer in use by any call when Swap returns, but
+// may still be read by query results until they are closed. The caller can
+// close the previous datastore after the channel is closed.
+func (d *Datastore) Swap(ds datastore.Datastore) (datastore.Datastore, <-chan struct{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	prev := d.ds
+	prevUsers := d.users
+	d.ds = ds
+	d.users = new(sync.WaitGroup)
+	return prev, usersDone(prevUsers)
+}
+
+func (d *Datastore) Get(ctx context.Context, key datastore.Key) ([]byte, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.ds.Get(ctx, key)
+}
+
+func (d *Datastore) Has(ctx context.Context, key datastore.Key) (bool, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.ds.Has(ctx, key)
+}
+
+func (d *Datastore) GetSize(ctx context.Context, key datastore.Key) (int, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.ds.GetSize(ctx, key)
+}
+
+// Query queries the underlying datastore. The underlying datastore is not
+// closed, if swapped, until the results are closed or read to the end.
+func (d *Datastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	results, err := d.ds.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	d.users.Add(1)
+	return &releasedResults{
+		Results: results,
+		release: d.users.Done,
+	}, nil
+}
+
+func (d *Datastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	return ErrReadOnly
+}
+
+func (d *Datastore) Delete(ctx context.Context, key datastore.Key) error {
+	return ErrReadOnly
+}
+
+func (d *Datastore) Sync(ctx context.Context, prefix datastore.Key) error {
+	return nil
+}
+
+// releasedResults calls release when query results are closed or read to the
+// end.
+type releasedResults struct {
+	query.Results
+	once    sync.Once
+	release func()
+}
+
+func (r *releasedResults) NextSync() (query.Result, bool) {
+	res, ok := r.Results.NextSync()
+	if !ok {
+		r.once.Do(r.release)
+	}
+	return res, ok
+}
+
+func (r *releasedResults) Rest() ([]query.Entry, error) {
+	defer r.once.Do(r.release)
+	return r.Results.Rest()
+}
+
+func (r *releasedResults) Close() error {
+	defer r.once.Do(r.release)
+	return r.Results.Close()
+}
+
+// usersDone returns a channel that is closed when there are no more users.
+func usersDone(users *sync.WaitGroup) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		users.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// Close closes the underlying datastore.
+func (d *Datastore) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.ds.Close()
+}
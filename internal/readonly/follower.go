@@ -0,0 +1,174 @@
+// Package readonly provides the read-only datastore and value store used by a
+// read-only finder replica, and a Follower that keeps the replica up to date
+// by loading snapshots taken by a primary indexer.
+package readonly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/storetheindex/filestore"
+	"github.com/ipni/storetheindex/internal/snapshot"
+)
+
+var log = logging.Logger("indexer/readonly")
+
+// ErrReadOnly is returned when writing to a read-only datastore or value
+// store.
+var ErrReadOnly = errors.New("read-only")
+
+// OpenFunc creates an empty datastore and value store in dir, for a snapshot
+// to be restored into.
+type OpenFunc func(dir string) (datastore.Batching, indexer.Interface, error)
+
+// Follower periodically checks a file store for a new snapshot. When one is
+// found, it is restored into a new datastore and value store, which then
+// replace the ones being read from.
+type Follower struct {
+	current string
+	dir     string
+	ds      *Datastore
+	open    OpenFunc
+	opts    followerConfig
+	src     filestore.Interface
+	vs      *ValueStore
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	// retired waits for previous stores to be closed.
+	retired sync.WaitGroup
+}
+
+// NewFollower creates a Follower that loads snapshots from src into ds and vs.
+// Each snapshot is restored into a subdirectory of dir, which is only used by
+// the Follower. Any previously restored snapshots in dir are removed.
+func NewFollower(src filestore.Interface, dir string, ds *Datastore, vs *ValueStore, open OpenFunc, options ...Option) (*Follower, error) {
+	if src == nil {
+		return nil, errors.New("snapshot source not configured")
+	}
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("cannot remove previous snapshots: %w", err)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &Follower{
+		dir:    dir,
+		ds:     ds,
+		open:   open,
+		opts:   opts,
+		src:    src,
+		vs:     vs,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go f.run(ctx)
+	return f, nil
+}
+
+// Close stops checking for new snapshots, and waits for any snapshot being
+// loaded to be abandoned, and for the stores of previous snapshots to be
+// closed.
+func (f *Follower) Close() {
+	f.cancel()
+	<-f.done
+	f.retired.Wait()
+}
+
+func (f *Follower) run(ctx context.Context) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.opts.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.check(ctx); err != nil && ctx.Err() == nil {
+			log.Errorw("Cannot load snapshot", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check loads the latest snapshot if it is newer than the one already loaded.
+func (f *Follower) check(ctx context.Context) error {
+	name, err := snapshot.Latest(ctx, f.src)
+	if err != nil {
+		return err
+	}
+	if name == "" || name <= f.current {
+		return nil
+	}
+
+	manifest, err := snapshot.ReadManifest(ctx, f.src, name)
+	if err != nil {
+		return err
+	}
+	log.Infow("Loading snapshot", "name", name)
+	start := time.Now()
+
+	snapDir := filepath.Join(f.dir, name)
+	ds, vs, err := f.open(snapDir)
+	if err != nil {
+		return fmt.Errorf("cannot open stores for snapshot: %w", err)
+	}
+	if err = snapshot.Restore(ctx, f.src, manifest, ds, nil, vs); err != nil {
+		ds.Close()
+		vs.Close()
+		os.RemoveAll(snapDir)
+		return fmt.Errorf("cannot restore snapshot %s: %w", name, err)
+	}
+
+	prevDS, dsDone := f.ds.Swap(ds)
+	prevVS, vsDone := f.vs.Swap(vs)
+	var prevDir string
+	if f.current != "" {
+		prevDir = filepath.Join(f.dir, f.current)
+	}
+	f.current = name
+
+	// Close the previous stores when they are no longer being read.
+	f.retired.Add(1)
+	go func() {
+		defer f.retired.Done()
+		<-dsDone
+		<-vsDone
+		if err := prevDS.Close(); err != nil {
+			log.Errorw("Error closing previous datastore", "err", err)
+		}
+		if err := prevVS.Close(); err != nil {
+			log.Errorw("Error closing previous value store", "err", err)
+		}
+		if prevDir != "" {
+			if err := os.RemoveAll(prevDir); err != nil {
+				log.Errorw("Cannot remove previous snapshot", "err", err)
+			}
+		}
+	}()
+
+	if f.opts.onLoad != nil {
+		if err = f.opts.onLoad(ctx); err != nil {
+			return err
+		}
+	}
+	log.Infow("Loaded snapshot", "name", name, "elapsed", time.Since(start))
+	return nil
+}
@@ -0,0 +1,136 @@
+package readonly_test
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/store/memory"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/filestore"
+	"github.com/ipni/storetheindex/internal/readonly"
+	"github.com/ipni/storetheindex/internal/snapshot"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollower(t *testing.T) {
+	ctx := context.Background()
+	src, err := filestore.New(config.FileStore{
+		Type: "local",
+		Local: config.LocalFileStore{
+			BasePath: t.TempDir(),
+		},
+	})
+	require.NoError(t, err)
+
+	// Take a snapshot of a primary indexer.
+	primaryDS := dssync.MutexWrap(datastore.NewMapDatastore())
+	primaryVS := memory.New()
+	dsKey := datastore.NewKey("/registry/prov1")
+	require.NoError(t, primaryDS.Put(ctx, dsKey, []byte("info1")))
+	mhs := util.RandomMultihashes(10, rand.New(rand.NewSource(1413)))
+	value := indexer.Value{ProviderID: peer.ID("provider-a"), ContextID: []byte("ctx-a"), MetadataBytes: []byte("meta-a")}
+	require.NoError(t, primaryVS.Put(value, mhs...))
+	primaryID, err := peer.Decode("12D3KooWQ9j3Ur5V9U63Vi6ved72TcA3sv34k74W3wpW5rwNvDc3")
+	require.NoError(t, err)
+	snapper, err := snapshot.New(src, primaryID, primaryDS, primaryVS)
+	require.NoError(t, err)
+	_, err = snapper.Snapshot(ctx, snapshot.NewName(time.Now()))
+	require.NoError(t, err)
+
+	ds := readonly.NewDatastore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	vs := readonly.NewValueStore(memory.New())
+	require.ErrorIs(t, ds.Put(ctx, dsKey, nil), readonly.ErrReadOnly)
+	require.ErrorIs(t, vs.Put(value, mhs...), readonly.ErrReadOnly)
+
+	var opened []string
+	open := func(dir string) (datastore.Batching, indexer.Interface, error) {
+		opened = append(opened, dir)
+		return dssync.MutexWrap(datastore.NewMapDatastore()), memory.New(), nil
+	}
+	var loads int32
+	onLoad := func(context.Context) error {
+		atomic.AddInt32(&loads, 1)
+		return nil
+	}
+
+	dir := filepath.Join(t.TempDir(), "replica")
+	f, err := readonly.NewFollower(src, dir, ds, vs, open,
+		readonly.WithCheckInterval(50*time.Millisecond),
+		readonly.WithOnLoad(onLoad))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&loads) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	has, err := ds.Has(ctx, dsKey)
+	require.NoError(t, err)
+	require.True(t, has)
+	values, found, err := vs.Get(mhs[0])
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []indexer.Value{value}, values)
+
+	// The same snapshot is not loaded again.
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	f.Close()
+	require.Len(t, opened, 1)
+	require.Equal(t, dir, filepath.Dir(opened[0]))
+}
+
+func TestSwapWaitsForUsers(t *testing.T) {
+	ctx := context.Background()
+	ds := readonly.NewDatastore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	vs := readonly.NewValueStore(memory.New())
+
+	results, err := ds.Query(ctx, query.Query{})
+	require.NoError(t, err)
+	iter, err := vs.Iter()
+	require.NoError(t, err)
+
+	_, dsDone := ds.Swap(dssync.MutexWrap(datastore.NewMapDatastore()))
+	_, vsDone := vs.Swap(memory.New())
+
+	// The previous stores are in use until the results and iterator are
+	// closed.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-dsDone:
+		t.Fatal("previous datastore released with open query results")
+	case <-vsDone:
+		t.Fatal("previous value store released with open iterator")
+	default:
+	}
+
+	_, err = results.Rest()
+	require.NoError(t, err)
+	require.NoError(t, iter.Close())
+	require.Eventually(t, func() bool {
+		select {
+		case <-dsDone:
+		default:
+			return false
+		}
+		select {
+		case <-vsDone:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Stores with no users are released right away.
+	_, dsDone = ds.Swap(dssync.MutexWrap(datastore.NewMapDatastore()))
+	<-dsDone
+}
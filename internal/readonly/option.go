@@ -0,0 +1,52 @@
+package readonly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultCheckInterval = 5 * time.Minute
+
+// followerConfig contains all options for the Follower.
+type followerConfig struct {
+	checkInterval time.Duration
+	onLoad        func(context.Context) error
+}
+
+// Option is a function that sets a value in a followerConfig.
+type Option func(*followerConfig) error
+
+// getOpts creates a followerConfig and applies Options to it.
+func getOpts(opts []Option) (followerConfig, error) {
+	cfg := followerConfig{
+		checkInterval: defaultCheckInterval,
+	}
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return followerConfig{}, fmt.Errorf("option %d error: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithCheckInterval sets how often to check for a new snapshot.
+func WithCheckInterval(interval time.Duration) Option {
+	return func(c *followerConfig) error {
+		if interval <= 0 {
+			return errors.New("check interval must be positive")
+		}
+		c.checkInterval = interval
+		return nil
+	}
+}
+
+// WithOnLoad sets a function that is called after a snapshot is loaded, to
+// reload anything that caches data read from the datastore.
+func WithOnLoad(onLoad func(context.Context) error) Option {
+	return func(c *followerConfig) error {
+		c.onLoad = onLoad
+		return nil
+	}
+}
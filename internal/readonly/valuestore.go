@@ -0,0 +1,117 @@
+package readonly
+
+import (
+	"context"
+	"sync"
+
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// ValueStore is a read-only value store whose underlying value store can be
+// replaced while it is in use.
+type ValueStore struct {
+	vs    indexer.Interface
+	mutex sync.RWMutex
+	// users counts the open iterators of vs.
+	users *sync.WaitGroup
+}
+
+var _ indexer.Interface = (*ValueStore)(nil)
+
+// NewValueStore creates a read-only ValueStore that reads from vs.
+func NewValueStore(vs indexer.Interface) *ValueStore {
+	return &ValueStore{
+		vs:    vs,
+		users: new(sync.WaitGroup),
+	}
+}
+
+// Swap replaces the underlying value store and returns the previous one, and a
+// channel that is closed when the previous value store is no longer in use.
+// The previous value store is no longer in use by any call when Swap returns,
+// but may still be read by iterators until they are closed. The caller can
+// close the previous value store after the channel is closed.
+func (s *ValueStore) Swap(vs indexer.Interface) (indexer.Interface, <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	prev := s.vs
+	prevUsers := s.users
+	s.vs = vs
+	s.users = new(sync.WaitGroup)
+	return prev, usersDone(prevUsers)
+}
+
+func (s *ValueStore) Get(mh multihash.Multihash) ([]indexer.Value, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.vs.Get(mh)
+}
+
+func (s *ValueStore) Put(indexer.Value, ...multihash.Multihash) error {
+	return ErrReadOnly
+}
+
+func (s *ValueStore) Remove(indexer.Value, ...multihash.Multihash) error {
+	return ErrReadOnly
+}
+
+func (s *ValueStore) RemoveProvider(context.Context, peer.ID) error {
+	return ErrReadOnly
+}
+
+func (s *ValueStore) RemoveProviderContext(peer.ID, []byte) error {
+	return ErrReadOnly
+}
+
+func (s *ValueStore) Size() (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.vs.Size()
+}
+
+func (s *ValueStore) Flush() error {
+	return nil
+}
+
+// Close closes the underlying value store.
+func (s *ValueStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.vs.Close()
+}
+
+// Iter creates an iterator over the underlying value store. The underlying
+// value store is not closed, if swapped, until the iterator is closed.
+func (s *ValueStore) Iter() (indexer.Iterator, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	iter, err := s.vs.Iter()
+	if err != nil {
+		return nil, err
+	}
+	s.users.Add(1)
+	return &releasedIterator{
+		Iterator: iter,
+		release:  s.users.Done,
+	}, nil
+}
+
+// releasedIterator calls release when an iterator is closed.
+type releasedIterator struct {
+	indexer.Iterator
+	once    sync.Once
+	release func()
+}
+
+func (it *releasedIterator) Close() error {
+	defer it.once.Do(it.release)
+	return it.Iterator.Close()
+}
+
+func (s *ValueStore) Stats() (*indexer.Stats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.vs.Stats()
+}
@@ -3,7 +3,10 @@ package registry
 import "errors"
 
 var (
+	ErrAddrsNotAllowed     = errors.New("addresses not allowed by policy")
 	ErrAlreadyAssigned     = errors.New("publisher already assigned to this indexer")
+	ErrCannotResume        = errors.New("cannot resume changes from requested sequence")
+	ErrClosed              = errors.New("registry closed")
 	ErrInProgress          = errors.New("discovery already in progress")
 	ErrCannotPublish       = errors.New("publisher not allowed to publish to other provider")
 	ErrFrozen              = errors.New("indexer frozen")
@@ -12,6 +15,7 @@ var (
 	ErrNoFreeze            = errors.New("freeze not configured")
 	ErrNotVerified         = errors.New("provider cannot be verified")
 	ErrPublisherNotAllowed = errors.New("publisher not allowed by policy")
+	ErrReadOnly            = errors.New("registry is read-only")
 	ErrTooSoon             = errors.New("not enough time since previous discovery")
 	ErrNoAssigner          = errors.New("not configured to work with assigner service")
 )
@@ -0,0 +1,210 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ChangeKind identifies the type of change made to a provider in the
+// registry.
+type ChangeKind string
+
+const (
+	// ProviderRegistered means that a provider that was not in the registry
+	// was added to it.
+	ProviderRegistered ChangeKind = "registered"
+	// ProviderUpdated means that the addresses, publisher, or extended
+	// providers of a registered provider changed.
+	ProviderUpdated ChangeKind = "updated"
+	// ProviderFrozen means that the indexer was frozen and the provider's
+	// frozen-at advertisement was recorded.
+	ProviderFrozen ChangeKind = "frozen"
+	// ProviderRemoved means that a provider was removed from the registry.
+	ProviderRemoved ChangeKind = "removed"
+)
+
+const (
+	// defaultChangeHistory is the default number of recent change events kept
+	// so that subscribers can resume from a previous sequence number.
+	defaultChangeHistory = 1024
+	// changeChanBuffer is the number of change events that can be buffered
+	// for a subscriber, in addition to any replayed events. A subscriber that
+	// does not keep up is dropped.
+	changeChanBuffer = 256
+)
+
+// ChangesFromNow is given to OnChange to receive only the changes that happen
+// after subscribing.
+const ChangesFromNow uint64 = 0
+
+// ChangeEvent describes a change made to a provider in the registry.
+type ChangeEvent struct {
+	// Seq is the sequence number of the change. Sequence numbers increase
+	// with every change, and can be given to OnChange to resume receiving
+	// changes after the last one seen.
+	Seq uint64
+	// Kind is the type of change.
+	Kind ChangeKind
+	// ProviderID is the ID of the provider that changed.
+	ProviderID peer.ID
+	// Info is the provider information after the change. This is nil when
+	// the provider was removed.
+	Info *ProviderInfo
+	// Time is when the change happened.
+	Time time.Time
+}
+
+// changeFeed keeps a limited history of change events and delivers new
+// events to subscribers.
+type changeFeed struct {
+	mutex   sync.Mutex
+	seq     uint64
+	history []ChangeEvent
+	maxHist int
+	subs    map[chan ChangeEvent]struct{}
+}
+
+func newChangeFeed(historySize int) *changeFeed {
+	return &changeFeed{
+		// Sequence numbers are not persisted. Starting from the current time
+		// keeps sequence numbers increasing across restarts, so that a
+		// sequence number from before a restart is never mistaken for one
+		// after it.
+		seq:     uint64(time.Now().UnixMicro()),
+		maxHist: historySize,
+		subs:    make(map[chan ChangeEvent]struct{}),
+	}
+}
+
+// publish assigns the next sequence number to a change event, records it in
+// the history, and sends it to all subscribers. A subscriber whose channel is
+// full is dropped by closing its channel.
+func (f *changeFeed) publish(kind ChangeKind, providerID peer.ID, info *ProviderInfo) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.seq++
+	event := ChangeEvent{
+		Seq:        f.seq,
+		Kind:       kind,
+		ProviderID: providerID,
+		Info:       info,
+		Time:       time.Now(),
+	}
+
+	if f.maxHist > 0 {
+		if len(f.history) == f.maxHist {
+			copy(f.history, f.history[1:])
+			f.history[len(f.history)-1] = event
+		} else {
+			f.history = append(f.history, event)
+		}
+	}
+
+	for ch := range f.subs {
+		select {
+		case ch <- event:
+		default:
+			log.Warnw("Change event subscriber not keeping up, dropping subscriber", "seq", event.Seq)
+			close(ch)
+			delete(f.subs, ch)
+		}
+	}
+}
+
+func (f *changeFeed) subscribe(since uint64) (<-chan ChangeEvent, uint64, context.CancelFunc, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.subs == nil {
+		return nil, 0, nil, ErrClosed
+	}
+
+	var replay []ChangeEvent
+	if since == ChangesFromNow {
+		since = f.seq
+	} else if since != f.seq {
+		// Check that no events after since have been dropped from history.
+		if since > f.seq || len(f.history) == 0 || since < f.history[0].Seq-1 {
+			return nil, 0, nil, ErrCannotResume
+		}
+		replay = f.history[int(since-(f.history[0].Seq-1)):]
+	}
+
+	ch := make(chan ChangeEvent, len(replay)+changeChanBuffer)
+	for _, event := range replay {
+		ch <- event
+	}
+	f.subs[ch] = struct{}{}
+
+	cancel := func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, since, cancel, nil
+}
+
+// close closes all subscriber channels and prevents new subscriptions.
+func (f *changeFeed) close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for ch := range f.subs {
+		close(ch)
+	}
+	f.subs = nil
+}
+
+// OnChange returns a channel that receives an event each time a provider is
+// registered, updated, frozen, or removed. The channel is closed when the
+// returned cancel function is called, when the registry is closed, or when
+// the reader does not keep up with events. A reader whose channel is closed
+// may call OnChange again with the sequence number of the last event it
+// received to resume where it left off.
+//
+// If since is ChangesFromNow, then only new events are delivered. Otherwise,
+// all events with a sequence number greater than since are delivered.
+// ErrCannotResume is returned if some of those events are no longer
+// available.
+//
+// The returned sequence number is the one that the delivered events follow.
+// When subscribing from now, this is the sequence number of the most recent
+// change at the time of subscribing, so that a reader that receives no events
+// can later resume from it without missing any changes.
+func (r *Registry) OnChange(since uint64) (<-chan ChangeEvent, uint64, context.CancelFunc, error) {
+	return r.changes.subscribe(since)
+}
+
+// providerChanged returns true if any provider information, other than
+// advertisement and contact information, is different.
+func providerChanged(old, info *ProviderInfo) bool {
+	if old.Publisher != info.Publisher {
+		return true
+	}
+	if (old.PublisherAddr == nil) != (info.PublisherAddr == nil) {
+		return true
+	}
+	if old.PublisherAddr != nil && !old.PublisherAddr.Equal(info.PublisherAddr) {
+		return true
+	}
+	if len(old.AddrInfo.Addrs) != len(info.AddrInfo.Addrs) {
+		return true
+	}
+	for i := range old.AddrInfo.Addrs {
+		if !old.AddrInfo.Addrs[i].Equal(info.AddrInfo.Addrs[i]) {
+			return true
+		}
+	}
+	if old.ExtendedProviders != info.ExtendedProviders {
+		return !reflect.DeepEqual(old.ExtendedProviders, info.ExtendedProviders)
+	}
+	return false
+}
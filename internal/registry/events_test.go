@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnChange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := New(ctx, discoveryCfg, nil, WithChangeHistory(3))
+	require.NoError(t, err)
+	defer r.Close()
+
+	changes, seq, cancelChanges, err := r.OnChange(ChangesFromNow)
+	require.NoError(t, err)
+	defer cancelChanges()
+
+	peerID, err := peer.Decode(limitedID)
+	require.NoError(t, err)
+	maddr, err := multiaddr.NewMultiaddr(minerAddr)
+	require.NoError(t, err)
+	provider := peer.AddrInfo{
+		ID:    peerID,
+		Addrs: []multiaddr.Multiaddr{maddr},
+	}
+
+	err = r.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0)
+	require.NoError(t, err)
+	first := requireChange(t, changes, ProviderRegistered, peerID)
+	require.NotNil(t, first.Info)
+	require.Equal(t, seq+1, first.Seq)
+
+	// Updating only the advertisement does not generate a change.
+	adCid, err := cid.Decode("bafybeigvgzoolc3drupxhlevdp2ugqcrbcsqfmcek2zxiw5wctk3xjpjwy")
+	require.NoError(t, err)
+	err = r.Update(ctx, provider, peer.AddrInfo{}, adCid, nil, 0)
+	require.NoError(t, err)
+
+	maddr2, err := multiaddr.NewMultiaddr(minerAddr2)
+	require.NoError(t, err)
+	provider.Addrs = []multiaddr.Multiaddr{maddr2}
+	err = r.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0)
+	require.NoError(t, err)
+	second := requireChange(t, changes, ProviderUpdated, peerID)
+	require.Equal(t, first.Seq+1, second.Seq)
+	require.True(t, second.Info.AddrInfo.Addrs[0].Equal(maddr2))
+
+	require.NoError(t, r.RemoveProvider(ctx, peerID))
+	third := requireChange(t, changes, ProviderRemoved, peerID)
+	require.Equal(t, second.Seq+1, third.Seq)
+	require.Nil(t, third.Info)
+
+	// Removing a provider that is not registered does not generate a change.
+	require.NoError(t, r.RemoveProvider(ctx, peerID))
+	select {
+	case event := <-changes:
+		t.Fatalf("unexpected change event: %v", event.Kind)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Resume after the first event.
+	resumed, seq, cancelResumed, err := r.OnChange(first.Seq)
+	require.NoError(t, err)
+	require.Equal(t, first.Seq, seq)
+	require.Equal(t, second.Seq, requireChange(t, resumed, ProviderUpdated, peerID).Seq)
+	require.Equal(t, third.Seq, requireChange(t, resumed, ProviderRemoved, peerID).Seq)
+	cancelResumed()
+	_, open := <-resumed
+	require.False(t, open)
+
+	// Register again, so that the first event drops out of the history.
+	err = r.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0)
+	require.NoError(t, err)
+	requireChange(t, changes, ProviderRegistered, peerID)
+
+	_, _, _, err = r.OnChange(first.Seq - 1)
+	require.ErrorIs(t, err, ErrCannotResume)
+	_, _, _, err = r.OnChange(third.Seq + 10)
+	require.ErrorIs(t, err, ErrCannotResume)
+
+	// Check that closing the registry closes the change channel.
+	r.Close()
+	_, open = <-changes
+	require.False(t, open)
+	_, _, _, err = r.OnChange(ChangesFromNow)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func requireChange(t *testing.T, changes <-chan ChangeEvent, kind ChangeKind, providerID peer.ID) ChangeEvent {
+	select {
+	case event, ok := <-changes:
+		require.True(t, ok, "change channel closed")
+		require.Equal(t, kind, event.Kind)
+		require.Equal(t, providerID, event.ProviderID)
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for change event")
+	}
+	return ChangeEvent{}
+}
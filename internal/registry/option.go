@@ -7,7 +7,9 @@ import (
 
 // regConfig contains all options for the server.
 type regConfig struct {
+	changeHistory   int
 	freezeAtPercent float64
+	readOnly        bool
 	valueStoreDir   string
 }
 
@@ -16,7 +18,9 @@ type Option func(*regConfig) error
 
 // getOpts creates a regConfig and applies Options to it.
 func getOpts(opts []Option) (regConfig, error) {
-	var cfg regConfig
+	cfg := regConfig{
+		changeHistory: defaultChangeHistory,
+	}
 	for i, opt := range opts {
 		if err := opt(&cfg); err != nil {
 			return regConfig{}, fmt.Errorf("option %d error: %s", i, err)
@@ -35,3 +39,26 @@ func WithFreezer(valueStoreDir string, freezeAtPercent float64) Option {
 		return nil
 	}
 }
+
+// WithReadOnly makes the registry read-only. A read-only registry does not
+// write to its datastore, does not poll providers, and rejects updates. Use
+// Reload to load changes made to the datastore by something else.
+func WithReadOnly(readOnly bool) Option {
+	return func(c *regConfig) error {
+		c.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithChangeHistory sets the number of recent provider change events that are
+// kept, so that a change subscriber can resume from where it left off. A value
+// of zero means that no history is kept and subscribers cannot resume.
+func WithChangeHistory(size int) Option {
+	return func(c *regConfig) error {
+		if size < 0 {
+			return errors.New("change history size cannot be negative")
+		}
+		c.changeHistory = size
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// pausedKeyPath is where paused publishers are stored in the indexer repo.
+const pausedKeyPath = "/registry/paused"
+
+// PausedAnnounce is the latest announce received from a publisher while
+// ingestion from the publisher is paused.
+type PausedAnnounce struct {
+	// Cid is the announced advertisement CID, or cid.Undef if no announce was
+	// received while paused.
+	Cid cid.Cid
+	// Addrs are the publisher addresses in the announce.
+	Addrs []multiaddr.Multiaddr
+}
+
+// pausedData is the persisted form of a PausedAnnounce.
+type pausedData struct {
+	Cid   cid.Cid  `json:",omitempty"`
+	Addrs []string `json:",omitempty"`
+}
+
+// PausePublisher pauses ingestion from the publisher. The paused state is
+// persisted so that the publisher remains paused when the indexer restarts.
+// Returns false if the publisher was already paused.
+func (r *Registry) PausePublisher(publisherID peer.ID) (bool, error) {
+	r.pausedMutex.Lock()
+	defer r.pausedMutex.Unlock()
+
+	if _, ok := r.paused[publisherID]; ok {
+		return false, nil
+	}
+	if err := r.savePaused(publisherID, PausedAnnounce{}); err != nil {
+		return false, fmt.Errorf("cannot save paused publisher: %w", err)
+	}
+	r.paused[publisherID] = PausedAnnounce{}
+	return true, nil
+}
+
+// ResumePublisher resumes ingestion from a paused publisher, and returns the
+// latest announce recorded while the publisher was paused. Returns false if
+// the publisher was not paused.
+func (r *Registry) ResumePublisher(publisherID peer.ID) (PausedAnnounce, bool, error) {
+	r.pausedMutex.Lock()
+	defer r.pausedMutex.Unlock()
+
+	pa, ok := r.paused[publisherID]
+	if !ok {
+		return PausedAnnounce{}, false, nil
+	}
+	if r.dstore != nil {
+		dsKey := peerIDToDsKey(pausedKeyPath, publisherID)
+		if err := r.dstore.Delete(context.Background(), dsKey); err != nil {
+			return PausedAnnounce{}, false, fmt.Errorf("cannot delete paused publisher: %w", err)
+		}
+	}
+	delete(r.paused, publisherID)
+	return pa, true, nil
+}
+
+// PublisherPaused returns true if ingestion from the publisher is paused.
+func (r *Registry) PublisherPaused(publisherID peer.ID) bool {
+	r.pausedMutex.Lock()
+	defer r.pausedMutex.Unlock()
+	_, ok := r.paused[publisherID]
+	return ok
+}
+
+// PausedAnnounceFor returns the latest announce recorded while the publisher
+// is paused. Returns false if the publisher is not paused.
+func (r *Registry) PausedAnnounceFor(publisherID peer.ID) (PausedAnnounce, bool) {
+	r.pausedMutex.Lock()
+	defer r.pausedMutex.Unlock()
+	pa, ok := r.paused[publisherID]
+	return pa, ok
+}
+
+// RecordPausedAnnounce records an announce from a paused publisher, replacing
+// any previously recorded announce. Returns false if the publisher is not
+// paused.
+func (r *Registry) RecordPausedAnnounce(publisherID peer.ID, adCid cid.Cid, addrs []multiaddr.Multiaddr) (bool, error) {
+	r.pausedMutex.Lock()
+	defer r.pausedMutex.Unlock()
+
+	if _, ok := r.paused[publisherID]; !ok {
+		return false, nil
+	}
+	pa := PausedAnnounce{
+		Cid:   adCid,
+		Addrs: addrs,
+	}
+	if err := r.savePaused(publisherID, pa); err != nil {
+		return true, fmt.Errorf("cannot save paused announce: %w", err)
+	}
+	r.paused[publisherID] = pa
+	return true, nil
+}
+
+func (r *Registry) savePaused(publisherID peer.ID, pa PausedAnnounce) error {
+	if r.dstore == nil {
+		return nil
+	}
+	pd := pausedData{
+		Cid: pa.Cid,
+	}
+	if len(pa.Addrs) != 0 {
+		pd.Addrs = make([]string, len(pa.Addrs))
+		for i, addr := range pa.Addrs {
+			pd.Addrs[i] = addr.String()
+		}
+	}
+	valData, err := json.Marshal(&pd)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	dsKey := peerIDToDsKey(pausedKeyPath, publisherID)
+	if err = r.dstore.Put(ctx, dsKey, valData); err != nil {
+		return err
+	}
+	return r.dstore.Sync(ctx, dsKey)
+}
+
+func loadPersistedPaused(ctx context.Context, dstore datastore.Datastore) (map[peer.ID]PausedAnnounce, error) {
+	paused := make(map[peer.ID]PausedAnnounce)
+
+	if dstore == nil {
+		return paused, nil
+	}
+
+	results, err := dstore.Query(ctx, query.Query{
+		Prefix: pausedKeyPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, fmt.Errorf("cannot read paused publisher data: %w", result.Error)
+		}
+		ent := result.Entry
+
+		peerID, err := peer.Decode(path.Base(ent.Key))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode paused publisher ID: %w", err)
+		}
+
+		var pa PausedAnnounce
+		var pd pausedData
+		if err = json.Unmarshal(ent.Value, &pd); err != nil {
+			// Keep the publisher paused even if the recorded announce is lost.
+			log.Errorw("Cannot load paused announce", "err", err, "publisher", peerID)
+		} else {
+			pa.Cid = pd.Cid
+			for _, s := range pd.Addrs {
+				addr, err := multiaddr.NewMultiaddr(s)
+				if err != nil {
+					log.Errorw("Cannot decode paused announce address", "err", err, "publisher", peerID)
+					continue
+				}
+				pa.Addrs = append(pa.Addrs, addr)
+			}
+		}
+		paused[peerID] = pa
+	}
+
+	return paused, nil
+}
@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// addrPolicy allows or blocks multiaddrs by IP network, DNS name suffix, and
+// transport protocol.
+type addrPolicy struct {
+	allowNets       []*net.IPNet
+	blockNets       []*net.IPNet
+	allowDNS        []string
+	blockDNS        []string
+	allowTransports map[int]struct{}
+	blockTransports map[int]struct{}
+
+	cfg config.AddrPolicy
+}
+
+func newAddrPolicy(cfg config.AddrPolicy) (addrPolicy, error) {
+	var err error
+	ap := addrPolicy{
+		allowDNS: normalizeDNSSuffixes(cfg.AllowDNSSuffixes),
+		blockDNS: normalizeDNSSuffixes(cfg.BlockDNSSuffixes),
+		cfg:      cfg,
+	}
+	if ap.allowNets, err = parseCIDRs(cfg.AllowCIDRs); err != nil {
+		return addrPolicy{}, err
+	}
+	if ap.blockNets, err = parseCIDRs(cfg.BlockCIDRs); err != nil {
+		return addrPolicy{}, err
+	}
+	if ap.allowTransports, err = parseTransports(cfg.AllowTransports); err != nil {
+		return addrPolicy{}, err
+	}
+	if ap.blockTransports, err = parseTransports(cfg.BlockTransports); err != nil {
+		return addrPolicy{}, err
+	}
+	return ap, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	if len(cidrs) == 0 {
+		return nil, nil
+	}
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("bad address policy cidr %q: %s", cidr, err)
+		}
+		nets[i] = ipNet
+	}
+	return nets, nil
+}
+
+func parseTransports(names []string) (map[int]struct{}, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	codes := make(map[int]struct{}, len(names))
+	for _, name := range names {
+		proto := multiaddr.ProtocolWithName(name)
+		if proto.Code == 0 {
+			return nil, fmt.Errorf("bad address policy transport %q", name)
+		}
+		codes[proto.Code] = struct{}{}
+	}
+	return codes, nil
+}
+
+func normalizeDNSSuffixes(suffixes []string) []string {
+	if len(suffixes) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(suffixes))
+	for i, suffix := range suffixes {
+		normalized[i] = strings.Trim(strings.ToLower(suffix), ".")
+	}
+	return normalized
+}
+
+// empty returns true if the policy has no rules.
+func (ap *addrPolicy) empty() bool {
+	return len(ap.allowNets) == 0 && len(ap.blockNets) == 0 &&
+		len(ap.allowDNS) == 0 && len(ap.blockDNS) == 0 &&
+		len(ap.allowTransports) == 0 && len(ap.blockTransports) == 0
+}
+
+// allowed returns true if the policy allows the multiaddr.
+func (ap *addrPolicy) allowed(maddr multiaddr.Multiaddr) bool {
+	if maddr == nil {
+		return false
+	}
+	if ap.empty() {
+		return true
+	}
+
+	var blocked, hostAllowed, transportAllowed bool
+	multiaddr.ForEach(maddr, func(c multiaddr.Component) bool {
+		code := c.Protocol().Code
+		switch code {
+		case multiaddr.P_IP4, multiaddr.P_IP6:
+			ip := net.IP(c.RawValue())
+			if matchNets(ap.blockNets, ip) {
+				blocked = true
+				return false
+			}
+			if matchNets(ap.allowNets, ip) {
+				hostAllowed = true
+			}
+		case multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6, multiaddr.P_DNSADDR:
+			name := strings.Trim(strings.ToLower(c.Value()), ".")
+			if matchDNS(ap.blockDNS, name) {
+				blocked = true
+				return false
+			}
+			if matchDNS(ap.allowDNS, name) {
+				hostAllowed = true
+			}
+		default:
+			if _, ok := ap.blockTransports[code]; ok {
+				blocked = true
+				return false
+			}
+			if _, ok := ap.allowTransports[code]; ok {
+				transportAllowed = true
+			}
+		}
+		return true
+	})
+	if blocked {
+		return false
+	}
+	if (len(ap.allowNets) != 0 || len(ap.allowDNS) != 0) && !hostAllowed {
+		return false
+	}
+	if len(ap.allowTransports) != 0 && !transportAllowed {
+		return false
+	}
+	return true
+}
+
+// filter returns the multiaddrs that the policy allows. If no multiaddrs are
+// removed, then returns the original slice. If all are removed, then returns
+// nil.
+func (ap *addrPolicy) filter(maddrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	if ap.empty() || len(maddrs) == 0 {
+		return maddrs
+	}
+	filtered := multiaddr.FilterAddrs(maddrs, ap.allowed)
+	if len(filtered) == 0 {
+		return nil
+	}
+	return filtered
+}
+
+func matchNets(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchDNS(suffixes []string, name string) bool {
+	for _, suffix := range suffixes {
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
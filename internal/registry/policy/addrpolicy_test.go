@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrPolicy(t *testing.T) {
+	p, err := New(config.Policy{
+		Allow: true,
+		Addrs: config.AddrPolicy{
+			AllowCIDRs:       []string{"203.0.113.0/24", "2001:db8::/32"},
+			BlockCIDRs:       []string{"203.0.113.128/25"},
+			AllowDNSSuffixes: []string{"example.com."},
+			BlockDNSSuffixes: []string{"bad.example.com"},
+			BlockTransports:  []string{"ws"},
+		},
+	})
+	require.NoError(t, err)
+
+	for addr, allowed := range map[string]bool{
+		"/ip4/203.0.113.10/tcp/3104":             true,
+		"/ip6/2001:db8::1/udp/3104/quic":         true,
+		"/dns4/example.com/tcp/443/https":        true,
+		"/dns/ipni.EXAMPLE.com/tcp/443/https":    true,
+		"/ip4/203.0.113.200/tcp/3104":            false,
+		"/ip4/198.51.100.1/tcp/3104":             false,
+		"/dns4/notexample.com/tcp/443/https":     false,
+		"/dns4/www.bad.example.com/tcp/443/http": false,
+		"/ip4/203.0.113.10/tcp/3104/ws":          false,
+	} {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		require.NoError(t, err)
+		require.Equal(t, allowed, p.AddrAllowed(maddr), "wrong result for %s", addr)
+	}
+
+	good, err := multiaddr.NewMultiaddr("/ip4/203.0.113.10/tcp/3104")
+	require.NoError(t, err)
+	bad, err := multiaddr.NewMultiaddr("/ip4/198.51.100.1/tcp/3104")
+	require.NoError(t, err)
+	require.Equal(t, []multiaddr.Multiaddr{good}, p.FilterAddrs([]multiaddr.Multiaddr{bad, good}))
+	require.Nil(t, p.FilterAddrs([]multiaddr.Multiaddr{bad}))
+
+	// Check that a copied policy keeps the address rules.
+	cp, err := New(config.Policy{})
+	require.NoError(t, err)
+	cp.Copy(p)
+	require.False(t, cp.AddrAllowed(bad))
+	require.Equal(t, []string{"ws"}, cp.ToConfig().Addrs.BlockTransports)
+}
+
+func TestAddrPolicyTransports(t *testing.T) {
+	p, err := New(config.Policy{
+		Allow: true,
+		Addrs: config.AddrPolicy{
+			AllowTransports: []string{"https", "quic"},
+		},
+	})
+	require.NoError(t, err)
+
+	for addr, allowed := range map[string]bool{
+		"/dns4/example.com/tcp/443/https": true,
+		"/ip4/198.51.100.1/udp/3104/quic": true,
+		"/ip4/198.51.100.1/tcp/3104":      false,
+		"/dns4/example.com/tcp/80/http":   false,
+	} {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		require.NoError(t, err)
+		require.Equal(t, allowed, p.AddrAllowed(maddr), "wrong result for %s", addr)
+	}
+
+	// Empty address policy allows everything.
+	p, err = New(config.Policy{Allow: true})
+	require.NoError(t, err)
+	maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3104")
+	require.NoError(t, err)
+	require.True(t, p.AddrAllowed(maddr))
+}
+
+func TestAddrPolicyBadConfig(t *testing.T) {
+	_, err := New(config.Policy{
+		Addrs: config.AddrPolicy{
+			BlockCIDRs: []string{"10.0.0.0/33"},
+		},
+	})
+	require.ErrorContains(t, err, "cidr")
+
+	_, err = New(config.Policy{
+		Addrs: config.AddrPolicy{
+			AllowTransports: []string{"carrier-pigeon"},
+		},
+	})
+	require.ErrorContains(t, err, "transport")
+}
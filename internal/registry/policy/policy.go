@@ -7,11 +7,13 @@ import (
 	"github.com/ipni/storetheindex/config"
 	"github.com/ipni/storetheindex/peerutil"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 )
 
 type Policy struct {
 	allow   peerutil.Policy
 	publish peerutil.Policy
+	addrs   addrPolicy
 	rwmutex sync.RWMutex
 }
 
@@ -26,9 +28,15 @@ func New(cfg config.Policy) (*Policy, error) {
 		return nil, fmt.Errorf("bad publish policy: %s", err)
 	}
 
+	addrs, err := newAddrPolicy(cfg.Addrs)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Policy{
 		allow:   allow,
 		publish: publish,
+		addrs:   addrs,
 	}, nil
 }
 
@@ -57,6 +65,22 @@ func (p *Policy) PublishAllowed(publisherID, providerID peer.ID) bool {
 	return p.publish.Eval(publisherID)
 }
 
+// AddrAllowed returns true if the policy allows the multiaddr to be used as a
+// publisher or provider address.
+func (p *Policy) AddrAllowed(maddr multiaddr.Multiaddr) bool {
+	p.rwmutex.RLock()
+	defer p.rwmutex.RUnlock()
+	return p.addrs.allowed(maddr)
+}
+
+// FilterAddrs returns the multiaddrs that the policy allows. If no multiaddrs
+// are removed, then returns the original slice.
+func (p *Policy) FilterAddrs(maddrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	p.rwmutex.RLock()
+	defer p.rwmutex.RUnlock()
+	return p.addrs.filter(maddrs)
+}
+
 // Allow alters the policy to allow the specified peer. Returns true if the
 // policy needed to be updated.
 func (p *Policy) Allow(peerIDs ...peer.ID) bool {
@@ -95,6 +119,7 @@ func (p *Policy) Copy(other *Policy) {
 	other.rwmutex.RLock()
 	p.allow = other.allow
 	p.publish = other.publish
+	p.addrs = other.addrs
 	other.rwmutex.RUnlock()
 }
 
@@ -108,6 +133,7 @@ func (p *Policy) ToConfig() config.Policy {
 		Except:        p.allow.ExceptStrings(),
 		Publish:       p.publish.Default(),
 		PublishExcept: p.publish.ExceptStrings(),
+		Addrs:         p.addrs.cfg,
 	}
 }
 
@@ -41,6 +41,7 @@ var log = logging.Logger("indexer/registry")
 // Registry stores information about discovered providers
 type Registry struct {
 	actions   chan func()
+	changes   *changeFeed
 	closed    chan struct{}
 	closeOnce sync.Once
 	closing   chan struct{}
@@ -48,6 +49,7 @@ type Registry struct {
 	filterIPs bool
 	freezer   *freeze.Freezer
 	providers map[peer.ID]*ProviderInfo
+	readOnly  bool
 	sequences *sequences
 
 	policy *policy.Policy
@@ -60,6 +62,11 @@ type Registry struct {
 	// index data from.
 	preferred map[peer.ID]struct{}
 
+	// paused maps each publisher that has ingestion paused to the latest
+	// announce received from it while paused.
+	paused      map[peer.ID]PausedAnnounce
+	pausedMutex sync.Mutex
+
 	syncChan chan *ProviderInfo
 }
 
@@ -265,10 +272,12 @@ func New(ctx context.Context, cfg config.Discovery, dstore datastore.Datastore,
 
 	r := &Registry{
 		actions:   make(chan func()),
+		changes:   newChangeFeed(opts.changeHistory),
 		closed:    make(chan struct{}),
 		closing:   make(chan struct{}),
 		filterIPs: cfg.FilterIPs,
 		policy:    regPolicy,
+		readOnly:  opts.readOnly,
 		sequences: newSequences(0),
 
 		dstore:   dstore,
@@ -281,8 +290,16 @@ func New(ctx context.Context, cfg config.Discovery, dstore datastore.Datastore,
 	}
 	log.Infow("Loaded providers into registry", "count", len(r.providers))
 
+	r.paused, err = loadPersistedPaused(ctx, dstore)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load paused publishers from datastore: %w", err)
+	}
+	if len(r.paused) != 0 {
+		log.Infow("Loaded paused publishers into registry", "count", len(r.paused))
+	}
+
 	if cfg.UseAssigner {
-		r.assigned, err = loadPersistedAssignments(ctx, dstore, cfg.RemoveOldAssignments)
+		r.assigned, err = loadPersistedAssignments(ctx, dstore, cfg.RemoveOldAssignments, !opts.readOnly)
 		if err != nil {
 			return nil, err
 		}
@@ -303,7 +320,7 @@ func New(ctx context.Context, cfg config.Discovery, dstore datastore.Datastore,
 
 	go r.run()
 
-	if opts.freezeAtPercent >= 0 {
+	if opts.freezeAtPercent >= 0 && !opts.readOnly {
 		r.freezer, err = freeze.New(opts.valueStoreDir, opts.freezeAtPercent, dstore, r.freeze)
 		if err != nil {
 			return nil, fmt.Errorf("cannot create freezer: %s", err)
@@ -345,6 +362,7 @@ func (r *Registry) Close() {
 		close(r.closing)
 	})
 	<-r.closed
+	r.changes.close()
 }
 
 func (r *Registry) SyncChan() <-chan *ProviderInfo {
@@ -378,6 +396,10 @@ func (r *Registry) runPollCheck(poll polling, pollOverrides map[peer.ID]polling)
 		retryAfter = time.Minute
 	}
 	timer := time.NewTimer(retryAfter)
+	if r.readOnly {
+		// A read-only registry does not poll providers.
+		timer.Stop()
+	}
 running:
 	for {
 		select {
@@ -436,6 +458,17 @@ func (r *Registry) PublishAllowed(publisherID, providerID peer.ID) bool {
 	return r.policy.PublishAllowed(publisherID, providerID)
 }
 
+// AddrAllowed checks if the policy allows the multiaddr to be used as a
+// publisher or provider address.
+func (r *Registry) AddrAllowed(maddr multiaddr.Multiaddr) bool {
+	return r.policy.AddrAllowed(maddr)
+}
+
+// FilterAddrs returns the multiaddrs that are allowed by policy.
+func (r *Registry) FilterAddrs(maddrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	return r.policy.FilterAddrs(maddrs)
+}
+
 func (r *Registry) SetPolicy(policyCfg config.Policy) error {
 	newPol, err := policy.New(policyCfg)
 	if err != nil {
@@ -515,6 +548,9 @@ func (r *Registry) AssignPeer(publisherID peer.ID) error {
 }
 
 func (r *Registry) assignPeer(publisherID, frozenID peer.ID) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
 	r.assignMutex.Lock()
 	defer r.assignMutex.Unlock()
 
@@ -539,6 +575,9 @@ func (r *Registry) UnassignPeer(peerID peer.ID) (bool, error) {
 	if r.assigned == nil {
 		return false, ErrNoAssigner
 	}
+	if r.readOnly {
+		return false, ErrReadOnly
+	}
 
 	r.assignMutex.Lock()
 	defer r.assignMutex.Unlock()
@@ -604,6 +643,9 @@ func validateExtProviders(extendedProviders *ExtendedProviders) error {
 // has a valid ID, then the supplied publisher data replaces the provider's
 // previous publisher information.
 func (r *Registry) Update(ctx context.Context, provider, publisher peer.AddrInfo, adCid cid.Cid, extendedProviders *ExtendedProviders, lag int) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
 	// Do not accept update if provider is not allowed.
 	if !r.policy.Allowed(provider.ID) {
 		return ErrNotAllowed
@@ -613,6 +655,15 @@ func (r *Registry) Update(ctx context.Context, provider, publisher peer.AddrInfo
 		provider.Addrs = mautil.FilterPrivateIPs(provider.Addrs)
 		publisher.Addrs = mautil.FilterPrivateIPs(publisher.Addrs)
 	}
+	// Store addresses without applying the address policy, so that they are
+	// available again if the policy changes. The policy is applied when the
+	// addresses are served. Reject the update if every address is blocked.
+	if len(provider.Addrs) != 0 && len(r.policy.FilterAddrs(provider.Addrs)) == 0 {
+		return v0.NewError(ErrAddrsNotAllowed, http.StatusForbidden)
+	}
+	if len(publisher.Addrs) != 0 && len(r.policy.FilterAddrs(publisher.Addrs)) == 0 {
+		return v0.NewError(ErrAddrsNotAllowed, http.StatusForbidden)
+	}
 
 	var newPublisher bool
 
@@ -691,7 +742,8 @@ func (r *Registry) Update(ctx context.Context, provider, publisher peer.AddrInfo
 		// If no existing publisher addrs, and publisher ID is same as
 		// provider ID, then use provider addresses if any.
 		if len(publisher.Addrs) != 0 {
-			info.PublisherAddr = publisher.Addrs[0]
+			// Use the first address allowed by policy.
+			info.PublisherAddr = r.policy.FilterAddrs(publisher.Addrs)[0]
 		} else if info.PublisherAddr == nil && publisher.ID == info.AddrInfo.ID {
 			info.PublisherAddr = info.AddrInfo.Addrs[0]
 		}
@@ -858,6 +910,9 @@ func (r *Registry) Handoff(ctx context.Context, publisherID, frozenID peer.ID, f
 // ImportProviders reads providers from another indexer and registers any that
 // are not already registered. Returns the count of newly registered providers.
 func (r *Registry) ImportProviders(ctx context.Context, fromURL *url.URL) (int, error) {
+	if r.readOnly {
+		return 0, ErrReadOnly
+	}
 	cl, err := httpclient.New(fromURL.String())
 	if err != nil {
 		return 0, err
@@ -916,6 +971,9 @@ func (r *Registry) ImportProviders(ctx context.Context, fromURL *url.URL) (int,
 }
 
 func (r *Registry) RemoveProvider(ctx context.Context, providerID peer.ID) error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
 	var pinfo *ProviderInfo
 	errChan := make(chan error)
 	r.actions <- func() {
@@ -935,6 +993,48 @@ func (r *Registry) RemoveProvider(ctx context.Context, providerID peer.ID) error
 	return nil
 }
 
+// Reload replaces the provider information, assignments, and paused
+// publishers in the registry with those in the datastore. This is used by a
+// read-only registry to load changes made to its datastore by something else.
+func (r *Registry) Reload(ctx context.Context) error {
+	providers, err := loadPersistedProviders(ctx, r.dstore, r.filterIPs)
+	if err != nil {
+		return fmt.Errorf("cannot load provider data from datastore: %w", err)
+	}
+
+	var assigned map[peer.ID]peer.ID
+	if r.assigned != nil {
+		assigned, err = loadPersistedAssignments(ctx, r.dstore, false, !r.readOnly)
+		if err != nil {
+			return err
+		}
+	}
+
+	paused, err := loadPersistedPaused(ctx, r.dstore)
+	if err != nil {
+		return fmt.Errorf("cannot load paused publishers from datastore: %w", err)
+	}
+	r.pausedMutex.Lock()
+	r.paused = paused
+	r.pausedMutex.Unlock()
+
+	done := make(chan struct{})
+	r.actions <- func() {
+		r.providers = providers
+		if assigned != nil {
+			r.assignMutex.Lock()
+			r.assigned = assigned
+			r.preferred = loadPreferredAssignments(providers, assigned)
+			r.assignMutex.Unlock()
+		}
+		close(done)
+	}
+	<-done
+
+	log.Infow("Reloaded providers into registry", "count", len(providers))
+	return nil
+}
+
 func (r *Registry) CheckSequence(peerID peer.ID, seq uint64) error {
 	return r.sequences.check(peerID, seq)
 }
@@ -1039,6 +1139,7 @@ func (r *Registry) syncFreeze(now time.Time) error {
 			frozenInfo.FrozenAtTime = info.LastAdvertisementTime
 		}
 		r.providers[id] = &frozenInfo
+		r.changes.publish(ProviderFrozen, id, &frozenInfo)
 
 		if r.dstore == nil {
 			continue
@@ -1061,12 +1162,18 @@ func (r *Registry) syncFreeze(now time.Time) error {
 }
 
 func (r *Registry) syncRegister(ctx context.Context, info *ProviderInfo) error {
+	old := r.providers[info.AddrInfo.ID]
 	r.providers[info.AddrInfo.ID] = info
 	err := r.syncPersistProvider(ctx, info)
 	if err != nil {
 		err = fmt.Errorf("could not persist provider: %s", err)
 		return v0.NewError(err, http.StatusInternalServerError)
 	}
+	if old == nil {
+		r.changes.publish(ProviderRegistered, info.AddrInfo.ID, info)
+	} else if providerChanged(old, info) {
+		r.changes.publish(ProviderUpdated, info.AddrInfo.ID, info)
+	}
 	return nil
 }
 
@@ -1163,7 +1270,10 @@ func (r *Registry) pollProviders(poll polling, pollOverrides map[peer.ID]polling
 
 func (r *Registry) syncRemoveProvider(ctx context.Context, providerID peer.ID) error {
 	// Remove the provider from the registry.
-	delete(r.providers, providerID)
+	if _, ok := r.providers[providerID]; ok {
+		delete(r.providers, providerID)
+		r.changes.publish(ProviderRemoved, providerID, nil)
+	}
 
 	if r.dstore == nil {
 		return nil
@@ -1311,16 +1421,18 @@ func loadPersistedProviders(ctx context.Context, dstore datastore.Datastore, fil
 	return providers, nil
 }
 
-func loadPersistedAssignments(ctx context.Context, dstore datastore.Datastore, deleteOld bool) (map[peer.ID]peer.ID, error) {
+func loadPersistedAssignments(ctx context.Context, dstore datastore.Datastore, deleteOld, migrate bool) (map[peer.ID]peer.ID, error) {
 	assigned := make(map[peer.ID]peer.ID)
 
 	if dstore == nil {
 		return assigned, nil
 	}
 
-	err := migrateOldAssignments(ctx, dstore, oldAssignmentsKeyPath, deleteOld)
-	if err != nil {
-		return nil, err
+	if migrate {
+		err := migrateOldAssignments(ctx, dstore, oldAssignmentsKeyPath, deleteOld)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Load all assigned publishers from datastore.
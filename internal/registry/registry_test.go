@@ -81,6 +81,39 @@ func TestUpdateNewProvider(t *testing.T) {
 	r.Close()
 }
 
+func TestReadOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dstore := datastore.NewMapDatastore()
+	roReg, err := New(ctx, discoveryCfg, dstore, WithReadOnly(true))
+	require.NoError(t, err)
+	defer roReg.Close()
+
+	peerID, err := peer.Decode(limitedID)
+	require.NoError(t, err)
+	maddr, err := multiaddr.NewMultiaddr(minerAddr)
+	require.NoError(t, err)
+	provider := peer.AddrInfo{
+		ID:    peerID,
+		Addrs: []multiaddr.Multiaddr{maddr},
+	}
+
+	err = roReg.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0)
+	require.ErrorIs(t, err, ErrReadOnly)
+	require.ErrorIs(t, roReg.RemoveProvider(ctx, peerID), ErrReadOnly)
+
+	// Write a provider to the datastore using a writable registry.
+	r, err := New(ctx, discoveryCfg, dstore)
+	require.NoError(t, err)
+	require.NoError(t, r.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0))
+	r.Close()
+
+	require.False(t, roReg.IsRegistered(peerID))
+	require.NoError(t, roReg.Reload(ctx))
+	require.True(t, roReg.IsRegistered(peerID))
+}
+
 func TestDatastore(t *testing.T) {
 	dataStorePath := t.TempDir()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -658,6 +691,65 @@ func TestFilterIPs(t *testing.T) {
 	require.Equal(t, pubAddr, pinfo.AddrInfo.Addrs[0])
 }
 
+func TestAddrPolicy(t *testing.T) {
+	cfg := config.NewDiscovery()
+	cfg.Policy.Addrs.BlockDNSSuffixes = []string{"blocked.example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	provID, err := peer.Decode(limitedID)
+	require.NoError(t, err)
+	pubID, err := peer.Decode(publisherID)
+	require.NoError(t, err)
+
+	blockedAddr, err := multiaddr.NewMultiaddr("/dns4/blocked.example.com/tcp/12345")
+	require.NoError(t, err)
+	provAddr, err := multiaddr.NewMultiaddr("/dns4/provider.example.com/tcp/12345")
+	require.NoError(t, err)
+	pubAddr, err := multiaddr.NewMultiaddr("/dns4/publisher.example.com/tcp/9876")
+	require.NoError(t, err)
+
+	reg, err := New(ctx, cfg, datastore.NewMapDatastore())
+	require.NoError(t, err)
+	t.Cleanup(func() { reg.Close() })
+
+	// Check that update is rejected when all addresses are blocked.
+	provider := peer.AddrInfo{
+		ID:    provID,
+		Addrs: []multiaddr.Multiaddr{blockedAddr},
+	}
+	err = reg.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0)
+	require.ErrorIs(t, err, ErrAddrsNotAllowed)
+	pinfo, _ := reg.ProviderInfo(provID)
+	require.Nil(t, pinfo)
+
+	publisher := peer.AddrInfo{
+		ID:    pubID,
+		Addrs: []multiaddr.Multiaddr{blockedAddr},
+	}
+	provider.Addrs = []multiaddr.Multiaddr{provAddr}
+	err = reg.Update(ctx, provider, publisher, cid.Undef, nil, 0)
+	require.ErrorIs(t, err, ErrAddrsNotAllowed)
+
+	// Check that blocked addresses are stored, and that the first allowed
+	// publisher address is used.
+	provider.Addrs = []multiaddr.Multiaddr{blockedAddr, provAddr}
+	publisher.Addrs = []multiaddr.Multiaddr{blockedAddr, pubAddr}
+	err = reg.Update(ctx, provider, publisher, cid.Undef, nil, 0)
+	require.NoError(t, err)
+	pinfo, _ = reg.ProviderInfo(provID)
+	require.NotNil(t, pinfo)
+	require.Equal(t, provider.Addrs, pinfo.AddrInfo.Addrs)
+	require.Equal(t, pubAddr.String(), pinfo.PublisherAddr.String())
+	require.Equal(t, []multiaddr.Multiaddr{provAddr}, reg.FilterAddrs(pinfo.AddrInfo.Addrs))
+
+	// Check that the stored addresses are allowed after loosening policy.
+	cfg.Policy.Addrs.BlockDNSSuffixes = nil
+	require.NoError(t, reg.SetPolicy(cfg.Policy))
+	require.Equal(t, provider.Addrs, reg.FilterAddrs(pinfo.AddrInfo.Addrs))
+}
+
 func TestRegistry_loadPersistedProvidersFiltersNilAddrGracefully(t *testing.T) {
 	ctx := context.Background()
 	ds := datastore.NewMapDatastore()
@@ -875,6 +967,68 @@ func TestHandoff(t *testing.T) {
 	require.ErrorIs(t, r.AssignPeer(pubID2), ErrFrozen)
 }
 
+func TestPausePublisher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pubID, err := peer.Decode(publisherID)
+	require.NoError(t, err)
+	pubAddr, err := multiaddr.NewMultiaddr(publisherAddr)
+	require.NoError(t, err)
+	adCid, err := cid.Decode("bafybeigvgzoolc3drupxhlevdp2ugqcrbcsqfmcek2zxiw5wctk3xjpjwy")
+	require.NoError(t, err)
+
+	dstore := datastore.NewMapDatastore()
+	r, err := New(ctx, discoveryCfg, dstore)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+
+	// Announces are not recorded when the publisher is not paused.
+	recorded, err := r.RecordPausedAnnounce(pubID, adCid, nil)
+	require.NoError(t, err)
+	require.False(t, recorded)
+
+	paused, err := r.PausePublisher(pubID)
+	require.NoError(t, err)
+	require.True(t, paused)
+	require.True(t, r.PublisherPaused(pubID))
+	paused, err = r.PausePublisher(pubID)
+	require.NoError(t, err)
+	require.False(t, paused, "publisher should already be paused")
+
+	recorded, err = r.RecordPausedAnnounce(pubID, adCid, []multiaddr.Multiaddr{pubAddr})
+	require.NoError(t, err)
+	require.True(t, recorded)
+	r.Close()
+
+	// Check that paused state and recorded announce are loaded from datastore.
+	r, err = New(ctx, discoveryCfg, dstore)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	require.True(t, r.PublisherPaused(pubID))
+	pa, ok := r.PausedAnnounceFor(pubID)
+	require.True(t, ok)
+	require.Equal(t, adCid, pa.Cid)
+
+	pa, resumed, err := r.ResumePublisher(pubID)
+	require.NoError(t, err)
+	require.True(t, resumed)
+	require.Equal(t, adCid, pa.Cid)
+	require.Equal(t, []multiaddr.Multiaddr{pubAddr}, pa.Addrs)
+	require.False(t, r.PublisherPaused(pubID))
+
+	_, resumed, err = r.ResumePublisher(pubID)
+	require.NoError(t, err)
+	require.False(t, resumed)
+	r.Close()
+
+	// Check that resumed publisher is not paused after restart.
+	r, err = New(ctx, discoveryCfg, dstore)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	require.False(t, r.PublisherPaused(pubID))
+}
+
 func writeJsonResponse(w http.ResponseWriter, status int, body []byte) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
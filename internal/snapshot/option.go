@@ -0,0 +1,54 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// snapshotConfig contains all options for taking snapshots.
+type snapshotConfig struct {
+	dsAds          datastore.Batching
+	pause          func() func()
+	valueStoreType string
+}
+
+// Option is a function that sets a value in a snapshotConfig.
+type Option func(*snapshotConfig) error
+
+// getOpts creates a snapshotConfig and applies Options to it.
+func getOpts(opts []Option) (snapshotConfig, error) {
+	var cfg snapshotConfig
+	for i, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return snapshotConfig{}, fmt.Errorf("option %d error: %s", i, err)
+		}
+	}
+	return cfg, nil
+}
+
+// WithAdsDatastore sets the advertisements datastore to include in snapshots,
+// when it is separate from the indexer datastore.
+func WithAdsDatastore(dsAds datastore.Batching) Option {
+	return func(c *snapshotConfig) error {
+		c.dsAds = dsAds
+		return nil
+	}
+}
+
+// WithPause sets the function that pauses ingestion while a snapshot is
+// started. The function returned by pause resumes ingestion.
+func WithPause(pause func() func()) Option {
+	return func(c *snapshotConfig) error {
+		c.pause = pause
+		return nil
+	}
+}
+
+// WithValueStoreType sets the type of value store that snapshots are taken of.
+func WithValueStoreType(vsType string) Option {
+	return func(c *snapshotConfig) error {
+		c.valueStoreType = vsType
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// maxFieldSize limits the size of a field read from a snapshot file, so that
+// a corrupt length does not cause a huge allocation.
+const maxFieldSize = 64 << 20
+
+// Snapshot files are a sequence of records. Each record is a sequence of
+// fields, where each field is a uvarint length followed by that many bytes.
+// A datastore record is a key and a value. A value store record is a
+// multihash, a uvarint count of values, and the provider ID, context ID, and
+// metadata of each value.
+
+type recordWriter struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	return &recordWriter{
+		w: bufio.NewWriter(w),
+	}
+}
+
+func (rw *recordWriter) writeUvarint(x uint64) error {
+	n := binary.PutUvarint(rw.buf[:], x)
+	_, err := rw.w.Write(rw.buf[:n])
+	return err
+}
+
+func (rw *recordWriter) writeField(data []byte) error {
+	if err := rw.writeUvarint(uint64(len(data))); err != nil {
+		return err
+	}
+	_, err := rw.w.Write(data)
+	return err
+}
+
+func (rw *recordWriter) writeDatastoreRecord(key string, value []byte) error {
+	if err := rw.writeField([]byte(key)); err != nil {
+		return err
+	}
+	return rw.writeField(value)
+}
+
+func (rw *recordWriter) writeValueStoreRecord(mh multihash.Multihash, values []indexer.Value) error {
+	if err := rw.writeField(mh); err != nil {
+		return err
+	}
+	if err := rw.writeUvarint(uint64(len(values))); err != nil {
+		return err
+	}
+	for _, value := range values {
+		if err := rw.writeField([]byte(value.ProviderID)); err != nil {
+			return err
+		}
+		if err := rw.writeField(value.ContextID); err != nil {
+			return err
+		}
+		if err := rw.writeField(value.MetadataBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rw *recordWriter) flush() error {
+	return rw.w.Flush()
+}
+
+type recordReader struct {
+	r *bufio.Reader
+}
+
+func newRecordReader(r io.Reader) *recordReader {
+	return &recordReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+func (rr *recordReader) readUvarint() (uint64, error) {
+	return binary.ReadUvarint(rr.r)
+}
+
+// readField reads a field. Returns io.EOF only if there is no more data.
+func (rr *recordReader) readField() ([]byte, error) {
+	size, err := rr.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if size > maxFieldSize {
+		return nil, fmt.Errorf("field size %d exceeds maximum", size)
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(rr.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// readDatastoreRecord reads a key and value. Returns io.EOF if there are no
+// more records.
+func (rr *recordReader) readDatastoreRecord() (string, []byte, error) {
+	key, err := rr.readField()
+	if err != nil {
+		return "", nil, err
+	}
+	value, err := rr.readField()
+	if err != nil {
+		return "", nil, unexpectedEOF(err)
+	}
+	return string(key), value, nil
+}
+
+// readValueStoreRecord reads a multihash and its values. Returns io.EOF if
+// there are no more records.
+func (rr *recordReader) readValueStoreRecord() (multihash.Multihash, []indexer.Value, error) {
+	mh, err := rr.readField()
+	if err != nil {
+		return nil, nil, err
+	}
+	count, err := rr.readUvarint()
+	if err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+	values := make([]indexer.Value, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var fields [3][]byte
+		for j := range fields {
+			if fields[j], err = rr.readField(); err != nil {
+				return nil, nil, unexpectedEOF(err)
+			}
+		}
+		values = append(values, indexer.Value{
+			ProviderID:    peer.ID(fields[0]),
+			ContextID:     fields[1],
+			MetadataBytes: fields[2],
+		})
+	}
+	return multihash.Multihash(mh), values, nil
+}
+
+// unexpectedEOF converts io.EOF into io.ErrUnexpectedEOF, for data that ends
+// in the middle of a record.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/ipfs/go-datastore"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/storetheindex/command/migratevs"
+	"github.com/ipni/storetheindex/filestore"
+)
+
+const (
+	restoreDatastoreBatchSize  = 1024
+	restoreValueStoreBatchSize = 16384
+)
+
+// ReadManifest reads the manifest of the named snapshot from the file store.
+func ReadManifest(ctx context.Context, src filestore.Interface, name string) (*Manifest, error) {
+	_, rc, err := src.Get(ctx, path.Join(name, ManifestFile))
+	if err != nil {
+		if errors.Is(err, filestore.ErrNotFound) {
+			return nil, fmt.Errorf("snapshot %s not found or incomplete", name)
+		}
+		return nil, fmt.Errorf("cannot read snapshot manifest: %w", err)
+	}
+	defer rc.Close()
+
+	var manifest Manifest
+	if err = json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot manifest: %w", err)
+	}
+	if manifest.File(KindDatastore) == nil || manifest.File(KindValueStore) == nil {
+		return nil, errors.New("snapshot manifest is missing files")
+	}
+	return &manifest, nil
+}
+
+// Latest returns the name of the newest complete snapshot in the file store,
+// or an empty string if there are no complete snapshots.
+func Latest(ctx context.Context, src filestore.Interface) (string, error) {
+	var latest string
+	files, errs := src.List(ctx, "", true)
+	for file := range files {
+		dir, fileName := path.Split(file.Path)
+		if fileName != ManifestFile {
+			continue
+		}
+		// Snapshot names sort by the time they were taken.
+		if name := path.Clean(dir); name > latest && name != "." {
+			latest = name
+		}
+	}
+	if err := <-errs; err != nil {
+		return "", fmt.Errorf("cannot list snapshots: %w", err)
+	}
+	return latest, nil
+}
+
+// Restore writes the data of the snapshot described by manifest into the
+// datastores and value store. If dsAds is nil, then advertisements are
+// restored into ds. The checksum, size, and number of records of each file
+// are verified against the manifest. If verification fails, then the data
+// that was restored must be discarded.
+func Restore(ctx context.Context, src filestore.Interface, manifest *Manifest, ds, dsAds datastore.Batching, valueStore indexer.Interface) error {
+	err := restoreFile(ctx, src, manifest.File(KindDatastore), func(rr *recordReader) (uint64, error) {
+		return restoreDatastore(ctx, rr, ds)
+	})
+	if err != nil {
+		return err
+	}
+
+	if adsFile := manifest.File(KindAdsDatastore); adsFile != nil {
+		if dsAds == nil {
+			dsAds = ds
+		}
+		err = restoreFile(ctx, src, adsFile, func(rr *recordReader) (uint64, error) {
+			return restoreDatastore(ctx, rr, dsAds)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = restoreFile(ctx, src, manifest.File(KindValueStore), func(rr *recordReader) (uint64, error) {
+		return restoreValueStore(ctx, rr, valueStore)
+	})
+	if err != nil {
+		return err
+	}
+	return valueStore.Flush()
+}
+
+// restoreFile reads the records of a snapshot file using readRecords, and
+// verifies the file against its manifest information.
+func restoreFile(ctx context.Context, src filestore.Interface, info *FileInfo, readRecords func(*recordReader) (uint64, error)) error {
+	log.Infow("Restoring snapshot file", "path", info.Path, "size", info.Size, "records", info.Records)
+
+	_, rc, err := src.Get(ctx, info.Path)
+	if err != nil {
+		return fmt.Errorf("cannot read snapshot %s: %w", info.Kind, err)
+	}
+	defer rc.Close()
+
+	hasher := sha256.New()
+	counter := &countWriter{}
+	r := io.TeeReader(rc, io.MultiWriter(hasher, counter))
+
+	records, err := readRecords(newRecordReader(r))
+	if err != nil {
+		return fmt.Errorf("cannot restore snapshot %s: %w", info.Kind, err)
+	}
+	// Read any remaining data so that it is included in the checksum.
+	if _, err = io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("cannot read snapshot %s: %w", info.Kind, err)
+	}
+
+	if counter.n != info.Size {
+		return fmt.Errorf("snapshot %s has size %d, expected %d", info.Kind, counter.n, info.Size)
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != info.SHA256 {
+		return fmt.Errorf("snapshot %s checksum mismatch", info.Kind)
+	}
+	if records != info.Records {
+		return fmt.Errorf("snapshot %s has %d records, expected %d", info.Kind, records, info.Records)
+	}
+	return nil
+}
+
+func restoreDatastore(ctx context.Context, rr *recordReader, ds datastore.Batching) (uint64, error) {
+	batch, err := ds.Batch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	for {
+		key, value, err := rr.readDatastoreRecord()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return count, err
+		}
+		if err = batch.Put(ctx, datastore.NewKey(key), value); err != nil {
+			return count, err
+		}
+		count++
+		if count%restoreDatastoreBatchSize == 0 {
+			if err = batch.Commit(ctx); err != nil {
+				return count, err
+			}
+			if batch, err = ds.Batch(ctx); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err = batch.Commit(ctx); err != nil {
+		return count, err
+	}
+	return count, ds.Sync(ctx, datastore.NewKey(""))
+}
+
+func restoreValueStore(ctx context.Context, rr *recordReader, valueStore indexer.Interface) (uint64, error) {
+	batch := migratevs.NewBatch()
+	var count uint64
+	for {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		mh, values, err := rr.readValueStoreRecord()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return count, err
+		}
+		batch.Add(mh, values)
+		count++
+		if batch.Len() >= restoreValueStoreBatchSize {
+			if err = batch.Write(valueStore); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, batch.Write(valueStore)
+}
@@ -0,0 +1,315 @@
+// Package snapshot writes consistent snapshots of an indexer's state to a
+// file store, and restores an indexer's state from a snapshot.
+//
+// A snapshot contains the indexer's datastore, its advertisements datastore if
+// separate, and all multihashes and values in its value store. The files of a
+// snapshot are tied together by a manifest that records the size and checksum
+// of each file.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/storetheindex/filestore"
+	"github.com/ipni/storetheindex/version"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("indexer/snapshot")
+
+const (
+	// KindDatastore identifies the file containing the indexer datastore.
+	KindDatastore = "datastore"
+	// KindAdsDatastore identifies the file containing the advertisements
+	// datastore.
+	KindAdsDatastore = "ads-datastore"
+	// KindValueStore identifies the file containing the value store.
+	KindValueStore = "valuestore"
+
+	// ManifestFile is the name of the manifest file within a snapshot.
+	ManifestFile = "manifest.json"
+
+	// consistentValueStore is the value store type that iterates over a
+	// point-in-time view of its data.
+	consistentValueStore = "pebble"
+)
+
+// ErrInProgress is returned when a snapshot is requested while another is
+// being taken.
+var ErrInProgress = errors.New("snapshot already in progress")
+
+// Manifest describes the files of a snapshot.
+type Manifest struct {
+	// Name is the name of the snapshot, which is also the directory in the
+	// file store that contains the snapshot files.
+	Name string
+	// Created is the time the snapshot was started.
+	Created time.Time
+	// IndexerID is the peer ID of the indexer that the snapshot was taken
+	// from.
+	IndexerID peer.ID
+	// Version is the version of the indexer that took the snapshot.
+	Version string
+	// ValueStoreType is the type of value store that the snapshot was taken
+	// from. A snapshot can be restored into any type of value store.
+	ValueStoreType string
+	// Files are the snapshot data files.
+	Files []FileInfo
+}
+
+// FileInfo describes one file in a snapshot.
+type FileInfo struct {
+	// Kind is the kind of data in the file.
+	Kind string
+	// Path is the path of the file in the file store.
+	Path string
+	// Size is the number of bytes in the file.
+	Size int64
+	// SHA256 is the hex encoded SHA-256 checksum of the file.
+	SHA256 string
+	// Records is the number of records in the file.
+	Records uint64
+}
+
+// File returns information about the file of the given kind, or nil if the
+// snapshot has no file of that kind.
+func (m *Manifest) File(kind string) *FileInfo {
+	for i := range m.Files {
+		if m.Files[i].Kind == kind {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// Snapshotter takes snapshots of an indexer's state.
+type Snapshotter struct {
+	dest       filestore.Interface
+	ds         datastore.Batching
+	indexerID  peer.ID
+	valueStore indexer.Interface
+	opts       snapshotConfig
+
+	// inProgress allows only one snapshot at a time.
+	inProgress sync.Mutex
+}
+
+// New creates a Snapshotter that writes snapshots of the datastore and value
+// store to the file store dest.
+func New(dest filestore.Interface, indexerID peer.ID, ds datastore.Batching, valueStore indexer.Interface, options ...Option) (*Snapshotter, error) {
+	if dest == nil {
+		return nil, errors.New("snapshot destination not configured")
+	}
+	opts, err := getOpts(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshotter{
+		dest:       dest,
+		ds:         ds,
+		indexerID:  indexerID,
+		valueStore: valueStore,
+		opts:       opts,
+	}, nil
+}
+
+// NewName returns a snapshot name based on the given time.
+func NewName(t time.Time) string {
+	return "snapshot-" + t.UTC().Format("20060102T150405Z")
+}
+
+// Snapshot writes a snapshot with the given name, and returns its manifest.
+//
+// Ingestion is paused while iterators over the datastores and value store are
+// opened. The datastores and a pebble value store iterate over a point-in-time
+// view of their data, so ingestion resumes while data is written. Other types
+// of value store are not consistent while being written to, so ingestion stays
+// paused until the value store is written.
+func (s *Snapshotter) Snapshot(ctx context.Context, name string) (*Manifest, error) {
+	if !s.inProgress.TryLock() {
+		return nil, ErrInProgress
+	}
+	defer s.inProgress.Unlock()
+
+	manifest := &Manifest{
+		Name:           name,
+		Created:        time.Now().UTC(),
+		IndexerID:      s.indexerID,
+		Version:        version.String(),
+		ValueStoreType: s.opts.valueStoreType,
+	}
+
+	resume := func() {}
+	if s.opts.pause != nil {
+		var once sync.Once
+		resumeIngest := s.opts.pause()
+		resume = func() { once.Do(resumeIngest) }
+	}
+	defer resume()
+
+	if err := s.valueStore.Flush(); err != nil {
+		return nil, fmt.Errorf("cannot flush value store: %w", err)
+	}
+
+	dsResults, err := s.ds.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot query datastore: %w", err)
+	}
+	defer dsResults.Close()
+
+	var adsResults query.Results
+	if s.opts.dsAds != nil {
+		adsResults, err = s.opts.dsAds.Query(ctx, query.Query{})
+		if err != nil {
+			return nil, fmt.Errorf("cannot query advertisements datastore: %w", err)
+		}
+		defer adsResults.Close()
+	}
+
+	vsIter, err := s.valueStore.Iter()
+	if err != nil {
+		return nil, fmt.Errorf("cannot iterate value store: %w", err)
+	}
+	defer vsIter.Close()
+
+	if s.opts.valueStoreType == consistentValueStore {
+		resume()
+	}
+
+	log.Infow("Writing snapshot", "name", name)
+
+	info, err := s.writeFile(ctx, name, KindDatastore, func(rw *recordWriter) (uint64, error) {
+		return writeDatastore(rw, dsResults)
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, info)
+
+	if adsResults != nil {
+		info, err = s.writeFile(ctx, name, KindAdsDatastore, func(rw *recordWriter) (uint64, error) {
+			return writeDatastore(rw, adsResults)
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, info)
+	}
+
+	info, err = s.writeFile(ctx, name, KindValueStore, func(rw *recordWriter) (uint64, error) {
+		return writeValueStore(ctx, rw, vsIter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, info)
+	resume()
+
+	// Write the manifest last, so that a snapshot without a manifest is known
+	// to be incomplete.
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.dest.Put(ctx, path.Join(name, ManifestFile), bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("cannot write snapshot manifest: %w", err)
+	}
+	log.Infow("Finished writing snapshot", "name", name, "elapsed", time.Since(manifest.Created))
+
+	return manifest, nil
+}
+
+// writeFile streams the records written by writeRecords to a file in the file
+// store, and returns the file information for the manifest.
+func (s *Snapshotter) writeFile(ctx context.Context, name, kind string, writeRecords func(*recordWriter) (uint64, error)) (FileInfo, error) {
+	info := FileInfo{
+		Kind: kind,
+		Path: path.Join(name, kind),
+	}
+
+	pr, pw := io.Pipe()
+	hasher := sha256.New()
+	counter := &countWriter{}
+	rw := newRecordWriter(io.MultiWriter(pw, hasher, counter))
+
+	recordsChan := make(chan uint64, 1)
+	go func() {
+		records, err := writeRecords(rw)
+		if err == nil {
+			err = rw.flush()
+		}
+		recordsChan <- records
+		pw.CloseWithError(err)
+	}()
+
+	_, err := s.dest.Put(ctx, info.Path, pr)
+	// Unblock the writer if Put returned before reading all data.
+	pr.CloseWithError(io.ErrClosedPipe)
+	info.Records = <-recordsChan
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("cannot write snapshot %s: %w", kind, err)
+	}
+
+	info.Size = counter.n
+	info.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	log.Infow("Wrote snapshot file", "path", info.Path, "size", info.Size, "records", info.Records)
+	return info, nil
+}
+
+func writeDatastore(rw *recordWriter, results query.Results) (uint64, error) {
+	var count uint64
+	for r := range results.Next() {
+		if r.Error != nil {
+			return count, fmt.Errorf("cannot read datastore: %w", r.Error)
+		}
+		if err := rw.writeDatastoreRecord(r.Key, r.Value); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func writeValueStore(ctx context.Context, rw *recordWriter, iter indexer.Iterator) (uint64, error) {
+	var count uint64
+	for {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		mh, values, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return count, nil
+			}
+			return count, fmt.Errorf("cannot read value store: %w", err)
+		}
+		if err = rw.writeValueStoreRecord(mh, values); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// countWriter counts the bytes written to it.
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,147 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/go-indexer-core/store/memory"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/filestore"
+	"github.com/ipni/storetheindex/internal/snapshot"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+var indexerID, _ = peer.Decode("12D3KooWQ9j3Ur5V9U63Vi6ved72TcA3sv34k74W3wpW5rwNvDc3")
+
+func newFileStore(t *testing.T) filestore.Interface {
+	fs, err := filestore.New(config.FileStore{
+		Type: "local",
+		Local: config.LocalFileStore{
+			BasePath: t.TempDir(),
+		},
+	})
+	require.NoError(t, err)
+	return fs
+}
+
+func populate(t *testing.T, ds, dsAds datastore.Datastore, vs indexer.Interface) {
+	ctx := context.Background()
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("/sync/pub1"), []byte("cid1")))
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("/registry/prov1"), []byte("info1")))
+	require.NoError(t, dsAds.Put(ctx, datastore.NewKey("/bafyad1"), []byte("ad1")))
+
+	mhs := util.RandomMultihashes(40, rand.New(rand.NewSource(1413)))
+	valueA := indexer.Value{ProviderID: peer.ID("provider-a"), ContextID: []byte("ctx-a"), MetadataBytes: []byte("meta-a")}
+	valueB := indexer.Value{ProviderID: peer.ID("provider-b"), ContextID: []byte("ctx-b"), MetadataBytes: []byte("meta-b")}
+	require.NoError(t, vs.Put(valueA, mhs[:25]...))
+	require.NoError(t, vs.Put(valueB, mhs[15:]...))
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	fs := newFileStore(t)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	dsAds := dssync.MutexWrap(datastore.NewMapDatastore())
+	vs := memory.New()
+	populate(t, ds, dsAds, vs)
+
+	var paused, resumed int
+	pause := func() func() {
+		paused++
+		return func() { resumed++ }
+	}
+
+	snapper, err := snapshot.New(fs, indexerID, ds, vs,
+		snapshot.WithAdsDatastore(dsAds),
+		snapshot.WithPause(pause),
+		snapshot.WithValueStoreType("memory"))
+	require.NoError(t, err)
+
+	manifest, err := snapper.Snapshot(ctx, "snap1")
+	require.NoError(t, err)
+	require.Equal(t, 1, paused)
+	require.Equal(t, 1, resumed)
+	require.Equal(t, indexerID, manifest.IndexerID)
+	require.Len(t, manifest.Files, 3)
+	require.Equal(t, uint64(2), manifest.File(snapshot.KindDatastore).Records)
+	require.Equal(t, uint64(1), manifest.File(snapshot.KindAdsDatastore).Records)
+	require.Equal(t, uint64(40), manifest.File(snapshot.KindValueStore).Records)
+
+	readManifest, err := snapshot.ReadManifest(ctx, fs, "snap1")
+	require.NoError(t, err)
+	require.Equal(t, manifest.Files, readManifest.Files)
+
+	// Restore into a single datastore.
+	newDS := dssync.MutexWrap(datastore.NewMapDatastore())
+	newVS := memory.New()
+	require.NoError(t, snapshot.Restore(ctx, fs, readManifest, newDS, nil, newVS))
+
+	for _, key := range []string{"/sync/pub1", "/registry/prov1", "/bafyad1"} {
+		has, err := newDS.Has(ctx, datastore.NewKey(key))
+		require.NoError(t, err)
+		require.True(t, has, key)
+	}
+
+	iter, err := vs.Iter()
+	require.NoError(t, err)
+	for {
+		mh, values, err := iter.Next()
+		if err != nil {
+			break
+		}
+		restored, found, err := newVS.Get(mh)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.ElementsMatch(t, values, restored)
+	}
+
+	latest, err := snapshot.Latest(ctx, fs)
+	require.NoError(t, err)
+	require.Equal(t, "snap1", latest)
+
+	_, err = snapshot.ReadManifest(ctx, fs, "missing")
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	ctx := context.Background()
+	fs := newFileStore(t)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	vs := memory.New()
+	populate(t, ds, ds, vs)
+
+	snapper, err := snapshot.New(fs, indexerID, ds, vs)
+	require.NoError(t, err)
+	manifest, err := snapper.Snapshot(ctx, "snap1")
+	require.NoError(t, err)
+	require.Nil(t, manifest.File(snapshot.KindAdsDatastore))
+
+	// Replace a byte in the datastore file.
+	info := manifest.File(snapshot.KindDatastore)
+	_, rc, err := fs.Get(ctx, info.Path)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(rc)
+	require.NoError(t, err)
+	rc.Close()
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+	_, err = fs.Put(ctx, info.Path, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	newDS := dssync.MutexWrap(datastore.NewMapDatastore())
+	err = snapshot.Restore(ctx, fs, manifest, newDS, nil, memory.New())
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestSnapshotNoDestination(t *testing.T) {
+	_, err := snapshot.New(nil, indexerID, datastore.NewMapDatastore(), memory.New())
+	require.Error(t, err)
+}
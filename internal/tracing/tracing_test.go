@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestStartOTLP(t *testing.T) {
+	var mutex sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	cfg := config.NewTracing()
+	cfg.Exporter = ExporterOTLP
+	cfg.Endpoint = srv.URL + "/collector"
+	stop, err := Start(cfg)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "test")
+	span.End()
+	require.NoError(t, stop(context.Background()))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, []string{"/collector" + otlpTracesPath}, paths)
+}
+
+func TestStartBadConfig(t *testing.T) {
+	cfg := config.NewTracing()
+	cfg.Exporter = ExporterOTLP
+	cfg.Endpoint = "ftp://localhost:4318"
+	_, err := Start(cfg)
+	require.ErrorContains(t, err, "http or https")
+
+	cfg.Exporter = "carrier-pigeon"
+	_, err = Start(cfg)
+	require.ErrorContains(t, err, "unknown trace exporter")
+
+	cfg.Exporter = ExporterStdout
+	cfg.SampleRatio = 2
+	_, err = Start(cfg)
+	require.ErrorContains(t, err, "sample ratio")
+}
@@ -45,8 +45,11 @@ func main() {
 			command.InitCmd,
 			command.LoadtestCmd,
 			command.LogCmd,
+			command.MigrateValueStoreCmd,
 			command.ProvidersCmd,
+			command.RestoreCmd,
 			command.SPAddrCmd,
+			command.VerifyChainCmd,
 		},
 	}
 
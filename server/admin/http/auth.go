@@ -0,0 +1,115 @@
+package adminserver
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ipni/storetheindex/config"
+)
+
+// role is the level of access granted to an admin request. Each role is
+// allowed everything that a lesser role is allowed.
+type role int
+
+const (
+	roleNone role = iota
+	roleReadOnly
+	roleOperator
+	roleSuperuser
+)
+
+func parseRole(name string) (role, error) {
+	switch name {
+	case config.AdminRoleReadOnly:
+		return roleReadOnly, nil
+	case config.AdminRoleOperator:
+		return roleOperator, nil
+	case config.AdminRoleSuperuser:
+		return roleSuperuser, nil
+	}
+	return roleNone, fmt.Errorf("unknown admin role %q", name)
+}
+
+// authorizer checks that a request has a bearer token with a role that allows
+// the request. Tokens are kept as hashes so that looking up a token does not
+// reveal anything about configured tokens through timing.
+type authorizer struct {
+	mutex  sync.RWMutex
+	tokens map[[sha256.Size]byte]role
+}
+
+func newAuthorizer(tokens []config.AdminToken) (*authorizer, error) {
+	a := &authorizer{}
+	if err := a.setTokens(tokens); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// setTokens replaces the set of accepted tokens. If there are no tokens, then
+// all requests are allowed.
+func (a *authorizer) setTokens(tokens []config.AdminToken) error {
+	tokenRoles := make(map[[sha256.Size]byte]role, len(tokens))
+	for i, t := range tokens {
+		if t.Token == "" {
+			return fmt.Errorf("admin token %d is empty", i)
+		}
+		r, err := parseRole(t.Role)
+		if err != nil {
+			return fmt.Errorf("admin token %d: %w", i, err)
+		}
+		tokenRoles[sha256.Sum256([]byte(t.Token))] = r
+	}
+	a.mutex.Lock()
+	a.tokens = tokenRoles
+	a.mutex.Unlock()
+	return nil
+}
+
+// requestRole returns the role of the token in the request. If no tokens are
+// configured, then every request has the superuser role.
+func (a *authorizer) requestRole(r *http.Request) (role, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.tokens) == 0 {
+		return roleSuperuser, nil
+	}
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return roleNone, errors.New("missing bearer token")
+	}
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return roleNone, errors.New("authorization is not a bearer token")
+	}
+	tokenRole, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(token)))]
+	if !ok {
+		return roleNone, errors.New("invalid bearer token")
+	}
+	return tokenRole, nil
+}
+
+// allow wraps a handler so that it is only called for requests that have at
+// least the needed role.
+func (a *authorizer) allow(need role, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		have, err := a.requestRole(r)
+		if err != nil {
+			log.Infow("Rejected unauthenticated admin request", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", err)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if have < need {
+			log.Infow("Rejected unauthorized admin request", "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "token role does not allow this request", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,152 @@
+package adminserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ipni/storetheindex/api/v0/admin/model"
+	"github.com/ipni/storetheindex/internal/httpserver"
+	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ingestEvents streams the results of processing advertisements as
+// newline-delimited JSON. If the "publisher" query parameter is given, then
+// only events for advertisements from that publisher are streamed.
+//
+// The stream ends before the server write timeout. Events that happen before a
+// client makes another request are not delivered.
+func (h *adminHandler) ingestEvents(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	if h.ingester == nil {
+		log.Warn("ingest events not available, ingester disabled")
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var publisher peer.ID
+	if pubStr := r.URL.Query().Get("publisher"); pubStr != "" {
+		var ok bool
+		publisher, ok = decodePeerID(pubStr, w)
+		if !ok {
+			return
+		}
+	}
+
+	events, cancel := h.ingester.OnAdEvent(publisher)
+	defer cancel()
+
+	stream, err := newNDJSONStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	endStream, stopTimer := streamTimer(h.writeTimeout)
+	defer stopTimer()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			ingestEvent := model.IngestEvent{
+				Publisher:      event.Publisher,
+				Provider:       event.Provider,
+				AdCid:          event.AdCid,
+				Status:         string(event.Status),
+				MultihashCount: event.MultihashCount,
+				Elapsed:        event.Elapsed,
+				Time:           event.Time,
+			}
+			if event.Err != nil {
+				ingestEvent.Error = event.Err.Error()
+			}
+			if err = stream.write(ingestEvent); err != nil {
+				log.Debugw("Cannot write ingest event", "err", err)
+				return
+			}
+		case <-endStream:
+			return
+		case <-r.Context().Done():
+			return
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+// registryEvents streams registry changes as newline-delimited JSON. If the
+// "since" query parameter is given, then the stream starts with the changes
+// after that sequence number.
+//
+// The stream ends before the server write timeout. A client continues
+// receiving changes by making another request, using the sequence number of
+// the last change received, or the sequence number in the ChangeSeqHeader if
+// no changes were received.
+func (h *adminHandler) registryEvents(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	since := registry.ChangesFromNow
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since value", http.StatusBadRequest)
+			return
+		}
+	}
+	changes, since, cancel, err := h.reg.OnChange(since)
+	if err != nil {
+		if errors.Is(err, registry.ErrCannotResume) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set(model.ChangeSeqHeader, strconv.FormatUint(since, 10))
+	stream, err := newNDJSONStream(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	endStream, stopTimer := streamTimer(h.writeTimeout)
+	defer stopTimer()
+
+	for {
+		select {
+		case event, ok := <-changes:
+			if !ok {
+				return
+			}
+			change := model.ProviderChange{
+				Seq:        event.Seq,
+				Kind:       string(event.Kind),
+				ProviderID: event.ProviderID,
+				Time:       event.Time,
+			}
+			if event.Info != nil {
+				change.Provider = registry.RegToApiProviderInfo(event.Info, 0)
+			}
+			if err = stream.write(change); err != nil {
+				log.Debugw("Cannot write registry change", "err", err)
+				return
+			}
+		case <-endStream:
+			return
+		case <-r.Context().Done():
+			return
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
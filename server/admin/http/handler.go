@@ -12,6 +12,7 @@ import (
 	"path"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ipni/go-indexer-core"
 	"github.com/ipni/storetheindex/api/v0/admin/model"
@@ -19,6 +20,7 @@ import (
 	"github.com/ipni/storetheindex/internal/importer"
 	"github.com/ipni/storetheindex/internal/ingest"
 	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/ipni/storetheindex/internal/snapshot"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
@@ -32,9 +34,16 @@ type adminHandler struct {
 	reg           *registry.Registry
 	reloadErrChan chan<- chan error
 	pendingSyncs  sync.WaitGroup
+	writeTimeout  time.Duration
+
+	readOnly bool
+
+	snapshotter    *snapshot.Snapshotter
+	snapshotStatus *model.SnapshotStatus
+	snapshotMutex  sync.Mutex
 }
 
-func newHandler(ctx context.Context, id peer.ID, indexer indexer.Interface, ingester *ingest.Ingester, reg *registry.Registry, reloadErrChan chan<- chan error) *adminHandler {
+func newHandler(ctx context.Context, id peer.ID, indexer indexer.Interface, ingester *ingest.Ingester, reg *registry.Registry, reloadErrChan chan<- chan error, writeTimeout time.Duration) *adminHandler {
 	return &adminHandler{
 		ctx:           ctx,
 		id:            id,
@@ -42,6 +51,7 @@ func newHandler(ctx context.Context, id peer.ID, indexer indexer.Interface, inge
 		ingester:      ingester,
 		reg:           reg,
 		reloadErrChan: reloadErrChan,
+		writeTimeout:  writeTimeout,
 	}
 }
 
@@ -250,6 +260,50 @@ func (h *adminHandler) blockPeer(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (h *adminHandler) pausePeer(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPut) {
+		return
+	}
+
+	if h.ingester == nil {
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	peerID, ok := decodePeerID(path.Base(r.URL.Path), w)
+	if !ok {
+		return
+	}
+	if err := h.ingester.PausePublisher(peerID); err != nil {
+		log.Errorw("Cannot pause publisher", "err", err, "publisher", peerID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *adminHandler) resumePeer(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPut) {
+		return
+	}
+
+	if h.ingester == nil {
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	peerID, ok := decodePeerID(path.Base(r.URL.Path), w)
+	if !ok {
+		return
+	}
+	if err := h.ingester.ResumePublisher(peerID); err != nil {
+		log.Errorw("Cannot resume publisher", "err", err, "publisher", peerID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *adminHandler) sync(w http.ResponseWriter, r *http.Request) {
 	if !httpserver.MethodOK(w, r, http.MethodPost) {
 		return
@@ -267,6 +321,11 @@ func (h *adminHandler) sync(w http.ResponseWriter, r *http.Request) {
 	}
 	log := log.With("peerID", peerID)
 
+	if h.ingester.PublisherPaused(peerID) {
+		http.Error(w, ingest.ErrPublisherPaused.Error(), http.StatusConflict)
+		return
+	}
+
 	query := r.URL.Query()
 	var depth int64
 	depthStr := query.Get("depth")
@@ -316,6 +375,12 @@ func (h *adminHandler) sync(w http.ResponseWriter, r *http.Request) {
 		log = log.With("address", syncAddr)
 	}
 
+	if h.ingester.Draining() {
+		log.Warn("Cannot sync with peer while draining")
+		http.Error(w, ingest.ErrDraining.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
 	log.Info("Syncing with peer")
 
 	// Start the sync, but do not wait for it to complete.
@@ -335,6 +400,40 @@ func (h *adminHandler) sync(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (h *adminHandler) recountProvider(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPost) {
+		return
+	}
+
+	if h.ingester == nil {
+		log.Warn("recount not available, ingester disabled")
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	providerID, ok := decodePeerID(path.Base(r.URL.Path), w)
+	if !ok {
+		return
+	}
+	log := log.With("provider", providerID)
+
+	log.Info("Recounting provider indexes")
+
+	// Start the recount, but do not wait for it to complete, since it requires
+	// syncing the provider's advertisement chain and entries.
+	h.pendingSyncs.Add(1)
+	go func() {
+		_, _, err := h.ingester.RecountProvider(h.ctx, providerID)
+		if err != nil {
+			log.Errorw("Cannot recount provider indexes", "err", err)
+		}
+		h.pendingSyncs.Done()
+	}()
+
+	// Return (202) Accepted
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func (h *adminHandler) importProviders(w http.ResponseWriter, r *http.Request) {
 	if !httpserver.MethodOK(w, r, http.MethodPost) {
 		return
@@ -598,6 +697,110 @@ func (h *adminHandler) freeze(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func (h *adminHandler) drain(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPut) {
+		return
+	}
+	if h.ingester == nil {
+		log.Warn("drain not available, ingester disabled")
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+	h.ingester.Drain()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *adminHandler) undrain(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPut) {
+		return
+	}
+	if h.ingester == nil {
+		log.Warn("undrain not available, ingester disabled")
+		http.Error(w, "ingester disabled", http.StatusServiceUnavailable)
+		return
+	}
+	h.ingester.Undrain()
+	w.WriteHeader(http.StatusOK)
+}
+
+// readOnlyHandler rejects requests that change the state of a read-only
+// indexer.
+func readOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "indexer is read-only", http.StatusForbidden)
+}
+
+// snapshot starts a snapshot of the indexer's state in the background.
+func (h *adminHandler) snapshot(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodPut) {
+		return
+	}
+	if h.snapshotter == nil {
+		http.Error(w, "snapshot destination not configured", http.StatusBadRequest)
+		return
+	}
+
+	h.snapshotMutex.Lock()
+	defer h.snapshotMutex.Unlock()
+
+	if h.snapshotStatus != nil && h.snapshotStatus.Finished.IsZero() {
+		http.Error(w, snapshot.ErrInProgress.Error(), http.StatusConflict)
+		return
+	}
+	started := time.Now().UTC()
+	status := &model.SnapshotStatus{
+		Name:    snapshot.NewName(started),
+		Started: started,
+	}
+	h.snapshotStatus = status
+	log.Infow("Starting snapshot", "name", status.Name)
+
+	// Take the snapshot in the background, since it may take a long time.
+	h.pendingSyncs.Add(1)
+	go func() {
+		defer h.pendingSyncs.Done()
+		_, err := h.snapshotter.Snapshot(h.ctx, status.Name)
+		if err != nil {
+			log.Errorw("Cannot take snapshot", "name", status.Name, "err", err)
+		}
+		h.snapshotMutex.Lock()
+		status.Finished = time.Now().UTC()
+		if err != nil {
+			status.Error = err.Error()
+		}
+		h.snapshotMutex.Unlock()
+	}()
+
+	h.writeSnapshotStatus(w, http.StatusAccepted)
+}
+
+// getSnapshotStatus gets the status of the latest snapshot.
+func (h *adminHandler) getSnapshotStatus(w http.ResponseWriter, r *http.Request) {
+	if !httpserver.MethodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	h.snapshotMutex.Lock()
+	defer h.snapshotMutex.Unlock()
+
+	if h.snapshotStatus == nil {
+		http.Error(w, "no snapshot taken", http.StatusNotFound)
+		return
+	}
+	h.writeSnapshotStatus(w, http.StatusOK)
+}
+
+// writeSnapshotStatus writes the snapshot status. Must be called with
+// snapshotMutex held.
+func (h *adminHandler) writeSnapshotStatus(w http.ResponseWriter, code int) {
+	data, err := json.Marshal(h.snapshotStatus)
+	if err != nil {
+		log.Errorw("Error marshaling snapshot status", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	httpserver.WriteJsonResponse(w, code, data)
+}
+
 func (h *adminHandler) status(w http.ResponseWriter, r *http.Request) {
 	if !httpserver.MethodOK(w, r, http.MethodGet) {
 		return
@@ -613,9 +816,14 @@ func (h *adminHandler) status(w http.ResponseWriter, r *http.Request) {
 	}
 
 	status := model.Status{
-		Frozen: h.reg.Frozen(),
-		ID:     h.id,
-		Usage:  usage,
+		Frozen:   h.reg.Frozen(),
+		ID:       h.id,
+		ReadOnly: h.readOnly,
+		Usage:    usage,
+	}
+	if h.ingester != nil {
+		status.Draining = h.ingester.Draining()
+		status.Drained = h.ingester.Drained()
 	}
 
 	data, err := json.Marshal(status)
@@ -3,6 +3,9 @@ package adminserver
 import (
 	"fmt"
 	"time"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/snapshot"
 )
 
 const (
@@ -10,25 +13,28 @@ const (
 	defaultReadTimeout  = 30 * time.Second
 )
 
-// config contains all options for the server.
-type config struct {
+// serverConfig contains all options for the server.
+type serverConfig struct {
+	readOnly     bool
 	readTimeout  time.Duration
+	snapshotter  *snapshot.Snapshotter
+	tokens       []config.AdminToken
 	writeTimeout time.Duration
 }
 
 // Option is a function that sets a value in a config.
-type Option func(*config) error
+type Option func(*serverConfig) error
 
 // getOpts creates a config and applies Options to it.
-func getOpts(opts []Option) (config, error) {
-	cfg := config{
+func getOpts(opts []Option) (serverConfig, error) {
+	cfg := serverConfig{
 		readTimeout:  defaultReadTimeout,
 		writeTimeout: defaultWriteTimeout,
 	}
 
 	for i, opt := range opts {
 		if err := opt(&cfg); err != nil {
-			return config{}, fmt.Errorf("option %d error: %s", i, err)
+			return serverConfig{}, fmt.Errorf("option %d error: %s", i, err)
 		}
 	}
 	return cfg, nil
@@ -36,7 +42,7 @@ func getOpts(opts []Option) (config, error) {
 
 // WithReadTimeout configures server read timeout.
 func WithReadTimeout(t time.Duration) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.readTimeout = t
 		return nil
 	}
@@ -44,8 +50,36 @@ func WithReadTimeout(t time.Duration) Option {
 
 // WithWriteTimeout configures server write timeout.
 func WithWriteTimeout(t time.Duration) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.writeTimeout = t
 		return nil
 	}
 }
+
+// WithReadOnly disables requests that change the indexer's state, for a
+// read-only finder replica.
+func WithReadOnly(readOnly bool) Option {
+	return func(c *serverConfig) error {
+		c.readOnly = readOnly
+		return nil
+	}
+}
+
+// WithSnapshotter configures the Snapshotter used to take snapshots of the
+// indexer's state. If not configured, then snapshots are not available.
+func WithSnapshotter(snapshotter *snapshot.Snapshotter) Option {
+	return func(c *serverConfig) error {
+		c.snapshotter = snapshotter
+		return nil
+	}
+}
+
+// WithTokens configures the bearer tokens that the server accepts, and the
+// role granted by each. If no tokens are configured, then requests do not
+// require authentication.
+func WithTokens(tokens []config.AdminToken) Option {
+	return func(c *serverConfig) error {
+		c.tokens = tokens
+		return nil
+	}
+}
@@ -9,6 +9,7 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	indexer "github.com/ipni/go-indexer-core"
 	coremetrics "github.com/ipni/go-indexer-core/metrics"
+	"github.com/ipni/storetheindex/config"
 	"github.com/ipni/storetheindex/internal/ingest"
 	"github.com/ipni/storetheindex/internal/metrics"
 	"github.com/ipni/storetheindex/internal/metrics/pprof"
@@ -19,6 +20,7 @@ import (
 var log = logging.Logger("indexer/admin")
 
 type Server struct {
+	auth     *authorizer
 	cancel   context.CancelFunc
 	handler  *adminHandler
 	listener net.Listener
@@ -35,6 +37,11 @@ func New(listen string, id peer.ID, indexer indexer.Interface, ingester *ingest.
 		return nil, err
 	}
 
+	auth, err := newAuthorizer(opts.tokens)
+	if err != nil {
+		return nil, err
+	}
+
 	l, err := net.Listen("tcp", listen)
 	if err != nil {
 		return nil, err
@@ -48,51 +55,82 @@ func New(listen string, id peer.ID, indexer indexer.Interface, ingester *ingest.
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	h := newHandler(ctx, id, indexer, ingester, reg, reloadErrChan)
+	h := newHandler(ctx, id, indexer, ingester, reg, reloadErrChan, opts.writeTimeout)
+	h.readOnly = opts.readOnly
+	h.snapshotter = opts.snapshotter
 
 	s := &Server{
+		auth:     auth,
 		cancel:   cancel,
 		handler:  h,
 		listener: l,
 		server:   server,
 	}
 
-	// Set protocol handlers
+	// Set protocol handlers, each requiring the role that is allowed to use it.
+	handle := func(pattern string, need role, handler http.HandlerFunc) {
+		mux.Handle(pattern, auth.allow(need, handler))
+	}
+	// Set handlers for requests that change the indexer's state. These are
+	// rejected when the indexer is read-only.
+	handleWrite := func(pattern string, need role, handler http.HandlerFunc) {
+		if opts.readOnly {
+			handler = readOnlyHandler
+		}
+		handle(pattern, need, handler)
+	}
 
 	// Import routes
-	mux.HandleFunc("/import/manifest/", h.importManifest)
-	mux.HandleFunc("/import/cidlist/", h.importCidList)
+	handleWrite("/import/manifest/", roleSuperuser, h.importManifest)
+	handleWrite("/import/cidlist/", roleSuperuser, h.importCidList)
 
 	// Admin routes
-	mux.HandleFunc("/freeze", h.freeze)
-	mux.HandleFunc("/status", h.status)
-	mux.HandleFunc("/healthcheck", h.healthCheckHandler)
-	mux.HandleFunc("/importproviders", h.importProviders)
-	mux.HandleFunc("/reloadconfig", h.reloadConfig)
+	handleWrite("/freeze", roleSuperuser, h.freeze)
+	handleWrite("/drain", roleOperator, h.drain)
+	handleWrite("/undrain", roleOperator, h.undrain)
+	handle("/snapshot", roleSuperuser, h.snapshot)
+	handle("/snapshot/status", roleReadOnly, h.getSnapshotStatus)
+	handle("/status", roleReadOnly, h.status)
+	handle("/healthcheck", roleReadOnly, h.healthCheckHandler)
+	handleWrite("/importproviders", roleSuperuser, h.importProviders)
+	handle("/reloadconfig", roleOperator, h.reloadConfig)
+
+	// Event stream routes
+	handle("/ingest/events", roleReadOnly, h.ingestEvents)
+	handle("/registry/events", roleReadOnly, h.registryEvents)
 
 	// Ingester routes
-	mux.HandleFunc("/ingest/allow/", h.allowPeer)
-	mux.HandleFunc("/ingest/block/", h.blockPeer)
-	mux.HandleFunc("/ingest/sync/", h.sync)
+	handleWrite("/ingest/allow/", roleOperator, h.allowPeer)
+	handleWrite("/ingest/block/", roleOperator, h.blockPeer)
+	handleWrite("/ingest/pause/", roleOperator, h.pausePeer)
+	handleWrite("/ingest/resume/", roleOperator, h.resumePeer)
+	handleWrite("/ingest/sync/", roleOperator, h.sync)
+	handleWrite("/ingest/recount/", roleOperator, h.recountProvider)
 
 	// Assignment routes
-	mux.HandleFunc("/ingest/assign/", h.assignPeer)
-	mux.HandleFunc("/ingest/assigned", h.listAssignedPeers)
-	mux.HandleFunc("/ingest/handoff/", h.handoffPeer)
-	mux.HandleFunc("/ingest/unassign/", h.unassignPeer)
-	mux.HandleFunc("/ingest/preferred", h.listPreferredPeers)
+	handleWrite("/ingest/assign/", roleOperator, h.assignPeer)
+	handle("/ingest/assigned", roleReadOnly, h.listAssignedPeers)
+	handleWrite("/ingest/handoff/", roleSuperuser, h.handoffPeer)
+	handleWrite("/ingest/unassign/", roleOperator, h.unassignPeer)
+	handle("/ingest/preferred", roleReadOnly, h.listPreferredPeers)
 
 	// Metrics routes
-	mux.Handle("/metrics", metrics.Start(coremetrics.DefaultViews))
-	mux.Handle("/debug/pprof", pprof.WithProfile())
+	mux.Handle("/metrics", auth.allow(roleReadOnly, metrics.Start(coremetrics.DefaultViews)))
+	mux.Handle("/debug/pprof", auth.allow(roleSuperuser, pprof.WithProfile()))
 
 	// Config routes
-	mux.HandleFunc("/config/log/level", setLogLevel)
-	mux.HandleFunc("/config/log/subsystems", listLogSubSystems)
+	handle("/config/log/level", roleOperator, setLogLevel)
+	handle("/config/log/subsystems", roleReadOnly, listLogSubSystems)
 
 	return s, nil
 }
 
+// SetTokens replaces the bearer tokens that the server accepts. If there are
+// no tokens, then requests do not require authentication.
+func (s *Server) SetTokens(tokens []config.AdminToken) error {
+	return s.auth.setTokens(tokens)
+}
+
 func (s *Server) Start() error {
 	log.Infow("admin http server listening", "listen_addr", s.listener.Addr())
 	return s.server.Serve(s.listener)
@@ -100,7 +138,7 @@ func (s *Server) Start() error {
 
 func (s *Server) Close() error {
 	log.Info("admin http server shutdown")
-	s.cancel() // stop any sync in progress
+	s.cancel() // stop any sync or snapshot in progress
 	s.handler.pendingSyncs.Wait()
 	return s.server.Shutdown(context.Background())
 }
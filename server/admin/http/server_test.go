@@ -15,11 +15,15 @@ import (
 	"github.com/ipni/go-indexer-core/engine"
 	"github.com/ipni/go-indexer-core/store/memory"
 	client "github.com/ipni/storetheindex/api/v0/admin/client/http"
+	adminmodel "github.com/ipni/storetheindex/api/v0/admin/model"
 	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/api/v0/httpclient"
 	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/filestore"
 	"github.com/ipni/storetheindex/internal/counter"
 	"github.com/ipni/storetheindex/internal/ingest"
 	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/ipni/storetheindex/internal/snapshot"
 	server "github.com/ipni/storetheindex/server/admin/http"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -223,6 +227,231 @@ func TestStatus(t *testing.T) {
 	te.close(t)
 }
 
+func TestIngestEventsNoIngester(t *testing.T) {
+	idx := initIndex(t, true)
+	defer idx.Close()
+	reg := initRegistry(t, peerIDStr)
+	defer reg.Close()
+	s := setupServer(t, idx, nil, reg, nil, server.WithReadOnly(true))
+
+	errChan := make(chan error, 1)
+	go func() {
+		err := s.Start()
+		if err != http.ErrServerClosed {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	rsp, err := http.Get(s.URL() + "/ingest/events")
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	require.NoError(t, s.Close())
+	require.NoError(t, <-errChan)
+}
+
+func TestDrain(t *testing.T) {
+	te := makeTestenv(t)
+	ctx := context.Background()
+
+	require.NoError(t, te.client.Drain(ctx))
+	status, err := te.client.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, status.Draining)
+	require.True(t, status.Drained)
+	require.True(t, te.ingester.Draining())
+
+	// Announces and syncs are rejected while draining.
+	err = te.ingester.Announce(ctx, cid.Undef, peer.AddrInfo{ID: serverID})
+	require.ErrorIs(t, err, ingest.ErrDraining)
+	_, err = te.ingester.Sync(ctx, serverID, nil, 0, false)
+	require.ErrorIs(t, err, ingest.ErrDraining)
+	err = te.client.Sync(ctx, serverID, nil, 0, false)
+	require.ErrorContains(t, err, "503")
+
+	require.NoError(t, te.client.Undrain(ctx))
+	status, err = te.client.Status(ctx)
+	require.NoError(t, err)
+	require.False(t, status.Draining)
+	require.False(t, status.Drained)
+
+	te.close(t)
+}
+
+func TestReadOnly(t *testing.T) {
+	te := makeTestenv(t, server.WithReadOnly(true))
+	ctx := context.Background()
+
+	status, err := te.client.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, status.ReadOnly)
+
+	err = te.client.Block(ctx, peerID)
+	require.ErrorContains(t, err, "read-only")
+	err = te.client.Freeze(ctx)
+	require.ErrorContains(t, err, "read-only")
+
+	te.close(t)
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	// Snapshots are not available without a snapshot destination.
+	te := makeTestenv(t)
+	_, err := te.client.Snapshot(ctx)
+	require.ErrorContains(t, err, "not configured")
+	te.close(t)
+
+	dest, err := filestore.New(config.FileStore{
+		Type: "local",
+		Local: config.LocalFileStore{
+			BasePath: t.TempDir(),
+		},
+	})
+	require.NoError(t, err)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	snapshotter, err := snapshot.New(dest, serverID, ds, memory.New())
+	require.NoError(t, err)
+
+	te = makeTestenv(t, server.WithSnapshotter(snapshotter))
+	_, err = te.client.SnapshotStatus(ctx)
+	require.ErrorContains(t, err, "404")
+
+	st, err := te.client.Snapshot(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, st.Name)
+
+	require.Eventually(t, func() bool {
+		st, err = te.client.SnapshotStatus(ctx)
+		require.NoError(t, err)
+		return !st.Finished.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Empty(t, st.Error)
+
+	manifest, err := snapshot.ReadManifest(ctx, dest, st.Name)
+	require.NoError(t, err)
+	require.Equal(t, serverID, manifest.IndexerID)
+
+	te.close(t)
+}
+
+func TestTokenAuth(t *testing.T) {
+	tokens := []config.AdminToken{
+		{Token: "reader-secret", Role: config.AdminRoleReadOnly},
+		{Token: "operator-secret", Role: config.AdminRoleOperator},
+		{Token: "superuser-secret", Role: config.AdminRoleSuperuser},
+	}
+	te := makeTestenv(t, server.WithTokens(tokens))
+	ctx := context.Background()
+
+	tokenClient := func(token string) *client.Client {
+		c, err := client.New(te.server.URL(), httpclient.WithBearerToken(token))
+		require.NoError(t, err)
+		return c
+	}
+	reader := tokenClient("reader-secret")
+	operator := tokenClient("operator-secret")
+	superuser := tokenClient("superuser-secret")
+
+	// Requests without a valid token are rejected.
+	_, err := te.client.Status(ctx)
+	require.ErrorContains(t, err, "401")
+	_, err = tokenClient("wrong").Status(ctx)
+	require.ErrorContains(t, err, "401")
+
+	// Every role can read status.
+	for _, c := range []*client.Client{reader, operator, superuser} {
+		_, err = c.Status(ctx)
+		require.NoError(t, err)
+	}
+
+	// Only operator and superuser can block and allow peers.
+	err = reader.Block(ctx, peerID)
+	require.ErrorContains(t, err, "403")
+	require.NoError(t, operator.Block(ctx, peerID))
+	require.NoError(t, superuser.Allow(ctx, peerID))
+
+	// Only superuser can freeze.
+	err = operator.Freeze(ctx)
+	require.ErrorContains(t, err, "403")
+	require.NoError(t, superuser.Freeze(ctx))
+	status, err := reader.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, status.Frozen)
+
+	// Removing all tokens disables authentication.
+	require.NoError(t, te.server.SetTokens(nil))
+	_, err = te.client.Status(ctx)
+	require.NoError(t, err)
+
+	require.Error(t, te.server.SetTokens([]config.AdminToken{{Token: "x", Role: "admin"}}))
+
+	te.close(t)
+}
+
+func TestRegistryChanges(t *testing.T) {
+	// Use a short write timeout so that the client must request a new stream
+	// to continue receiving changes.
+	te := makeTestenv(t, server.WithWriteTimeout(1500*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan adminmodel.ProviderChange, 10)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- te.client.RegistryChanges(ctx, 0, func(change adminmodel.ProviderChange) error {
+			changes <- change
+			return nil
+		})
+	}()
+
+	maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/9999")
+	require.NoError(t, err)
+	provider := peer.AddrInfo{
+		ID:    peerID,
+		Addrs: []multiaddr.Multiaddr{maddr},
+	}
+
+	// Wait for the first stream to end before making any changes.
+	time.Sleep(time.Second)
+	require.NoError(t, te.registry.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0))
+	var registered adminmodel.ProviderChange
+	select {
+	case registered = <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for registry change")
+	}
+	require.Equal(t, string(registry.ProviderRegistered), registered.Kind)
+	require.Equal(t, peerID, registered.ProviderID)
+	require.NotNil(t, registered.Provider)
+	require.Equal(t, peerID, registered.Provider.AddrInfo.ID)
+
+	require.NoError(t, te.registry.RemoveProvider(ctx, peerID))
+	var removed adminmodel.ProviderChange
+	select {
+	case removed = <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for registry change")
+	}
+	require.Equal(t, string(registry.ProviderRemoved), removed.Kind)
+	require.Equal(t, registered.Seq+1, removed.Seq)
+	require.Nil(t, removed.Provider)
+
+	cancel()
+	require.ErrorIs(t, <-errChan, context.Canceled)
+
+	// Check that resuming from a sequence number that is too high fails.
+	err = te.client.RegistryChanges(context.Background(), removed.Seq+10, func(adminmodel.ProviderChange) error {
+		return nil
+	})
+	require.ErrorIs(t, err, client.ErrCannotResume)
+
+	te.close(t)
+}
+
 func writeJsonResponse(w http.ResponseWriter, status int, body []byte) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
@@ -231,11 +460,11 @@ func writeJsonResponse(w http.ResponseWriter, status int, body []byte) {
 	}
 }
 
-func makeTestenv(t *testing.T) *testenv {
+func makeTestenv(t *testing.T, options ...server.Option) *testenv {
 	idx := initIndex(t, true)
 	reg := initRegistry(t, peerIDStr)
 	ing := initIngest(t, idx, reg)
-	s := setupServer(t, idx, ing, reg, nil)
+	s := setupServer(t, idx, ing, reg, nil, options...)
 	c := setupClient(t, s.URL())
 
 	// Start server
@@ -266,9 +495,9 @@ func (te *testenv) close(t *testing.T) {
 	te.registry.Close()
 }
 
-func setupServer(t *testing.T, ind indexer.Interface, ing *ingest.Ingester, reg *registry.Registry, idxCts *counter.IndexCounts) *server.Server {
+func setupServer(t *testing.T, ind indexer.Interface, ing *ingest.Ingester, reg *registry.Registry, idxCts *counter.IndexCounts, options ...server.Option) *server.Server {
 	reloadErrChan := make(chan chan error)
-	s, err := server.New("127.0.0.1:0", serverID, ind, ing, reg, reloadErrChan)
+	s, err := server.New("127.0.0.1:0", serverID, ind, ing, reg, reloadErrChan, options...)
 	require.NoError(t, err)
 	return s
 }
@@ -0,0 +1,59 @@
+package adminserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// streamEndMargin is how long before the server write timeout a streaming
+// response is ended, so that it ends cleanly instead of being cut off.
+const streamEndMargin = time.Second
+
+// ndjsonStream writes a stream of newline-delimited JSON objects as an HTTP
+// response, flushing each object to the client as soon as it is written.
+type ndjsonStream struct {
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+// newNDJSONStream writes the response header for a streaming NDJSON response.
+func newNDJSONStream(w http.ResponseWriter) (*ndjsonStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported")
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &ndjsonStream{
+		enc:     json.NewEncoder(w),
+		flusher: flusher,
+	}, nil
+}
+
+func (s *ndjsonStream) write(v interface{}) error {
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamTimer returns a channel that is signaled when a streaming response
+// must end, so that the response is not cut off by the server's write
+// timeout. The returned channel is nil if there is no write timeout.
+func streamTimer(writeTimeout time.Duration) (<-chan time.Time, func()) {
+	if writeTimeout == 0 {
+		return nil, func() {}
+	}
+	d := writeTimeout - streamEndMargin
+	if d <= 0 {
+		d = writeTimeout / 2
+	}
+	timer := time.NewTimer(d)
+	return timer.C, func() { timer.Stop() }
+}
@@ -0,0 +1,487 @@
+package p2padminserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	logging "github.com/ipfs/go-log/v2"
+	v0 "github.com/ipni/storetheindex/api/v0"
+	"github.com/ipni/storetheindex/api/v0/admin/model"
+	pb "github.com/ipni/storetheindex/api/v0/admin/pb"
+	"github.com/ipni/storetheindex/internal/ingest"
+	"github.com/ipni/storetheindex/internal/libp2pserver"
+	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("indexer/admin")
+
+// handler handles admin requests from authorized peers.
+type libp2pHandler struct {
+	ctx           context.Context
+	id            peer.ID
+	ingester      *ingest.Ingester
+	reg           *registry.Registry
+	reloadErrChan chan<- chan error
+	pendingSyncs  sync.WaitGroup
+
+	authorized   map[peer.ID]struct{}
+	authorizedMu sync.RWMutex
+}
+
+// handlerFunc is the function signature required by handlers in this package
+type handlerFunc func(context.Context, peer.ID, *pb.AdminMessage) ([]byte, error)
+
+func newHandler(ctx context.Context, id peer.ID, ingester *ingest.Ingester, reg *registry.Registry, reloadErrChan chan<- chan error, authorizedPeers []peer.ID) *libp2pHandler {
+	h := &libp2pHandler{
+		ctx:           ctx,
+		id:            id,
+		ingester:      ingester,
+		reg:           reg,
+		reloadErrChan: reloadErrChan,
+	}
+	h.setAuthorizedPeers(authorizedPeers)
+	return h
+}
+
+func (h *libp2pHandler) ProtocolID() protocol.ID {
+	return v0.AdminProtocolID
+}
+
+func (h *libp2pHandler) setAuthorizedPeers(authorizedPeers []peer.ID) {
+	authorized := make(map[peer.ID]struct{}, len(authorizedPeers))
+	for _, peerID := range authorizedPeers {
+		authorized[peerID] = struct{}{}
+	}
+	h.authorizedMu.Lock()
+	h.authorized = authorized
+	h.authorizedMu.Unlock()
+}
+
+func (h *libp2pHandler) isAuthorized(peerID peer.ID) bool {
+	h.authorizedMu.RLock()
+	defer h.authorizedMu.RUnlock()
+	_, ok := h.authorized[peerID]
+	return ok
+}
+
+func (h *libp2pHandler) HandleMessage(ctx context.Context, msgPeer peer.ID, msgbytes []byte) (proto.Message, error) {
+	var req pb.AdminMessage
+	err := req.Unmarshal(msgbytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !h.isAuthorized(msgPeer) {
+		log.Warnw("Rejected admin request from unauthorized peer", "peer", msgPeer, "request", req.GetType().String())
+		return &pb.AdminMessage{
+			Type: pb.AdminMessage_ERROR_RESPONSE,
+			Data: v0.EncodeError(v0.NewError(errors.New("peer not authorized"), http.StatusForbidden)),
+		}, nil
+	}
+
+	var rspType pb.AdminMessage_MessageType
+	var handle handlerFunc
+	switch req.GetType() {
+	case pb.AdminMessage_STATUS:
+		handle = h.status
+		rspType = pb.AdminMessage_STATUS_RESPONSE
+	case pb.AdminMessage_SYNC:
+		handle = h.sync
+		rspType = pb.AdminMessage_SYNC_RESPONSE
+	case pb.AdminMessage_ALLOW:
+		handle = h.allowPeer
+		rspType = pb.AdminMessage_ALLOW_RESPONSE
+	case pb.AdminMessage_BLOCK:
+		handle = h.blockPeer
+		rspType = pb.AdminMessage_BLOCK_RESPONSE
+	case pb.AdminMessage_PAUSE:
+		handle = h.pausePeer
+		rspType = pb.AdminMessage_PAUSE_RESPONSE
+	case pb.AdminMessage_RESUME:
+		handle = h.resumePeer
+		rspType = pb.AdminMessage_RESUME_RESPONSE
+	case pb.AdminMessage_FREEZE:
+		handle = h.freeze
+		rspType = pb.AdminMessage_FREEZE_RESPONSE
+	case pb.AdminMessage_ASSIGN:
+		handle = h.assignPeer
+		rspType = pb.AdminMessage_ASSIGN_RESPONSE
+	case pb.AdminMessage_HANDOFF:
+		handle = h.handoffPeer
+		rspType = pb.AdminMessage_HANDOFF_RESPONSE
+	case pb.AdminMessage_UNASSIGN:
+		handle = h.unassignPeer
+		rspType = pb.AdminMessage_UNASSIGN_RESPONSE
+	case pb.AdminMessage_LIST_ASSIGNED:
+		handle = h.listAssignedPeers
+		rspType = pb.AdminMessage_LIST_ASSIGNED_RESPONSE
+	case pb.AdminMessage_LIST_PREFERRED:
+		handle = h.listPreferredPeers
+		rspType = pb.AdminMessage_LIST_PREFERRED_RESPONSE
+	case pb.AdminMessage_RELOAD_CONFIG:
+		handle = h.reloadConfig
+		rspType = pb.AdminMessage_RELOAD_CONFIG_RESPONSE
+	case pb.AdminMessage_IMPORT_PROVIDERS:
+		handle = h.importProviders
+		rspType = pb.AdminMessage_IMPORT_PROVIDERS_RESPONSE
+	case pb.AdminMessage_RECOUNT:
+		handle = h.recountProvider
+		rspType = pb.AdminMessage_RECOUNT_RESPONSE
+	default:
+		return nil, fmt.Errorf("unsupported message type %d", req.GetType())
+	}
+
+	log.Infow("Handling admin request", "peer", msgPeer, "request", req.GetType().String())
+
+	data, err := handle(ctx, msgPeer, &req)
+	if err != nil {
+		err = libp2pserver.HandleError(err, req.GetType().String())
+		data = v0.EncodeError(err)
+		rspType = pb.AdminMessage_ERROR_RESPONSE
+	}
+
+	return &pb.AdminMessage{
+		Type: rspType,
+		Data: data,
+	}, nil
+}
+
+// ----- admin handlers -----
+
+func (h *libp2pHandler) status(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	var usage float64
+	du, err := h.reg.ValueStoreUsage()
+	if err != nil {
+		log.Error(err)
+		usage = -1.0
+	} else {
+		usage = du.Percent
+	}
+
+	status := model.Status{
+		Frozen: h.reg.Frozen(),
+		ID:     h.id,
+		Usage:  usage,
+	}
+	if h.ingester != nil {
+		status.Draining = h.ingester.Draining()
+		status.Drained = h.ingester.Drained()
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		log.Errorw("Error marshaling status", "err", err)
+		return nil, v0.NewError(nil, http.StatusInternalServerError)
+	}
+	return data, nil
+}
+
+func (h *libp2pHandler) freeze(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	err := h.reg.Freeze()
+	if err != nil {
+		if errors.Is(err, registry.ErrNoFreeze) {
+			log.Infow("Cannot freeze indexer", "reason", err)
+			return nil, v0.NewError(err, http.StatusBadRequest)
+		}
+		log.Errorw("Cannot freeze indexer", "err", err)
+		return nil, v0.NewError(nil, http.StatusInternalServerError)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) reloadConfig(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	errChan := make(chan error)
+	h.reloadErrChan <- errChan
+	err := <-errChan
+	if err != nil {
+		return nil, v0.NewError(err, http.StatusInternalServerError)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) importProviders(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	if len(msg.GetData()) == 0 {
+		return nil, v0.NewError(errors.New("missing indexer url in request"), http.StatusBadRequest)
+	}
+	fromURL, err := url.Parse(string(msg.GetData()))
+	if err != nil {
+		return nil, v0.NewError(fmt.Errorf("bad indexer url: %w", err), http.StatusBadRequest)
+	}
+
+	_, err = h.reg.ImportProviders(h.ctx, fromURL)
+	if err != nil {
+		msg := "Cannot get providers from other indexer"
+		log.Errorw(msg, "err", err)
+		return nil, v0.NewError(errors.New(msg), http.StatusBadGateway)
+	}
+	return nil, nil
+}
+
+// ----- ingest handlers -----
+
+func (h *libp2pHandler) allowPeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("Allowing peer to publish and provide content", "peer", peerID)
+	if h.reg.AllowPeer(peerID) {
+		log.Infow("Update config to persist allowing peer", "peer", peerID)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) blockPeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	log.Infow("Blocking peer from publishing or providing content", "peer", peerID)
+	if h.reg.BlockPeer(peerID) {
+		log.Infow("Update config to persist blocking peer", "peer", peerID)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) pausePeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	if h.ingester == nil {
+		log.Warn("pause not available, ingester disabled")
+		return nil, v0.NewError(errors.New("ingester disabled"), http.StatusServiceUnavailable)
+	}
+
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	if err = h.ingester.PausePublisher(peerID); err != nil {
+		log.Errorw("Cannot pause publisher", "err", err, "publisher", peerID)
+		return nil, v0.NewError(err, http.StatusInternalServerError)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) resumePeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	if h.ingester == nil {
+		log.Warn("resume not available, ingester disabled")
+		return nil, v0.NewError(errors.New("ingester disabled"), http.StatusServiceUnavailable)
+	}
+
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	if err = h.ingester.ResumePublisher(peerID); err != nil {
+		log.Errorw("Cannot resume publisher", "err", err, "publisher", peerID)
+		return nil, v0.NewError(err, http.StatusInternalServerError)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) sync(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	if h.ingester == nil {
+		log.Warn("sync not available, ingester disabled")
+		return nil, v0.NewError(errors.New("ingester disabled"), http.StatusServiceUnavailable)
+	}
+
+	var req model.SyncRequest
+	err := json.Unmarshal(msg.GetData(), &req)
+	if err != nil {
+		log.Errorw("Cannot unmarshal sync request", "err", err)
+		return nil, v0.NewError(errors.New("cannot decode request"), http.StatusBadRequest)
+	}
+	if req.PeerID.Validate() != nil {
+		return nil, v0.NewError(errors.New("missing peer id"), http.StatusBadRequest)
+	}
+	if h.ingester.PublisherPaused(req.PeerID) {
+		return nil, v0.NewError(ingest.ErrPublisherPaused, http.StatusConflict)
+	}
+	log := log.With("peerID", req.PeerID, "depth", req.Depth, "resync", req.Resync)
+
+	var syncAddr multiaddr.Multiaddr
+	if req.Addr != "" {
+		syncAddr, err = multiaddr.NewMultiaddr(req.Addr)
+		if err != nil {
+			log.Errorw("Cannot decode sync multiaddr", "err", err)
+			return nil, v0.NewError(err, http.StatusBadRequest)
+		}
+		log = log.With("address", syncAddr)
+	}
+
+	if h.ingester.Draining() {
+		log.Warn("Cannot sync with peer while draining")
+		return nil, v0.NewError(ingest.ErrDraining, http.StatusServiceUnavailable)
+	}
+
+	log.Info("Syncing with peer")
+
+	// Start the sync, but do not wait for it to complete.
+	h.pendingSyncs.Add(1)
+	go func() {
+		_, err := h.ingester.Sync(h.ctx, req.PeerID, syncAddr, int(req.Depth), req.Resync)
+		if err != nil {
+			log.Errorw("Cannot sync with peer", "err", err)
+		}
+		h.pendingSyncs.Done()
+	}()
+
+	return nil, nil
+}
+
+func (h *libp2pHandler) recountProvider(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	if h.ingester == nil {
+		log.Warn("recount not available, ingester disabled")
+		return nil, v0.NewError(errors.New("ingester disabled"), http.StatusServiceUnavailable)
+	}
+
+	providerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	log := log.With("provider", providerID)
+
+	log.Info("Recounting provider indexes")
+
+	// Start the recount, but do not wait for it to complete.
+	h.pendingSyncs.Add(1)
+	go func() {
+		_, _, err := h.ingester.RecountProvider(h.ctx, providerID)
+		if err != nil {
+			log.Errorw("Cannot recount provider indexes", "err", err)
+		}
+		h.pendingSyncs.Done()
+	}()
+
+	return nil, nil
+}
+
+// ----- assignment handlers -----
+
+func (h *libp2pHandler) assignPeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	err = h.reg.AssignPeer(peerID)
+	if err != nil {
+		return nil, assignError(err)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) handoffPeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	var req model.HandoffRequest
+	err := json.Unmarshal(msg.GetData(), &req)
+	if err != nil {
+		log.Errorw("Cannot unmarshal handoff request", "err", err)
+		return nil, v0.NewError(errors.New("cannot decode request"), http.StatusBadRequest)
+	}
+	log := log.With("publisher", req.Publisher, "from", req.FrozenID)
+
+	frozenURL, err := url.Parse(req.FrozenURL)
+	if err != nil {
+		log.Errorw("Cannot parse handoff 'frozen' URL", "err", err, "url", req.FrozenURL)
+		return nil, v0.NewError(err, http.StatusBadRequest)
+	}
+
+	err = h.reg.Handoff(ctx, req.Publisher, req.FrozenID, frozenURL)
+	if err != nil {
+		return nil, assignError(err)
+	}
+	return nil, nil
+}
+
+func (h *libp2pHandler) unassignPeer(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	peerID, err := decodePeerID(msg.GetData())
+	if err != nil {
+		return nil, err
+	}
+	ok, err := h.reg.UnassignPeer(peerID)
+	if err != nil {
+		log.Infow("Cannot unassign peer from indexer", "peer", peerID)
+		if errors.Is(err, registry.ErrNoAssigner) {
+			return nil, v0.NewError(err, http.StatusServiceUnavailable)
+		}
+		return nil, v0.NewError(nil, http.StatusInternalServerError)
+	}
+	if !ok {
+		return nil, v0.NewError(errors.New("peer was not assigned"), http.StatusNotFound)
+	}
+
+	log.Infow("Unassigned publisher from indexer", "publisher", peerID)
+	return nil, nil
+}
+
+func (h *libp2pHandler) listAssignedPeers(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	publishers, continued, err := h.reg.ListAssignedPeers()
+	if err != nil {
+		return nil, v0.NewError(err, http.StatusServiceUnavailable)
+	}
+	if len(publishers) == 0 {
+		return nil, nil
+	}
+
+	apiAssigned := make([]model.Assigned, len(publishers))
+	for i := range publishers {
+		apiAssigned[i].Publisher = publishers[i]
+		apiAssigned[i].Continued = continued[i]
+	}
+
+	data, err := json.Marshal(apiAssigned)
+	if err != nil {
+		log.Errorw("Error marshaling assigned list", "err", err)
+		return nil, v0.NewError(nil, http.StatusInternalServerError)
+	}
+	return data, nil
+}
+
+func (h *libp2pHandler) listPreferredPeers(ctx context.Context, p peer.ID, msg *pb.AdminMessage) ([]byte, error) {
+	preferred, err := h.reg.ListPreferredPeers()
+	if err != nil {
+		return nil, v0.NewError(err, http.StatusServiceUnavailable)
+	}
+	if len(preferred) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(preferred)
+	if err != nil {
+		log.Errorw("Error marshaling preferred list", "err", err)
+		return nil, v0.NewError(nil, http.StatusInternalServerError)
+	}
+	return data, nil
+}
+
+// ----- utility functions -----
+
+func assignError(err error) error {
+	log.Errorw("Cannot assign publisher to indexer", "err", err)
+	switch {
+	case errors.Is(err, registry.ErrNotAllowed), errors.Is(err, registry.ErrPublisherNotAllowed), errors.Is(err, registry.ErrCannotPublish):
+		return v0.NewError(err, http.StatusForbidden)
+	case errors.Is(err, registry.ErrNoAssigner):
+		return v0.NewError(err, http.StatusServiceUnavailable)
+	case errors.Is(err, registry.ErrAlreadyAssigned):
+		return v0.NewError(err, http.StatusBadRequest)
+	default:
+		return v0.NewError(nil, http.StatusInternalServerError)
+	}
+}
+
+func decodePeerID(data []byte) (peer.ID, error) {
+	var peerID peer.ID
+	err := json.Unmarshal(data, &peerID)
+	if err != nil {
+		log.Errorw("Cannot decode peer id", "err", err)
+		return "", v0.NewError(errors.New("cannot decode peer id"), http.StatusBadRequest)
+	}
+	return peerID, nil
+}
@@ -0,0 +1,94 @@
+package p2padminserver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	p2pclient "github.com/ipni/storetheindex/api/v0/admin/client/libp2p"
+	p2pserver "github.com/ipni/storetheindex/server/admin/libp2p"
+	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) host.Host {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func requireStatus(t *testing.T, err error, status int) {
+	require.ErrorContains(t, err, fmt.Sprintf("%d %s", status, http.StatusText(status)))
+}
+
+func TestAdminAuthorization(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := test.InitRegistryWithRestrictivePolicy(t, true)
+	defer reg.Close()
+
+	reloadErrChan := make(chan chan error)
+	go func() {
+		for errChan := range reloadErrChan {
+			errChan <- nil
+		}
+	}()
+	defer close(reloadErrChan)
+
+	sh := newHost(t)
+	operator := newHost(t)
+	other := newHost(t)
+
+	s := p2pserver.New(ctx, sh, nil, reg, reloadErrChan, []peer.ID{operator.ID()})
+	defer s.Close()
+
+	opClient, err := p2pclient.New(operator, s.ID())
+	require.NoError(t, err)
+	defer opClient.Close()
+	require.NoError(t, opClient.ConnectAddrs(ctx, sh.Addrs()...))
+
+	otherClient, err := p2pclient.New(other, s.ID())
+	require.NoError(t, err)
+	defer otherClient.Close()
+	require.NoError(t, otherClient.ConnectAddrs(ctx, sh.Addrs()...))
+
+	// Unauthorized peer cannot perform any operation.
+	_, err = otherClient.Status(ctx)
+	requireStatus(t, err, http.StatusForbidden)
+	publisher := other.ID()
+	err = otherClient.Allow(ctx, publisher)
+	requireStatus(t, err, http.StatusForbidden)
+	require.False(t, reg.Allowed(publisher))
+
+	// Authorized peer can.
+	status, err := opClient.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, s.ID(), status.ID)
+	require.False(t, status.Frozen)
+
+	require.NoError(t, opClient.Allow(ctx, publisher))
+	require.True(t, reg.Allowed(publisher))
+	require.NoError(t, opClient.Block(ctx, publisher))
+	require.False(t, reg.Allowed(publisher))
+
+	require.NoError(t, opClient.ReloadConfig(ctx))
+
+	// Errors from handlers are returned with the same status as over http.
+	err = opClient.Sync(ctx, publisher, nil, 0, false)
+	requireStatus(t, err, http.StatusServiceUnavailable)
+	_, err = opClient.ListAssignedPeers(ctx)
+	requireStatus(t, err, http.StatusServiceUnavailable)
+
+	// Authorization can be changed while running.
+	s.SetAuthorizedPeers([]peer.ID{other.ID()})
+	_, err = opClient.Status(ctx)
+	requireStatus(t, err, http.StatusForbidden)
+	_, err = otherClient.Status(ctx)
+	require.NoError(t, err)
+}
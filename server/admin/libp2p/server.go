@@ -0,0 +1,45 @@
+package p2padminserver
+
+import (
+	"context"
+
+	"github.com/ipni/storetheindex/internal/ingest"
+	"github.com/ipni/storetheindex/internal/libp2pserver"
+	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// AdminServer handles admin requests over libp2p from authorized peers.
+type AdminServer struct {
+	libp2pserver.Server
+	cancel     context.CancelFunc
+	p2pHandler *libp2pHandler
+}
+
+// New creates a new libp2p admin server. Only requests from the
+// authorizedPeers are handled.
+func New(ctx context.Context, h host.Host, ingester *ingest.Ingester, reg *registry.Registry, reloadErrChan chan<- chan error, authorizedPeers []peer.ID) *AdminServer {
+	ctx, cancel := context.WithCancel(ctx)
+	p2ph := newHandler(ctx, h.ID(), ingester, reg, reloadErrChan, authorizedPeers)
+	s := &AdminServer{
+		cancel:     cancel,
+		p2pHandler: p2ph,
+	}
+	s.Server = *libp2pserver.New(ctx, h, p2ph)
+	return s
+}
+
+// SetAuthorizedPeers replaces the set of peers that are allowed to perform
+// admin operations.
+func (s *AdminServer) SetAuthorizedPeers(authorizedPeers []peer.ID) {
+	s.p2pHandler.setAuthorizedPeers(authorizedPeers)
+}
+
+// Close stops any sync started by an admin request and waits for it to
+// finish.
+func (s *AdminServer) Close() error {
+	s.cancel()
+	s.p2pHandler.pendingSyncs.Wait()
+	return nil
+}
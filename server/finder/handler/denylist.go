@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/ipni/storetheindex/internal/denylist"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/multiformats/go-multihash"
+)
+
+// SetDenylist sets the denylist that removes denied multihashes from find
+// requests. This must be called before the handler handles any requests.
+func (h *FinderHandler) SetDenylist(dl *denylist.Denylist) {
+	h.denylist = dl
+}
+
+// removeDenied returns the multihashes that are not denied by the denylist.
+func (h *FinderHandler) removeDenied(mhashes []multihash.Multihash) []multihash.Multihash {
+	if h.denylist == nil {
+		return mhashes
+	}
+	allowed, denied := h.denylist.Filter(mhashes)
+	if denied != 0 {
+		metrics.RecordDenied("find", denied)
+	}
+	return allowed
+}
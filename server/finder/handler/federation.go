@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	finderhttpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/api/v0/httpclient"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// federationBackend is a remote indexer that is queried for find requests.
+type federationBackend struct {
+	url     string
+	client  *finderhttpclient.Client
+	timeout time.Duration
+}
+
+// ConfigureFederation configures remote indexers that are queried, in parallel
+// with the local value store, to answer find requests. This must be called
+// before the handler handles any requests.
+func (h *FinderHandler) ConfigureFederation(cfg config.FinderFederation) error {
+	backends := make([]*federationBackend, 0, len(cfg.Backends))
+	for _, b := range cfg.Backends {
+		client, err := finderhttpclient.New(b.URL, httpclient.WithHeader(model.FederatedHeader, "1"))
+		if err != nil {
+			return fmt.Errorf("cannot create client for federated indexer %s: %w", b.URL, err)
+		}
+		timeout := time.Duration(b.Timeout)
+		if timeout == 0 {
+			timeout = time.Duration(cfg.Timeout)
+		}
+		backends = append(backends, &federationBackend{
+			url:     b.URL,
+			client:  client,
+			timeout: timeout,
+		})
+	}
+	h.federation = backends
+	return nil
+}
+
+// find queries the backend for the multihashes.
+func (b *federationBackend) find(ctx context.Context, mhashes []multihash.Multihash) (*model.FindResponse, error) {
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var rsp *model.FindResponse
+	var err error
+	if len(mhashes) == 1 {
+		rsp, err = b.client.Find(ctx, mhashes[0])
+	} else {
+		rsp, err = b.client.FindBatch(ctx, mhashes)
+	}
+	metrics.RecordFederatedFind(b.url, time.Since(start), err)
+	return rsp, err
+}
+
+// FindLocal is the same as Find, but only returns results from the local value
+// store. This answers find requests from other federated indexers.
+func (h *FinderHandler) FindLocal(ctx context.Context, mhashes []multihash.Multihash) (*model.FindResponse, error) {
+	mhashes = h.removeDenied(mhashes)
+	if len(mhashes) == 0 {
+		return &model.FindResponse{}, nil
+	}
+	return h.findLocal(ctx, mhashes)
+}
+
+// FindFederated is the same as Find, and also returns the URLs of the
+// federated indexers that failed to respond. Results from the federated
+// indexers that did respond are merged with results from the local value
+// store. Only an error from the local value store fails the request.
+func (h *FinderHandler) FindFederated(ctx context.Context, mhashes []multihash.Multihash) (*model.FindResponse, []string, error) {
+	mhashes = h.removeDenied(mhashes)
+	if len(mhashes) == 0 {
+		return &model.FindResponse{}, nil, nil
+	}
+	if len(h.federation) == 0 {
+		rsp, err := h.findLocal(ctx, mhashes)
+		return rsp, nil, err
+	}
+
+	ctx, span := tracer.Start(ctx, "FinderHandler.FindFederated")
+	defer span.End()
+	span.SetAttributes(attribute.Int("backends", len(h.federation)))
+
+	remotes := make([]*model.FindResponse, len(h.federation))
+	errs := make([]error, len(h.federation))
+	var wg sync.WaitGroup
+	for i, b := range h.federation {
+		wg.Add(1)
+		go func(i int, b *federationBackend) {
+			defer wg.Done()
+			remotes[i], errs[i] = b.find(ctx, mhashes)
+		}(i, b)
+	}
+
+	local, err := h.findLocal(ctx, mhashes)
+	wg.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var failed []string
+	responses := make([]*model.FindResponse, 1, len(remotes)+1)
+	responses[0] = local
+	for i, rsp := range remotes {
+		if errs[i] != nil {
+			log.Warnw("Federated indexer failed to respond to find", "backend", h.federation[i].url, "err", errs[i])
+			failed = append(failed, h.federation[i].url)
+			continue
+		}
+		responses = append(responses, rsp)
+	}
+	span.SetAttributes(attribute.Int("failed", len(failed)))
+
+	return mergeFindResponses(mhashes, responses), failed, nil
+}
+
+// mergeFindResponses merges the responses into a single response that has
+// results in the order of the given multihashes. Results for a multihash are
+// deduplicated by provider and context ID. A result from an earlier response
+// takes precedence over the same provider and context ID in a later response.
+// Results within a single response are all kept, since one response may
+// have more than one result for a provider and context ID, such as for an
+// extended provider with different metadata.
+func mergeFindResponses(mhashes []multihash.Multihash, responses []*model.FindResponse) *model.FindResponse {
+	merged := make(map[string][]model.ProviderResult, len(mhashes))
+	seen := make(map[string]map[string]struct{}, len(mhashes))
+
+	for _, rsp := range responses {
+		if rsp == nil {
+			continue
+		}
+		for _, mhr := range rsp.MultihashResults {
+			mhKey := string(mhr.Multihash)
+			mhSeen := seen[mhKey]
+			added := make(map[string]struct{}, len(mhr.ProviderResults))
+			for _, pr := range mhr.ProviderResults {
+				if pr.Provider == nil {
+					continue
+				}
+				key := string(pr.Provider.ID) + string(pr.ContextID)
+				if _, ok := mhSeen[key]; ok {
+					continue
+				}
+				merged[mhKey] = append(merged[mhKey], pr)
+				added[key] = struct{}{}
+			}
+			if mhSeen == nil {
+				seen[mhKey] = added
+				continue
+			}
+			for key := range added {
+				mhSeen[key] = struct{}{}
+			}
+		}
+	}
+
+	results := make([]model.MultihashResult, 0, len(merged))
+	for _, mh := range mhashes {
+		provResults, ok := merged[string(mh)]
+		if !ok {
+			continue
+		}
+		// Do not return the same multihash twice if it is in the request more
+		// than once.
+		delete(merged, string(mh))
+		results = append(results, model.MultihashResult{
+			Multihash:       mh,
+			ProviderResults: provResults,
+		})
+	}
+	return &model.FindResponse{
+		MultihashResults: results,
+	}
+}
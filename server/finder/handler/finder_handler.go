@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,14 +13,21 @@ import (
 	v0 "github.com/ipni/storetheindex/api/v0"
 	"github.com/ipni/storetheindex/api/v0/finder/model"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
+	"github.com/ipni/storetheindex/internal/ingest"
 	"github.com/ipni/storetheindex/internal/registry"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 var log = logging.Logger("indexer/finder")
 
+var tracer = otel.Tracer("github.com/ipni/storetheindex/server/finder/handler")
+
 // avg_mh_size is a slight overcount over the expected size of a multihash as a
 // way of estimating the number of entries in the primary value store.
 const avg_mh_size = 40
@@ -31,6 +39,9 @@ type FinderHandler struct {
 	registry    *registry.Registry
 	indexCounts *counter.IndexCounts
 	stats       *cachedStats
+	details     *statsDetails
+	federation  []*federationBackend
+	denylist    *denylist.Denylist
 }
 
 func NewFinderHandler(indexer indexer.Interface, registry *registry.Registry, indexCounts *counter.IndexCounts) *FinderHandler {
@@ -39,19 +50,51 @@ func NewFinderHandler(indexer indexer.Interface, registry *registry.Registry, in
 		registry:    registry,
 		indexCounts: indexCounts,
 		stats:       newCachedStats(indexer, time.Hour),
+		details: &statsDetails{
+			registry:    registry,
+			indexCounts: indexCounts,
+			refresh:     defaultDetailsRefresh,
+		},
+	}
+}
+
+// ConfigureStats sets how often the entries stats are refreshed, how long
+// detailed stats are cached, and the ingester that ingest stats are read from.
+// A zero interval leaves that interval unchanged. This must be called before
+// the handler handles any requests.
+func (h *FinderHandler) ConfigureStats(refresh, detailsRefresh time.Duration, ingester *ingest.Ingester) {
+	if refresh > 0 {
+		h.stats.ticker.Reset(refresh)
+	}
+	if detailsRefresh > 0 {
+		h.details.refresh = detailsRefresh
 	}
+	h.details.ingester = ingester
+}
+
+// Find reads from indexer core, and from any federated indexers, to populate
+// a response from a list of multihashes. Multihashes that are denied by the
+// denylist are not looked up.
+func (h *FinderHandler) Find(ctx context.Context, mhashes []multihash.Multihash) (*model.FindResponse, error) {
+	rsp, _, err := h.FindFederated(ctx, mhashes)
+	return rsp, err
 }
 
-// Find reads from indexer core to populate a response from a list of
+// findLocal reads from indexer core to populate a response from a list of
 // multihashes.
-func (h *FinderHandler) Find(mhashes []multihash.Multihash) (*model.FindResponse, error) {
+func (h *FinderHandler) findLocal(ctx context.Context, mhashes []multihash.Multihash) (*model.FindResponse, error) {
+	ctx, span := tracer.Start(ctx, "FinderHandler.Find")
+	defer span.End()
+	span.SetAttributes(attribute.Int("multihashes", len(mhashes)))
+
 	results := make([]model.MultihashResult, 0, len(mhashes))
 	provInfos := map[peer.ID]*registry.ProviderInfo{}
 
 	for i := range mhashes {
-		values, found, err := h.indexer.Get(mhashes[i])
+		values, found, err := h.getValues(ctx, mhashes[i])
 		if err != nil {
 			err = fmt.Errorf("failed to query multihash %s: %s", mhashes[i].B58String(), err)
+			span.SetStatus(codes.Error, err.Error())
 			return nil, v0.NewError(err, http.StatusInternalServerError)
 		}
 		if !found {
@@ -69,7 +112,7 @@ func (h *FinderHandler) Find(mhashes []multihash.Multihash) (*model.FindResponse
 			}
 
 			// Adding the main provider
-			provResult := providerResultFromValue(provID, iVal.ContextID, iVal.MetadataBytes, pinfo.AddrInfo.Addrs)
+			provResult := providerResultFromValue(provID, iVal.ContextID, iVal.MetadataBytes, h.registry.FilterAddrs(pinfo.AddrInfo.Addrs))
 			provResults = append(provResults, provResult)
 
 			if pinfo.ExtendedProviders == nil {
@@ -94,7 +137,7 @@ func (h *FinderHandler) Find(mhashes []multihash.Multihash) (*model.FindResponse
 						(len(epInfo.Metadata) == 0 || bytes.Equal(epInfo.Metadata, iVal.MetadataBytes)) {
 						continue
 					}
-					provResult := createExtendedProviderResult(epInfo, iVal)
+					provResult := h.createExtendedProviderResult(epInfo, iVal)
 					provResults = append(provResults, *provResult)
 
 				}
@@ -113,7 +156,7 @@ func (h *FinderHandler) Find(mhashes []multihash.Multihash) (*model.FindResponse
 					(len(epInfo.Metadata) == 0 || bytes.Equal(epInfo.Metadata, iVal.MetadataBytes)) {
 					continue
 				}
-				provResult := createExtendedProviderResult(epInfo, iVal)
+				provResult := h.createExtendedProviderResult(epInfo, iVal)
 				provResults = append(provResults, *provResult)
 			}
 
@@ -132,11 +175,36 @@ func (h *FinderHandler) Find(mhashes []multihash.Multihash) (*model.FindResponse
 		})
 	}
 
+	span.SetAttributes(attribute.Int("results", len(results)))
 	return &model.FindResponse{
 		MultihashResults: results,
 	}, nil
 }
 
+// ProviderResultCount returns the number of provider results for all
+// multihashes in the response.
+func ProviderResultCount(r *model.FindResponse) int {
+	var count int
+	for i := range r.MultihashResults {
+		count += len(r.MultihashResults[i].ProviderResults)
+	}
+	return count
+}
+
+// getValues reads the values for a multihash from the value store.
+func (h *FinderHandler) getValues(ctx context.Context, mh multihash.Multihash) ([]indexer.Value, bool, error) {
+	_, span := tracer.Start(ctx, "indexer.Get")
+	defer span.End()
+
+	values, found, err := h.indexer.Get(mh)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, false, err
+	}
+	span.SetAttributes(attribute.Bool("found", found), attribute.Int("values", len(values)))
+	return values, found, nil
+}
+
 func (h *FinderHandler) fetchProviderInfo(provID peer.ID,
 	contextID []byte,
 	provAddrs map[peer.ID]*registry.ProviderInfo,
@@ -172,25 +240,17 @@ func (h *FinderHandler) fetchProviderInfo(provID peer.ID,
 }
 
 func (h *FinderHandler) ListProviders() ([]byte, error) {
-	infos := h.registry.AllProviderInfo()
-
-	responses := make([]model.ProviderInfo, len(infos))
-	for i, pInfo := range infos {
-		var indexCount uint64
-		if h.indexCounts != nil {
-			var err error
-			indexCount, err = h.indexCounts.Provider(pInfo.AddrInfo.ID)
-			if err != nil {
-				log.Errorw("Could not get provider index count", "err", err)
-			}
-		}
-		responses[i] = *registry.RegToApiProviderInfo(pInfo, indexCount)
+	provs, _, err := h.FindProviders(model.ProviderQuery{})
+	if err != nil {
+		return nil, err
 	}
-
-	return json.Marshal(responses)
+	return json.Marshal(provs)
 }
 
-func (h *FinderHandler) GetProvider(providerID peer.ID) ([]byte, error) {
+// GetProvider returns the JSON encoded information for a provider. If
+// withContexts is true, then the response includes the index count for each of
+// the provider's context IDs.
+func (h *FinderHandler) GetProvider(providerID peer.ID, withContexts bool) ([]byte, error) {
 	info, allowed := h.registry.ProviderInfo(providerID)
 	if info == nil || !allowed || info.Inactive() {
 		return nil, nil
@@ -205,6 +265,27 @@ func (h *FinderHandler) GetProvider(providerID peer.ID) ([]byte, error) {
 		}
 	}
 	rsp := registry.RegToApiProviderInfo(info, indexCount)
+	rsp.AddrInfo.Addrs = h.registry.FilterAddrs(rsp.AddrInfo.Addrs)
+	if rsp.Publisher != nil {
+		rsp.Publisher.Addrs = h.registry.FilterAddrs(rsp.Publisher.Addrs)
+	}
+	rsp.Paused = h.registry.PublisherPaused(info.Publisher)
+
+	if withContexts && h.indexCounts != nil {
+		ctxCounts, err := h.indexCounts.ProviderContexts(providerID)
+		if err != nil {
+			log.Errorw("Could not get provider context index counts", "err", err)
+		}
+		if len(ctxCounts) != 0 {
+			rsp.ContextCounts = make([]model.ContextCount, len(ctxCounts))
+			for i, cc := range ctxCounts {
+				rsp.ContextCounts[i] = model.ContextCount{
+					ContextID: cc.ContextID,
+					Count:     cc.Count,
+				}
+			}
+		}
+	}
 	return json.Marshal(rsp)
 }
 
@@ -212,11 +293,16 @@ func (h *FinderHandler) RefreshStats() {
 	h.stats.refresh()
 }
 
-func (h *FinderHandler) GetStats() ([]byte, error) {
+// GetStats returns the indexer stats. If withDetails is true, then detailed
+// stats about providers and ingestion are included.
+func (h *FinderHandler) GetStats(withDetails bool) ([]byte, error) {
 	stats, err := h.stats.get()
 	if err != nil {
 		return nil, err
 	}
+	if withDetails {
+		stats.Details = h.details.get()
+	}
 	return model.MarshalStats(&stats)
 }
 
@@ -235,12 +321,12 @@ func providerResultFromValue(provID peer.ID, contextID []byte, metadata []byte,
 	}
 }
 
-func createExtendedProviderResult(epInfo registry.ExtendedProviderInfo, iVal indexer.Value) *model.ProviderResult {
+func (h *FinderHandler) createExtendedProviderResult(epInfo registry.ExtendedProviderInfo, iVal indexer.Value) *model.ProviderResult {
 	metadata := epInfo.Metadata
 	if metadata == nil {
 		metadata = iVal.MetadataBytes
 	}
 
-	provResult := providerResultFromValue(epInfo.PeerID, iVal.ContextID, metadata, epInfo.Addrs)
+	provResult := providerResultFromValue(epInfo.PeerID, iVal.ContextID, metadata, h.registry.FilterAddrs(epInfo.Addrs))
 	return &provResult
 }
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// defaultProvidersLimit is the page size used when a cursor is given
+	// without a limit.
+	defaultProvidersLimit = 100
+	// maxProvidersLimit is the largest page of providers returned.
+	maxProvidersLimit = 1000
+)
+
+// FindProviders returns information about the providers selected by the
+// query, ordered by provider ID. If the query is paged and there are more
+// selected providers after the returned ones, then the cursor to get the next
+// page is also returned.
+func (h *FinderHandler) FindProviders(query model.ProviderQuery) ([]*model.ProviderInfo, string, error) {
+	if err := query.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	var protoCode int
+	if query.Protocol != "" {
+		protoCode = multiaddr.ProtocolWithName(query.Protocol).Code
+	}
+
+	type selected struct {
+		id    string
+		info  *registry.ProviderInfo
+		addrs []multiaddr.Multiaddr
+	}
+	infos := h.registry.AllProviderInfo()
+	sel := make([]selected, 0, len(infos))
+	for _, info := range infos {
+		// Match against the addresses that are returned, so that a query
+		// cannot select a provider by an address the policy blocks.
+		addrs := h.registry.FilterAddrs(info.AddrInfo.Addrs)
+		if !matchProvider(info, addrs, &query, protoCode) {
+			continue
+		}
+		id := info.AddrInfo.ID.String()
+		if query.Cursor != "" && id <= query.Cursor {
+			continue
+		}
+		sel = append(sel, selected{id, info, addrs})
+	}
+	sort.Slice(sel, func(i, j int) bool { return sel[i].id < sel[j].id })
+
+	var nextCursor string
+	if query.Paged() {
+		limit := query.Limit
+		if limit == 0 {
+			limit = defaultProvidersLimit
+		} else if limit > maxProvidersLimit {
+			limit = maxProvidersLimit
+		}
+		if len(sel) > limit {
+			sel = sel[:limit]
+			nextCursor = sel[limit-1].id
+		}
+	}
+
+	provs := make([]*model.ProviderInfo, len(sel))
+	for i := range sel {
+		pInfo := sel[i].info
+		var indexCount uint64
+		if h.indexCounts != nil {
+			var err error
+			indexCount, err = h.indexCounts.Provider(pInfo.AddrInfo.ID)
+			if err != nil {
+				log.Errorw("Could not get provider index count", "err", err)
+			}
+		}
+		provs[i] = registry.RegToApiProviderInfo(pInfo, indexCount)
+		provs[i].AddrInfo.Addrs = sel[i].addrs
+		if provs[i].Publisher != nil {
+			provs[i].Publisher.Addrs = h.registry.FilterAddrs(provs[i].Publisher.Addrs)
+		}
+		provs[i].Paused = h.registry.PublisherPaused(pInfo.Publisher)
+	}
+	return provs, nextCursor, nil
+}
+
+// matchProvider returns true if the provider is selected by the query filters.
+// The address filters are matched against addrs, which are the provider
+// addresses allowed by policy.
+func matchProvider(info *registry.ProviderInfo, addrs []multiaddr.Multiaddr, query *model.ProviderQuery, protoCode int) bool {
+	if query.Publisher != "" && info.Publisher != query.Publisher {
+		return false
+	}
+	if !query.LastAdvertisementSince.IsZero() && info.LastAdvertisementTime.Before(query.LastAdvertisementSince) {
+		return false
+	}
+	if !query.LastAdvertisementUntil.IsZero() && !info.LastAdvertisementTime.Before(query.LastAdvertisementUntil) {
+		return false
+	}
+	if query.ExtendedProviders != nil {
+		ep := info.ExtendedProviders
+		hasEP := ep != nil && (len(ep.Providers) != 0 || len(ep.ContextualProviders) != 0)
+		if hasEP != *query.ExtendedProviders {
+			return false
+		}
+	}
+	if query.Inactive != nil && info.Inactive() != *query.Inactive {
+		return false
+	}
+	if query.Frozen != nil && (info.FrozenAt != cid.Undef) != *query.Frozen {
+		return false
+	}
+	if query.Address == "" && protoCode == 0 {
+		return true
+	}
+	for _, addr := range addrs {
+		if query.Address != "" && !strings.Contains(addr.String(), query.Address) {
+			continue
+		}
+		if protoCode != 0 {
+			if _, err := addr.ValueForProtocol(protoCode); err != nil {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/ingest"
+	"github.com/ipni/storetheindex/internal/registry"
+)
+
+const (
+	// defaultDetailsRefresh is how long detailed stats are cached before they
+	// are collected again.
+	defaultDetailsRefresh = time.Minute
+	// topProvidersCount is the number of providers reported in TopProviders.
+	topProvidersCount = 10
+)
+
+// statsDetails collects detailed stats when they are requested, and caches
+// them until they are older than the refresh interval.
+type statsDetails struct {
+	registry    *registry.Registry
+	indexCounts *counter.IndexCounts
+	ingester    *ingest.Ingester
+	refresh     time.Duration
+
+	mutex  sync.Mutex
+	latest *model.StatsDetails
+}
+
+func (d *statsDetails) get() *model.StatsDetails {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.latest == nil || time.Since(d.latest.UpdatedAt) >= d.refresh {
+		d.latest = d.collect()
+	}
+	return d.latest
+}
+
+func (d *statsDetails) collect() *model.StatsDetails {
+	details := &model.StatsDetails{
+		UpdatedAt: time.Now(),
+	}
+
+	pinfos := d.registry.AllProviderInfo()
+	for _, pinfo := range pinfos {
+		switch {
+		case pinfo.FrozenAt != cid.Undef:
+			details.Providers.Frozen++
+		case pinfo.Inactive():
+			details.Providers.Inactive++
+		default:
+			details.Providers.Active++
+		}
+	}
+
+	if d.ingester != nil {
+		ingStats := d.ingester.Stats()
+		details.Ingest = &model.IngestStats{
+			QueueDepth:           ingStats.QueueDepth,
+			Workers:              ingStats.Workers,
+			ActiveWorkers:        ingStats.ActiveWorkers,
+			AdsProcessedLastHour: ingStats.AdsProcessedLastHour,
+			AdsProcessedLastDay:  ingStats.AdsProcessedLastDay,
+		}
+		if ingStats.Workers != 0 {
+			details.Ingest.WorkerUtilization = float64(ingStats.ActiveWorkers) / float64(ingStats.Workers)
+		}
+	}
+
+	if d.indexCounts != nil {
+		details.TopProviders = d.topProviders(pinfos)
+	}
+
+	du, err := d.registry.ValueStoreUsage()
+	if err != nil {
+		details.ValueStoreUsage = -1.0
+	} else {
+		details.ValueStoreUsage = du.Percent
+	}
+
+	return details
+}
+
+// topProviders returns the providers with the highest index counts.
+func (d *statsDetails) topProviders(pinfos []*registry.ProviderInfo) []model.ProviderIndexCount {
+	counts := make([]model.ProviderIndexCount, 0, len(pinfos))
+	for _, pinfo := range pinfos {
+		count, err := d.indexCounts.Provider(pinfo.AddrInfo.ID)
+		if err != nil {
+			log.Errorw("Cannot get provider index count", "err", err, "provider", pinfo.AddrInfo.ID)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+		counts = append(counts, model.ProviderIndexCount{
+			ProviderID: pinfo.AddrInfo.ID,
+			IndexCount: count,
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].IndexCount > counts[j].IndexCount
+	})
+	if len(counts) > topProvidersCount {
+		counts = counts[:topProvidersCount]
+	}
+	return counts
+}
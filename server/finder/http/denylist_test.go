@@ -0,0 +1,79 @@
+package httpfinderserver_test
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	indexer "github.com/ipni/go-indexer-core"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/denylist"
+	httpserver "github.com/ipni/storetheindex/server/finder/http"
+	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rng := rand.New(rand.NewSource(1413))
+	mhs := util.RandomMultihashes(3, rng)
+	providerID, err := peer.Decode("12D3KooWKRyzVWW6ChFjQjK4miCty85Niy48tpPV95XdKu1BcvMA")
+	require.NoError(t, err)
+	value := indexer.Value{
+		ProviderID:    providerID,
+		ContextID:     []byte("ctx-a"),
+		MetadataBytes: []byte("metadata-a"),
+	}
+
+	ind := test.InitIndex(t, true)
+	defer ind.Close()
+	reg := test.InitRegistry(t)
+	defer reg.Close()
+	registerProvider(ctx, t, reg, providerID)
+	require.NoError(t, ind.Put(value, mhs...))
+
+	listFile := filepath.Join(t.TempDir(), "test.deny")
+	require.NoError(t, os.WriteFile(listFile, []byte(mhs[0].B58String()+"\n"), 0o644))
+	dl, err := denylist.New(ctx, config.Denylist{
+		Sources: []string{listFile},
+	})
+	require.NoError(t, err)
+
+	s, err := httpserver.New("127.0.0.1:0", ind, reg, httpserver.WithDenylist(dl))
+	require.NoError(t, err)
+	go s.Start()
+	defer s.Close()
+	c := setupClient(s.URL(), t)
+
+	// Denied multihash is not found.
+	rsp, err := http.Get(s.URL() + "/multihash/" + mhs[0].B58String())
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+
+	findRsp, err := c.Find(ctx, mhs[1])
+	require.NoError(t, err)
+	require.Len(t, findRsp.MultihashResults, 1)
+
+	// Batch find does not return the denied multihash.
+	findRsp, err = c.FindBatch(ctx, mhs)
+	require.NoError(t, err)
+	require.Len(t, findRsp.MultihashResults, 2)
+	for _, mhr := range findRsp.MultihashResults {
+		require.NotEqual(t, mhs[0], mhr.Multihash)
+	}
+
+	// Removing the denylist source allows the multihash to be found.
+	require.NoError(t, dl.Reload(ctx, config.Denylist{}))
+	findRsp, err = c.Find(ctx, mhs[0])
+	require.NoError(t, err)
+	require.Len(t, findRsp.MultihashResults, 1)
+}
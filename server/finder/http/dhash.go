@@ -0,0 +1,114 @@
+package httpfinderserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/ipni/dhstore"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/internal/httpserver"
+	b58 "github.com/mr-tron/base58/base58"
+	"github.com/multiformats/go-multihash"
+)
+
+// findEncryptedMultihash looks up the encrypted value keys for a double-hashed
+// multihash. The response is a FindResponse that only has
+// EncryptedMultihashResults.
+func (s *Server) findEncryptedMultihash(w http.ResponseWriter, r *http.Request) {
+	enableCors(w)
+
+	if !httpserver.MethodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := acceptsAnyOf(w, r, false, mediaTypeJson, mediaTypeAny); !ok {
+		return
+	}
+
+	if !s.limiter.allow(w, r, findBucket, 1) {
+		return
+	}
+
+	mhVar := path.Base(r.URL.Path)
+	mh, err := multihash.FromB58String(mhVar)
+	if err != nil {
+		http.Error(w, "invalid multihash", http.StatusBadRequest)
+		return
+	}
+
+	evks, err := s.dhStore.Lookup(mh)
+	if err != nil {
+		log.Errorw("Cannot look up encrypted multihash", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	if len(evks) == 0 {
+		http.Error(w, "no results for query", http.StatusNotFound)
+		return
+	}
+
+	encValueKeys := make([][]byte, len(evks))
+	for i, evk := range evks {
+		encValueKeys[i] = evk
+	}
+	data, err := json.Marshal(model.FindResponse{
+		EncryptedMultihashResults: []model.EncryptedMultihashResult{
+			{
+				Multihash:          mh,
+				EncryptedValueKeys: encValueKeys,
+			},
+		},
+	})
+	if err != nil {
+		log.Errorw("Failed marshalling encrypted find response", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	httpserver.WriteJsonResponse(w, http.StatusOK, data)
+}
+
+// getEncryptedMetadata gets the encrypted metadata for a hashed value key.
+func (s *Server) getEncryptedMetadata(w http.ResponseWriter, r *http.Request) {
+	enableCors(w)
+
+	if !httpserver.MethodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := acceptsAnyOf(w, r, false, mediaTypeJson, mediaTypeAny); !ok {
+		return
+	}
+
+	if !s.limiter.allow(w, r, findBucket, 1) {
+		return
+	}
+
+	hvk, err := b58.Decode(path.Base(r.URL.Path))
+	if err != nil {
+		http.Error(w, "invalid value key", http.StatusBadRequest)
+		return
+	}
+
+	encMetadata, err := s.dhStore.GetMetadata(hvk)
+	if err != nil {
+		log.Errorw("Cannot get encrypted metadata", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	// Empty metadata is a tombstone written when the value is removed.
+	if len(encMetadata) == 0 {
+		http.Error(w, "metadata not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(dhstore.GetMetadataResponse{
+		EncryptedMetadata: encMetadata,
+	})
+	if err != nil {
+		log.Errorw("Failed marshalling encrypted metadata response", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+	httpserver.WriteJsonResponse(w, http.StatusOK, data)
+}
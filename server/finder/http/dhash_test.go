@@ -0,0 +1,95 @@
+package httpfinderserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/ipni/dhstore"
+	"github.com/ipni/go-indexer-core/store/dhash"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	httpserver "github.com/ipni/storetheindex/server/finder/http"
+	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	b58 "github.com/mr-tron/base58/base58"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedLookup(t *testing.T) {
+	rng := rand.New(rand.NewSource(1413))
+	mhs := util.RandomMultihashes(2, rng)
+	providerID, err := peer.Decode("12D3KooWKRyzVWW6ChFjQjK4miCty85Niy48tpPV95XdKu1BcvMA")
+	require.NoError(t, err)
+	contextID := []byte("ctx-a")
+	metadata := []byte("metadata-a")
+
+	dhs, err := dhstore.NewPebbleDHStore(t.TempDir(), nil)
+	require.NoError(t, err)
+	defer dhs.Close()
+
+	valueKey := dhash.CreateValueKey(providerID, contextID)
+	encMetadata, err := dhash.EncryptMetadata(metadata, valueKey)
+	require.NoError(t, err)
+	require.NoError(t, dhs.PutMetadata(dhash.SHA256(valueKey, nil), encMetadata))
+	mh2, err := dhash.SecondMultihash(mhs[0])
+	require.NoError(t, err)
+	encValueKey, err := dhash.EncryptValueKey(valueKey, mhs[0])
+	require.NoError(t, err)
+	require.NoError(t, dhs.MergeIndex(mh2, encValueKey))
+
+	ind := test.InitIndex(t, true)
+	defer ind.Close()
+	reg := test.InitRegistry(t)
+	defer reg.Close()
+
+	s, err := httpserver.New("127.0.0.1:0", ind, reg, httpserver.WithDHStore(dhs))
+	require.NoError(t, err)
+	go s.Start()
+	defer s.Close()
+
+	// Look up encrypted value keys by the second multihash.
+	rsp, err := http.Get(s.URL() + "/encrypted/multihash/" + mh2.B58String())
+	require.NoError(t, err)
+	body, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	var findRsp model.FindResponse
+	require.NoError(t, json.Unmarshal(body, &findRsp))
+	require.Len(t, findRsp.EncryptedMultihashResults, 1)
+	require.Equal(t, mh2, findRsp.EncryptedMultihashResults[0].Multihash)
+	require.Len(t, findRsp.EncryptedMultihashResults[0].EncryptedValueKeys, 1)
+	vk, err := dhash.DecryptValueKey(findRsp.EncryptedMultihashResults[0].EncryptedValueKeys[0], mhs[0])
+	require.NoError(t, err)
+	require.Equal(t, valueKey, vk)
+
+	// Look up encrypted metadata by the hashed value key.
+	rsp, err = http.Get(s.URL() + "/metadata/" + b58.Encode(dhash.SHA256(vk, nil)))
+	require.NoError(t, err)
+	body, err = io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	var mdRsp dhstore.GetMetadataResponse
+	require.NoError(t, json.Unmarshal(body, &mdRsp))
+	md, err := dhash.DecryptMetadata(mdRsp.EncryptedMetadata, vk)
+	require.NoError(t, err)
+	require.Equal(t, metadata, md)
+
+	// Check that unknown multihash is not found.
+	mh2, err = dhash.SecondMultihash(mhs[1])
+	require.NoError(t, err)
+	rsp, err = http.Get(s.URL() + "/encrypted/multihash/" + mh2.B58String())
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+
+	// Check that bad value key is rejected.
+	rsp, err = http.Get(s.URL() + "/metadata/not-base58-0OIl")
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+}
@@ -0,0 +1,138 @@
+package httpfinderserver_test
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	indexer "github.com/ipni/go-indexer-core"
+	finderhttpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/api/v0/httpclient"
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/registry"
+	httpserver "github.com/ipni/storetheindex/server/finder/http"
+	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/ipni/storetheindex/test/util"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rng := rand.New(rand.NewSource(1413))
+	mhs := util.RandomMultihashes(12, rng)
+	providerID, err := peer.Decode("12D3KooWKRyzVWW6ChFjQjK4miCty85Niy48tpPV95XdKu1BcvMA")
+	require.NoError(t, err)
+	valueA := indexer.Value{
+		ProviderID:    providerID,
+		ContextID:     []byte("ctx-a"),
+		MetadataBytes: []byte("metadata-a"),
+	}
+	valueB := indexer.Value{
+		ProviderID:    providerID,
+		ContextID:     []byte("ctx-b"),
+		MetadataBytes: []byte("metadata-b"),
+	}
+
+	// Remote indexer has the first 10 multihashes with context A.
+	remoteInd := test.InitIndex(t, true)
+	defer remoteInd.Close()
+	remoteReg := test.InitRegistry(t)
+	defer remoteReg.Close()
+	registerProvider(ctx, t, remoteReg, providerID)
+	require.NoError(t, remoteInd.Put(valueA, mhs[:10]...))
+	remote := setupServer(remoteInd, remoteReg, nil, t)
+	go remote.Start()
+	defer remote.Close()
+
+	// Local indexer has multihashes 5 through 9 with both context A and B.
+	localInd := test.InitIndex(t, true)
+	defer localInd.Close()
+	localReg := test.InitRegistry(t)
+	defer localReg.Close()
+	registerProvider(ctx, t, localReg, providerID)
+	require.NoError(t, localInd.Put(valueA, mhs[5:10]...))
+	require.NoError(t, localInd.Put(valueB, mhs[5:10]...))
+
+	s, err := httpserver.New("127.0.0.1:0", localInd, localReg,
+		httpserver.WithFederation(config.FinderFederation{
+			Backends: []config.FederationBackend{
+				{URL: remote.URL()},
+				// Nothing is listening on this address.
+				{URL: "http://127.0.0.1:1", Timeout: config.Duration(time.Second)},
+			},
+			Timeout: config.Duration(5 * time.Second),
+		}))
+	require.NoError(t, err)
+	go s.Start()
+	defer s.Close()
+	c := setupClient(s.URL(), t)
+
+	// Multihash only in remote indexer.
+	rsp, err := c.Find(ctx, mhs[0])
+	require.NoError(t, err)
+	require.Len(t, rsp.MultihashResults, 1)
+	require.Len(t, rsp.MultihashResults[0].ProviderResults, 1)
+	require.Equal(t, valueA.ContextID, rsp.MultihashResults[0].ProviderResults[0].ContextID)
+
+	// Multihash in both indexers is not duplicated.
+	rsp, err = c.Find(ctx, mhs[5])
+	require.NoError(t, err)
+	require.Len(t, rsp.MultihashResults, 1)
+	require.Len(t, rsp.MultihashResults[0].ProviderResults, 2)
+
+	// Batch find merges results in request order.
+	rsp, err = c.FindBatch(ctx, mhs)
+	require.NoError(t, err)
+	require.Len(t, rsp.MultihashResults, 10)
+	for i, mhr := range rsp.MultihashResults {
+		require.Equal(t, mhs[i], mhr.Multihash)
+		if i < 5 {
+			require.Len(t, mhr.ProviderResults, 1)
+		} else {
+			require.Len(t, mhr.ProviderResults, 2)
+		}
+	}
+
+	// Check that the failed backend is reported in a header.
+	httpRsp, err := http.Get(s.URL() + "/multihash/" + mhs[0].B58String())
+	require.NoError(t, err)
+	httpRsp.Body.Close()
+	require.Equal(t, http.StatusOK, httpRsp.StatusCode)
+	require.Equal(t, "http://127.0.0.1:1", httpRsp.Header.Get("X-Federation-Failed"))
+
+	// Check that a request from a federated indexer is answered only from the
+	// local value store, so that requests do not loop between indexers.
+	req, err := http.NewRequest(http.MethodGet, s.URL()+"/multihash/"+mhs[0].B58String(), nil)
+	require.NoError(t, err)
+	req.Header.Set(model.FederatedHeader, "1")
+	httpRsp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	httpRsp.Body.Close()
+	require.Equal(t, http.StatusNotFound, httpRsp.StatusCode)
+	require.Empty(t, httpRsp.Header.Get("X-Federation-Failed"))
+
+	fedClient, err := finderhttpclient.New(s.URL(), httpclient.WithHeader(model.FederatedHeader, "1"))
+	require.NoError(t, err)
+	rsp, err = fedClient.Find(ctx, mhs[5])
+	require.NoError(t, err)
+	require.Len(t, rsp.MultihashResults, 1)
+	require.Len(t, rsp.MultihashResults[0].ProviderResults, 2)
+}
+
+func registerProvider(ctx context.Context, t *testing.T, reg *registry.Registry, providerID peer.ID) {
+	maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/9999")
+	require.NoError(t, err)
+	provider := peer.AddrInfo{
+		ID:    providerID,
+		Addrs: []multiaddr.Multiaddr{maddr},
+	}
+	require.NoError(t, reg.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0))
+}
@@ -11,6 +11,13 @@ const (
 	mediaTypeNDJson = "application/x-ndjson"
 	mediaTypeJson   = "application/json"
 	mediaTypeAny    = "*/*"
+
+	// nextCursorHeader holds the cursor for the next page of a streamed
+	// providers response.
+	nextCursorHeader = "X-Next-Cursor"
+	// federationFailedHeader lists the federated indexers that failed to
+	// respond to a find request, when the response has partial results.
+	federationFailedHeader = "X-Federation-Failed"
 )
 
 func acceptsAnyOf(w http.ResponseWriter, r *http.Request, strict bool, mts ...string) (string, bool) {
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ipni/dhstore"
+	"github.com/ipni/storetheindex/config"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
+	"github.com/ipni/storetheindex/internal/ingest"
 )
 
 const (
@@ -14,21 +18,29 @@ const (
 	defaultWriteTimeout = 30 * time.Second
 )
 
-// config contains all options for the server.
-type config struct {
+// serverConfig contains all options for the server.
+type serverConfig struct {
+	denylist     *denylist.Denylist
+	dhStore      dhstore.DHStore
+	federation   config.FinderFederation
 	homepageURL  string
 	indexCounts  *counter.IndexCounts
+	ingester     *ingest.Ingester
 	maxConns     int
+	rateLimit    config.FinderRateLimit
 	readTimeout  time.Duration
 	writeTimeout time.Duration
+
+	statsRefresh        time.Duration
+	statsDetailsRefresh time.Duration
 }
 
-// Option is a function that sets a value in a config.
-type Option func(*config) error
+// Option is a function that sets a value in a serverConfig.
+type Option func(*serverConfig) error
 
-// getOpts creates a config and applies Options to it.
-func getOpts(opts []Option) (config, error) {
-	cfg := config{
+// getOpts creates a serverConfig and applies Options to it.
+func getOpts(opts []Option) (serverConfig, error) {
+	cfg := serverConfig{
 		homepageURL:  defaultHomepage,
 		maxConns:     defaultMaxConns,
 		readTimeout:  defaultReadTimeout,
@@ -37,7 +49,7 @@ func getOpts(opts []Option) (config, error) {
 
 	for i, opt := range opts {
 		if err := opt(&cfg); err != nil {
-			return config{}, fmt.Errorf("option %d error: %s", i, err)
+			return serverConfig{}, fmt.Errorf("option %d error: %s", i, err)
 		}
 	}
 	return cfg, nil
@@ -45,7 +57,7 @@ func getOpts(opts []Option) (config, error) {
 
 // WithHomepage config for API.
 func WithHomepage(URL string) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.homepageURL = URL
 		return nil
 	}
@@ -53,7 +65,7 @@ func WithHomepage(URL string) Option {
 
 // MaxConnections config allowed by server.
 func WithMaxConnections(maxConnections int) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.maxConns = maxConnections
 		return nil
 	}
@@ -61,7 +73,7 @@ func WithMaxConnections(maxConnections int) Option {
 
 // WithIndexCounts supplies a counter.IndexCounts for tracking index counts.
 func WithIndexCounts(indexCounts *counter.IndexCounts) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.indexCounts = indexCounts
 		return nil
 	}
@@ -69,7 +81,7 @@ func WithIndexCounts(indexCounts *counter.IndexCounts) Option {
 
 // WithReadTimeout configures server read timeout.
 func WithReadTimeout(t time.Duration) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.readTimeout = t
 		return nil
 	}
@@ -77,8 +89,75 @@ func WithReadTimeout(t time.Duration) Option {
 
 // WithWriteTimeout configures server write timeout.
 func WithWriteTimeout(t time.Duration) Option {
-	return func(c *config) error {
+	return func(c *serverConfig) error {
 		c.writeTimeout = t
 		return nil
 	}
 }
+
+// WithIngester supplies the ingester that detailed ingest stats are read from.
+func WithIngester(ingester *ingest.Ingester) Option {
+	return func(c *serverConfig) error {
+		c.ingester = ingester
+		return nil
+	}
+}
+
+// WithStatsRefresh configures how often the indexer entries stats are
+// refreshed.
+func WithStatsRefresh(interval time.Duration) Option {
+	return func(c *serverConfig) error {
+		if interval < 0 {
+			return fmt.Errorf("stats refresh interval cannot be negative")
+		}
+		c.statsRefresh = interval
+		return nil
+	}
+}
+
+// WithStatsDetailsRefresh configures how long detailed stats are cached before
+// they are recollected.
+func WithStatsDetailsRefresh(interval time.Duration) Option {
+	return func(c *serverConfig) error {
+		if interval < 0 {
+			return fmt.Errorf("stats details refresh interval cannot be negative")
+		}
+		c.statsDetailsRefresh = interval
+		return nil
+	}
+}
+
+// WithRateLimit configures rate limiting of find requests.
+func WithRateLimit(rateLimit config.FinderRateLimit) Option {
+	return func(c *serverConfig) error {
+		c.rateLimit = rateLimit
+		return nil
+	}
+}
+
+// WithFederation configures remote indexers that are queried, along with the
+// local value store, to answer find requests.
+func WithFederation(federation config.FinderFederation) Option {
+	return func(c *serverConfig) error {
+		c.federation = federation
+		return nil
+	}
+}
+
+// WithDHStore configures a local double-hashed index that is used to serve
+// reader-privacy lookups of encrypted multihashes and metadata.
+func WithDHStore(dhs dhstore.DHStore) Option {
+	return func(c *serverConfig) error {
+		c.dhStore = dhs
+		return nil
+	}
+}
+
+// WithDenylist configures the denylist that removes denied multihashes from
+// find requests, including reframe requests.
+func WithDenylist(dl *denylist.Denylist) Option {
+	return func(c *serverConfig) error {
+		c.denylist = dl
+		return nil
+	}
+}
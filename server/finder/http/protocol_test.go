@@ -6,15 +6,21 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-delegated-routing/client"
 	"github.com/ipfs/go-delegated-routing/gen/proto"
 	indexer "github.com/ipni/go-indexer-core"
 	httpclient "github.com/ipni/storetheindex/api/v0/finder/client/http"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
+	"github.com/ipni/storetheindex/config"
 	"github.com/ipni/storetheindex/internal/counter"
 	"github.com/ipni/storetheindex/internal/registry"
 	httpserver "github.com/ipni/storetheindex/server/finder/http"
 	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
 )
 
 func setupServer(ind indexer.Interface, reg *registry.Registry, idxCts *counter.IndexCounts, t *testing.T) *httpserver.Server {
@@ -192,9 +198,53 @@ func TestProviderInfo(t *testing.T) {
 
 	test.GetProviderTest(t, httpClient, peerID)
 
+	provInfo, err := httpClient.GetProviderWithContexts(ctx, peerID)
+	require.NoError(t, err)
+	require.Equal(t, []model.ContextCount{{ContextID: []byte("context-id"), Count: 939}}, provInfo.ContextCounts)
+
 	test.ListProvidersTest(t, httpClient, peerID)
 
-	err := s.Close()
+	page, err := httpClient.ListProvidersPage(ctx, model.ProviderQuery{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Providers, 1)
+	require.Equal(t, peerID, page.Providers[0].AddrInfo.ID)
+	require.Empty(t, page.NextCursor)
+
+	page, err = httpClient.ListProvidersPage(ctx, model.ProviderQuery{Cursor: peerID.String()})
+	require.NoError(t, err)
+	require.Empty(t, page.Providers)
+
+	yes, no := true, false
+	var count int
+	err = httpClient.EachProvider(ctx, model.ProviderQuery{ExtendedProviders: &yes, Inactive: &no},
+		func(prov *model.ProviderInfo) error {
+			require.Equal(t, peerID, prov.AddrInfo.ID)
+			count++
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count = 0
+	cursor, err := httpClient.StreamProviders(ctx, model.ProviderQuery{Frozen: &yes},
+		func(prov *model.ProviderInfo) error {
+			count++
+			return nil
+		})
+	require.NoError(t, err)
+	require.Empty(t, cursor)
+	require.Zero(t, count)
+
+	_, err = httpClient.StreamProviders(ctx, model.ProviderQuery{Protocol: "tcp"},
+		func(prov *model.ProviderInfo) error {
+			require.Equal(t, peerID, prov.AddrInfo.ID)
+			count++
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	err = s.Close()
 	if err != nil {
 		t.Error("shutdown error:", err)
 	}
@@ -209,6 +259,46 @@ func TestProviderInfo(t *testing.T) {
 	}
 }
 
+func TestProviderQueryAddrPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ind := test.InitIndex(t, true)
+	defer ind.Close()
+	cfg := config.NewDiscovery()
+	cfg.Policy.Addrs.BlockDNSSuffixes = []string{"blocked.example.com"}
+	reg, err := registry.New(ctx, cfg, nil)
+	require.NoError(t, err)
+	defer reg.Close()
+
+	providerID, err := peer.Decode("12D3KooWKRyzVWW6ChFjQjK4miCty85Niy48tpPV95XdKu1BcvMA")
+	require.NoError(t, err)
+	blockedAddr, err := multiaddr.NewMultiaddr("/dns4/blocked.example.com/tcp/9999")
+	require.NoError(t, err)
+	allowedAddr, err := multiaddr.NewMultiaddr("/dns4/allowed.example.com/tcp/9999")
+	require.NoError(t, err)
+	provider := peer.AddrInfo{
+		ID:    providerID,
+		Addrs: []multiaddr.Multiaddr{blockedAddr, allowedAddr},
+	}
+	require.NoError(t, reg.Update(ctx, provider, peer.AddrInfo{}, cid.Undef, nil, 0))
+
+	s := setupServer(ind, reg, nil, t)
+	go s.Start()
+	defer s.Close()
+	httpClient := setupClient(s.URL(), t)
+
+	// Provider must not be selected by an address that the policy blocks.
+	page, err := httpClient.ListProvidersPage(ctx, model.ProviderQuery{Address: "blocked", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, page.Providers)
+
+	page, err = httpClient.ListProvidersPage(ctx, model.ProviderQuery{Address: "allowed", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Providers, 1)
+	require.Equal(t, []multiaddr.Multiaddr{allowedAddr}, page.Providers[0].AddrInfo.Addrs)
+}
+
 func TestGetStats(t *testing.T) {
 	ind := test.InitPebbleIndex(t, false)
 	defer ind.Close()
@@ -233,7 +323,17 @@ func TestGetStats(t *testing.T) {
 
 	test.GetStatsTest(ctx, t, ind, s.RefreshStats, httpClient)
 
-	err := s.Close()
+	stats, err := httpClient.GetStats(ctx)
+	require.NoError(t, err)
+	require.Nil(t, stats.Details)
+
+	stats, err = httpClient.GetStatsWithDetails(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, stats.Details)
+	require.Nil(t, stats.Details.Ingest)
+	require.Zero(t, stats.Details.Providers.Active)
+
+	err = s.Close()
 	if err != nil {
 		t.Error("shutdown error:", err)
 	}
@@ -0,0 +1,213 @@
+package httpfinderserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/ipni/storetheindex/internal/metrics"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// findBucket limits single multihash and CID find requests.
+	findBucket = "find"
+	// batchBucket limits the number of multihashes in batch find requests.
+	batchBucket = "batch"
+
+	// clientIdleTimeout is how long a client's limiters are kept after the
+	// client's last request.
+	clientIdleTimeout = 10 * time.Minute
+)
+
+// rateLimiter applies token-bucket rate limits to find requests from each
+// client.
+type rateLimiter struct {
+	cfg  config.FinderRateLimit
+	keys map[string]config.FinderAPIKey
+
+	mutex   sync.Mutex
+	clients map[string]*clientLimiters
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// clientLimiters holds the limiters for one client. A nil limiter means that
+// the client is not limited.
+type clientLimiters struct {
+	find     *rate.Limiter
+	batch    *rate.Limiter
+	lastUsed time.Time
+}
+
+// newRateLimiter creates a rateLimiter, or returns nil if no rate limits are
+// configured.
+func newRateLimiter(cfg config.FinderRateLimit) *rateLimiter {
+	if cfg.RequestsPerSecond == 0 && cfg.BatchMultihashesPerSecond == 0 && len(cfg.APIKeys) == 0 {
+		return nil
+	}
+	keys := make(map[string]config.FinderAPIKey, len(cfg.APIKeys))
+	for _, k := range cfg.APIKeys {
+		keys[k.Key] = k
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rl := &rateLimiter{
+		cfg:     cfg,
+		keys:    keys,
+		clients: make(map[string]*clientLimiters),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go rl.removeIdle(ctx)
+	return rl
+}
+
+// allow takes n tokens from the client's limiter for the bucket. If the limit
+// is exceeded, then a 429 response is written with a Retry-After header and
+// false is returned. If n is larger than the limiter's burst size, then the
+// request can never be allowed, so a 413 response is written and false is
+// returned.
+func (rl *rateLimiter) allow(w http.ResponseWriter, r *http.Request, bucket string, n int) bool {
+	if rl == nil {
+		return true
+	}
+	clientID, clientKind := rl.clientID(r)
+	now := time.Now()
+
+	rl.mutex.Lock()
+	cl, ok := rl.clients[clientID]
+	if !ok {
+		cl = rl.newClientLimiters(clientKind, clientID)
+		rl.clients[clientID] = cl
+	}
+	cl.lastUsed = now
+	rl.mutex.Unlock()
+
+	lim := cl.find
+	if bucket == batchBucket {
+		lim = cl.batch
+	}
+	if lim == nil {
+		return true
+	}
+	if n > lim.Burst() {
+		recordRateLimited(bucket, clientKind)
+		msg := fmt.Sprintf("request of %d exceeds rate limit burst of %d", n, lim.Burst())
+		http.Error(w, msg, http.StatusRequestEntityTooLarge)
+		return false
+	}
+	res := lim.ReserveN(now, n)
+	delay := res.DelayFrom(now)
+	if delay == 0 {
+		return true
+	}
+	res.CancelAt(now)
+	recordRateLimited(bucket, clientKind)
+
+	retryAfter := int(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
+
+// clientID returns the ID that the client is limited by, and whether the
+// client is identified by API key or IP address.
+func (rl *rateLimiter) clientID(r *http.Request) (string, string) {
+	if key := r.Header.Get(rl.cfg.APIKeyHeader); key != "" {
+		if _, ok := rl.keys[key]; ok {
+			return key, "apikey"
+		}
+	}
+	if rl.cfg.TrustForwardedFor {
+		// Use the last address, which is the one added by the trusted proxy.
+		// Addresses before it are set by the client and cannot be trusted.
+		if fwds := r.Header.Values("X-Forwarded-For"); len(fwds) != 0 {
+			fwd := fwds[len(fwds)-1]
+			if i := strings.LastIndexByte(fwd, ','); i != -1 {
+				fwd = fwd[i+1:]
+			}
+			if ip := strings.TrimSpace(fwd); ip != "" {
+				return ip, "ip"
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr, "ip"
+	}
+	return host, "ip"
+}
+
+func (rl *rateLimiter) newClientLimiters(clientKind, clientID string) *clientLimiters {
+	if clientKind == "apikey" {
+		k := rl.keys[clientID]
+		return &clientLimiters{
+			find:  newLimiter(k.RequestsPerSecond, k.BurstSize),
+			batch: newLimiter(k.BatchMultihashesPerSecond, k.BatchBurstSize),
+		}
+	}
+	return &clientLimiters{
+		find:  newLimiter(rl.cfg.RequestsPerSecond, rl.cfg.BurstSize),
+		batch: newLimiter(rl.cfg.BatchMultihashesPerSecond, rl.cfg.BatchBurstSize),
+	}
+}
+
+func newLimiter(perSecond, burst int) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+// removeIdle periodically removes the limiters of clients that have not made
+// a request for clientIdleTimeout.
+func (rl *rateLimiter) removeIdle(ctx context.Context) {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(clientIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cutoff := time.Now().Add(-clientIdleTimeout)
+			rl.mutex.Lock()
+			for id, cl := range rl.clients {
+				if cl.lastUsed.Before(cutoff) {
+					delete(rl.clients, id)
+				}
+			}
+			rl.mutex.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func recordRateLimited(bucket, clientKind string) {
+	_ = stats.RecordWithOptions(context.Background(),
+		stats.WithTags(tag.Insert(metrics.LimitBucket, bucket), tag.Insert(metrics.ClientKind, clientKind)),
+		stats.WithMeasurements(metrics.FindRateLimited.M(1)))
+}
+
+func (rl *rateLimiter) close() {
+	if rl == nil {
+		return
+	}
+	rl.cancel()
+	<-rl.done
+}
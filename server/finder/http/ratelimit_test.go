@@ -0,0 +1,98 @@
+package httpfinderserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/storetheindex/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	require.Nil(t, newRateLimiter(config.NewFinderRateLimit()))
+
+	cfg := config.FinderRateLimit{
+		RequestsPerSecond:         1,
+		BurstSize:                 2,
+		BatchMultihashesPerSecond: 1,
+		BatchBurstSize:            10,
+		APIKeyHeader:              "X-Api-Key",
+		APIKeys: []config.FinderAPIKey{
+			{Key: "unlimited"},
+		},
+	}
+	rl := newRateLimiter(cfg)
+	require.NotNil(t, rl)
+	defer rl.close()
+
+	allow := func(remoteAddr, apiKey, bucket string, n int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/multihash", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-Api-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		if rl.allow(w, req, bucket, n) {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w
+	}
+
+	// Burst is allowed, then limited.
+	require.Equal(t, http.StatusOK, allow("10.0.0.1:1234", "", findBucket, 1).Code)
+	require.Equal(t, http.StatusOK, allow("10.0.0.1:1234", "", findBucket, 1).Code)
+	w := allow("10.0.0.1:1234", "", findBucket, 1)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Other clients have separate limits.
+	require.Equal(t, http.StatusOK, allow("10.0.0.2:1234", "", findBucket, 1).Code)
+
+	// Batch limits are separate and count each multihash.
+	require.Equal(t, http.StatusOK, allow("10.0.0.1:1234", "", batchBucket, 6).Code)
+	require.Equal(t, http.StatusTooManyRequests, allow("10.0.0.1:1234", "", batchBucket, 6).Code)
+
+	// A batch larger than the burst is never allowed, and does not use the
+	// client's tokens.
+	require.Equal(t, http.StatusOK, allow("10.0.0.3:1234", "", batchBucket, 1).Code)
+	require.Equal(t, http.StatusRequestEntityTooLarge, allow("10.0.0.3:1234", "", batchBucket, 11).Code)
+	require.Equal(t, http.StatusOK, allow("10.0.0.3:1234", "", batchBucket, 9).Code)
+	require.Equal(t, http.StatusTooManyRequests, allow("10.0.0.3:1234", "", batchBucket, 1).Code)
+
+	// A known API key uses its own limits, and an unknown key is limited by IP.
+	for i := 0; i < 10; i++ {
+		require.Equal(t, http.StatusOK, allow("10.0.0.1:1234", "unlimited", findBucket, 1).Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, allow("10.0.0.1:1234", "unknown", findBucket, 1).Code)
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	rl := newRateLimiter(config.FinderRateLimit{
+		RequestsPerSecond: 1,
+		BurstSize:         1,
+		TrustForwardedFor: true,
+	})
+	require.NotNil(t, rl)
+	defer rl.close()
+
+	allow := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/multihash", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		if rl.allow(w, req, findBucket, 1) {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w.Code
+	}
+
+	// The proxy appends the client address to any spoofed addresses, so
+	// changing the spoofed address does not get a new limiter.
+	require.Equal(t, http.StatusOK, allow("192.0.2.1, 198.51.100.7"))
+	require.Equal(t, http.StatusTooManyRequests, allow("192.0.2.2, 198.51.100.7"))
+	require.Equal(t, http.StatusTooManyRequests, allow("198.51.100.7"))
+
+	// A different client address, added by the proxy, has a separate limit.
+	require.Equal(t, http.StatusOK, allow("192.0.2.1, 198.51.100.8"))
+}
@@ -10,11 +10,14 @@ import (
 	"net"
 	"net/http"
 	"path"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/dhstore"
 	indexer "github.com/ipni/go-indexer-core"
 	coremetrics "github.com/ipni/go-indexer-core/metrics"
 	"github.com/ipni/storetheindex/api/v0/finder/model"
@@ -42,9 +45,11 @@ func init() {
 }
 
 type Server struct {
+	dhStore       dhstore.DHStore
 	server        *http.Server
 	listener      net.Listener
 	finderHandler *handler.FinderHandler
+	limiter       *rateLimiter
 }
 
 func (s *Server) URL() string {
@@ -87,14 +92,22 @@ func New(listen string, indexer indexer.Interface, registry *registry.Registry,
 
 	mux := http.NewServeMux()
 	server := &http.Server{
-		Handler:      mux,
+		Handler:      httpserver.TraceContext(mux),
 		WriteTimeout: opts.writeTimeout,
 		ReadTimeout:  opts.readTimeout,
 	}
 	s := &Server{
+		dhStore:       opts.dhStore,
 		server:        server,
 		listener:      l,
 		finderHandler: handler.NewFinderHandler(indexer, registry, opts.indexCounts),
+		limiter:       newRateLimiter(opts.rateLimit),
+	}
+	s.finderHandler.ConfigureStats(opts.statsRefresh, opts.statsDetailsRefresh, opts.ingester)
+	s.finderHandler.SetDenylist(opts.denylist)
+	if err = s.finderHandler.ConfigureFederation(opts.federation); err != nil {
+		l.Close()
+		return nil, err
 	}
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -119,9 +132,18 @@ func New(listen string, indexer indexer.Interface, registry *registry.Registry,
 	mux.HandleFunc("/providers", s.listProviders)
 	mux.HandleFunc("/providers/", s.getProvider)
 	mux.HandleFunc("/stats", s.getStats)
+	if s.dhStore != nil {
+		mux.HandleFunc("/encrypted/multihash/", s.findEncryptedMultihash)
+		mux.HandleFunc("/metadata/", s.getEncryptedMetadata)
+	}
 
-	reframeHandler := reframe.NewReframeHTTPHandler(indexer, registry)
-	mux.HandleFunc("/reframe", reframeHandler)
+	reframeHandler := reframe.NewReframeHTTPHandler(indexer, registry, opts.denylist)
+	mux.HandleFunc("/reframe", func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.allow(w, r, findBucket, 1) {
+			return
+		}
+		reframeHandler(w, r)
+	})
 
 	return s, nil
 }
@@ -138,6 +160,7 @@ func (s *Server) RefreshStats() {
 func (s *Server) Close() error {
 	log.Info("finder http server shutdown")
 	s.finderHandler.Close()
+	s.limiter.close()
 	return s.server.Shutdown(context.Background())
 }
 
@@ -159,6 +182,10 @@ func (s *Server) findCid(w http.ResponseWriter, r *http.Request) {
 	// Explicitly accepts NDJson.
 	stream := match == mediaTypeNDJson
 
+	if !s.limiter.allow(w, r, findBucket, 1) {
+		return
+	}
+
 	cidVar := path.Base(r.URL.Path)
 	c, err := cid.Decode(cidVar)
 	if err != nil {
@@ -166,7 +193,7 @@ func (s *Server) findCid(w http.ResponseWriter, r *http.Request) {
 		httpserver.HandleError(w, err, "find")
 		return
 	}
-	s.getIndexes(w, []multihash.Multihash{c.Hash()}, stream)
+	s.getIndexes(w, r, "http/cid", []multihash.Multihash{c.Hash()}, stream)
 }
 
 func (s *Server) findMultihash(w http.ResponseWriter, r *http.Request) {
@@ -183,6 +210,10 @@ func (s *Server) findMultihash(w http.ResponseWriter, r *http.Request) {
 	// Explicitly accepts NDJson.
 	stream := match == mediaTypeNDJson
 
+	if !s.limiter.allow(w, r, findBucket, 1) {
+		return
+	}
+
 	mhVar := path.Base(r.URL.Path)
 	m, err := multihash.FromB58String(mhVar)
 	if err != nil {
@@ -190,7 +221,7 @@ func (s *Server) findMultihash(w http.ResponseWriter, r *http.Request) {
 		httpserver.HandleError(w, err, "find")
 		return
 	}
-	s.getIndexes(w, []multihash.Multihash{m}, stream)
+	s.getIndexes(w, r, "http/multihash", []multihash.Multihash{m}, stream)
 }
 
 func (s *Server) findBatch(w http.ResponseWriter, r *http.Request) {
@@ -216,7 +247,10 @@ func (s *Server) findBatch(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	s.getIndexes(w, req.Multihashes, false)
+	if !s.limiter.allow(w, r, batchBucket, len(req.Multihashes)) {
+		return
+	}
+	s.getIndexes(w, r, "http/batch", req.Multihashes, false)
 }
 
 func (s *Server) listProviders(w http.ResponseWriter, r *http.Request) {
@@ -226,20 +260,69 @@ func (s *Server) listProviders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, ok := acceptsAnyOf(w, r, false, mediaTypeJson, mediaTypeAny); !ok {
+	match, ok := acceptsAnyOf(w, r, false, mediaTypeNDJson, mediaTypeJson, mediaTypeAny)
+	if !ok {
+		return
+	}
+
+	query, err := model.ParseProviderQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	data, err := s.finderHandler.ListProviders()
+	provs, nextCursor, err := s.finderHandler.FindProviders(query)
 	if err != nil {
 		log.Errorw("cannot list providers", "err", err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
+	// Explicitly accepts NDJson.
+	if match == mediaTypeNDJson {
+		s.streamProviders(w, provs, nextCursor)
+		return
+	}
+
+	var data []byte
+	if query.Paged() {
+		data, err = json.Marshal(&model.ProvidersPage{
+			Providers:  provs,
+			NextCursor: nextCursor,
+		})
+	} else {
+		data, err = json.Marshal(provs)
+	}
+	if err != nil {
+		log.Errorw("cannot encode providers", "err", err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
 	httpserver.WriteJsonResponse(w, http.StatusOK, data)
 }
 
+// streamProviders writes each provider as a separate line of JSON. The cursor
+// for the next page, if any, is returned in the X-Next-Cursor header.
+func (s *Server) streamProviders(w http.ResponseWriter, provs []*model.ProviderInfo, nextCursor string) {
+	w.Header().Set("Content-Type", mediaTypeNDJson)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if nextCursor != "" {
+		w.Header().Set(nextCursorHeader, nextCursor)
+	}
+	flusher, flushable := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for i, prov := range provs {
+		if err := encoder.Encode(prov); err != nil {
+			log.Errorw("Failed to encode streaming providers response", "err", err)
+			return
+		}
+		if flushable && (i+1)%100 == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
 func (s *Server) getProvider(w http.ResponseWriter, r *http.Request) {
 	enableCors(w)
 
@@ -257,7 +340,16 @@ func (s *Server) getProvider(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := s.finderHandler.GetProvider(providerID)
+	var withContexts bool
+	if ctxStr := r.URL.Query().Get("contexts"); ctxStr != "" {
+		withContexts, err = strconv.ParseBool(ctxStr)
+		if err != nil {
+			http.Error(w, "invalid contexts value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := s.finderHandler.GetProvider(providerID, withContexts)
 	if err != nil {
 		log.Error("cannot get provider", "err", err)
 		http.Error(w, "", http.StatusInternalServerError)
@@ -283,7 +375,17 @@ func (s *Server) getStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := s.finderHandler.GetStats()
+	var withDetails bool
+	if detStr := r.URL.Query().Get("details"); detStr != "" {
+		var err error
+		withDetails, err = strconv.ParseBool(detStr)
+		if err != nil {
+			http.Error(w, "invalid details value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := s.finderHandler.GetStats(withDetails)
 	switch {
 	case err != nil:
 		log.Errorw("cannot get stats", "err", err)
@@ -307,7 +409,7 @@ func (s *Server) health(w http.ResponseWriter, r *http.Request) {
 	httpserver.WriteJsonResponse(w, http.StatusOK, versionData)
 }
 
-func (s *Server) getIndexes(w http.ResponseWriter, mhs []multihash.Multihash, stream bool) {
+func (s *Server) getIndexes(w http.ResponseWriter, r *http.Request, endpoint string, mhs []multihash.Multihash, stream bool) {
 	if len(mhs) != 1 && stream {
 		log.Errorw("Streaming response is not supported for batch find")
 		http.Error(w, "", http.StatusInternalServerError)
@@ -315,18 +417,33 @@ func (s *Server) getIndexes(w http.ResponseWriter, mhs []multihash.Multihash, st
 	}
 	startTime := time.Now()
 	var found bool
+	var resultSize int
 	defer func() {
 		msecPerMh := coremetrics.MsecSince(startTime) / float64(len(mhs))
 		_ = stats.RecordWithOptions(context.Background(),
 			stats.WithTags(tag.Insert(metrics.Method, "http"), tag.Insert(metrics.Found, fmt.Sprintf("%v", found))),
 			stats.WithMeasurements(metrics.FindLatency.M(msecPerMh)))
+		metrics.RecordFind(endpoint, time.Since(startTime), resultSize)
 	}()
 
-	response, err := s.finderHandler.Find(mhs)
+	var response *model.FindResponse
+	var failed []string
+	var err error
+	if r.Header.Get(model.FederatedHeader) != "" {
+		// Do not send a request from another federated indexer to the
+		// federated indexers, since it may have come from one of them.
+		response, err = s.finderHandler.FindLocal(r.Context(), mhs)
+	} else {
+		response, failed, err = s.finderHandler.FindFederated(r.Context(), mhs)
+	}
 	if err != nil {
 		httpserver.HandleError(w, err, "get")
 		return
 	}
+	if len(failed) != 0 {
+		w.Header().Set(federationFailedHeader, strings.Join(failed, ", "))
+	}
+	resultSize = handler.ProviderResultCount(response)
 
 	// If no info for any multihashes, then 404
 	if len(response.MultihashResults) == 0 {
@@ -22,6 +22,7 @@ import (
 	"github.com/ipni/storetheindex/server/finder/handler"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multihash"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 )
@@ -33,6 +34,8 @@ type libp2pHandler struct {
 	finderHandler *handler.FinderHandler
 }
 
+var _ libp2pserver.StreamHandler = (*libp2pHandler)(nil)
+
 // handlerFunc is the function signature required by handlers in this package
 type handlerFunc func(context.Context, peer.ID, *pb.FinderMessage) ([]byte, error)
 
@@ -68,6 +71,10 @@ func (h *libp2pHandler) HandleMessage(ctx context.Context, msgPeer peer.ID, msgb
 	case pb.FinderMessage_GET_STATS:
 		handle = h.getStats
 		rspType = pb.FinderMessage_GET_STATS_RESPONSE
+	case pb.FinderMessage_CANCEL:
+		// A cancel that arrives after a streamed response has ended is
+		// ignored.
+		return nil, nil
 	default:
 		return nil, fmt.Errorf("unsupported message type %d", req.GetType())
 	}
@@ -85,6 +92,104 @@ func (h *libp2pHandler) HandleMessage(ctx context.Context, msgPeer peer.ID, msgb
 	}, nil
 }
 
+// StreamRequest returns a function that streams the response to a
+// FIND_STREAM request. Each multihash result is sent in a separate
+// FIND_STREAM_RESPONSE message, and the stream ends with a FIND_STREAM_END
+// message. If the request fails, is canceled, or exceeds its timeout, then the
+// stream ends with an ERROR_RESPONSE message.
+func (h *libp2pHandler) StreamRequest(msgPeer peer.ID, msgbytes []byte) libp2pserver.StreamFunc {
+	var req pb.FinderMessage
+	if err := req.Unmarshal(msgbytes); err != nil {
+		return nil
+	}
+	if req.GetType() != pb.FinderMessage_FIND_STREAM {
+		return nil
+	}
+	return func(ctx context.Context, send func(proto.Message) error) error {
+		err := h.findStream(ctx, &req, send)
+		if err != nil {
+			var sendErr *streamSendError
+			if errors.As(err, &sendErr) {
+				return sendErr.err
+			}
+			err = libp2pserver.HandleError(err, req.GetType().String())
+			return send(&pb.FinderMessage{
+				Type: pb.FinderMessage_ERROR_RESPONSE,
+				Data: v0.EncodeError(err),
+			})
+		}
+		return send(&pb.FinderMessage{
+			Type: pb.FinderMessage_FIND_STREAM_END,
+		})
+	}
+}
+
+// streamSendError is an error sending a message of a streamed response.
+type streamSendError struct {
+	err error
+}
+
+func (e *streamSendError) Error() string {
+	return e.err.Error()
+}
+
+func (h *libp2pHandler) findStream(ctx context.Context, msg *pb.FinderMessage, send func(proto.Message) error) error {
+	startTime := time.Now()
+
+	req, err := model.UnmarshalFindRequest(msg.GetData())
+	if err != nil {
+		return err
+	}
+	if len(req.Multihashes) == 0 {
+		return nil
+	}
+
+	if timeout := msg.GetTimeoutMsec(); timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	var found bool
+	var resultSize int
+	defer func() {
+		msecPerMh := coremetrics.MsecSince(startTime) / float64(len(req.Multihashes))
+		_ = stats.RecordWithOptions(context.Background(),
+			stats.WithTags(tag.Insert(metrics.Method, "libp2p"), tag.Insert(metrics.Found, fmt.Sprintf("%v", found))),
+			stats.WithMeasurements(metrics.FindLatency.M(msecPerMh)))
+		metrics.RecordFind("libp2p/findstream", time.Since(startTime), resultSize)
+	}()
+
+	for _, mh := range req.Multihashes {
+		if err = ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return v0.NewError(errors.New("request timeout exceeded"), http.StatusRequestTimeout)
+			}
+			return v0.NewError(errors.New("request canceled"), http.StatusRequestTimeout)
+		}
+		r, err := h.finderHandler.Find(ctx, []multihash.Multihash{mh})
+		if err != nil {
+			return err
+		}
+		resultSize += handler.ProviderResultCount(r)
+		for i := range r.MultihashResults {
+			data, err := json.Marshal(&r.MultihashResults[i])
+			if err != nil {
+				return err
+			}
+			err = send(&pb.FinderMessage{
+				Type: pb.FinderMessage_FIND_STREAM_RESPONSE,
+				Data: data,
+			})
+			if err != nil {
+				return &streamSendError{err}
+			}
+			found = true
+		}
+	}
+	return nil
+}
+
 func (h *libp2pHandler) RefreshStats() {
 	h.finderHandler.RefreshStats()
 }
@@ -98,17 +203,20 @@ func (h *libp2pHandler) find(ctx context.Context, p peer.ID, msg *pb.FinderMessa
 	}
 
 	var found bool
+	var resultSize int
 	defer func() {
 		msecPerMh := coremetrics.MsecSince(startTime) / float64(len(req.Multihashes))
 		_ = stats.RecordWithOptions(context.Background(),
 			stats.WithTags(tag.Insert(metrics.Method, "libp2p"), tag.Insert(metrics.Found, fmt.Sprintf("%v", found))),
 			stats.WithMeasurements(metrics.FindLatency.M(msecPerMh)))
+		metrics.RecordFind("libp2p/find", time.Since(startTime), resultSize)
 	}()
 
-	r, err := h.finderHandler.Find(req.Multihashes)
+	r, err := h.finderHandler.Find(ctx, req.Multihashes)
 	if err != nil {
 		return nil, err
 	}
+	resultSize = handler.ProviderResultCount(r)
 	data, err := model.MarshalFindResponse(r)
 	if err != nil {
 		return nil, err
@@ -122,6 +230,26 @@ func (h *libp2pHandler) find(ctx context.Context, p peer.ID, msg *pb.FinderMessa
 }
 
 func (h *libp2pHandler) listProviders(ctx context.Context, p peer.ID, msg *pb.FinderMessage) ([]byte, error) {
+	// A request with a query gets a page of the selected providers.
+	if len(msg.GetData()) != 0 {
+		var query model.ProviderQuery
+		if err := json.Unmarshal(msg.GetData(), &query); err != nil {
+			return nil, v0.NewError(err, http.StatusBadRequest)
+		}
+		if err := query.Validate(); err != nil {
+			return nil, v0.NewError(err, http.StatusBadRequest)
+		}
+		provs, nextCursor, err := h.finderHandler.FindProviders(query)
+		if err != nil {
+			log.Errorw("cannot list providers", "err", err)
+			return nil, v0.NewError(nil, http.StatusInternalServerError)
+		}
+		return json.Marshal(&model.ProvidersPage{
+			Providers:  provs,
+			NextCursor: nextCursor,
+		})
+	}
+
 	data, err := h.finderHandler.ListProviders()
 	if err != nil {
 		log.Errorw("cannot list providers", "err", err)
@@ -139,7 +267,7 @@ func (h *libp2pHandler) getProvider(ctx context.Context, p peer.ID, msg *pb.Find
 		return nil, v0.NewError(errors.New("cannot decode request"), http.StatusBadRequest)
 	}
 
-	data, err := h.finderHandler.GetProvider(providerID)
+	data, err := h.finderHandler.GetProvider(providerID, false)
 	if err != nil {
 		log.Errorw("cannot get provider", "err", err)
 		return nil, v0.NewError(nil, http.StatusInternalServerError)
@@ -153,7 +281,7 @@ func (h *libp2pHandler) getProvider(ctx context.Context, p peer.ID, msg *pb.Find
 }
 
 func (h *libp2pHandler) getStats(ctx context.Context, p peer.ID, msg *pb.FinderMessage) ([]byte, error) {
-	data, err := h.finderHandler.GetStats()
+	data, err := h.finderHandler.GetStats(false)
 	if err != nil {
 		log.Errorw("cannot get stats", "err", err)
 		return nil, v0.NewError(nil, http.StatusInternalServerError)
@@ -2,18 +2,24 @@ package p2pfinderserver_test
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-datastore"
 	indexer "github.com/ipni/go-indexer-core"
 	p2pclient "github.com/ipni/storetheindex/api/v0/finder/client/libp2p"
+	"github.com/ipni/storetheindex/api/v0/finder/model"
 	"github.com/ipni/storetheindex/internal/counter"
 	"github.com/ipni/storetheindex/internal/registry"
 	p2pserver "github.com/ipni/storetheindex/server/finder/libp2p"
 	"github.com/ipni/storetheindex/server/finder/test"
+	"github.com/ipni/storetheindex/test/util"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
 )
 
 func setupServer(ctx context.Context, ind indexer.Interface, reg *registry.Registry, idxCts *counter.IndexCounts, t *testing.T) (*p2pserver.FinderServer, host.Host) {
@@ -21,7 +27,7 @@ func setupServer(ctx context.Context, ind indexer.Interface, reg *registry.Regis
 	if err != nil {
 		t.Fatal(err)
 	}
-	s := p2pserver.New(ctx, h, ind, reg, idxCts)
+	s := p2pserver.New(ctx, h, ind, reg, idxCts, nil)
 	return s, h
 }
 
@@ -105,6 +111,16 @@ func TestProviderInfo(t *testing.T) {
 
 	test.ListProvidersTest(t, p2pClient, peerID)
 
+	var count int
+	err = p2pClient.EachProvider(ctx, model.ProviderQuery{Address: "/tcp/9999", Limit: 1},
+		func(prov *model.ProviderInfo) error {
+			require.Equal(t, peerID, prov.AddrInfo.ID)
+			count++
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
 	reg.Close()
 	if err = ind.Close(); err != nil {
 		t.Errorf("Error closing indexer core: %s", err)
@@ -150,3 +166,72 @@ func TestRemoveProvider(t *testing.T) {
 		t.Errorf("Error closing indexer core: %s", err)
 	}
 }
+
+func TestFindStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ind := test.InitIndex(t, true)
+	defer ind.Close()
+	reg := test.InitRegistry(t)
+	defer reg.Close()
+	s, sh := setupServer(ctx, ind, reg, nil, t)
+	c := setupClient(s.ID(), t)
+	defer c.Close()
+	err := c.ConnectAddrs(ctx, sh.Addrs()...)
+	require.NoError(t, err)
+
+	providerID := test.Register(ctx, t, reg)
+	mhs := util.RandomMultihashes(15, rand.New(rand.NewSource(1413)))
+	v := indexer.Value{
+		ProviderID:    providerID,
+		ContextID:     []byte("test-context-id"),
+		MetadataBytes: []byte("test-metadata"),
+	}
+	require.NoError(t, ind.Put(v, mhs[:10]...))
+
+	// Only the indexed multihashes have results.
+	var results []model.MultihashResult
+	err = c.FindStream(ctx, mhs, func(result model.MultihashResult) error {
+		results = append(results, result)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+	for i, result := range results {
+		require.Equal(t, mhs[i], result.Multihash)
+		require.NotEmpty(t, result.ProviderResults)
+		require.Equal(t, providerID, result.ProviderResults[0].Provider.ID)
+	}
+
+	// Check that returning an error from the result callback cancels the
+	// request, and that the client can still make requests afterwards.
+	errStop := errors.New("stop")
+	var count int
+	err = c.FindStream(ctx, mhs, func(result model.MultihashResult) error {
+		count++
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1, count)
+
+	resp, err := c.FindBatch(ctx, mhs[:2])
+	require.NoError(t, err)
+	require.Len(t, resp.MultihashResults, 2)
+
+	results = results[:0]
+	err = c.FindStream(ctx, mhs[9:], func(result model.MultihashResult) error {
+		results = append(results, result)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	// Check that a request with an expired deadline is not sent.
+	expCtx, expCancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer expCancel()
+	err = c.FindStream(expCtx, mhs, func(result model.MultihashResult) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -5,6 +5,7 @@ import (
 
 	indexer "github.com/ipni/go-indexer-core"
 	"github.com/ipni/storetheindex/internal/counter"
+	"github.com/ipni/storetheindex/internal/denylist"
 	"github.com/ipni/storetheindex/internal/libp2pserver"
 	"github.com/ipni/storetheindex/internal/registry"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -16,8 +17,9 @@ type FinderServer struct {
 }
 
 // New creates a new libp2p server
-func New(ctx context.Context, h host.Host, indexer indexer.Interface, registry *registry.Registry, indexCounts *counter.IndexCounts) *FinderServer {
+func New(ctx context.Context, h host.Host, indexer indexer.Interface, registry *registry.Registry, indexCounts *counter.IndexCounts, dl *denylist.Denylist) *FinderServer {
 	p2ph := newHandler(indexer, registry, indexCounts)
+	p2ph.finderHandler.SetDenylist(dl)
 	s := &FinderServer{
 		p2pHandler: p2ph,
 	}
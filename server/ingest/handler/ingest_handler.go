@@ -14,6 +14,7 @@ import (
 	"github.com/ipni/storetheindex/api/v0/ingest/schema"
 	"github.com/ipni/storetheindex/internal/ingest"
 	"github.com/ipni/storetheindex/internal/registry"
+	"github.com/ipni/storetheindex/internal/tracing"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 )
@@ -110,7 +111,7 @@ func (h *IngestHandler) IndexContent(ctx context.Context, data []byte) error {
 	return nil
 }
 
-func (h *IngestHandler) Announce(an message.Message) error {
+func (h *IngestHandler) Announce(ctx context.Context, an message.Message) error {
 	if len(an.Addrs) == 0 {
 		return fmt.Errorf("must specify location to fetch on direct announcments")
 	}
@@ -135,6 +136,9 @@ func (h *IngestHandler) Announce(an message.Message) error {
 		err = fmt.Errorf("announce requests not allowed from peer %s", addrInfo.ID)
 		return v0.NewError(err, http.StatusForbidden)
 	}
+	if h.ingester.Draining() {
+		return v0.NewError(ingest.ErrDraining, http.StatusServiceUnavailable)
+	}
 	cur, err := h.ingester.GetLatestSync(addrInfo.ID)
 	if err == nil {
 		if cur.Equals(an.Cid) {
@@ -143,8 +147,9 @@ func (h *IngestHandler) Announce(an message.Message) error {
 	}
 
 	// Use background context because this will be an async process. We don't
-	// want to attach the context to the request context that started this.
-	return h.ingester.Announce(context.Background(), an.Cid, addrInfo)
+	// want to attach the context to the request context that started this,
+	// but do keep the request's trace.
+	return h.ingester.Announce(tracing.Detach(ctx), an.Cid, addrInfo)
 }
 
 func stringsToMultiaddrs(addrs []string) ([]multiaddr.Multiaddr, error) {
@@ -23,6 +23,7 @@ var log = logging.Logger("indexer/ingest")
 type Server struct {
 	server        *http.Server
 	listener      net.Listener
+	ingester      *ingest.Ingester
 	ingestHandler *handler.IngestHandler
 }
 
@@ -43,13 +44,14 @@ func New(listen string, indexer indexer.Interface, ingester *ingest.Ingester, re
 
 	mux := http.NewServeMux()
 	server := &http.Server{
-		Handler:      mux,
+		Handler:      httpserver.TraceContext(mux),
 		WriteTimeout: opts.writeTimeout,
 		ReadTimeout:  opts.readTimeout,
 	}
 	s := &Server{
 		server:        server,
 		listener:      l,
+		ingester:      ingester,
 		ingestHandler: handler.NewIngestHandler(indexer, ingester, registry),
 	}
 
@@ -94,7 +96,7 @@ func (s *Server) putAnnounce(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.ingestHandler.Announce(an); err != nil {
+	if err = s.ingestHandler.Announce(r.Context(), an); err != nil {
 		httpserver.HandleError(w, err, "announce")
 		return
 	}
@@ -107,6 +109,12 @@ func (s *Server) getHealth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Cache-Control", "no-cache")
+	// Report unavailable while draining, so that announces are sent to other
+	// indexers.
+	if s.ingester != nil && s.ingester.Draining() {
+		http.Error(w, ingest.ErrDraining.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	v := version.String()
 	b, _ := json.Marshal(v)
 	httpserver.WriteJsonResponse(w, http.StatusOK, b)
@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-delegated-routing/server"
 	"github.com/ipni/go-indexer-core"
 	coremetrics "github.com/ipni/go-indexer-core/metrics"
+	"github.com/ipni/storetheindex/internal/denylist"
 	"github.com/ipni/storetheindex/internal/metrics"
 	"github.com/ipni/storetheindex/internal/registry"
 	"github.com/ipni/storetheindex/server/finder/handler"
@@ -24,8 +25,10 @@ import (
 	"go.opencensus.io/tag"
 )
 
-func NewReframeHTTPHandler(indexer indexer.Interface, registry *registry.Registry) http.HandlerFunc {
-	return server.DelegatedRoutingAsyncHandler(NewReframeService(handler.NewFinderHandler(indexer, registry, nil)))
+func NewReframeHTTPHandler(indexer indexer.Interface, registry *registry.Registry, dl *denylist.Denylist) http.HandlerFunc {
+	fh := handler.NewFinderHandler(indexer, registry, nil)
+	fh.SetDenylist(dl)
+	return server.DelegatedRoutingAsyncHandler(NewReframeService(fh))
 }
 
 func NewReframeService(fh *handler.FinderHandler) *ReframeService {
@@ -39,18 +42,21 @@ type ReframeService struct {
 func (x *ReframeService) FindProviders(ctx context.Context, key cid.Cid) (<-chan client.FindProvidersAsyncResult, error) {
 	startTime := time.Now()
 	var found bool
+	var resultSize int
 	defer func() {
 		msecPerMh := coremetrics.MsecSince(startTime)
 		_ = stats.RecordWithOptions(context.Background(),
 			stats.WithTags(tag.Insert(metrics.Method, "reframe"), tag.Insert(metrics.Found, fmt.Sprintf("%v", found))),
 			stats.WithMeasurements(metrics.FindLatency.M(msecPerMh)))
+		metrics.RecordFind("reframe", time.Since(startTime), resultSize)
 	}()
 
 	mh := key.Hash()
-	fr, err := x.finderHandler.Find([]multihash.Multihash{mh})
+	fr, err := x.finderHandler.Find(ctx, []multihash.Multihash{mh})
 	if err != nil {
 		return nil, err
 	}
+	resultSize = handler.ProviderResultCount(fr)
 	ch := make(chan client.FindProvidersAsyncResult, 1)
 	var peerAddrs []peer.AddrInfo
 	for _, mhr := range fr.MultihashResults {